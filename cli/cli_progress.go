@@ -67,6 +67,8 @@ type cliProgress struct {
 	lastLineLength int
 	// +checklocks:outputMutex
 	spinPhase int
+	// +checklocks:outputMutex
+	fetchedIndexBlobs int
 
 	uploadStartTime timetrack.Estimator // +checklocksignore
 
@@ -112,8 +114,12 @@ func (p *cliProgress) Error(path string, err error, isIgnored bool) {
 }
 
 func (p *cliProgress) CachedFile(fname string, numBytes int64) {
+	p.CachedFiles(1, numBytes)
+}
+
+func (p *cliProgress) CachedFiles(numFiles int32, numBytes int64) {
 	atomic.AddInt64(&p.cachedBytes, numBytes)
-	atomic.AddInt32(&p.cachedFiles, 1)
+	atomic.AddInt32(&p.cachedFiles, numFiles)
 	p.maybeOutput()
 }
 
@@ -195,6 +201,34 @@ func (p *cliProgress) output(col *color.Color, msg string) {
 	p.out.printStderr("\r%v%v", line, extraSpaces)
 }
 
+// FetchedIndexBlobs reports progress of downloading index blobs when opening the repository.
+func (p *cliProgress) FetchedIndexBlobs(downloaded, total int) {
+	if !p.enableProgress {
+		return
+	}
+
+	if downloaded < total && !p.outputThrottle.ShouldOutput(p.progressUpdateInterval) {
+		return
+	}
+
+	p.outputMutex.Lock()
+	defer p.outputMutex.Unlock()
+
+	// progress may be reported concurrently, don't go back.
+	if downloaded <= p.fetchedIndexBlobs {
+		return
+	}
+
+	p.fetchedIndexBlobs = downloaded
+
+	line := fmt.Sprintf(" Downloaded %v of %v index blobs", downloaded, total)
+	if downloaded == total {
+		line += "\n"
+	}
+
+	p.out.printStderr("\r%v", line)
+}
+
 // +checklocks:p.outputMutex
 func (p *cliProgress) spinnerCharacter() string {
 	if atomic.LoadInt32(&p.uploadFinished) == 1 {
@@ -1,17 +1,19 @@
 package cli
 
 type commandBlob struct {
-	delete commandBlobDelete
-	gc     commandBlobGC
-	list   commandBlobList
-	shards commandBlobShards
-	show   commandBlobShow
-	stats  commandBlobStats
+	checkPacks commandBlobCheckPacks
+	delete     commandBlobDelete
+	gc         commandBlobGC
+	list       commandBlobList
+	shards     commandBlobShards
+	show       commandBlobShow
+	stats      commandBlobStats
 }
 
 func (c *commandBlob) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("blob", "Commands to manipulate BLOBs.").Hidden()
 
+	c.checkPacks.setup(svc, cmd)
 	c.delete.setup(svc, cmd)
 	c.gc.setup(svc, cmd)
 	c.list.setup(svc, cmd)
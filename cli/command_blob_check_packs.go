@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+type commandBlobCheckPacks struct {
+	samplePercent float64
+	parallel      int
+
+	out textOutput
+}
+
+func (c *commandBlobCheckPacks) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("check-packs", "Quickly check that sampled pack blobs begin with a valid header and end with a valid trailer")
+	cmd.Flag("percent", "Percentage of pack blobs to check [0.0 .. 100.0]").Default("10").Float64Var(&c.samplePercent)
+	cmd.Flag("parallel", "Parallelism").Default("16").IntVar(&c.parallel)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandBlobCheckPacks) run(ctx context.Context, rep repo.DirectRepository) error {
+	var checkedCount, invalidCount int32
+
+	// find the first content of each pack, which together with the preamble makes up the pack header.
+	firstContents := map[blob.ID]content.Info{}
+
+	if err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{IncludeDeleted: true}, func(ci content.Info) error {
+		if prev, ok := firstContents[ci.GetPackBlobID()]; !ok || ci.GetPackOffset() < prev.GetPackOffset() {
+			firstContents[ci.GetPackBlobID()] = ci
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "error iterating contents")
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+
+	blobCh := make(chan blob.Metadata)
+
+	// goroutine to populate sampled pack blob metadata into a channel.
+	eg.Go(func() error {
+		defer close(blobCh)
+
+		for _, prefix := range content.PackBlobIDPrefixes {
+			if err := rep.BlobReader().ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+				// nolint:gosec
+				if 100*rand.Float64() >= c.samplePercent {
+					return nil
+				}
+
+				select {
+				case blobCh <- bm:
+					return nil
+				case <-ctx.Done():
+					return errors.Wrap(ctx.Err(), "context error")
+				}
+			}); err != nil {
+				return errors.Wrapf(err, "error listing blobs with prefix %q", prefix)
+			}
+		}
+
+		return nil
+	})
+
+	for i := 0; i < c.parallel; i++ {
+		eg.Go(func() error {
+			for bm := range blobCh {
+				atomic.AddInt32(&checkedCount, 1)
+
+				if err := c.checkPack(ctx, rep, bm, firstContents[bm.BlobID]); err != nil {
+					atomic.AddInt32(&invalidCount, 1)
+					c.out.printStdout("%v %v\n", bm.BlobID, err)
+				}
+			}
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return errors.Wrap(err, "error checking packs")
+	}
+
+	ic := atomic.LoadInt32(&invalidCount)
+
+	log(ctx).Infof("Checked %v pack blobs, found %v invalid.", atomic.LoadInt32(&checkedCount), ic)
+
+	if ic > 0 {
+		return errors.Errorf("found %v invalid pack blobs", ic)
+	}
+
+	return nil
+}
+
+func (c *commandBlobCheckPacks) checkPack(ctx context.Context, rep repo.DirectRepository, bm blob.Metadata, first content.Info) error {
+	if first != nil {
+		if err := rep.ContentReader().VerifyPackBlobHeader(ctx, first); err != nil {
+			return errors.Wrap(err, "invalid header")
+		}
+	}
+
+	return errors.Wrap(content.VerifyPackBlobTrailer(ctx, rep.BlobReader(), bm.BlobID, bm.Length), "invalid trailer")
+}
@@ -0,0 +1,56 @@
+package cli_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func (s *formatSpecificTestSuite) TestBlobCheckPacks(t *testing.T) {
+	env := testenv.NewCLITest(t, s.formatFlags, testenv.NewInProcRunner(t))
+
+	dir := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file1.txt"), bytes.Repeat([]byte{1, 2, 3, 4, 5}, 15000), 0o600))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+	env.RunAndExpectSuccess(t, "blob", "check-packs", "--percent=100")
+
+	// corrupt the first content of one of the pack blobs, which is a part of its header.
+	var infos []struct {
+		PackFile   string `json:"packFile"`
+		PackOffset int64  `json:"packOffset"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(strings.Join(env.RunAndExpectSuccess(t, "content", "list", "--non-prefixed", "--json"), "\n")), &infos))
+	require.NotEmpty(t, infos)
+
+	first := infos[0]
+
+	for _, ci := range infos {
+		if ci.PackFile == first.PackFile && ci.PackOffset < first.PackOffset {
+			first = ci
+		}
+	}
+
+	packFile := findBlobFile(t, env.RepoDir, first.PackFile)
+
+	packData, err := os.ReadFile(packFile)
+	require.NoError(t, err)
+
+	packData[first.PackOffset] ^= 0xff
+
+	require.NoError(t, os.WriteFile(packFile, packData, 0o600))
+
+	stdout, _, err := env.Run(t, true, "blob", "check-packs", "--percent=100")
+	require.Error(t, err)
+	require.Contains(t, mustGetLineContaining(t, stdout, first.PackFile), "invalid header")
+}
@@ -15,10 +15,13 @@ type commandCacheSetParams struct {
 	directory              string
 	contentCacheSizeMB     int64
 	maxMetadataCacheSizeMB int64
+	maxIndexCacheSizeMB    int64
 	maxListCacheDuration   time.Duration
 	contentMinSweepAge     time.Duration
 	metadataMinSweepAge    time.Duration
 	indexMinSweepAge       time.Duration
+	maxOpenIndexSegments   int
+	indexFetchParallelism  int
 
 	svc appServices
 }
@@ -35,7 +38,10 @@ func (c *commandCacheSetParams) setup(svc appServices, parent commandParent) {
 	cmd.Flag("content-min-sweep-age", "Minimal age of content cache item to be subject to sweeping").DurationVar(&c.contentMinSweepAge)
 	cmd.Flag("metadata-cache-size-mb", "Size of local metadata cache").PlaceHolder("MB").Default("-1").Int64Var(&c.maxMetadataCacheSizeMB)
 	cmd.Flag("metadata-min-sweep-age", "Minimal age of metadata cache item to be subject to sweeping").DurationVar(&c.metadataMinSweepAge)
+	cmd.Flag("index-cache-size-mb", "Size of local index cache (0 - unlimited)").PlaceHolder("MB").Default("-1").Int64Var(&c.maxIndexCacheSizeMB)
 	cmd.Flag("index-min-sweep-age", "Minimal age of index cache item to be subject to sweeping").DurationVar(&c.indexMinSweepAge)
+	cmd.Flag("max-open-index-segments", "Maximum number of index segments kept open at the same time (0 - unlimited)").Default("-1").IntVar(&c.maxOpenIndexSegments)
+	cmd.Flag("index-fetch-parallelism", "Number of index blobs downloaded in parallel (0 - default)").Default("-1").IntVar(&c.indexFetchParallelism)
 	cmd.Flag("max-list-cache-duration", "Duration of index cache").Default("-1ns").DurationVar(&c.maxListCacheDuration)
 	cmd.Action(svc.repositoryWriterAction(c.run))
 	c.svc = svc
@@ -69,6 +75,13 @@ func (c *commandCacheSetParams) run(ctx context.Context, rep repo.RepositoryWrit
 		changed++
 	}
 
+	if v := c.maxIndexCacheSizeMB; v != -1 {
+		v *= 1e6 // convert MB to bytes
+		log(ctx).Infof("changing index cache size to %v", units.BytesStringBase10(v))
+		opts.MaxIndexCacheBytes = v
+		changed++
+	}
+
 	if v := c.maxListCacheDuration; v != -1 {
 		log(ctx).Infof("changing list cache duration to %v", v)
 		opts.MaxListCacheDuration = content.DurationSeconds(v.Seconds())
@@ -93,6 +106,18 @@ func (c *commandCacheSetParams) run(ctx context.Context, rep repo.RepositoryWrit
 		changed++
 	}
 
+	if v := c.maxOpenIndexSegments; v != -1 {
+		log(ctx).Infof("changing maximum number of open index segments to %v", v)
+		opts.MaxOpenIndexSegments = v
+		changed++
+	}
+
+	if v := c.indexFetchParallelism; v != -1 {
+		log(ctx).Infof("changing index fetch parallelism to %v", v)
+		opts.IndexFetchParallelism = v
+		changed++
+	}
+
 	if changed == 0 {
 		return errors.Errorf("no changes")
 	}
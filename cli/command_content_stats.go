@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 
 	"github.com/pkg/errors"
@@ -10,17 +11,22 @@
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/compression"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/content/index"
 )
 
 type commandContentStats struct {
-	raw          bool
-	contentRange contentRangeFlags
-	out          textOutput
+	raw           bool
+	byCompression bool
+	packingRatios bool
+	contentRange  contentRangeFlags
+	out           textOutput
 }
 
 func (c *commandContentStats) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("stats", "Content statistics")
 	cmd.Flag("raw", "Raw numbers").Short('r').BoolVar(&c.raw)
+	cmd.Flag("by-compression", "Show statistics for each compression method, including unknown ones").BoolVar(&c.byCompression)
+	cmd.Flag("packing-ratios", "Show distribution of packed to original size ratios").BoolVar(&c.packingRatios)
 	c.contentRange.setup(cmd)
 	c.out.setup(svc)
 	cmd.Action(svc.directRepositoryReadAction(c.run))
@@ -43,7 +49,12 @@ func (c *commandContentStats) run(ctx context.Context, rep repo.DirectRepository
 		sizeThreshold *= 10
 	}
 
-	grandTotal, byCompressionTotal, countMap, totalSizeOfContentsUnder, err := c.calculateStats(ctx, rep, sizeBuckets)
+	var packingRatios *content.PackingRatioHistogram
+	if c.packingRatios {
+		packingRatios = &content.PackingRatioHistogram{}
+	}
+
+	grandTotal, byCompressionTotal, countMap, totalSizeOfContentsUnder, err := c.calculateStats(ctx, rep, sizeBuckets, packingRatios)
 	if err != nil {
 		return errors.Wrap(err, "error calculating totals")
 	}
@@ -65,24 +76,32 @@ func (c *commandContentStats) run(ctx context.Context, rep repo.DirectRepository
 			formatCompressionPercentage(grandTotal.originalSize, grandTotal.packedSize))
 	}
 
-	if len(byCompressionTotal) > 1 {
+	if len(byCompressionTotal) > 1 || c.byCompression {
 		c.out.printStdout("By Method:\n")
 
-		if bct := byCompressionTotal[content.NoCompression]; bct != nil {
-			c.out.printStdout("  %-22v count: %v size: %v\n", "(uncompressed)", bct.count, sizeToString(bct.originalSize))
+		if bct, ok := byCompressionTotal[content.NoCompression]; ok {
+			c.out.printStdout("  %-22v count: %v size: %v\n", "(uncompressed)", bct.Count, sizeToString(bct.OriginalLength))
 		}
 
 		for hdrID, bct := range byCompressionTotal {
+			if hdrID == content.NoCompression {
+				continue
+			}
+
 			cname := compression.HeaderIDToName[hdrID]
 			if cname == "" {
-				continue
+				if !c.byCompression {
+					continue
+				}
+
+				cname = compression.Name(fmt.Sprintf("(unknown %x)", uint32(hdrID)))
 			}
 
 			c.out.printStdout("  %-22v count: %v size: %v packed: %v compression: %v\n",
-				cname, bct.count,
-				sizeToString(bct.originalSize),
-				sizeToString(bct.packedSize),
-				formatCompressionPercentage(bct.originalSize, bct.packedSize))
+				cname, bct.Count,
+				sizeToString(bct.OriginalLength),
+				sizeToString(bct.PackedLength),
+				formatCompressionPercentage(bct.OriginalLength, bct.PackedLength))
 		}
 	}
 
@@ -106,17 +125,39 @@ func (c *commandContentStats) run(ctx context.Context, rep repo.DirectRepository
 		lastSize = size
 	}
 
+	if packingRatios != nil {
+		c.printPackingRatios(packingRatios, sizeToString)
+	}
+
 	return nil
 }
 
-func (c *commandContentStats) calculateStats(ctx context.Context, rep repo.DirectRepository, sizeBuckets []uint32) (
+func (c *commandContentStats) printPackingRatios(h *content.PackingRatioHistogram, sizeToString func(int64) string) {
+	c.out.printStdout("\nPacking Ratios:\n\n")
+
+	for b := 0; b < content.PackingRatioBuckets; b++ {
+		desc := fmt.Sprintf("between %v%% and %v%%", b*10, (b+1)*10) // nolint:gomnd
+		if b == content.PackingRatioBuckets-1 {
+			desc = "not reduced"
+		}
+
+		c.out.printStdout("%9v %-22v (original %v packed %v)\n",
+			h.Count[b],
+			desc,
+			sizeToString(h.OriginalBytes[b]),
+			sizeToString(h.PackedBytes[b]),
+		)
+	}
+}
+
+func (c *commandContentStats) calculateStats(ctx context.Context, rep repo.DirectRepository, sizeBuckets []uint32, packingRatios *content.PackingRatioHistogram) (
 	grandTotal contentStatsTotals,
-	byCompressionTotal map[compression.HeaderID]*contentStatsTotals,
+	byCompressionTotal map[compression.HeaderID]index.CompressionStat,
 	countMap map[uint32]int,
 	totalSizeOfContentsUnder map[uint32]int64,
 	err error,
 ) {
-	byCompressionTotal = make(map[compression.HeaderID]*contentStatsTotals)
+	byCompressionTotal = make(map[compression.HeaderID]index.CompressionStat)
 	totalSizeOfContentsUnder = make(map[uint32]int64)
 	countMap = make(map[uint32]int)
 
@@ -127,25 +168,18 @@ func (c *commandContentStats) calculateStats(ctx context.Context, rep repo.Direc
 	err = rep.ContentReader().IterateContents(
 		ctx,
 		content.IterateOptions{
-			Range: c.contentRange.contentIDRange(),
+			Range:         c.contentRange.contentIDRange(),
+			PackingRatios: packingRatios,
 		},
 		func(b content.Info) error {
 			grandTotal.packedSize += int64(b.GetPackedLength())
 			grandTotal.originalSize += int64(b.GetOriginalLength())
 			grandTotal.count++
 
-			bct := byCompressionTotal[b.GetCompressionHeaderID()]
-			if bct == nil {
-				bct = &contentStatsTotals{}
-				byCompressionTotal[b.GetCompressionHeaderID()] = bct
-			}
-
-			bct.packedSize += int64(b.GetPackedLength())
-			bct.originalSize += int64(b.GetOriginalLength())
-			bct.count++
+			index.AddCompressionStat(byCompressionTotal, b)
 
 			for s := range countMap {
-				if b.GetPackedLength() < s {
+				if b.GetPackedLength() < uint64(s) {
 					countMap[s]++
 					totalSizeOfContentsUnder[s] += int64(b.GetPackedLength())
 				}
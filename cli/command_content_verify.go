@@ -2,6 +2,8 @@
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
 	"math/rand"
 	"sync"
 	"sync/atomic"
@@ -9,20 +11,137 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/quarantine"
 	"github.com/kopia/kopia/internal/timetrack"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/hashing"
+	"github.com/kopia/kopia/repo/manifest"
 )
 
+// verifyDownloadThrottlingWindow is the duration window during which the download token bucket fully replenishes.
+const verifyDownloadThrottlingWindow = time.Second
+
 type commandContentVerify struct {
 	contentVerifyParallel       int
 	contentVerifyFull           bool
 	contentVerifyIncludeDeleted bool
 	contentVerifyPercent        float64
+	contentVerifyNoList         bool
 	progressInterval            time.Duration
+	resumeFile                  string
+	blobIDs                     []string
+	blobListFile                string
+	blobMapCacheFile            string
+	quarantine                  bool
+	traceCorruptSnapshots       bool
+	maxDownloadBytesPerSecond   float64
+	failFast                    bool
+	checkBlobs                  bool
+	checkBlobsPercent           float64
 
 	contentRange contentRangeFlags
+	jo           jsonOutput
+
+	outputMutex sync.Mutex
+}
+
+// contentVerifyErrorCategory classifies content verification errors, which call for different responses.
+type contentVerifyErrorCategory string
+
+const (
+	// pack blob is missing entirely, which usually means it was deleted from the storage.
+	verifyErrorMissingBlob contentVerifyErrorCategory = "missingBlob"
+
+	// content range exceeds the length of its pack blob, which usually means index corruption.
+	verifyErrorOutOfBounds contentVerifyErrorCategory = "outOfBounds"
+
+	// content could not be downloaded, decrypted or verified.
+	verifyErrorDownload contentVerifyErrorCategory = "downloadFailed"
+
+	// content was downloaded and decrypted, but its data doesn't hash to its content ID.
+	verifyErrorHashMismatch contentVerifyErrorCategory = "hashMismatch"
+
+	// any other error, such as a failure to look up blob metadata.
+	verifyErrorOther contentVerifyErrorCategory = "other"
+)
+
+// contentVerifyFailure is returned by contentVerify and records the category of the error.
+type contentVerifyFailure struct {
+	category contentVerifyErrorCategory
+	err      error
+}
+
+func (f *contentVerifyFailure) Error() string { return f.err.Error() }
+
+func (f *contentVerifyFailure) Unwrap() error { return f.err }
+
+func verifyErrorCategory(err error) contentVerifyErrorCategory {
+	var f *contentVerifyFailure
+
+	if errors.As(err, &f) {
+		return f.category
+	}
+
+	return verifyErrorOther
+}
+
+// contentVerifyErrorCounts holds the number of errors in each category, the map itself is never modified
+// after creation, so it can be safely used by parallel workers.
+type contentVerifyErrorCounts map[contentVerifyErrorCategory]*int32
+
+func newContentVerifyErrorCounts() contentVerifyErrorCounts {
+	return contentVerifyErrorCounts{
+		verifyErrorMissingBlob:  new(int32),
+		verifyErrorOutOfBounds:  new(int32),
+		verifyErrorDownload:     new(int32),
+		verifyErrorHashMismatch: new(int32),
+		verifyErrorOther:        new(int32),
+	}
+}
+
+func (c contentVerifyErrorCounts) add(cat contentVerifyErrorCategory) {
+	atomic.AddInt32(c[cat], 1)
+}
+
+func (c contentVerifyErrorCounts) get(cat contentVerifyErrorCategory) int32 {
+	return atomic.LoadInt32(c[cat])
+}
+
+func (c contentVerifyErrorCounts) snapshot() map[contentVerifyErrorCategory]int32 {
+	result := map[contentVerifyErrorCategory]int32{}
+
+	for cat := range c {
+		result[cat] = c.get(cat)
+	}
+
+	return result
+}
+
+// contentVerifyError is emitted in JSON mode for each content that failed verification.
+type contentVerifyError struct {
+	Type         string                     `json:"type"`
+	ContentID    content.ID                 `json:"contentID"`
+	PackBlobID   blob.ID                    `json:"packBlobID"`
+	PackOffset   uint64                     `json:"packOffset"`
+	PackedLength uint64                     `json:"packedLength"`
+	Category     contentVerifyErrorCategory `json:"category"`
+	Reason       string                     `json:"reason"`
+}
+
+// contentVerifySummary is emitted in JSON mode after verification completes.
+type contentVerifySummary struct {
+	Type             string                               `json:"type"`
+	VerifiedCount    int32                                `json:"verifiedCount"`
+	SuccessCount     int32                                `json:"successCount"`
+	ErrorCount       int32                                `json:"errorCount"`
+	ErrorsByCategory map[contentVerifyErrorCategory]int32 `json:"errorsByCategory"`
+	ElapsedSeconds   float64                              `json:"elapsedSeconds"`
+	StoppedEarly     bool                                 `json:"stoppedEarly,omitempty"`
+	Blobs            *contentVerifyBlobResults            `json:"blobs,omitempty"`
 }
 
 func (c *commandContentVerify) setup(svc appServices, parent commandParent) {
@@ -32,8 +151,20 @@ func (c *commandContentVerify) setup(svc appServices, parent commandParent) {
 	cmd.Flag("full", "Full verification (including download)").BoolVar(&c.contentVerifyFull)
 	cmd.Flag("include-deleted", "Include deleted contents").BoolVar(&c.contentVerifyIncludeDeleted)
 	cmd.Flag("download-percent", "Download a percentage of files [0.0 .. 100.0]").Float64Var(&c.contentVerifyPercent)
+	cmd.Flag("no-list", "Do not list all blobs, instead look up metadata of each referenced pack blob").BoolVar(&c.contentVerifyNoList)
 	cmd.Flag("progress-interval", "Progress output interval").Default("3s").DurationVar(&c.progressInterval)
+	cmd.Flag("resume-file", "Periodically save progress to the provided file and resume from it when restarted").StringVar(&c.resumeFile)
+	cmd.Flag("blob", "Only verify contents stored in the provided pack blob (can be repeated)").StringsVar(&c.blobIDs)
+	cmd.Flag("blob-list", "Only verify contents stored in pack blobs listed in the provided file, one per line").ExistingFileVar(&c.blobListFile)
+	cmd.Flag("blob-map-cache", "Cache the listing of blobs in the provided file and refresh it incrementally, referenced pack blobs are still looked up").StringVar(&c.blobMapCacheFile)
+	cmd.Flag("quarantine", "Record corrupt contents in a quarantine manifest grouped by pack blob, without deleting anything").BoolVar(&c.quarantine)
+	cmd.Flag("trace-snapshots", "Find snapshots referencing corrupt contents (expensive)").BoolVar(&c.traceCorruptSnapshots)
+	cmd.Flag("max-download-bytes-per-second", "Limit the rate of downloading contents across all parallel workers").Float64Var(&c.maxDownloadBytesPerSecond)
+	cmd.Flag("fail-fast", "Stop verification on the first error").BoolVar(&c.failFast)
+	cmd.Flag("check-blobs", "After verifying contents, report pack blobs not referenced by any content").BoolVar(&c.checkBlobs)
+	cmd.Flag("check-blobs-percent", "Percentage of pack blobs whose trailer is verified with --check-blobs [0.0 .. 100.0]").Float64Var(&c.checkBlobsPercent)
 	c.contentRange.setup(cmd)
+	c.jo.setup(svc, cmd)
 	cmd.Action(svc.directRepositoryReadAction(c.run))
 }
 
@@ -57,25 +188,153 @@ func readBlobMap(ctx context.Context, br blob.Reader) (map[blob.ID]blob.Metadata
 	return blobMap, nil
 }
 
+// verifyBlobMetadata provides metadata of pack blobs during content verification, either from
+// a prebuilt blob listing or, when listing is not available or may be stale, by looking up each referenced blob.
+type verifyBlobMetadata struct {
+	br     blob.Reader
+	listed map[blob.ID]blob.Metadata
+
+	// listedFromCache indicates that the listing was read from the blob map cache and may be stale,
+	// so referenced blobs are looked up to confirm that they still exist.
+	listedFromCache bool
+
+	mu sync.Mutex
+	// +checklocks:mu
+	cached map[blob.ID]blob.Metadata
+}
+
+// get returns the metadata of a given blob and a boolean indicating whether the blob exists.
+func (m *verifyBlobMetadata) get(ctx context.Context, blobID blob.ID) (blob.Metadata, bool, error) {
+	if m.listed != nil && !m.listedFromCache {
+		bm, ok := m.listed[blobID]
+		return bm, ok, nil
+	}
+
+	m.mu.Lock()
+	bm, ok := m.cached[blobID]
+	m.mu.Unlock()
+
+	if !ok {
+		var err error
+
+		bm, err = m.br.GetMetadata(ctx, blobID)
+
+		switch {
+		case errors.Is(err, blob.ErrBlobNotFound):
+			// cache missing blob as empty metadata
+			bm = blob.Metadata{}
+		case err != nil:
+			return blob.Metadata{}, false, errors.Wrapf(err, "unable to get metadata of blob %v", blobID)
+		}
+
+		m.mu.Lock()
+		m.cached[blobID] = bm
+		m.mu.Unlock()
+	}
+
+	return bm, bm.BlobID != "", nil
+}
+
 func (c *commandContentVerify) run(ctx context.Context, rep repo.DirectRepository) error {
-	blobMap := map[blob.ID]blob.Metadata{}
 	downloadPercent := c.contentVerifyPercent
 
 	if c.contentVerifyFull {
 		downloadPercent = 100.0
 	}
 
-	blobMap, err := readBlobMap(ctx, rep.BlobReader())
+	blobMetadata := &verifyBlobMetadata{
+		br:     rep.BlobReader(),
+		cached: map[blob.ID]blob.Metadata{},
+	}
+
+	if c.checkBlobs && c.contentVerifyNoList {
+		return errors.Errorf("--check-blobs requires listing blobs and can't be used with --no-list")
+	}
+
+	blobFilter, err := c.verifyBlobFilter()
 	if err != nil {
 		return err
 	}
 
+	switch {
+	case blobFilter != nil:
+		// only selected blobs are of interest, there's no need to list all of them.
+		if blobMetadata.listed, err = readSelectedBlobMap(ctx, rep.BlobReader(), blobFilter); err != nil {
+			return err
+		}
+
+	case !c.contentVerifyNoList:
+		var (
+			listed    map[blob.ID]blob.Metadata
+			fromCache bool
+			listErr   error
+		)
+
+		// checking pack blobs requires a fresh listing.
+		if c.blobMapCacheFile != "" && !c.checkBlobs {
+			listed, fromCache, listErr = readCachedBlobMap(ctx, rep.BlobReader(), c.blobMapCacheFile)
+		} else {
+			listed, listErr = readBlobMap(ctx, rep.BlobReader())
+		}
+
+		if listErr != nil {
+			log(ctx).Warnf("%v, falling back to looking up each pack blob.", listErr)
+		} else {
+			blobMetadata.listed = listed
+			blobMetadata.listedFromCache = fromCache
+		}
+	}
+
+	contentRange := c.contentRange.contentIDRange()
+
+	var checkpoint *verifyCheckpoint
+
+	if c.resumeFile != "" {
+		// the blob map is always read again, only contents below the checkpoint are skipped.
+		if contentRange, err = resumeContentRange(ctx, c.resumeFile, contentRange); err != nil {
+			return err
+		}
+
+		checkpoint = newVerifyCheckpoint(c.resumeFile)
+	}
+
+	// downloaded contents are verified using the hash function configured for the repository.
+	contentFormat := rep.ContentReader().ContentFormat()
+
+	hashFunc, err := hashing.CreateHashFunc(&contentFormat)
+	if err != nil {
+		return errors.Wrap(err, "unable to create hash function")
+	}
+
+	var downloadThrottler throttling.Throttler
+
+	if c.maxDownloadBytesPerSecond > 0 {
+		// a single throttler is shared by all workers, so the limit applies regardless of parallelism.
+		if downloadThrottler, err = throttling.NewThrottler(throttling.Limits{
+			DownloadBytesPerSecond: c.maxDownloadBytesPerSecond,
+		}, verifyDownloadThrottlingWindow, 0); err != nil {
+			return errors.Wrap(err, "unable to create download throttler")
+		}
+	}
+
+	var (
+		quarantined quarantine.Collector
+		corrupt     corruptContentSet
+	)
+
 	verifiedCount := new(int32)
 	successCount := new(int32)
 	errorCount := new(int32)
+	errorCounts := newContentVerifyErrorCounts()
 	totalCount := new(int32)
 	subctx, cancel := context.WithCancel(ctx)
 
+	// with --fail-fast the first error cancels subctx, which stops the iteration and the workers.
+	var (
+		firstErr     error
+		firstErrOnce sync.Once
+	)
+
 	var wg sync.WaitGroup
 
 	// ensure we cancel estimation goroutine and wait for it before returning
@@ -89,7 +348,7 @@ func (c *commandContentVerify) run(ctx context.Context, rep repo.DirectRepositor
 
 	go func() {
 		defer wg.Done()
-		c.getTotalContentCount(subctx, rep, totalCount)
+		c.getTotalContentCount(subctx, rep, contentRange, blobFilter, totalCount)
 	}()
 
 	log(ctx).Infof("Verifying all contents...")
@@ -98,21 +357,59 @@ func (c *commandContentVerify) run(ctx context.Context, rep repo.DirectRepositor
 
 	throttle := new(timetrack.Throttle)
 	est := timetrack.Start()
+	timer := timetrack.StartTimer()
+
+	verifyOne := func(ci content.Info) {
+		// subctx is canceled on the first error with --fail-fast, which interrupts in-flight downloads.
+		err := c.contentVerify(subctx, rep.ContentReader(), ci, blobMetadata, downloadPercent, downloadThrottler, hashFunc)
+		if err != nil && c.failFast && subctx.Err() != nil {
+			// verification was interrupted after the first error, the content is neither valid nor invalid.
+			return
+		}
+
+		if err != nil {
+			category := verifyErrorCategory(err)
 
-	if err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{
-		Range:          c.contentRange.contentIDRange(),
-		Parallel:       c.contentVerifyParallel,
-		IncludeDeleted: c.contentVerifyIncludeDeleted,
-	}, func(ci content.Info) error {
-		if err := c.contentVerify(ctx, rep.ContentReader(), ci, blobMap, downloadPercent); err != nil {
 			log(ctx).Errorf("error %v", err)
 			atomic.AddInt32(errorCount, 1)
+			errorCounts.add(category)
+
+			if c.quarantine {
+				quarantined.Add(ci, err)
+			}
+
+			if c.traceCorruptSnapshots {
+				corrupt.add(ci.GetContentID())
+			}
+
+			if c.jo.jsonOutput {
+				c.emitJSON(&contentVerifyError{
+					Type:         "error",
+					ContentID:    ci.GetContentID(),
+					PackBlobID:   ci.GetPackBlobID(),
+					PackOffset:   ci.GetPackOffset(),
+					PackedLength: ci.GetPackedLength(),
+					Category:     category,
+					Reason:       err.Error(),
+				})
+			}
+
+			if c.failFast {
+				firstErrOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
 		} else {
 			atomic.AddInt32(successCount, 1)
 		}
 
 		atomic.AddInt32(verifiedCount, 1)
 
+		if checkpoint != nil {
+			checkpoint.finished(ci.GetContentID())
+		}
+
 		if throttle.ShouldOutput(c.progressInterval) {
 			timings, ok := est.Estimate(float64(atomic.LoadInt32(verifiedCount)), float64(atomic.LoadInt32(totalCount)))
 			if ok {
@@ -127,34 +424,199 @@ func (c *commandContentVerify) run(ctx context.Context, rep repo.DirectRepositor
 			} else {
 				log(ctx).Infof("  Verified %v contents, %v errors, estimating...", atomic.LoadInt32(verifiedCount), atomic.LoadInt32(errorCount))
 			}
+
+			if checkpoint != nil {
+				if err := checkpoint.save(); err != nil {
+					log(ctx).Warnf("unable to save checkpoint: %v", err)
+				}
+			}
 		}
+	}
 
-		return nil
-	}); err != nil {
-		return errors.Wrap(err, "iterate contents")
+	// contents are dispatched to workers in the order of their IDs, which allows the checkpoint
+	// to determine the ID below which all contents have been verified.
+	parallel := c.contentVerifyParallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	workch := make(chan content.Info, parallel)
+
+	var workers sync.WaitGroup
+
+	for i := 0; i < parallel; i++ {
+		workers.Add(1)
+
+		go func() {
+			defer workers.Done()
+
+			for ci := range workch {
+				if subctx.Err() != nil {
+					// drain remaining work after fail-fast cancellation.
+					continue
+				}
+
+				verifyOne(ci)
+			}
+		}()
+	}
+
+	iterErr := rep.ContentReader().IterateContents(subctx, content.IterateOptions{
+		Range:          contentRange,
+		IncludeDeleted: c.contentVerifyIncludeDeleted,
+	}, func(ci content.Info) error {
+		if blobFilter != nil && !blobFilter[ci.GetPackBlobID()] {
+			return nil
+		}
+
+		if checkpoint != nil {
+			checkpoint.started(ci.GetContentID())
+		}
+
+		select {
+		case workch <- ci:
+			return nil
+		case <-subctx.Done():
+			return errors.Wrap(subctx.Err(), "context error")
+		}
+	})
+
+	close(workch)
+	workers.Wait()
+
+	stoppedEarly := firstErr != nil
+
+	if iterErr != nil || stoppedEarly {
+		if checkpoint != nil {
+			if err := checkpoint.save(); err != nil {
+				log(ctx).Warnf("unable to save checkpoint: %v", err)
+			}
+		}
+
+		if !stoppedEarly {
+			return errors.Wrap(iterErr, "iterate contents")
+		}
+	} else if checkpoint != nil {
+		if err := checkpoint.remove(); err != nil {
+			return err
+		}
+	}
+
+	if stoppedEarly {
+		log(ctx).Infof("Stopped verifying after the first error, verified %v contents, found %v errors.", atomic.LoadInt32(verifiedCount), atomic.LoadInt32(errorCount))
+	} else {
+		log(ctx).Infof("Finished verifying %v contents, found %v errors.", atomic.LoadInt32(verifiedCount), atomic.LoadInt32(errorCount))
 	}
 
-	log(ctx).Infof("Finished verifying %v contents, found %v errors.", atomic.LoadInt32(verifiedCount), atomic.LoadInt32(errorCount))
+	var blobResults *contentVerifyBlobResults
+
+	if c.checkBlobs && !stoppedEarly {
+		if blobMetadata.listed == nil {
+			log(ctx).Warnf("Blobs could not be listed, skipping pack blob check.")
+		} else if blobResults, err = c.verifyPackBlobs(ctx, rep, blobMetadata.listed); err != nil {
+			return err
+		}
+	}
+
+	if c.jo.jsonOutput {
+		c.emitJSON(&contentVerifySummary{
+			Type:             "summary",
+			VerifiedCount:    atomic.LoadInt32(verifiedCount),
+			SuccessCount:     atomic.LoadInt32(successCount),
+			ErrorCount:       atomic.LoadInt32(errorCount),
+			ErrorsByCategory: errorCounts.snapshot(),
+			ElapsedSeconds:   timer.Elapsed().Seconds(),
+			StoppedEarly:     stoppedEarly,
+			Blobs:            blobResults,
+		})
+	}
 
 	ec := atomic.LoadInt32(errorCount)
 	if ec == 0 {
+		if blobResults != nil && blobResults.InvalidCount > 0 {
+			return errors.Errorf("found %v invalid pack blobs", blobResults.InvalidCount)
+		}
+
 		return nil
 	}
 
+	log(ctx).Infof("  %v contents depend on missing pack blobs (deleted from storage?)", errorCounts.get(verifyErrorMissingBlob))
+	log(ctx).Infof("  %v contents are out of bounds of their pack blobs (index corruption?)", errorCounts.get(verifyErrorOutOfBounds))
+	log(ctx).Infof("  %v contents failed to download or decrypt", errorCounts.get(verifyErrorDownload))
+	log(ctx).Infof("  %v contents don't match their content IDs", errorCounts.get(verifyErrorHashMismatch))
+
+	if n := errorCounts.get(verifyErrorOther); n > 0 {
+		log(ctx).Infof("  %v contents failed verification for other reasons", n)
+	}
+
+	if c.quarantine {
+		if err := c.writeQuarantineRecord(ctx, rep, quarantined.Record()); err != nil {
+			return err
+		}
+	}
+
+	if c.traceCorruptSnapshots {
+		if err := c.traceSnapshots(ctx, rep, &corrupt); err != nil {
+			return err
+		}
+	}
+
+	if stoppedEarly {
+		return errors.Wrap(firstErr, "verification stopped on the first error")
+	}
+
 	return errors.Errorf("encountered %v errors", ec)
 }
 
-func (c *commandContentVerify) getTotalContentCount(ctx context.Context, rep repo.DirectRepository, totalCount *int32) {
+// writeQuarantineRecord stores the record of corrupt contents in the repository and prints them grouped by pack blob.
+func (c *commandContentVerify) writeQuarantineRecord(ctx context.Context, rep repo.Repository, r *quarantine.Record) error {
+	var manifestID manifest.ID
+
+	if err := repo.WriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: "cli:content verify quarantine",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		var err error
+
+		manifestID, err = quarantine.Put(ctx, w, r)
+
+		return err
+	}); err != nil {
+		return errors.Wrap(err, "unable to write quarantine record")
+	}
+
+	log(ctx).Infof("Quarantined %v contents in %v pack blobs, record %v:", r.ContentCount, len(r.Blobs), manifestID)
+
+	for _, b := range r.Blobs {
+		log(ctx).Infof("  %v: %v contents", b.BlobID, len(b.Contents))
+	}
+
+	return nil
+}
+
+// emitJSON writes the provided value to stdout as a single line of JSON, logs are written to stderr
+// so that stdout only contains JSON objects.
+func (c *commandContentVerify) emitJSON(v interface{}) {
+	c.outputMutex.Lock()
+	defer c.outputMutex.Unlock()
+
+	fmt.Fprintf(c.jo.out, "%s\n", c.jo.jsonBytes(v))
+}
+
+func (c *commandContentVerify) getTotalContentCount(ctx context.Context, rep repo.DirectRepository, contentRange content.IDRange, blobFilter map[blob.ID]bool, totalCount *int32) {
 	var tc int32
 
 	if err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{
-		Range:          c.contentRange.contentIDRange(),
+		Range:          contentRange,
 		IncludeDeleted: c.contentVerifyIncludeDeleted,
 	}, func(ci content.Info) error {
 		if err := ctx.Err(); err != nil {
 			return errors.Wrap(err, "context error")
 		}
 
+		if blobFilter != nil && !blobFilter[ci.GetPackBlobID()] {
+			return nil
+		}
+
 		tc++
 		return nil
 	}); err != nil {
@@ -165,20 +627,38 @@ func (c *commandContentVerify) getTotalContentCount(ctx context.Context, rep rep
 	atomic.StoreInt32(totalCount, tc)
 }
 
-func (c *commandContentVerify) contentVerify(ctx context.Context, r content.Reader, ci content.Info, blobMap map[blob.ID]blob.Metadata, downloadPercent float64) error {
-	bi, ok := blobMap[ci.GetPackBlobID()]
+func (c *commandContentVerify) contentVerify(ctx context.Context, r content.Reader, ci content.Info, blobMetadata *verifyBlobMetadata, downloadPercent float64, downloadThrottler throttling.Throttler, hashFunc hashing.HashFunc) error {
+	bi, ok, err := blobMetadata.get(ctx, ci.GetPackBlobID())
+	if err != nil {
+		return err
+	}
+
 	if !ok {
-		return errors.Errorf("content %v depends on missing blob %v", ci.GetContentID(), ci.GetPackBlobID())
+		return &contentVerifyFailure{verifyErrorMissingBlob, errors.Errorf("content %v depends on missing blob %v", ci.GetContentID(), ci.GetPackBlobID())}
 	}
 
-	if int64(ci.GetPackOffset()+ci.GetPackedLength()) > bi.Length {
-		return errors.Errorf("content %v out of bounds of its pack blob %v", ci.GetContentID(), ci.GetPackBlobID())
+	if bi.Length != unknownBlobLength && int64(ci.GetPackOffset()+ci.GetPackedLength()) > bi.Length {
+		return &contentVerifyFailure{verifyErrorOutOfBounds, errors.Errorf("content %v out of bounds of its pack blob %v", ci.GetContentID(), ci.GetPackBlobID())}
 	}
 
 	// nolint:gosec
 	if 100*rand.Float64() < downloadPercent {
-		if _, err := r.GetContent(ctx, ci.GetContentID()); err != nil {
-			return errors.Wrapf(err, "content %v is invalid", ci.GetContentID())
+		if downloadThrottler != nil {
+			downloadThrottler.BeforeDownload(ctx, int64(ci.GetPackedLength()))
+		}
+
+		data, err := r.GetContent(ctx, ci.GetContentID())
+		if errors.Is(err, blob.ErrInvalidRange) {
+			// when the blob length is not known upfront, out of bounds contents are found when downloading.
+			return &contentVerifyFailure{verifyErrorOutOfBounds, errors.Wrapf(err, "content %v out of bounds of its pack blob %v", ci.GetContentID(), ci.GetPackBlobID())}
+		}
+
+		if err != nil {
+			return &contentVerifyFailure{verifyErrorDownload, errors.Wrapf(err, "content %v is invalid", ci.GetContentID())}
+		}
+
+		if actual := contentIDOfData(ci.GetContentID().Prefix(), data, hashFunc); actual != ci.GetContentID() {
+			return &contentVerifyFailure{verifyErrorHashMismatch, errors.Errorf("content %v has data hashing to %v", ci.GetContentID(), actual)}
 		}
 
 		return nil
@@ -186,3 +666,10 @@ func (c *commandContentVerify) contentVerify(ctx context.Context, r content.Read
 
 	return nil
 }
+
+// contentIDOfData returns the content ID of the provided data the same way the content manager computes it.
+func contentIDOfData(prefix content.ID, data []byte, hashFunc hashing.HashFunc) content.ID {
+	var hashOutput [hashing.MaxHashSize]byte
+
+	return prefix + content.ID(hex.EncodeToString(hashFunc(hashOutput[:0], gather.FromSlice(data))))
+}
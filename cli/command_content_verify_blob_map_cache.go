@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/internal/epoch"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// blobMapCacheIndexPrefixes are prefixes of index blobs, which are rewritten whenever pack blobs
+// are added to or removed from the repository.
+// nolint:gochecknoglobals
+var blobMapCacheIndexPrefixes = []blob.ID{
+	content.IndexBlobPrefix,
+	epoch.EpochManagerIndexUberPrefix,
+}
+
+// blobMapCache is persisted in a JSON file and holds the listing of blobs from a previous verification.
+type blobMapCache struct {
+	// Token is computed from the listing of index blobs and changes when the set of pack blobs changes.
+	Token string          `json:"token"`
+	Blobs []blob.Metadata `json:"blobs"`
+}
+
+// blobMapFreshnessToken returns a token that changes whenever index blobs change, which is much
+// cheaper to compute than listing all blobs.
+func blobMapFreshnessToken(ctx context.Context, br blob.Reader) (string, error) {
+	var indexBlobs []blob.Metadata
+
+	for _, prefix := range blobMapCacheIndexPrefixes {
+		if err := br.ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+			indexBlobs = append(indexBlobs, bm)
+			return nil
+		}); err != nil {
+			return "", errors.Wrap(err, "unable to list index blobs")
+		}
+	}
+
+	sort.Slice(indexBlobs, func(i, j int) bool {
+		return indexBlobs[i].BlobID < indexBlobs[j].BlobID
+	})
+
+	h := sha256.New()
+
+	for _, bm := range indexBlobs {
+		fmt.Fprintf(h, "%v %v %v\n", bm.BlobID, bm.Length, bm.Timestamp.UnixNano())
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readCachedBlobMap returns the blob map from the cache file if the index blobs have not changed since it was written.
+// When they have changed and the storage supports listing after a marker, only blobs sorting after the last cached
+// blob with each pack prefix are listed and added to the cached map, otherwise all blobs are listed. The cache file
+// is updated in both cases.
+//
+// The returned boolean is true when the map comes from the cache, in which case it may still contain blobs deleted
+// out-of-band or lack new blobs sorting before the markers, so the caller must confirm the existence of blobs
+// instead of relying on the map.
+func readCachedBlobMap(ctx context.Context, br blob.Reader, filename string) (map[blob.ID]blob.Metadata, bool, error) {
+	token, err := blobMapFreshnessToken(ctx, br)
+	if err != nil {
+		return nil, false, err
+	}
+
+	cached, cacheErr := loadBlobMapCache(filename)
+	if cacheErr != nil {
+		log(ctx).Warnf("unable to read blob map cache: %v", cacheErr)
+	}
+
+	if cached != nil {
+		blobMap := map[blob.ID]blob.Metadata{}
+
+		for _, bm := range cached.Blobs {
+			blobMap[bm.BlobID] = bm
+		}
+
+		if cached.Token == token {
+			log(ctx).Infof("Using %v cached blobs from %v.", len(blobMap), filename)
+
+			return blobMap, true, nil
+		}
+
+		added, refreshErr := refreshBlobMapAfterMarkers(ctx, br, blobMap)
+		switch {
+		case refreshErr == nil:
+			log(ctx).Infof("Using %v cached blobs from %v, listed %v new blobs.", len(blobMap), filename, added)
+
+			if err := saveBlobMapCache(filename, token, blobMap); err != nil {
+				log(ctx).Warnf("unable to write blob map cache: %v", err)
+			}
+
+			return blobMap, true, nil
+
+		case errors.Is(refreshErr, blob.ErrUnsupportedMethod):
+			log(ctx).Debugf("storage does not support listing after marker, listing all blobs")
+
+		default:
+			return nil, false, refreshErr
+		}
+	}
+
+	blobMap, err := readBlobMap(ctx, br)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := saveBlobMapCache(filename, token, blobMap); err != nil {
+		log(ctx).Warnf("unable to write blob map cache: %v", err)
+	}
+
+	return blobMap, false, nil
+}
+
+// refreshBlobMapAfterMarkers adds pack blobs sorting after the last cached blob with the same prefix to the
+// provided map and returns the number of added blobs.
+func refreshBlobMapAfterMarkers(ctx context.Context, br blob.Reader, blobMap map[blob.ID]blob.Metadata) (int, error) {
+	markers := map[blob.ID]blob.ID{}
+
+	for id := range blobMap {
+		for _, prefix := range content.PackBlobIDPrefixes {
+			if strings.HasPrefix(string(id), string(prefix)) && id > markers[prefix] {
+				markers[prefix] = id
+			}
+		}
+	}
+
+	added := 0
+
+	for _, prefix := range content.PackBlobIDPrefixes {
+		if err := blob.ListBlobsAfter(ctx, br, prefix, markers[prefix], func(bm blob.Metadata) error {
+			if _, ok := blobMap[bm.BlobID]; !ok {
+				added++
+			}
+
+			blobMap[bm.BlobID] = bm
+
+			return nil
+		}); err != nil {
+			return 0, errors.Wrapf(err, "unable to list blobs with prefix %v", prefix)
+		}
+	}
+
+	return added, nil
+}
+
+// loadBlobMapCache reads the blob map cache file, returns nil if the file does not exist.
+func loadBlobMapCache(filename string) (*blobMapCache, error) {
+	f, err := os.Open(filename) //nolint:gosec
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open blob map cache")
+	}
+
+	defer f.Close() //nolint:errcheck,gosec
+
+	c := &blobMapCache{}
+	if err := json.NewDecoder(f).Decode(c); err != nil {
+		return nil, errors.Wrap(err, "unable to parse blob map cache")
+	}
+
+	return c, nil
+}
+
+func saveBlobMapCache(filename, token string, blobMap map[blob.ID]blob.Metadata) error {
+	c := &blobMapCache{
+		Token: token,
+	}
+
+	for _, bm := range blobMap {
+		c.Blobs = append(c.Blobs, bm)
+	}
+
+	sort.Slice(c.Blobs, func(i, j int) bool {
+		return c.Blobs[i].BlobID < c.Blobs[j].BlobID
+	})
+
+	var buf bytes.Buffer
+
+	if err := json.NewEncoder(&buf).Encode(c); err != nil {
+		return errors.Wrap(err, "unable to marshal JSON")
+	}
+
+	return errors.Wrap(atomicfile.Write(filename, &buf), "error writing blob map cache")
+}
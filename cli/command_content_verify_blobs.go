@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// verifyBlobFilter returns the set of pack blobs to restrict verification to, nil if all contents should be verified.
+func (c *commandContentVerify) verifyBlobFilter() (map[blob.ID]bool, error) {
+	if len(c.blobIDs) == 0 && c.blobListFile == "" {
+		return nil, nil
+	}
+
+	result := map[blob.ID]bool{}
+
+	for _, b := range c.blobIDs {
+		result[blob.ID(b)] = true
+	}
+
+	if c.blobListFile != "" {
+		f, err := os.Open(c.blobListFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to open blob list")
+		}
+
+		defer f.Close() //nolint:errcheck,gosec
+
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			if l := strings.TrimSpace(s.Text()); l != "" {
+				result[blob.ID(l)] = true
+			}
+		}
+
+		if err := s.Err(); err != nil {
+			return nil, errors.Wrap(err, "unable to read blob list")
+		}
+	}
+
+	return result, nil
+}
+
+// unknownBlobLength is the length of blobs known to exist, whose metadata was not retrieved.
+const unknownBlobLength = -1
+
+// readSelectedBlobMap returns metadata of the provided blobs, omitting the ones that do not exist.
+// When the storage can check the existence of many blobs at once, only existence is determined
+// and lengths of existing blobs are reported as unknownBlobLength.
+func readSelectedBlobMap(ctx context.Context, br blob.Reader, blobIDs map[blob.ID]bool) (map[blob.ID]blob.Metadata, error) {
+	blobMap := map[blob.ID]blob.Metadata{}
+
+	var ids []blob.ID
+
+	for blobID := range blobIDs {
+		ids = append(ids, blobID)
+	}
+
+	log(ctx).Infof("Checking existence of %v blobs...", len(ids))
+
+	exists, err := blob.BlobsExist(ctx, br, ids)
+
+	switch {
+	case err == nil:
+		for blobID, ok := range exists {
+			if ok {
+				blobMap[blobID] = blob.Metadata{BlobID: blobID, Length: unknownBlobLength}
+			}
+		}
+
+		return blobMap, nil
+
+	case !errors.Is(err, blob.ErrUnsupportedMethod):
+		return nil, errors.Wrap(err, "unable to check existence of blobs")
+	}
+
+	log(ctx).Infof("Getting metadata of %v blobs...", len(blobIDs))
+
+	for blobID := range blobIDs {
+		bm, err := br.GetMetadata(ctx, blobID)
+
+		switch {
+		case errors.Is(err, blob.ErrBlobNotFound):
+			continue
+		case err != nil:
+			return nil, errors.Wrapf(err, "unable to get metadata of blob %v", blobID)
+		}
+
+		blobMap[blobID] = bm
+	}
+
+	return blobMap, nil
+}
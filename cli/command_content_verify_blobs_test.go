@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// existenceCheckingStorage implements blob.ExistenceChecker on top of GetMetadata.
+type existenceCheckingStorage struct {
+	blob.Storage
+
+	calls int
+}
+
+func (s *existenceCheckingStorage) BlobsExist(ctx context.Context, ids []blob.ID) (map[blob.ID]bool, error) {
+	s.calls++
+
+	result := map[blob.ID]bool{}
+
+	for _, id := range ids {
+		_, err := s.GetMetadata(ctx, id)
+		result[id] = err == nil
+	}
+
+	return result, nil
+}
+
+func TestReadSelectedBlobMap(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	require.NoError(t, st.PutBlob(ctx, "p1", gather.FromSlice([]byte{1, 2, 3}), blob.PutOptions{}))
+	require.NoError(t, st.PutBlob(ctx, "p2", gather.FromSlice([]byte{1, 2, 3, 4}), blob.PutOptions{}))
+
+	selected := map[blob.ID]bool{"p1": true, "p3": true}
+
+	// without existence checks, metadata of each blob is retrieved.
+	bm, err := readSelectedBlobMap(ctx, st, selected)
+	require.NoError(t, err)
+	require.Len(t, bm, 1)
+	require.Equal(t, int64(3), bm["p1"].Length)
+
+	ec := &existenceCheckingStorage{Storage: st}
+
+	bm, err = readSelectedBlobMap(ctx, ec, selected)
+	require.NoError(t, err)
+	require.Equal(t, 1, ec.calls)
+	require.Equal(t, map[blob.ID]blob.Metadata{
+		"p1": {BlobID: "p1", Length: unknownBlobLength},
+	}, bm)
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// contentVerifyBlobProblem describes what is wrong with a pack blob found by --check-blobs.
+type contentVerifyBlobProblem string
+
+const (
+	// pack blob is not referenced by any content, which usually means leaked space.
+	verifyBlobUnreferenced contentVerifyBlobProblem = "unreferenced"
+
+	// pack blob does not end with a valid pack trailer.
+	verifyBlobInvalidTrailer contentVerifyBlobProblem = "invalidTrailer"
+)
+
+// contentVerifyBlobError is emitted in JSON mode for each pack blob flagged by --check-blobs.
+type contentVerifyBlobError struct {
+	Type    string                   `json:"type"`
+	BlobID  blob.ID                  `json:"blobID"`
+	Length  int64                    `json:"length"`
+	Problem contentVerifyBlobProblem `json:"problem"`
+	Reason  string                   `json:"reason,omitempty"`
+}
+
+// contentVerifyBlobResults holds the results of checking pack blobs.
+type contentVerifyBlobResults struct {
+	CheckedCount      int32 `json:"checkedCount"`
+	UnreferencedCount int32 `json:"unreferencedCount"`
+	UnreferencedBytes int64 `json:"unreferencedBytes"`
+	InvalidCount      int32 `json:"invalidCount"`
+}
+
+func isPackBlobID(id blob.ID) bool {
+	for _, prefix := range content.PackBlobIDPrefixes {
+		if strings.HasPrefix(string(id), string(prefix)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyPackBlobs complements content verification with a blob-centric view: it reports listed pack blobs that
+// are not referenced by any content and verifies trailers of a sample of pack blobs.
+func (c *commandContentVerify) verifyPackBlobs(ctx context.Context, rep repo.DirectRepository, listed map[blob.ID]blob.Metadata) (*contentVerifyBlobResults, error) {
+	referenced := map[blob.ID]bool{}
+
+	// contents are considered regardless of the requested range, deleted contents still occupy their packs.
+	if err := rep.ContentReader().IteratePacks(ctx, content.IteratePackOptions{
+		IncludePacksWithOnlyDeletedContent: true,
+		Prefixes:                           content.PackBlobIDPrefixes,
+	}, func(pi content.PackInfo) error {
+		referenced[pi.PackID] = true
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "unable to determine referenced pack blobs")
+	}
+
+	log(ctx).Infof("Checking pack blobs...")
+
+	var res contentVerifyBlobResults
+
+	eg, ctx := errgroup.WithContext(ctx)
+
+	blobCh := make(chan blob.Metadata)
+
+	eg.Go(func() error {
+		defer close(blobCh)
+
+		for _, bm := range listed {
+			if !isPackBlobID(bm.BlobID) {
+				continue
+			}
+
+			if !referenced[bm.BlobID] {
+				// blobs written by concurrent sessions are not yet referenced by any index.
+				log(ctx).Warnf("pack blob %v (%v bytes, written %v) is not referenced by any content", bm.BlobID, bm.Length, formatTimestamp(bm.Timestamp))
+
+				res.UnreferencedCount++
+				res.UnreferencedBytes += bm.Length
+
+				c.emitBlobError(bm, verifyBlobUnreferenced, nil)
+			}
+
+			// nolint:gosec
+			if 100*rand.Float64() >= c.checkBlobsPercent {
+				continue
+			}
+
+			select {
+			case blobCh <- bm:
+			case <-ctx.Done():
+				return errors.Wrap(ctx.Err(), "context error")
+			}
+		}
+
+		return nil
+	})
+
+	parallel := c.contentVerifyParallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	for i := 0; i < parallel; i++ {
+		eg.Go(func() error {
+			for bm := range blobCh {
+				atomic.AddInt32(&res.CheckedCount, 1)
+
+				if err := content.VerifyPackBlobTrailer(ctx, rep.BlobReader(), bm.BlobID, bm.Length); err != nil {
+					log(ctx).Errorf("error %v", err)
+					atomic.AddInt32(&res.InvalidCount, 1)
+
+					c.emitBlobError(bm, verifyBlobInvalidTrailer, err)
+				}
+			}
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, errors.Wrap(err, "error checking pack blobs")
+	}
+
+	log(ctx).Infof("Found %v unreferenced pack blobs (%v bytes), checked trailers of %v pack blobs, found %v invalid.",
+		res.UnreferencedCount, res.UnreferencedBytes, res.CheckedCount, res.InvalidCount)
+
+	return &res, nil
+}
+
+func (c *commandContentVerify) emitBlobError(bm blob.Metadata, problem contentVerifyBlobProblem, err error) {
+	if !c.jo.jsonOutput {
+		return
+	}
+
+	be := &contentVerifyBlobError{
+		Type:    "blob",
+		BlobID:  bm.BlobID,
+		Length:  bm.Length,
+		Problem: problem,
+	}
+
+	if err != nil {
+		be.Reason = err.Error()
+	}
+
+	c.emitJSON(be)
+}
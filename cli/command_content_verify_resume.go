@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// verifyResumeState is persisted in a JSON file and used to resume interrupted content verification.
+type verifyResumeState struct {
+	// all contents with IDs below ResumeFrom have been verified.
+	ResumeFrom content.ID `json:"resumeFrom"`
+}
+
+// verifyCheckpoint tracks progress of content verification, which dispatches contents in sorted order
+// but verifies them in parallel, and periodically persists the ID to resume from.
+type verifyCheckpoint struct {
+	filename string
+
+	mu sync.Mutex
+	// +checklocks:mu
+	inFlight map[content.ID]struct{}
+	// +checklocks:mu
+	lastStarted content.ID
+}
+
+func newVerifyCheckpoint(filename string) *verifyCheckpoint {
+	return &verifyCheckpoint{
+		filename: filename,
+		inFlight: map[content.ID]struct{}{},
+	}
+}
+
+// started must be called for each content in the order of content IDs before it's verified.
+func (c *verifyCheckpoint) started(cid content.ID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight[cid] = struct{}{}
+	c.lastStarted = cid
+}
+
+// finished must be called after the content has been verified.
+func (c *verifyCheckpoint) finished(cid content.ID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.inFlight, cid)
+}
+
+// resumeFrom returns the lowest content ID which may not have been verified yet.
+func (c *verifyCheckpoint) resumeFrom() content.ID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.inFlight) == 0 {
+		// all started contents have been verified, the last one will be verified again after resuming.
+		return c.lastStarted
+	}
+
+	var lowest content.ID
+
+	for cid := range c.inFlight {
+		if lowest == "" || cid < lowest {
+			lowest = cid
+		}
+	}
+
+	return lowest
+}
+
+// save persists the current checkpoint.
+func (c *verifyCheckpoint) save() error {
+	rf := c.resumeFrom()
+	if rf == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+
+	if err := json.NewEncoder(&buf).Encode(&verifyResumeState{ResumeFrom: rf}); err != nil {
+		return errors.Wrap(err, "unable to marshal JSON")
+	}
+
+	return errors.Wrap(atomicfile.Write(c.filename, &buf), "error writing resume file")
+}
+
+// remove removes the persisted checkpoint after verification has completed.
+func (c *verifyCheckpoint) remove() error {
+	if err := os.Remove(c.filename); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "error removing resume file")
+	}
+
+	return nil
+}
+
+// readVerifyResumeState reads the resume file, returns nil state if the file does not exist.
+func readVerifyResumeState(filename string) (*verifyResumeState, error) {
+	f, err := os.Open(filename) //nolint:gosec
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open resume file")
+	}
+
+	defer f.Close() //nolint:errcheck,gosec
+
+	rs := &verifyResumeState{}
+	if err := json.NewDecoder(f).Decode(rs); err != nil {
+		return nil, errors.Wrap(err, "unable to parse resume file")
+	}
+
+	return rs, nil
+}
+
+// resumeContentRange returns the range of contents to verify, which starts at the checkpoint persisted in the
+// resume file if it's within the requested range.
+func resumeContentRange(ctx context.Context, filename string, r content.IDRange) (content.IDRange, error) {
+	rs, err := readVerifyResumeState(filename)
+	if err != nil {
+		return r, err
+	}
+
+	if rs == nil {
+		return r, nil
+	}
+
+	if !r.Contains(rs.ResumeFrom) {
+		log(ctx).Warnf("Checkpoint %v in %v is outside of the requested range, verifying all contents.", rs.ResumeFrom, filename)
+
+		return r, nil
+	}
+
+	log(ctx).Infof("Resuming verification from content %v.", rs.ResumeFrom)
+
+	r.StartID = rs.ResumeFrom
+
+	return r, nil
+}
@@ -2,6 +2,7 @@
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -23,6 +24,8 @@ func (s *formatSpecificTestSuite) TestContentVerify(t *testing.T) {
 	env.RunAndExpectSuccess(t, "content", "verify")
 	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
 	env.RunAndExpectSuccess(t, "content", "verify", "--download-percent=30")
+	env.RunAndExpectSuccess(t, "content", "verify", "--no-list")
+	env.RunAndExpectSuccess(t, "content", "verify", "--full", "--parallel=4", "--max-download-bytes-per-second=100000000")
 
 	// delete one of 'p' blobs.
 	blobIDToDelete := strings.Split(env.RunAndExpectSuccess(t, "blob", "list", "--prefix=p")[0], " ")[0]
@@ -36,5 +39,295 @@ func (s *formatSpecificTestSuite) TestContentVerify(t *testing.T) {
 	// this fails if not found
 	mustGetLineContaining(t, verifyStderr, "missing blob "+blobIDToDelete)
 
+	// errors are broken down by category.
+	require.NotContains(t, mustGetLineContaining(t, verifyStderr, "depend on missing pack blobs"), " 0 contents")
+	require.Contains(t, mustGetLineContaining(t, verifyStderr, "out of bounds of their pack blobs"), " 0 contents")
+
+	_, verifyStderr, err = env.Run(t, true, "content", "verify", "--no-list")
+	require.Error(t, err)
+
+	mustGetLineContaining(t, verifyStderr, "missing blob "+blobIDToDelete)
+
 	env.RunAndExpectFailure(t, "content", "verify", "--full")
+
+	// only contents of selected blobs are verified.
+	qBlobID := strings.Split(env.RunAndExpectSuccess(t, "blob", "list", "--prefix=q")[0], " ")[0]
+	env.RunAndExpectSuccess(t, "content", "verify", "--blob", qBlobID)
+
+	_, verifyStderr, err = env.Run(t, true, "content", "verify", "--blob", qBlobID, "--blob", blobIDToDelete)
+	require.Error(t, err)
+
+	mustGetLineContaining(t, verifyStderr, "missing blob "+blobIDToDelete)
+
+	blobListFile := filepath.Join(testutil.TempDirectory(t), "blobs.txt")
+	require.NoError(t, os.WriteFile(blobListFile, []byte(qBlobID+"\n"+blobIDToDelete+"\n"), 0o600))
+	env.RunAndExpectFailure(t, "content", "verify", "--blob-list", blobListFile)
+
+	// corrupt contents are recorded in a quarantine manifest grouped by pack blob.
+	require.Empty(t, env.RunAndExpectSuccess(t, "manifest", "list", "--filter=type:quarantine"))
+
+	_, verifyStderr, err = env.Run(t, true, "content", "verify", "--quarantine")
+	require.Error(t, err)
+
+	mustGetLineContaining(t, verifyStderr, "Quarantined")
+	mustGetLineContaining(t, verifyStderr, "  "+blobIDToDelete+": ")
+	require.Len(t, env.RunAndExpectSuccess(t, "manifest", "list", "--filter=type:quarantine"), 1)
+
+	// snapshots referencing corrupt contents are reported.
+	_, verifyStderr, err = env.Run(t, true, "content", "verify", "--trace-snapshots")
+	require.Error(t, err)
+
+	require.Contains(t, mustGetLineContaining(t, verifyStderr, "references corrupt content"), "file1.txt")
+
+	// in JSON mode, stdout contains one object per error followed by a summary.
+	verifyStdout, _, err := env.Run(t, true, "content", "verify", "--json")
+	require.Error(t, err)
+	require.GreaterOrEqual(t, len(verifyStdout), 2)
+
+	for _, l := range verifyStdout[0 : len(verifyStdout)-1] {
+		var ve map[string]interface{}
+
+		require.NoError(t, json.Unmarshal([]byte(l), &ve))
+		require.Equal(t, "error", ve["type"])
+		require.Equal(t, blobIDToDelete, ve["packBlobID"])
+		require.Equal(t, "missingBlob", ve["category"])
+		require.Contains(t, ve["reason"], "missing blob "+blobIDToDelete)
+	}
+
+	var summary map[string]interface{}
+
+	require.NoError(t, json.Unmarshal([]byte(verifyStdout[len(verifyStdout)-1]), &summary))
+	require.Equal(t, "summary", summary["type"])
+	require.Equal(t, float64(len(verifyStdout)-1), summary["errorCount"])
+	require.Equal(t, map[string]interface{}{
+		"missingBlob":    summary["errorCount"],
+		"outOfBounds":    float64(0),
+		"downloadFailed": float64(0),
+		"hashMismatch":   float64(0),
+		"other":          float64(0),
+	}, summary["errorsByCategory"])
+	require.Equal(t, summary["verifiedCount"], summary["successCount"].(float64)+summary["errorCount"].(float64))
+	require.NotContains(t, summary, "stoppedEarly")
+
+	// with --fail-fast verification stops on the first error, partial counts are still reported.
+	verifyStdout, verifyStderr, err = env.Run(t, true, "content", "verify", "--fail-fast", "--parallel=1", "--json")
+	require.Error(t, err)
+	mustGetLineContaining(t, verifyStderr, "Stopped verifying after the first error")
+
+	require.NoError(t, json.Unmarshal([]byte(verifyStdout[len(verifyStdout)-1]), &summary))
+	require.Equal(t, "summary", summary["type"])
+	require.Equal(t, float64(1), summary["errorCount"])
+	require.Equal(t, true, summary["stoppedEarly"])
+}
+
+func (s *formatSpecificTestSuite) TestContentVerifyTraceCorruptDirectory(t *testing.T) {
+	env := testenv.NewCLITest(t, s.formatFlags, testenv.NewInProcRunner(t))
+
+	dir := testutil.TempDirectory(t)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "subdir", "file1.txt"), bytes.Repeat([]byte{1, 2, 3, 4, 5}, 15000), 0o600))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	// corrupt all directory contents, which are stored in 'q' blobs, without touching snapshot manifests.
+	dirContents := env.RunAndExpectSuccess(t, "content", "list", "--prefix=k", "--json")
+
+	var infos []struct {
+		PackFile   string `json:"packFile"`
+		PackOffset int64  `json:"packOffset"`
+		Length     int64  `json:"length"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(strings.Join(dirContents, "\n")), &infos))
+	require.NotEmpty(t, infos)
+
+	for _, ci := range infos {
+		require.True(t, strings.HasPrefix(ci.PackFile, "q"))
+
+		packFile := findBlobFile(t, env.RepoDir, ci.PackFile)
+
+		packData, err := os.ReadFile(packFile)
+		require.NoError(t, err)
+
+		for i := ci.PackOffset; i < ci.PackOffset+ci.Length; i++ {
+			packData[i] ^= 0xff
+		}
+
+		require.NoError(t, os.WriteFile(packFile, packData, 0o600))
+	}
+
+	// make sure corrupted blobs are not served from the metadata cache.
+	env.RunAndExpectSuccess(t, "cache", "clear")
+
+	// directories that can't be read are reported as walk errors, snapshots referencing them are still reported.
+	_, verifyStderr, err := env.Run(t, true, "content", "verify", "--download-percent=100", "--trace-snapshots")
+	require.Error(t, err)
+
+	mustGetLineContaining(t, verifyStderr, "references corrupt content")
+}
+
+// findBlobFile returns the path of the file storing the provided blob in a filesystem repository.
+func findBlobFile(t *testing.T, repoDir, blobID string) string {
+	t.Helper()
+
+	var found string
+
+	require.NoError(t, filepath.Walk(repoDir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(repoDir, p)
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSuffix(strings.ReplaceAll(rel, string(filepath.Separator), ""), ".f") == blobID {
+			found = p
+		}
+
+		return nil
+	}))
+
+	require.NotEmpty(t, found, "blob file not found: %v", blobID)
+
+	return found
+}
+
+func (s *formatSpecificTestSuite) TestContentVerifyResume(t *testing.T) {
+	env := testenv.NewCLITest(t, s.formatFlags, testenv.NewInProcRunner(t))
+
+	dir := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file1.txt"), bytes.Repeat([]byte{1, 2, 3, 4, 5}, 15000), 0o600))
+
+	resumeFile := filepath.Join(testutil.TempDirectory(t), "verify-resume.json")
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	// resume file is removed after successful completion.
+	env.RunAndExpectSuccess(t, "content", "verify", "--resume-file", resumeFile)
+	require.NoFileExists(t, resumeFile)
+
+	// delete one of 'p' blobs, which only contain non-prefixed contents.
+	blobIDToDelete := strings.Split(env.RunAndExpectSuccess(t, "blob", "list", "--prefix=p")[0], " ")[0]
+	env.RunAndExpectSuccess(t, "blob", "delete", blobIDToDelete)
+
+	// resume from the first prefixed content, non-prefixed contents are skipped.
+	require.NoError(t, os.WriteFile(resumeFile, []byte(`{"resumeFrom":"g"}`), 0o600))
+
+	_, verifyStderr, err := env.Run(t, false, "content", "verify", "--resume-file", resumeFile)
+	require.NoError(t, err)
+	mustGetLineContaining(t, verifyStderr, "Resuming verification from content g")
+	require.NoFileExists(t, resumeFile)
+
+	// checkpoint outside of the requested range is ignored.
+	require.NoError(t, os.WriteFile(resumeFile, []byte(`{"resumeFrom":"g"}`), 0o600))
+
+	_, verifyStderr, err = env.Run(t, true, "content", "verify", "--non-prefixed", "--resume-file", resumeFile)
+	require.Error(t, err)
+	mustGetLineContaining(t, verifyStderr, "missing blob "+blobIDToDelete)
+}
+
+func (s *formatSpecificTestSuite) TestContentVerifyBlobMapCache(t *testing.T) {
+	env := testenv.NewCLITest(t, s.formatFlags, testenv.NewInProcRunner(t))
+
+	dir := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file1.txt"), bytes.Repeat([]byte{1, 2, 3, 4, 5}, 15000), 0o600))
+
+	cacheFile := filepath.Join(testutil.TempDirectory(t), "blob-map.json")
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	_, stderr := env.RunAndExpectSuccessWithErrOut(t, "content", "verify", "--blob-map-cache", cacheFile)
+	require.FileExists(t, cacheFile)
+	require.NotContains(t, strings.Join(stderr, "\n"), "cached blobs")
+
+	// indexes have not changed, cached blob map is used.
+	_, stderr = env.RunAndExpectSuccessWithErrOut(t, "content", "verify", "--blob-map-cache", cacheFile)
+	mustGetLineContaining(t, stderr, "cached blobs")
+
+	// new snapshot writes new indexes, so blobs are listed again.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file2.txt"), bytes.Repeat([]byte{6, 7, 8}, 15000), 0o600))
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	_, stderr = env.RunAndExpectSuccessWithErrOut(t, "content", "verify", "--blob-map-cache", cacheFile)
+	require.NotContains(t, strings.Join(stderr, "\n"), "cached blobs")
+
+	// blobs deleted out-of-band don't change indexes, but contents referencing them are still reported.
+	blobIDToDelete := strings.Split(env.RunAndExpectSuccess(t, "blob", "list", "--prefix=p")[0], " ")[0]
+	env.RunAndExpectSuccess(t, "blob", "delete", blobIDToDelete)
+
+	_, stderr, err := env.Run(t, true, "content", "verify", "--blob-map-cache", cacheFile)
+	require.Error(t, err)
+	mustGetLineContaining(t, stderr, "cached blobs")
+	mustGetLineContaining(t, stderr, "missing blob "+blobIDToDelete)
+}
+
+func (s *formatSpecificTestSuite) TestContentVerifyCheckBlobs(t *testing.T) {
+	env := testenv.NewCLITest(t, s.formatFlags, testenv.NewInProcRunner(t))
+
+	dir := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file1.txt"), bytes.Repeat([]byte{1, 2, 3, 4, 5}, 15000), 0o600))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	_, stderr := env.RunAndExpectSuccessWithErrOut(t, "content", "verify", "--check-blobs", "--check-blobs-percent=100")
+	mustGetLineContaining(t, stderr, "Found 0 unreferenced pack blobs")
+
+	env.RunAndExpectFailure(t, "content", "verify", "--check-blobs", "--no-list")
+
+	// store a copy of one of 'p' blobs under a different name, which is not referenced by any content.
+	var packFile string
+
+	require.NoError(t, filepath.Walk(env.RepoDir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || packFile != "" {
+			return err
+		}
+
+		// blobs are sharded into subdirectories, so match on the path relative to the repository root.
+		rel, err := filepath.Rel(env.RepoDir, p)
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(rel, "p") && strings.HasSuffix(rel, ".f") {
+			packFile = p
+		}
+
+		return nil
+	}))
+
+	require.NotEmpty(t, packFile)
+
+	packData, err := os.ReadFile(packFile)
+	require.NoError(t, err)
+
+	unreferencedFile := strings.TrimSuffix(packFile, ".f") + "0.f"
+	require.NoError(t, os.WriteFile(unreferencedFile, packData, 0o600))
+
+	// unreferenced blobs are reported, but are not errors.
+	verifyStdout, stderr := env.RunAndExpectSuccessWithErrOut(t, "content", "verify", "--check-blobs", "--json")
+	mustGetLineContaining(t, stderr, "is not referenced by any content")
+
+	var be map[string]interface{}
+
+	require.NoError(t, json.Unmarshal([]byte(verifyStdout[0]), &be))
+	require.Equal(t, "blob", be["type"])
+	require.Equal(t, "unreferenced", be["problem"])
+
+	var summary map[string]interface{}
+
+	require.NoError(t, json.Unmarshal([]byte(verifyStdout[len(verifyStdout)-1]), &summary))
+	require.Equal(t, float64(1), summary["blobs"].(map[string]interface{})["unreferencedCount"])
+
+	// pack blobs with invalid trailers are errors.
+	require.NoError(t, os.WriteFile(unreferencedFile, bytes.Repeat([]byte{1}, 1000), 0o600))
+
+	_, stderr, err = env.Run(t, true, "content", "verify", "--check-blobs", "--check-blobs-percent=100")
+	require.Error(t, err)
+	mustGetLineContaining(t, stderr, "found 1 invalid")
 }
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// contentVerifySnapshotReference is emitted in JSON mode for each snapshot object referencing a corrupt content.
+type contentVerifySnapshotReference struct {
+	Type      string              `json:"type"`
+	Source    snapshot.SourceInfo `json:"source"`
+	StartTime string              `json:"startTime"`
+	Path      string              `json:"path"`
+	ContentID content.ID          `json:"contentID"`
+}
+
+// corruptContentSet is a set of contents that failed verification and is safe for concurrent use.
+type corruptContentSet struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	ids map[content.ID]bool
+}
+
+func (s *corruptContentSet) add(cid content.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ids == nil {
+		s.ids = map[content.ID]bool{}
+	}
+
+	s.ids[cid] = true
+}
+
+func (s *corruptContentSet) contains(cid content.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ids[cid]
+}
+
+func (s *corruptContentSet) size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.ids)
+}
+
+// traceSnapshots walks all snapshots and reports the ones with objects that resolve to any of the corrupt contents.
+func (c *commandContentVerify) traceSnapshots(ctx context.Context, rep repo.Repository, corrupt *corruptContentSet) error {
+	ids, err := snapshot.ListSnapshotManifests(ctx, rep, nil, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to list snapshot manifest IDs")
+	}
+
+	manifests, err := snapshot.LoadSnapshots(ctx, rep, ids)
+	if err != nil {
+		return errors.Wrap(err, "unable to load snapshot manifests")
+	}
+
+	log(ctx).Infof("Looking for snapshots referencing %v corrupt contents...", corrupt.size())
+
+	for _, m := range manifests {
+		if err := c.traceSnapshot(ctx, rep, m, corrupt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *commandContentVerify) traceSnapshot(ctx context.Context, rep repo.Repository, m *snapshot.Manifest, corrupt *corruptContentSet) error {
+	// each snapshot is walked separately, because the walker skips objects it has already seen
+	// and shared objects must be reported for every snapshot.
+	w, err := snapshotfs.NewTreeWalker(snapshotfs.TreeWalkerOptions{
+		EntryCallback: func(ctx context.Context, entry fs.Entry, oid object.ID, entryPath string) error {
+			contentIDs, err := rep.VerifyObject(ctx, oid)
+			if err != nil {
+				return errors.Wrapf(err, "error verifying %v", oid)
+			}
+
+			for _, cid := range contentIDs {
+				if corrupt.contains(cid) {
+					c.reportSnapshotReference(ctx, m, entryPath, cid)
+				}
+			}
+
+			return nil
+		},
+		// keep walking after directories stored in corrupt contents fail to load.
+		MaxErrors: -1,
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to initialize tree walker")
+	}
+
+	defer w.Close()
+
+	root, err := snapshotfs.SnapshotRoot(rep, m)
+	if err != nil {
+		return errors.Wrap(err, "unable to get snapshot root")
+	}
+
+	if err := w.Process(ctx, root, ""); err != nil {
+		log(ctx).Debugf("errors walking snapshot %v: %v", m.ID, err)
+	}
+
+	return nil
+}
+
+func (c *commandContentVerify) reportSnapshotReference(ctx context.Context, m *snapshot.Manifest, entryPath string, cid content.ID) {
+	if entryPath == "" {
+		entryPath = "."
+	}
+
+	log(ctx).Warnf("Snapshot %v at %v references corrupt content %v in %v", m.Source, formatTimestamp(m.StartTime), cid, entryPath)
+
+	if c.jo.jsonOutput {
+		c.emitJSON(&contentVerifySnapshotReference{
+			Type:      "snapshotReference",
+			Source:    m.Source,
+			StartTime: formatTimestamp(m.StartTime),
+			Path:      entryPath,
+			ContentID: cid,
+		})
+	}
+}
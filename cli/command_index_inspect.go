@@ -18,8 +18,9 @@ type commandIndexInspect struct {
 	active  bool
 	blobIDs []string
 
-	contentIDs []string
-	parallel   int
+	contentIDs      []string
+	parallel        int
+	verifyStructure bool
 
 	out textOutput
 }
@@ -30,6 +31,7 @@ func (c *commandIndexInspect) setup(svc appServices, parent commandParent) {
 	cmd.Flag("active", "Inspect all active index blobs").BoolVar(&c.active)
 	cmd.Flag("content-id", "Inspect all active index blobs").StringsVar(&c.contentIDs)
 	cmd.Flag("parallel", "Parallelism").Default("8").IntVar(&c.parallel)
+	cmd.Flag("verify-structure", "Perform deep verification of the structure of each index blob").BoolVar(&c.verifyStructure)
 	cmd.Arg("blobs", "Names of index blobs to inspect").StringsVar(&c.blobIDs)
 	cmd.Action(svc.directRepositoryReadAction(c.run))
 
@@ -162,6 +164,12 @@ func (c *commandIndexInspect) inspectSingleIndexBlob(ctx context.Context, rep re
 		return errors.Wrapf(err, "unable to get data for %v", blobID)
 	}
 
+	if c.verifyStructure {
+		if err := content.VerifyIndexBlobStructure(ctx, blobID, data.Bytes(), rep.Crypter()); err != nil {
+			return errors.Wrapf(err, "index blob %v is corrupt", blobID)
+		}
+	}
+
 	entries, err := content.ParseIndexBlob(ctx, blobID, data.Bytes(), rep.Crypter())
 	if err != nil {
 		return errors.Wrapf(err, "unable to recover index from %v", blobID)
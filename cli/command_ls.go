@@ -72,6 +72,10 @@ func (c *commandList) listDirectory(ctx context.Context, d fs.Directory, prefix,
 			for _, e := range ds.FailedEntries {
 				errorColor.Fprintf(c.out.stderr(), "- Error in \"%v\": %v\n", e.EntryPath, e.Error) //nolint:errcheck
 			}
+
+			if n := ds.TruncatedFailedEntryCount; n > 0 {
+				errorColor.Fprintf(c.out.stderr(), "- ... and %v more errors not recorded in the summary\n", n) //nolint:errcheck
+			}
 		}
 	}
 
@@ -5,6 +5,7 @@
 
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/snapshot/snapshotgc"
 	"github.com/kopia/kopia/snapshot/snapshotmaintenance"
 )
 
@@ -12,12 +13,15 @@ type commandMaintenanceRun struct {
 	maintenanceRunFull  bool
 	maintenanceRunForce bool
 	safety              maintenance.SafetyParameters
+
+	gcSnapshotParallelism int
 }
 
 func (c *commandMaintenanceRun) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("run", "Run repository maintenance").Default()
 	cmd.Flag("full", "Full maintenance").BoolVar(&c.maintenanceRunFull)
 	cmd.Flag("force", "Run maintenance even if not owned (unsafe)").Hidden().BoolVar(&c.maintenanceRunForce)
+	cmd.Flag("gc-snapshot-parallel", "Number of snapshots walked concurrently during snapshot GC").Default("1").IntVar(&c.gcSnapshotParallelism)
 	safetyFlagVar(cmd, &c.safety)
 
 	cmd.Action(svc.directRepositoryWriteAction(c.run))
@@ -32,5 +36,8 @@ func (c *commandMaintenanceRun) run(ctx context.Context, rep repo.DirectReposito
 	}
 
 	// nolint:wrapcheck
-	return snapshotmaintenance.Run(ctx, rep, mode, c.maintenanceRunForce, c.safety)
+	return snapshotmaintenance.RunWithOptions(ctx, rep, mode, c.maintenanceRunForce, c.safety, snapshotgc.Options{
+		SnapshotParallelism: c.gcSnapshotParallelism,
+		Progress:            snapshotGCProgress(ctx),
+	})
 }
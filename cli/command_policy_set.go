@@ -201,6 +201,40 @@ func applyOptionalInt(ctx context.Context, desc string, val **policy.OptionalInt
 	return nil
 }
 
+func applyOptionalFloat64(ctx context.Context, desc string, val **policy.OptionalFloat64, str string, minValue, maxValue float64, changeCount *int) error {
+	if str == "" {
+		// not changed
+		return nil
+	}
+
+	if str == inheritPolicyString || str == defaultPolicyString {
+		*changeCount++
+
+		log(ctx).Infof(" - resetting %q to a default value inherited from parent.", desc)
+
+		*val = nil
+
+		return nil
+	}
+
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return errors.Wrapf(err, "can't parse the %v %q", desc, str)
+	}
+
+	if v < minValue || v > maxValue {
+		return errors.Errorf("%v must be between %v and %v, got %v", desc, minValue, maxValue, str)
+	}
+
+	f := policy.OptionalFloat64(v)
+	*changeCount++
+
+	log(ctx).Infof(" - setting %q to %v.", desc, f)
+	*val = &f
+
+	return nil
+}
+
 func applyPolicyNumber64(ctx context.Context, desc string, val *int64, str string, changeCount *int) error {
 	if str == "" {
 		// not changed
@@ -5,17 +5,24 @@
 
 	"github.com/alecthomas/kingpin"
 
+	"github.com/kopia/kopia/repo/splitter"
 	"github.com/kopia/kopia/snapshot/policy"
 )
 
 type policyUploadFlags struct {
 	maxParallelUploads   string
 	maxParallelFileReads string
+	forceHashPercentage  string
+	splitter             string
+	symlinks             string
 }
 
 func (c *policyUploadFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("max-parallel-file-reads", "Maximum number of parallel file reads").StringVar(&c.maxParallelFileReads)
 	cmd.Flag("max-parallel-snapshots", "Maximum number of parallel snapshots (server, KopiaUI only)").StringVar(&c.maxParallelUploads)
+	cmd.Flag("force-hash-percentage", "Percentage of cached files to hash again [0.0 .. 100.0]").StringVar(&c.forceHashPercentage)
+	cmd.Flag("splitter", "Splitter used to break files into chunks, which determines the chunk size").EnumVar(&c.splitter, append([]string{inheritPolicyString}, splitter.SupportedAlgorithms()...)...)
+	cmd.Flag("symlinks", "Store symbolic links or follow them and store their targets").EnumVar(&c.symlinks, inheritPolicyString, policy.SymlinksStore, policy.SymlinksFollow)
 }
 
 func (c *policyUploadFlags) setUploadPolicyFromFlags(ctx context.Context, up *policy.UploadPolicy, changeCount *int) error {
@@ -27,5 +34,37 @@ func (c *policyUploadFlags) setUploadPolicyFromFlags(ctx context.Context, up *po
 		return err
 	}
 
+	if err := applyOptionalFloat64(ctx, "force hash percentage", &up.ForceHashPercentage, c.forceHashPercentage, 0, 100, changeCount); err != nil {
+		return err
+	}
+
+	if v := c.splitter; v != "" {
+		*changeCount++
+
+		if v == inheritPolicyString {
+			log(ctx).Infof(" - resetting splitter to default value inherited from parent")
+
+			up.Splitter = ""
+		} else {
+			log(ctx).Infof(" - setting splitter to %v", v)
+
+			up.Splitter = v
+		}
+	}
+
+	if v := c.symlinks; v != "" {
+		*changeCount++
+
+		if v == inheritPolicyString {
+			log(ctx).Infof(" - resetting symlink mode to default value inherited from parent")
+
+			up.Symlinks = ""
+		} else {
+			log(ctx).Infof(" - setting symlink mode to %v", v)
+
+			up.Symlinks = v
+		}
+	}
+
 	return nil
 }
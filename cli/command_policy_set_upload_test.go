@@ -43,4 +43,42 @@ func TestSetUploadPolicy(t *testing.T) {
 
 	require.Contains(t, lines, " Max parallel snapshots (server/UI): 1 inherited from (global)")
 	require.Contains(t, lines, " Max parallel file reads: - inherited from (global)")
+
+	require.Contains(t, lines, " Splitter: (repository default) inherited from (global)")
+
+	e.RunAndExpectSuccess(t, "policy", "set", td, "--force-hash-percentage=12.5")
+
+	lines = e.RunAndExpectSuccess(t, "policy", "show", td)
+	lines = compressSpaces(lines)
+
+	require.Contains(t, lines, " Force hash percentage: 12.5% (defined for this target)")
+
+	e.RunAndExpectFailure(t, "policy", "set", td, "--force-hash-percentage=-1")
+	e.RunAndExpectFailure(t, "policy", "set", td, "--force-hash-percentage=100.1")
+
+	e.RunAndExpectSuccess(t, "policy", "set", td, "--splitter=FIXED-8M")
+
+	lines = e.RunAndExpectSuccess(t, "policy", "show", td)
+	lines = compressSpaces(lines)
+
+	require.Contains(t, lines, " Splitter: FIXED-8M (defined for this target)")
+
+	e.RunAndExpectFailure(t, "policy", "set", td, "--splitter=NO-SUCH-SPLITTER")
+
+	e.RunAndExpectSuccess(t, "policy", "set", td, "--splitter=inherit")
+
+	lines = e.RunAndExpectSuccess(t, "policy", "show", td)
+	lines = compressSpaces(lines)
+
+	require.Contains(t, lines, " Splitter: (repository default) inherited from (global)")
+	require.Contains(t, lines, " Symlinks: store inherited from (global)")
+
+	e.RunAndExpectSuccess(t, "policy", "set", td, "--symlinks=follow")
+
+	lines = e.RunAndExpectSuccess(t, "policy", "show", td)
+	lines = compressSpaces(lines)
+
+	require.Contains(t, lines, " Symlinks: follow (defined for this target)")
+
+	e.RunAndExpectFailure(t, "policy", "set", td, "--symlinks=no-such-mode")
 }
@@ -269,9 +269,28 @@ func appendUploadPolicyRows(rows []policyTableRow, p *policy.Policy, def *policy
 		policyTableRow{"Uploads:", "", ""},
 		policyTableRow{"  Max parallel snapshots (server/UI):", valueOrNotSet(p.UploadPolicy.MaxParallelSnapshots), definitionPointToString(p.Target(), def.UploadPolicy.MaxParallelSnapshots)},
 		policyTableRow{"  Max parallel file reads:", valueOrNotSet(p.UploadPolicy.MaxParallelFileReads), definitionPointToString(p.Target(), def.UploadPolicy.MaxParallelFileReads)},
+		policyTableRow{"  Force hash percentage:", floatValueOrNotSet(p.UploadPolicy.ForceHashPercentage), definitionPointToString(p.Target(), def.UploadPolicy.ForceHashPercentage)},
+		policyTableRow{"  Splitter:", splitterOrRepositoryDefault(p.UploadPolicy.Splitter), definitionPointToString(p.Target(), def.UploadPolicy.Splitter)},
+		policyTableRow{"  Symlinks:", symlinkModeOrDefault(p.UploadPolicy.Symlinks), definitionPointToString(p.Target(), def.UploadPolicy.Symlinks)},
 	)
 }
 
+func splitterOrRepositoryDefault(v string) string {
+	if v == "" {
+		return "(repository default)"
+	}
+
+	return v
+}
+
+func symlinkModeOrDefault(v string) string {
+	if v == "" {
+		return policy.SymlinksStore
+	}
+
+	return v
+}
+
 func appendSchedulingPolicyRows(rows []policyTableRow, p *policy.Policy, def *policy.Definition) []policyTableRow {
 	rows = append(rows, policyTableRow{"Scheduling policy:", "", ""})
 
@@ -434,3 +453,11 @@ func valueOrNotSet(p *policy.OptionalInt) string {
 
 	return fmt.Sprintf("%v", *p)
 }
+
+func floatValueOrNotSet(p *policy.OptionalFloat64) string {
+	if p == nil {
+		return "-"
+	}
+
+	return fmt.Sprintf("%v%%", *p)
+}
@@ -111,9 +111,17 @@ func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository)
 	c.out.printStdout("Storage type:        %v\n", ci.Type)
 
 	switch cp, err := dr.BlobVolume().GetCapacity(ctx); {
+	case err == nil && cp.SizeB == 0:
+		// usage of storage without a fixed size, such as a bucket without a quota.
+		c.out.printStdout("Storage capacity:    unbounded\n")
+		c.out.printStdout("Storage used:        %v\n", units.BytesStringBase10(int64(cp.UsedB)))
 	case err == nil:
 		c.out.printStdout("Storage capacity:    %v\n", units.BytesStringBase10(int64(cp.SizeB)))
 		c.out.printStdout("Storage available:   %v\n", units.BytesStringBase10(int64(cp.FreeB)))
+
+		if cp.UsedB != 0 {
+			c.out.printStdout("Storage used:        %v\n", units.BytesStringBase10(int64(cp.UsedB)))
+		}
 	case errors.Is(err, blob.ErrNotAVolume):
 		c.out.printStdout("Storage capacity:    unbounded\n")
 	default:
@@ -5,6 +5,7 @@
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,6 +13,7 @@
 
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
@@ -37,8 +39,19 @@ type commandSnapshotCreate struct {
 	snapshotCreateForceDisableActions     bool
 	snapshotCreateStdinFileName           string
 	snapshotCreateCheckpointUploadLimitMB int64
+	snapshotCreateMaxUploadSpeedKB        int64
+	snapshotCreateFileReadRetries         int
+	snapshotCreateFileReadRetryInterval   time.Duration
+	snapshotCreateSkipSpecialFiles        bool
+	snapshotCreateMaxFailedEntriesPerDir  int
+	snapshotCreateAbortOnSourceIOError    bool
+	snapshotCreateMaxErrors               int
 	snapshotCreateTags                    []string
 	flushPerSource                        bool
+	recordUploadReasons                   bool
+	attachObjectMetadata                  bool
+	hashCacheFile                         string
+	jsonSummary                           bool
 
 	pins []string
 
@@ -56,10 +69,17 @@ func (c *commandSnapshotCreate) setup(svc appServices, parent commandParent) {
 	cmd.Arg("source", "Files or directories to create snapshot(s) of.").StringsVar(&c.snapshotCreateSources)
 	cmd.Flag("all", "Create snapshots for files or directories previously backed up by this user on this computer").BoolVar(&c.snapshotCreateAll)
 	cmd.Flag("upload-limit-mb", "Stop the backup process after the specified amount of data (in MB) has been uploaded.").PlaceHolder("MB").Default("0").Int64Var(&c.snapshotCreateCheckpointUploadLimitMB)
+	cmd.Flag("max-upload-speed-kb", "Limit the rate at which data is uploaded (in KB per second).").PlaceHolder("KB").Default("0").Int64Var(&c.snapshotCreateMaxUploadSpeedKB)
 	cmd.Flag("checkpoint-interval", "Frequency for creating periodic checkpoint.").DurationVar(&c.snapshotCreateCheckpointInterval)
 	cmd.Flag("description", "Free-form snapshot description.").StringVar(&c.snapshotCreateDescription)
 	cmd.Flag("fail-fast", "Fail fast when creating snapshot.").Envar("KOPIA_SNAPSHOT_FAIL_FAST").BoolVar(&c.snapshotCreateFailFast)
 	cmd.Flag("force-hash", "Force hashing of source files for a given percentage of files [0.0 .. 100.0]").Default("0").Float64Var(&c.snapshotCreateForceHash)
+	cmd.Flag("file-read-retries", "Number of times to retry reading a file after a transient error").Default("0").IntVar(&c.snapshotCreateFileReadRetries)
+	cmd.Flag("file-read-retry-interval", "Delay before the first retry of a file read, doubled after each attempt").Default(snapshotfs.DefaultFileReadRetryInterval.String()).DurationVar(&c.snapshotCreateFileReadRetryInterval)
+	cmd.Flag("skip-special-files", "Skip sockets, named pipes and devices without reporting errors").BoolVar(&c.snapshotCreateSkipSpecialFiles)
+	cmd.Flag("max-failed-entries-per-dir", "Maximum number of failed entries recorded in each directory summary").Default(strconv.Itoa(fs.MaxFailedEntriesPerDirectorySummary)).IntVar(&c.snapshotCreateMaxFailedEntriesPerDir)
+	cmd.Flag("abort-on-source-io-error", "Abort the snapshot when the source runs out of space or fails with I/O errors").BoolVar(&c.snapshotCreateAbortOnSourceIOError)
+	cmd.Flag("max-errors", "Abort the snapshot when the number of errors exceeds the provided value (0=unlimited)").Default("0").IntVar(&c.snapshotCreateMaxErrors)
 	cmd.Flag("parallel", "Upload N files in parallel").PlaceHolder("N").Default("0").IntVar(&c.snapshotCreateParallelUploads)
 	cmd.Flag("start-time", "Override snapshot start timestamp.").StringVar(&c.snapshotCreateStartTime)
 	cmd.Flag("end-time", "Override snapshot end timestamp.").StringVar(&c.snapshotCreateEndTime)
@@ -69,6 +89,10 @@ func (c *commandSnapshotCreate) setup(svc appServices, parent commandParent) {
 	cmd.Flag("tags", "Tags applied on the snapshot. Must be provided in the <key>:<value> format.").StringsVar(&c.snapshotCreateTags)
 	cmd.Flag("pin", "Create a pinned snapshot that's will not expire automatically").StringsVar(&c.pins)
 	cmd.Flag("flush-per-source", "Flush writes at the end of each source").Hidden().BoolVar(&c.flushPerSource)
+	cmd.Flag("hash-cache", "Remember object IDs of uploaded files in the provided local file and reuse them for unchanged files").StringVar(&c.hashCacheFile)
+	cmd.Flag("json-summary", "Print a machine-readable summary of each snapshot to stdout").BoolVar(&c.jsonSummary)
+	cmd.Flag("log-upload-reasons", "Log the reason why each file was uploaded instead of being cached").Hidden().BoolVar(&c.recordUploadReasons)
+	cmd.Flag("attach-object-metadata", "Record the source, path and type of each written object in the repository for diagnostics").Hidden().BoolVar(&c.attachObjectMetadata)
 
 	c.logDirDetail = -1
 	c.logEntryDetail = -1
@@ -114,6 +138,18 @@ func (c *commandSnapshotCreate) run(ctx context.Context, rep repo.RepositoryWrit
 
 	u := c.setupUploader(rep)
 
+	var hashCache *snapshotfs.LocalHashCache
+
+	if c.hashCacheFile != "" {
+		hc, err := snapshotfs.OpenLocalHashCache(c.hashCacheFile)
+		if err != nil {
+			return errors.Wrap(err, "unable to open hash cache")
+		}
+
+		hashCache = hc
+		u.HashCache = hc
+	}
+
 	var finalErrors []string
 
 	tags, err := getTags(c.snapshotCreateTags)
@@ -153,6 +189,13 @@ func (c *commandSnapshotCreate) run(ctx context.Context, rep repo.RepositoryWrit
 		}
 	}
 
+	// hash cache is only saved after the objects it refers to have been flushed.
+	if hashCache != nil {
+		if serr := hashCache.Save(); serr != nil {
+			log(ctx).Warnf("unable to save hash cache: %v", serr)
+		}
+	}
+
 	if len(finalErrors) == 0 {
 		return nil
 	}
@@ -208,7 +251,8 @@ func validateStartEndTime(st, et string) error {
 
 func (c *commandSnapshotCreate) setupUploader(rep repo.RepositoryWriter) *snapshotfs.Uploader {
 	u := snapshotfs.NewUploader(rep)
-	u.MaxUploadBytes = c.snapshotCreateCheckpointUploadLimitMB << 20 //nolint:gomnd
+	u.MaxUploadBytes = c.snapshotCreateCheckpointUploadLimitMB << 20   //nolint:gomnd
+	u.MaxUploadBytesPerSecond = c.snapshotCreateMaxUploadSpeedKB << 10 //nolint:gomnd
 
 	if c.snapshotCreateForceEnableActions {
 		u.EnableActions = true
@@ -240,6 +284,14 @@ func (c *commandSnapshotCreate) setupUploader(rep repo.RepositoryWriter) *snapsh
 	u.ParallelUploads = c.snapshotCreateParallelUploads
 
 	u.FailFast = c.snapshotCreateFailFast
+	u.FileReadRetries = c.snapshotCreateFileReadRetries
+	u.FileReadRetryInterval = c.snapshotCreateFileReadRetryInterval
+	u.SkipSpecialFiles = c.snapshotCreateSkipSpecialFiles
+	u.MaxFailedEntriesPerDir = c.snapshotCreateMaxFailedEntriesPerDir
+	u.AbortOnSourceIOError = c.snapshotCreateAbortOnSourceIOError
+	u.MaxErrors = c.snapshotCreateMaxErrors
+	u.RecordUploadReasons = c.recordUploadReasons
+	u.AttachObjectMetadata = c.attachObjectMetadata
 	u.Progress = c.svc.getProgress()
 
 	return u
@@ -329,10 +381,17 @@ func (c *commandSnapshotCreate) snapshotSingleSource(ctx context.Context, rep re
 		manifest.EndTime = endTimeOverride
 	}
 
-	if _, err = snapshot.SaveSnapshot(ctx, rep, manifest); err != nil {
+	snapID, err := snapshot.SaveSnapshot(ctx, rep, manifest)
+	if err != nil {
 		return errors.Wrap(err, "cannot save manifest")
 	}
 
+	if c.attachObjectMetadata {
+		if _, err = snapshotfs.SaveObjectMetadata(ctx, rep, snapID, u.WrittenObjectMetadata()); err != nil {
+			return errors.Wrap(err, "cannot save object metadata")
+		}
+	}
+
 	if _, err = policy.ApplyRetentionPolicy(ctx, rep, sourceInfo, true); err != nil {
 		return errors.Wrap(err, "unable to apply retention policy")
 	}
@@ -369,7 +428,22 @@ func (c *commandSnapshotCreate) reportSnapshotStatus(ctx context.Context, manife
 		return nil
 	}
 
+	if c.jsonSummary {
+		b, err := snapshotfs.ManifestSummaryJSON(manifest)
+		if err != nil {
+			return errors.Wrap(err, "unable to produce snapshot summary")
+		}
+
+		c.out.printStdout("%s\n", b)
+	}
+
 	log(ctx).Infof("Created%v snapshot with root %v and ID %v in %v", maybePartial, manifest.RootObjectID(), snapID, manifest.EndTime.Sub(manifest.StartTime).Truncate(time.Second))
+	if u := manifest.Stats.UnknownContentBytes; u > 0 {
+		// repository server does not report whether contents were deduplicated.
+		log(ctx).Infof("Uploaded %v logical, at least %v new, %v possibly deduplicated by the server", units.BytesStringBase10(manifest.Stats.TotalFileSize), units.BytesStringBase10(manifest.Stats.NewContentBytes), units.BytesStringBase10(u))
+	} else {
+		log(ctx).Infof("Uploaded %v logical, %v new", units.BytesStringBase10(manifest.Stats.TotalFileSize), units.BytesStringBase10(manifest.Stats.NewContentBytes))
+	}
 
 	if ds := manifest.RootEntry.DirSummary; ds != nil {
 		if ds.IgnoredErrorCount > 0 {
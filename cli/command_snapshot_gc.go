@@ -1,35 +1,185 @@
 package cli
 
 import (
+	"bufio"
 	"context"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/timetrack"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/snapshotgc"
 )
 
+// snapshotGCProgressInterval is the interval between progress reports of snapshot GC.
+const snapshotGCProgressInterval = 5 * time.Second
+
 type commandSnapshotGC struct {
 	snapshotGCDelete bool
 	snapshotGCSafety maintenance.SafetyParameters
+
+	inUseSetDirectory      string
+	inUseSetMaxBufferedIDs int
+	bloomFilterMB          int
+	bloomFilterOnly        bool
+	snapshotParallelism    int
+	reportFile             string
+	findOrphanedBlobs      bool
+	deleteOrphanedBlobs    bool
+	excludeSources         []string
+	exportInUseFile        string
+	maxDeleteCount         int64
+	maxDeleteBytes         int64
+	verifyContents         bool
 }
 
 func (c *commandSnapshotGC) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("gc", "Mark contents as deleted which are not used by any snapshot").Hidden()
 	cmd.Flag("delete", "Delete unreferenced contents").BoolVar(&c.snapshotGCDelete)
+	cmd.Flag("in-use-set-dir", "Store the set of in-use contents in the provided directory instead of memory").StringVar(&c.inUseSetDirectory)
+	cmd.Flag("in-use-set-max-buffered", "Maximum number of in-use content IDs buffered in memory before writing to disk").IntVar(&c.inUseSetMaxBufferedIDs)
+	cmd.Flag("bloom-filter-mb", "Size of the bloom filter of in-use contents in MB").IntVar(&c.bloomFilterMB)
+	cmd.Flag("bloom-filter-only", "Only use bloom filter to find in-use contents, some unused contents may not be collected").BoolVar(&c.bloomFilterOnly)
+	cmd.Flag("snapshot-parallel", "Number of snapshots walked concurrently").Default("1").IntVar(&c.snapshotParallelism)
+	cmd.Flag("report", "Write unreferenced contents as JSON lines to the provided file, without '--delete' GC succeeds as a dry run").StringVar(&c.reportFile)
+	cmd.Flag("find-orphaned-blobs", "Find pack blobs containing only deleted contents").BoolVar(&c.findOrphanedBlobs)
+	cmd.Flag("delete-orphaned-blobs", "Delete pack blobs containing only deleted contents (requires --delete)").BoolVar(&c.deleteOrphanedBlobs)
+	cmd.Flag("exclude-source", "Ignore snapshots of the provided source when looking for in-use contents (dangerous)").StringsVar(&c.excludeSources)
+	cmd.Flag("export-in-use", "Only find in-use contents and write their sorted IDs to the provided file, without deleting anything").StringVar(&c.exportInUseFile)
+	cmd.Flag("max-delete-count", "Maximum number of unused contents deleted in a single run, the rest is left for subsequent runs").Int64Var(&c.maxDeleteCount)
+	cmd.Flag("max-delete-bytes", "Maximum total size of unused contents deleted in a single run, the rest is left for subsequent runs").Int64Var(&c.maxDeleteBytes)
+	cmd.Flag("verify-contents", "Read all files in snapshots entirely to verify that their data can be retrieved (slow)").BoolVar(&c.verifyContents)
 	safetyFlagVar(cmd, &c.snapshotGCSafety)
 	cmd.Action(svc.directRepositoryWriteAction(c.run))
 }
 
 func (c *commandSnapshotGC) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
-	st, err := snapshotgc.Run(ctx, rep, c.snapshotGCDelete, c.snapshotGCSafety)
+	opts := snapshotgc.Options{
+		InUseSetDirectory:      c.inUseSetDirectory,
+		InUseSetMaxBufferedIDs: c.inUseSetMaxBufferedIDs,
+		BloomFilterBytes:       c.bloomFilterMB << 20, //nolint:gomnd
+		BloomFilterOnly:        c.bloomFilterOnly,
+		SnapshotParallelism:    c.snapshotParallelism,
+		Progress:               snapshotGCProgress(ctx),
+		FindOrphanedBlobs:      c.findOrphanedBlobs || c.deleteOrphanedBlobs,
+		DeleteOrphanedBlobs:    c.deleteOrphanedBlobs,
+		MaxDeleteCount:         c.maxDeleteCount,
+		MaxDeleteBytes:         c.maxDeleteBytes,
+		VerifyContents:         c.verifyContents,
+	}
+
+	for _, src := range c.excludeSources {
+		si, err := snapshot.ParseSourceInfo(src, rep.ClientOptions().Hostname, rep.ClientOptions().Username)
+		if err != nil {
+			return errors.Wrapf(err, "invalid source %v", src)
+		}
+
+		opts.ExcludeSources = append(opts.ExcludeSources, si)
+	}
+
+	if c.exportInUseFile != "" {
+		return c.exportInUse(ctx, rep, opts)
+	}
+
+	var report *bufio.Writer
+
+	if c.reportFile != "" {
+		f, err := os.Create(c.reportFile)
+		if err != nil {
+			return errors.Wrap(err, "unable to create report file")
+		}
+
+		defer f.Close() //nolint:errcheck,gosec
+
+		report = bufio.NewWriter(f)
+		opts.Report = report
+	}
+
+	st, err := snapshotgc.RunWithOptions(ctx, rep, c.snapshotGCDelete, c.snapshotGCSafety, opts)
+
+	if report != nil {
+		if ferr := report.Flush(); ferr != nil && err == nil {
+			err = errors.Wrap(ferr, "error writing report file")
+		}
+	}
 
 	log(ctx).Infof("GC found %v unused contents (%v bytes)", st.UnusedCount, units.BytesStringBase2(st.UnusedBytes))
 	log(ctx).Infof("GC found %v unused contents that are too recent to delete (%v bytes)", st.TooRecentCount, units.BytesStringBase2(st.TooRecentBytes))
 	log(ctx).Infof("GC found %v in-use contents (%v bytes)", st.InUseCount, units.BytesStringBase2(st.InUseBytes))
 	log(ctx).Infof("GC found %v in-use system-contents (%v bytes)", st.SystemCount, units.BytesStringBase2(st.SystemBytes))
 
+	if st.RemainingCount > 0 {
+		log(ctx).Infof("GC reached delete limit at content %v, %v unused contents (%v bytes) remain to be deleted by subsequent runs",
+			st.DeleteLimitStoppedAt, st.RemainingCount, units.BytesStringBase2(st.RemainingBytes))
+	}
+
+	if opts.FindOrphanedBlobs {
+		log(ctx).Infof("GC found %v orphaned pack blobs (%v bytes), deleted %v", st.OrphanedBlobCount, units.BytesStringBase2(st.OrphanedBlobBytes), st.DeletedOrphanedBlobCount)
+	}
+
 	return errors.Wrap(err, "error running snapshot GC")
 }
+
+func (c *commandSnapshotGC) exportInUse(ctx context.Context, rep repo.DirectRepositoryWriter, opts snapshotgc.Options) error {
+	f, err := os.Create(c.exportInUseFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to create export file")
+	}
+
+	defer f.Close() //nolint:errcheck,gosec
+
+	opts.ExportInUse = f
+
+	st, err := snapshotgc.RunWithOptions(ctx, rep, false, c.snapshotGCSafety, opts)
+	if err != nil {
+		return errors.Wrap(err, "error exporting in-use contents")
+	}
+
+	log(ctx).Infof("Exported %v in-use contents (%v bytes) to %v", st.InUseCount, units.BytesStringBase2(st.InUseBytes), c.exportInUseFile)
+
+	return errors.Wrap(f.Close(), "error closing export file")
+}
+
+// snapshotGCProgress returns a function that periodically logs progress and estimated completion time of each
+// phase of snapshot GC.
+func snapshotGCProgress(ctx context.Context) snapshotgc.ProgressFunc {
+	var (
+		mu       sync.Mutex
+		phase    snapshotgc.Phase
+		est      timetrack.Estimator
+		throttle timetrack.Throttle
+	)
+
+	return func(p snapshotgc.Phase, completed, total int64) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if p != phase {
+			phase = p
+			est = timetrack.Start()
+			throttle.Reset()
+		}
+
+		if completed != total && !throttle.ShouldOutput(snapshotGCProgressInterval) {
+			return
+		}
+
+		unit := "contents scanned"
+		if p == snapshotgc.PhaseMark {
+			unit = "snapshots walked"
+		}
+
+		if timings, ok := est.Estimate(float64(completed), float64(total)); ok {
+			log(ctx).Infof("  %v of %v %v (%.1f%%), remaining %v, ETA %v",
+				completed, total, unit, timings.PercentComplete, timings.Remaining, formatTimestamp(timings.EstimatedEndTime))
+		} else {
+			log(ctx).Infof("  %v of %v %v", completed, total, unit)
+		}
+	}
+}
@@ -36,6 +36,7 @@ type commandSnapshotVerify struct {
 	verifyCommandSources        []string
 	verifyCommandParallel       int
 	verifyCommandFilesPercent   float64
+	verifyCommandContents       bool
 
 	fileQueueLength int
 	fileParallelism int
@@ -54,6 +55,7 @@ func (c *commandSnapshotVerify) setup(svc appServices, parent commandParent) {
 	cmd.Flag("file-queue-length", "Queue length for file verification").Default("20000").IntVar(&c.fileQueueLength)
 	cmd.Flag("file-parallelism", "Parallelism for file verification").IntVar(&c.fileParallelism)
 	cmd.Flag("verify-files-percent", "Randomly verify a percentage of files by downloading them [0.0 .. 100.0]").Default("0").Float64Var(&c.verifyCommandFilesPercent)
+	cmd.Flag("verify-contents", "Verify all files by downloading them while walking snapshot trees").BoolVar(&c.verifyCommandContents)
 	cmd.Action(svc.repositoryReaderAction(c.run))
 }
 
@@ -159,9 +161,10 @@ func (c *commandSnapshotVerify) run(ctx context.Context, rep repo.Repository) er
 	}
 
 	tw, twerr := snapshotfs.NewTreeWalker(snapshotfs.TreeWalkerOptions{
-		Parallelism:   c.verifyCommandParallel,
-		EntryCallback: v.doVerifyObject,
-		MaxErrors:     c.verifyCommandErrorThreshold,
+		Parallelism:    c.verifyCommandParallel,
+		EntryCallback:  v.doVerifyObject,
+		MaxErrors:      c.verifyCommandErrorThreshold,
+		VerifyContents: c.verifyCommandContents,
 	})
 	if twerr != nil {
 		return errors.Wrap(twerr, "unable to initialize tree walker")
@@ -61,8 +61,9 @@ func (c *App) openRepository(ctx context.Context, required bool) (repo.Repositor
 
 func (c *App) optionsFromFlags(ctx context.Context) *repo.Options {
 	return &repo.Options{
-		TraceStorage:       c.traceStorage,
-		DisableInternalLog: c.disableInternalLog,
+		TraceStorage:         c.traceStorage,
+		DisableInternalLog:   c.disableInternalLog,
+		OnIndexFetchProgress: c.progress.FetchedIndexBlobs,
 	}
 }
 
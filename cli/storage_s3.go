@@ -2,6 +2,8 @@
 
 import (
 	"context"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/alecthomas/kingpin"
@@ -12,19 +14,44 @@
 )
 
 type storageS3Flags struct {
-	s3options s3.Options
+	s3options    s3.Options
+	tags         []string
+	httpTimeouts s3.HTTPTimeouts
+
+	embedRootCA bool
 }
 
 func (c *storageS3Flags) Setup(_ StorageProviderServices, cmd *kingpin.CmdClause) {
 	cmd.Flag("bucket", "Name of the S3 bucket").Required().StringVar(&c.s3options.BucketName)
-	cmd.Flag("endpoint", "Endpoint to use").Default("s3.amazonaws.com").StringVar(&c.s3options.Endpoint)
+	cmd.Flag("endpoint", "Endpoint to use, either host[:port] or URL with optional base path, such as https://host/s3/").Default("s3.amazonaws.com").StringVar(&c.s3options.Endpoint)
 	cmd.Flag("region", "S3 Region").Default("").StringVar(&c.s3options.Region)
-	cmd.Flag("access-key", "Access key ID (overrides AWS_ACCESS_KEY_ID environment variable)").Required().Envar("AWS_ACCESS_KEY_ID").StringVar(&c.s3options.AccessKeyID)
-	cmd.Flag("secret-access-key", "Secret access key (overrides AWS_SECRET_ACCESS_KEY environment variable)").Required().Envar("AWS_SECRET_ACCESS_KEY").StringVar(&c.s3options.SecretAccessKey)
+	cmd.Flag("credential-provider", "Source of credentials (static, iam, web-identity or env)").Default(s3.CredentialProviderStatic).EnumVar(&c.s3options.CredentialProvider,
+		s3.CredentialProviderStatic, s3.CredentialProviderIAM, s3.CredentialProviderWebIdentity, s3.CredentialProviderEnv)
+	cmd.Flag("access-key", "Access key ID (overrides AWS_ACCESS_KEY_ID environment variable)").Envar("AWS_ACCESS_KEY_ID").StringVar(&c.s3options.AccessKeyID)
+	cmd.Flag("secret-access-key", "Secret access key (overrides AWS_SECRET_ACCESS_KEY environment variable)").Envar("AWS_SECRET_ACCESS_KEY").StringVar(&c.s3options.SecretAccessKey)
+	cmd.Flag("anonymous", "Access public bucket without credentials, which makes the storage read-only").BoolVar(&c.s3options.Anonymous)
 	cmd.Flag("session-token", "Session token (overrides AWS_SESSION_TOKEN environment variable)").Envar("AWS_SESSION_TOKEN").StringVar(&c.s3options.SessionToken)
 	cmd.Flag("prefix", "Prefix to use for objects in the bucket").StringVar(&c.s3options.Prefix)
 	cmd.Flag("disable-tls", "Disable TLS security (HTTPS)").BoolVar(&c.s3options.DoNotUseTLS)
+	cmd.Flag("use-transfer-acceleration", "Use S3 Transfer Acceleration endpoint (must be enabled for the bucket)").BoolVar(&c.s3options.UseTransferAcceleration)
 	cmd.Flag("disable-tls-verification", "Disable TLS (HTTPS) certificate verification").BoolVar(&c.s3options.DoNotVerifyTLS)
+	cmd.Flag("root-ca-pem-path", "Use root certificates from the provided PEM file to verify the endpoint").ExistingFileVar(&c.s3options.RootCAPEMFile)
+	cmd.Flag("embed-root-ca", "Embed root certificates in Kopia configuration").BoolVar(&c.embedRootCA)
+	cmd.Flag("server-side-encryption", "Server-side encryption of stored objects (AES256 or aws:kms)").EnumVar(&c.s3options.ServerSideEncryption, "AES256", "aws:kms")
+	cmd.Flag("kms-key-id", "ID of the KMS key used with aws:kms server-side encryption").StringVar(&c.s3options.KMSKeyID)
+	cmd.Flag("tag", "Tag applied to all stored objects (key=value)").StringsVar(&c.tags)
+
+	cmd.Flag("dial-timeout", "Timeout of establishing connections to the endpoint").DurationVar(&c.httpTimeouts.DialTimeout)
+	cmd.Flag("tls-handshake-timeout", "Timeout of TLS handshakes with the endpoint").DurationVar(&c.httpTimeouts.TLSHandshakeTimeout)
+	cmd.Flag("response-header-timeout", "Timeout of waiting for response headers from the endpoint").DurationVar(&c.httpTimeouts.ResponseHeaderTimeout)
+	cmd.Flag("idle-connection-timeout", "Timeout after which idle connections to the endpoint are closed").DurationVar(&c.httpTimeouts.IdleConnTimeout)
+
+	cmd.Flag("capacity-quota", "Quota of the bucket reported as its capacity, in bytes, unless the server has one configured").Uint64Var(&c.s3options.CapacityQuotaBytes)
+	cmd.Flag("use-minio-admin-api", "Look up usage and quota of the bucket using the MinIO admin API (MinIO administrators only)").BoolVar(&c.s3options.UseMinioAdminAPI)
+	cmd.Flag("capacity-from-listing", "Compute usage of the bucket by listing all blobs when it's not available from the MinIO admin API (expensive for large buckets)").BoolVar(&c.s3options.CapacityFromListing)
+	cmd.Flag("use-crc32c-checksums", "Make the server verify CRC32C checksums of uploaded blobs and verify them when reading (requires server support)").BoolVar(&c.s3options.UseCRC32CChecksums)
+	cmd.Flag("store-mod-time-in-metadata", "Store modification times of blobs in object metadata, which allows preserving them").BoolVar(&c.s3options.StoreModTimeInMetadata)
+	cmd.Flag("list-parallelism", "Set list parallelism").Hidden().IntVar(&c.s3options.ListParallelism)
 
 	commonThrottlingFlags(cmd, &c.s3options.Limits)
 
@@ -51,6 +78,44 @@ func (c *storageS3Flags) Connect(ctx context.Context, isCreate bool, formatVersi
 		return nil, errors.New("Cannot specify a 'point-in-time' option when creating a repository")
 	}
 
+	if c.s3options.CredentialProvider == s3.CredentialProviderStatic {
+		if c.s3options.AccessKeyID == "" || c.s3options.SecretAccessKey == "" {
+			return nil, errors.New("--access-key and --secret-access-key are required with static credentials")
+		}
+	} else {
+		// credentials are obtained from the provider, don't persist any static ones picked up from the environment.
+		c.s3options.AccessKeyID = ""
+		c.s3options.SecretAccessKey = ""
+		c.s3options.SessionToken = ""
+	}
+
+	if c.embedRootCA && c.s3options.RootCAPEMFile != "" {
+		data, err := os.ReadFile(c.s3options.RootCAPEMFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to open root CA file")
+		}
+
+		c.s3options.RootCAPEM = data
+		c.s3options.RootCAPEMFile = ""
+	}
+
+	if c.httpTimeouts != (s3.HTTPTimeouts{}) {
+		c.s3options.HTTPTimeouts = &c.httpTimeouts
+	}
+
+	for _, t := range c.tags {
+		parts := strings.SplitN(t, "=", 2) // nolint:gomnd
+		if len(parts) != 2 {               // nolint:gomnd
+			return nil, errors.Errorf("invalid tag %q, must be key=value", t)
+		}
+
+		if c.s3options.Tags == nil {
+			c.s3options.Tags = map[string]string{}
+		}
+
+		c.s3options.Tags[parts[0]] = parts[1]
+	}
+
 	// nolint:wrapcheck
 	return s3.New(ctx, &c.s3options)
 }
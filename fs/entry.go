@@ -31,6 +31,10 @@ type OwnerInfo struct {
 type DeviceInfo struct {
 	Dev  uint64 `json:"dev"`
 	Rdev uint64 `json:"rdev"`
+
+	// inode number and number of hard links to the entry, zero if not known.
+	Ino   uint64 `json:"ino,omitempty"`
+	Nlink uint64 `json:"nlink,omitempty"`
 }
 
 // Entries is a list of entries sorted by name.
@@ -68,6 +72,17 @@ type DirectoryWithSummary interface {
 	Summary(ctx context.Context) (*DirectorySummary, error)
 }
 
+// HasXattrs is optionally implemented by entries that can provide their extended attributes.
+type HasXattrs interface {
+	Xattrs(ctx context.Context) (map[string][]byte, error)
+}
+
+// HasCreationTime is optionally implemented by entries that can provide their creation (birth) time.
+// Zero time is returned when the creation time is not known.
+type HasCreationTime interface {
+	CreationTime(ctx context.Context) (time.Time, error)
+}
+
 // ErrorEntry represents entry in a Directory that had encountered an error or is unknown/unsupported (ErrUnknown).
 type ErrorEntry interface {
 	Entry
@@ -94,7 +109,7 @@ func ReadDirAndFindChild(ctx context.Context, d Directory, name string) (Entry,
 	return e, nil
 }
 
-// MaxFailedEntriesPerDirectorySummary is the maximum number of failed entries per directory summary.
+// MaxFailedEntriesPerDirectorySummary is the default maximum number of failed entries per directory summary.
 const MaxFailedEntriesPerDirectorySummary = 10
 
 // EntryWithError describes error encountered when processing an entry.
@@ -106,18 +121,26 @@ type EntryWithError struct {
 // DirectorySummary represents summary information about a directory.
 type DirectorySummary struct {
 	TotalFileSize     int64     `json:"size"`
+	TotalPackedSize   int64     `json:"packedSize,omitempty"`
 	TotalFileCount    int64     `json:"files"`
 	TotalSymlinkCount int64     `json:"symlinks"`
 	TotalDirCount     int64     `json:"dirs"`
 	MaxModTime        time.Time `json:"maxTime"`
 	IncompleteReason  string    `json:"incomplete,omitempty"`
 
+	// number of immediate children of the directory (not including entries in subdirectories),
+	// zero in summaries written by older versions.
+	ImmediateChildCount int64 `json:"children,omitempty"`
+
 	// number of failed files
 	FatalErrorCount   int `json:"numFailed"`
 	IgnoredErrorCount int `json:"numIgnoredErrors,omitempty"`
 
-	// first 10 failed entries
+	// failed entries sorted by path, limited to MaxFailedEntriesPerDirectorySummary by default
 	FailedEntries []*EntryWithError `json:"errors,omitempty"`
+
+	// number of failed entries omitted from FailedEntries because of the limit
+	TruncatedFailedEntryCount int `json:"numTruncatedErrors,omitempty"`
 }
 
 // Clone clones given directory summary.
@@ -135,6 +158,17 @@ type Symlink interface {
 	Readlink(ctx context.Context) (string, error)
 }
 
+// ErrSymlinkLoop is returned when resolving a symbolic link that points to itself, directly or through other links.
+var ErrSymlinkLoop = errors.Errorf("symbolic link loop")
+
+// ResolvableSymlink represents a symbolic link entry whose target can be resolved to an entry.
+type ResolvableSymlink interface {
+	Symlink
+
+	// Resolve returns the entry the symbolic link points to after following all intermediate links.
+	Resolve(ctx context.Context) (Entry, error)
+}
+
 // FindByName returns an entry with a given name, or nil if not found.
 func (e Entries) FindByName(n string) Entry {
 	i := sort.Search(
@@ -5,6 +5,7 @@
 	"bufio"
 	"context"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -77,6 +78,26 @@ type ignoreDirectory struct {
 	fs.Directory
 }
 
+// Xattrs implements fs.HasXattrs.
+func (d *ignoreDirectory) Xattrs(ctx context.Context) (map[string][]byte, error) {
+	if x, ok := d.Directory.(fs.HasXattrs); ok {
+		// nolint:wrapcheck
+		return x.Xattrs(ctx)
+	}
+
+	return nil, nil
+}
+
+// CreationTime implements fs.HasCreationTime.
+func (d *ignoreDirectory) CreationTime(ctx context.Context) (time.Time, error) {
+	if c, ok := d.Directory.(fs.HasCreationTime); ok {
+		// nolint:wrapcheck
+		return c.CreationTime(ctx)
+	}
+
+	return time.Time{}, nil
+}
+
 func isCorrectCacheDirSignature(ctx context.Context, f fs.File) (bool, error) {
 	const (
 		validSignature    = repo.CacheDirMarkerHeader
@@ -7,6 +7,7 @@
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
@@ -68,6 +69,16 @@ func (e *filesystemEntry) Device() fs.DeviceInfo {
 	return e.device
 }
 
+// Xattrs implements fs.HasXattrs.
+func (e *filesystemEntry) Xattrs(ctx context.Context) (map[string][]byte, error) {
+	return platformSpecificXattrs(e.fullPath())
+}
+
+// CreationTime implements fs.HasCreationTime.
+func (e *filesystemEntry) CreationTime(ctx context.Context) (time.Time, error) {
+	return platformSpecificCreationTime(e.fullPath())
+}
+
 func (e *filesystemEntry) LocalFilesystemPath() string {
 	return e.fullPath()
 }
@@ -315,6 +326,21 @@ func (fsl *filesystemSymlink) Readlink(ctx context.Context) (string, error) {
 	return os.Readlink(fsl.fullPath())
 }
 
+// Resolve implements fs.ResolvableSymlink.
+func (fsl *filesystemSymlink) Resolve(ctx context.Context) (fs.Entry, error) {
+	target, err := filepath.EvalSymlinks(fsl.fullPath())
+	if err != nil {
+		// EvalSymlinks does not report loops using a distinguishable error, but stat() does.
+		if _, serr := os.Stat(fsl.fullPath()); errors.Is(serr, syscall.ELOOP) {
+			return nil, errors.Wrapf(fs.ErrSymlinkLoop, "unable to resolve %v", fsl.fullPath())
+		}
+
+		return nil, errors.Wrap(err, "unable to resolve symlink")
+	}
+
+	return NewEntry(target)
+}
+
 func (e *filesystemErrorEntry) ErrorInfo() error {
 	return e.err
 }
@@ -0,0 +1,28 @@
+package localfs
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+func platformSpecificCreationTime(path string) (time.Time, error) {
+	var st unix.Statx_t
+
+	if err := unix.Statx(unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW, unix.STATX_BTIME, &st); err != nil {
+		if errors.Is(err, unix.ENOSYS) {
+			// kernel does not support statx().
+			return time.Time{}, nil
+		}
+
+		return time.Time{}, errors.Wrap(err, "unable to read creation time")
+	}
+
+	// filesystem does not record creation time.
+	if st.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(st.Btime.Sec, int64(st.Btime.Nsec)), nil
+}
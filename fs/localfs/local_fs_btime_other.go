@@ -0,0 +1,10 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package localfs
+
+import "time"
+
+func platformSpecificCreationTime(path string) (time.Time, error) {
+	return time.Time{}, nil
+}
@@ -0,0 +1,23 @@
+package localfs
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func platformSpecificCreationTime(path string) (time.Time, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "unable to read creation time")
+	}
+
+	d, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(0, d.CreationTime.Nanoseconds()), nil
+}
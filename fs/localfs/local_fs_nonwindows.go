@@ -26,6 +26,8 @@ func platformSpecificDeviceInfo(fi os.FileInfo) fs.DeviceInfo {
 		// not making a separate type for 32-bit platforms here..
 		oi.Dev = platformSpecificWidenDev(stat.Dev)
 		oi.Rdev = platformSpecificWidenDev(stat.Rdev)
+		oi.Ino = uint64(stat.Ino)     // nolint:unconvert,nolintlint
+		oi.Nlink = uint64(stat.Nlink) // nolint:unconvert,nolintlint
 	}
 
 	return oi
@@ -0,0 +1,75 @@
+package localfs
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// maxXattrAttempts is the number of times to retry reading extended attributes that change size while being read.
+const maxXattrAttempts = 3
+
+func platformSpecificXattrs(path string) (map[string][]byte, error) {
+	names, err := readXattr(func(buf []byte) (int, error) {
+		// nolint:wrapcheck
+		return unix.Llistxattr(path, buf)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list extended attributes")
+	}
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	result := map[string][]byte{}
+
+	for _, n := range bytes.Split(bytes.TrimSuffix(names, []byte{0}), []byte{0}) {
+		name := string(n)
+
+		v, err := readXattr(func(buf []byte) (int, error) {
+			// nolint:wrapcheck
+			return unix.Lgetxattr(path, name, buf)
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read extended attribute %q", name)
+		}
+
+		result[name] = v
+	}
+
+	return result, nil
+}
+
+// readXattr invokes the provided function first to determine the size of the value and then to read it.
+func readXattr(read func(buf []byte) (int, error)) ([]byte, error) {
+	for i := 0; ; i++ {
+		sz, err := read(nil)
+		if err != nil {
+			if errors.Is(err, unix.ENOTSUP) {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		if sz == 0 {
+			return nil, nil
+		}
+
+		buf := make([]byte, sz)
+
+		n, err := read(buf)
+		if errors.Is(err, unix.ERANGE) && i < maxXattrAttempts {
+			// value grew since we asked for its size, try again.
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return buf[0:n], nil
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package localfs
+
+func platformSpecificXattrs(path string) (map[string][]byte, error) {
+	return nil, nil
+}
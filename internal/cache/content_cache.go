@@ -15,6 +15,7 @@ type ContentCache interface {
 	Close(ctx context.Context)
 	GetContent(ctx context.Context, contentID string, blobID blob.ID, offset, length int64, output *gather.WriteBuffer) error
 	PrefetchBlob(ctx context.Context, blobID blob.ID) error
+	PrefetchContents(ctx context.Context, blobID blob.ID, contentIDs []string, ranges []blob.Range) error
 	CacheStorage() Storage
 }
 
@@ -170,6 +171,72 @@ func (c *contentCacheImpl) PrefetchBlob(ctx context.Context, blobID blob.ID) err
 	return c.fetchBlobInternal(ctx, blobID, &blobData)
 }
 
+// PrefetchContents fetches the provided contents of a blob into the cache using a single GetBlobRanges() call.
+// Returns blob.ErrUnsupportedMethod if the storage can't read multiple ranges of a blob at once.
+func (c *contentCacheImpl) PrefetchContents(ctx context.Context, blobID blob.ID, contentIDs []string, ranges []blob.Range) error {
+	if c.fetchFullBlobs {
+		return c.PrefetchBlob(ctx, blobID)
+	}
+
+	// acquire shared lock on a blob, PrefetchBlob will acquire exclusive lock here.
+	mut := c.pc.GetFetchingMutex(string(blobID))
+	mut.RLock()
+	defer mut.RUnlock()
+
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	if c.pc.GetPartial(ctx, BlobIDCacheKey(blobID), 0, 1, &tmp) {
+		return nil
+	}
+
+	var (
+		missingIDs    []string
+		missingRanges []blob.Range
+	)
+
+	for i, contentID := range contentIDs {
+		if !c.pc.GetPartial(ctx, ContentIDCacheKey(contentID), 0, 1, &tmp) {
+			missingIDs = append(missingIDs, contentID)
+			missingRanges = append(missingRanges, ranges[i])
+		}
+	}
+
+	if len(missingIDs) == 0 {
+		return nil
+	}
+
+	buffers := make([]gather.WriteBuffer, len(missingIDs))
+	outputs := make([]blob.OutputBuffer, len(missingIDs))
+
+	defer func() {
+		for i := range buffers {
+			buffers[i].Close()
+		}
+	}()
+
+	for i := range buffers {
+		outputs[i] = &buffers[i]
+	}
+
+	if err := blob.GetBlobRanges(ctx, c.st, blobID, missingRanges, outputs); err != nil {
+		if !errors.Is(err, blob.ErrUnsupportedMethod) {
+			reportMissError()
+		}
+
+		// nolint:wrapcheck
+		return err
+	}
+
+	for i, contentID := range missingIDs {
+		reportMissBytes(int64(buffers[i].Length()))
+
+		c.pc.Put(ctx, ContentIDCacheKey(contentID), buffers[i].Bytes())
+	}
+
+	return nil
+}
+
 func (c *contentCacheImpl) CacheStorage() Storage {
 	return c.pc.cacheStorage
 }
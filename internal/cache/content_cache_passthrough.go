@@ -23,6 +23,10 @@ func (c passthroughContentCache) PrefetchBlob(ctx context.Context, blobID blob.I
 	return nil
 }
 
+func (c passthroughContentCache) PrefetchContents(ctx context.Context, blobID blob.ID, contentIDs []string, ranges []blob.Range) error {
+	return nil
+}
+
 func (c passthroughContentCache) Sync(ctx context.Context, blobPrefix blob.ID) error {
 	return nil
 }
@@ -314,6 +314,71 @@ func TestCacheFailureToRead(t *testing.T) {
 	}
 }
 
+func TestContentCachePrefetchContents(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	faulty := blobtesting.NewFaultyStorage(newUnderlyingStorageForContentCacheTesting(t))
+	underlying := &rangeGetterStorage{Storage: faulty}
+
+	cc, err := cache.NewContentCache(ctx, underlying, cache.Options{
+		Storage: blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil).(cache.Storage),
+		Sweep:   cache.SweepSettings{MaxSizeBytes: 10000},
+	})
+	require.NoError(t, err)
+
+	defer cc.Close(ctx)
+
+	ranges := []blob.Range{{Offset: 0, Length: 3}, {Offset: 5, Length: 2}}
+
+	require.NoError(t, cc.PrefetchContents(ctx, "content-1", []string{"aa", "bb"}, ranges))
+	require.Equal(t, 1, underlying.calls)
+
+	// contents that are already cached are not fetched again.
+	require.NoError(t, cc.PrefetchContents(ctx, "content-1", []string{"aa", "bb"}, ranges))
+	require.Equal(t, 1, underlying.calls)
+
+	// prefetched contents are served from the cache.
+	faulty.AddFault(blobtesting.MethodGetBlob).ErrorInstead(errors.New("some error")).Repeat(100)
+
+	var v gather.WriteBuffer
+	defer v.Close()
+
+	require.NoError(t, cc.GetContent(ctx, "aa", "content-1", 0, 3, &v))
+	require.Equal(t, []byte{1, 2, 3}, v.ToByteSlice())
+	require.NoError(t, cc.GetContent(ctx, "bb", "content-1", 5, 2, &v))
+	require.Equal(t, []byte{6, 7}, v.ToByteSlice())
+
+	// storage that can't read multiple ranges at once.
+	cc2, err := cache.NewContentCache(ctx, newUnderlyingStorageForContentCacheTesting(t), cache.Options{
+		Storage: blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil).(cache.Storage),
+		Sweep:   cache.SweepSettings{MaxSizeBytes: 10000},
+	})
+	require.NoError(t, err)
+
+	defer cc2.Close(ctx)
+
+	require.ErrorIs(t, cc2.PrefetchContents(ctx, "content-1", []string{"aa", "bb"}, ranges), blob.ErrUnsupportedMethod)
+}
+
+// rangeGetterStorage implements blob.RangeGetter by reading each range separately and counts the calls.
+type rangeGetterStorage struct {
+	blob.Storage
+
+	calls int
+}
+
+func (s *rangeGetterStorage) GetBlobRanges(ctx context.Context, id blob.ID, ranges []blob.Range, outputs []blob.OutputBuffer) error {
+	s.calls++
+
+	for i, r := range ranges {
+		if err := s.GetBlob(ctx, id, r.Offset, r.Length, outputs[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func verifyStorageContentList(t *testing.T, st blob.Storage, expectedContents ...blob.ID) {
 	t.Helper()
 
@@ -58,10 +58,14 @@ func InfoDiff(i1, i2 index.Info, ignore ...string) []string {
 		diffs = append(diffs, fmt.Sprintf("GetEncryptionKeyID %v != %v", l, r))
 	}
 
+	if l, r := i1.GetCompressionDictionaryID(), i2.GetCompressionDictionaryID(); l != r {
+		diffs = append(diffs, fmt.Sprintf("GetCompressionDictionaryID %v != %v", l, r))
+	}
+
 	// dear future reader, if this fails because the number of methods has changed,
 	// you need to add additional verification above.
 	// nolint:gomnd
-	if cnt := reflect.TypeOf((*index.Info)(nil)).Elem().NumMethod(); cnt != 11 {
+	if cnt := reflect.TypeOf((*index.Info)(nil)).Elem().NumMethod(); cnt != 12 {
 		diffs = append(diffs, fmt.Sprintf("unexpected number of methods on content.Info: %v, must update the test", cnt))
 	}
 
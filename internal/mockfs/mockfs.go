@@ -324,7 +324,8 @@ func (imf *File) Open(ctx context.Context) (fs.Reader, error) {
 type Symlink struct {
 	entry
 
-	target string
+	target   string
+	resolved fs.Entry
 }
 
 // Readlink implements fs.Symlink interface.
@@ -332,6 +333,20 @@ func (imsl *Symlink) Readlink(ctx context.Context) (string, error) {
 	return imsl.target, nil
 }
 
+// SetResolvedEntry sets the entry returned by Resolve(), which otherwise fails as if the target did not exist.
+func (imsl *Symlink) SetResolvedEntry(e fs.Entry) {
+	imsl.resolved = e
+}
+
+// Resolve implements fs.ResolvableSymlink interface.
+func (imsl *Symlink) Resolve(ctx context.Context) (fs.Entry, error) {
+	if imsl.resolved == nil {
+		return nil, &os.PathError{Op: "resolve", Path: imsl.target, Err: os.ErrNotExist}
+	}
+
+	return imsl.resolved, nil
+}
+
 // NewDirectory returns new mock directory.
 func NewDirectory() *Directory {
 	return &Directory{
@@ -0,0 +1,153 @@
+// Package quarantine manages records of corrupt contents discovered during verification, which can be
+// used to later rewrite or drop them.
+package quarantine
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+// ManifestType is the type of the manifest used to represent quarantine records.
+const ManifestType = "quarantine"
+
+// FormatVersion is the version of the Record format. Fields of the format are never removed or repurposed,
+// incompatible changes require a new version.
+const FormatVersion = 1
+
+// Record describes corrupt contents discovered during a single verification, grouped by pack blob.
+// The record is stored as JSON in a manifest with the type label set to ManifestType.
+type Record struct {
+	// FormatVersion is the version of the record format, currently always 1.
+	FormatVersion int `json:"formatVersion"`
+
+	// CreatedAt is the time when the record was created.
+	CreatedAt time.Time `json:"createdAt"`
+
+	// ContentCount is the total number of quarantined contents.
+	ContentCount int `json:"contentCount"`
+
+	// Blobs lists pack blobs that store corrupt contents, sorted by blob ID.
+	Blobs []BlobRecord `json:"blobs"`
+}
+
+// BlobRecord describes corrupt contents stored in a single pack blob.
+type BlobRecord struct {
+	// BlobID is the ID of the pack blob.
+	BlobID blob.ID `json:"blobID"`
+
+	// Contents lists corrupt contents stored in the blob, sorted by content ID.
+	Contents []ContentRecord `json:"contents"`
+}
+
+// ContentRecord describes a single corrupt content.
+type ContentRecord struct {
+	// ContentID is the ID of the content.
+	ContentID content.ID `json:"contentID"`
+
+	// PackOffset and PackedLength describe the location of the content in the pack blob.
+	PackOffset   uint64 `json:"packOffset"`
+	PackedLength uint64 `json:"packedLength"`
+
+	// Reason is the human-readable description of the problem.
+	Reason string `json:"reason"`
+}
+
+// Collector accumulates corrupt contents and is safe for concurrent use.
+type Collector struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	byBlob map[blob.ID][]ContentRecord
+}
+
+// Add records the provided content as corrupt.
+func (c *Collector) Add(ci content.Info, reason error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.byBlob == nil {
+		c.byBlob = map[blob.ID][]ContentRecord{}
+	}
+
+	c.byBlob[ci.GetPackBlobID()] = append(c.byBlob[ci.GetPackBlobID()], ContentRecord{
+		ContentID:    ci.GetContentID(),
+		PackOffset:   ci.GetPackOffset(),
+		PackedLength: ci.GetPackedLength(),
+		Reason:       reason.Error(),
+	})
+}
+
+// Record returns the record of all corrupt contents collected so far.
+func (c *Collector) Record() *Record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := &Record{
+		FormatVersion: FormatVersion,
+		CreatedAt:     clock.Now(),
+		Blobs:         []BlobRecord{},
+	}
+
+	for blobID, contents := range c.byBlob {
+		contents = append([]ContentRecord(nil), contents...)
+
+		sort.Slice(contents, func(i, j int) bool {
+			return contents[i].ContentID < contents[j].ContentID
+		})
+
+		r.Blobs = append(r.Blobs, BlobRecord{BlobID: blobID, Contents: contents})
+		r.ContentCount += len(contents)
+	}
+
+	sort.Slice(r.Blobs, func(i, j int) bool {
+		return r.Blobs[i].BlobID < r.Blobs[j].BlobID
+	})
+
+	return r
+}
+
+// Put stores the provided record in the repository.
+func Put(ctx context.Context, rep repo.RepositoryWriter, r *Record) (manifest.ID, error) {
+	id, err := rep.PutManifest(ctx, map[string]string{
+		manifest.TypeLabelKey: ManifestType,
+	}, r)
+	if err != nil {
+		return "", errors.Wrap(err, "error writing quarantine record")
+	}
+
+	return id, nil
+}
+
+// List returns all quarantine records in the repository, oldest first.
+func List(ctx context.Context, rep repo.Repository) ([]*Record, error) {
+	entries, err := rep.FindManifests(ctx, map[string]string{manifest.TypeLabelKey: ManifestType})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing quarantine records")
+	}
+
+	var result []*Record
+
+	for _, e := range entries {
+		r := &Record{}
+		if _, err := rep.GetManifest(ctx, e.ID, r); err != nil {
+			return nil, errors.Wrapf(err, "error loading quarantine record %v", e.ID)
+		}
+
+		result = append(result, r)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
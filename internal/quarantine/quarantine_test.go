@@ -0,0 +1,55 @@
+package quarantine_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/quarantine"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/content"
+)
+
+func TestQuarantine(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	recs, err := quarantine.List(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Empty(t, recs)
+
+	var c quarantine.Collector
+
+	c.Add(&content.InfoStruct{ContentID: "c2", PackBlobID: "p2", PackOffset: 10, PackedLength: 20}, errors.New("bad c2"))
+	c.Add(&content.InfoStruct{ContentID: "c3", PackBlobID: "p1", PackOffset: 30, PackedLength: 40}, errors.New("bad c3"))
+	c.Add(&content.InfoStruct{ContentID: "c1", PackBlobID: "p1", PackOffset: 50, PackedLength: 60}, errors.New("bad c1"))
+
+	r := c.Record()
+
+	require.Equal(t, quarantine.FormatVersion, r.FormatVersion)
+	require.Equal(t, 3, r.ContentCount)
+	require.Equal(t, []quarantine.BlobRecord{
+		{
+			BlobID: "p1",
+			Contents: []quarantine.ContentRecord{
+				{ContentID: "c1", PackOffset: 50, PackedLength: 60, Reason: "bad c1"},
+				{ContentID: "c3", PackOffset: 30, PackedLength: 40, Reason: "bad c3"},
+			},
+		},
+		{
+			BlobID: "p2",
+			Contents: []quarantine.ContentRecord{
+				{ContentID: "c2", PackOffset: 10, PackedLength: 20, Reason: "bad c2"},
+			},
+		},
+	}, r.Blobs)
+
+	_, err = quarantine.Put(ctx, env.RepositoryWriter, r)
+	require.NoError(t, err)
+
+	recs, err = quarantine.List(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	require.Equal(t, r.Blobs, recs[0].Blobs)
+	require.Equal(t, r.ContentCount, recs[0].ContentCount)
+}
@@ -571,12 +571,30 @@ func (t *uitaskProgress) ExcludedDir(dirname string) {
 	t.maybeReport()
 }
 
+// SkippedSpecialFile is emitted whenever a socket, named pipe or device is skipped.
+func (t *uitaskProgress) SkippedSpecialFile(fname string) {
+	t.p.SkippedSpecialFile(fname)
+	t.maybeReport()
+}
+
 // EstimatedDataSize is emitted whenever the size of upload is estimated.
 func (t *uitaskProgress) EstimatedDataSize(fileCount int, totalBytes int64) {
 	t.p.EstimatedDataSize(fileCount, totalBytes)
 	t.maybeReport()
 }
 
+// EstimatedDataSizeDetailed is emitted after EstimatedDataSize with the breakdown of the estimate.
+func (t *uitaskProgress) EstimatedDataSizeDetailed(details snapshotfs.EstimatedDataSizeDetails) {
+	t.p.EstimatedDataSizeDetailed(details)
+	t.maybeReport()
+}
+
+// UploadReason is emitted before a file is hashed with the reason why a cached entry could not be used.
+func (t *uitaskProgress) UploadReason(path string, reason snapshotfs.UploadReason) {
+	t.p.UploadReason(path, reason)
+	t.maybeReport()
+}
+
 func newSourceManager(src snapshot.SourceInfo, server *Server, rep repo.Repository) *sourceManager {
 	m := &sourceManager{
 		src:              src,
@@ -22,7 +22,13 @@ type Pool struct {
 	// +checkatomic
 	activeWorkers int32
 
-	semaphore chan struct{}
+	// +checkatomic
+	reservedWorkers int32 // number of workers that have been handed work or are about to be
+
+	// +checkatomic
+	maxActiveWorkers int32
+
+	numWorkers int
 
 	work   chan workItem
 	closed chan struct{}
@@ -35,6 +41,40 @@ func (w *Pool) ActiveWorkers() int {
 	return int(atomic.LoadInt32(&w.activeWorkers))
 }
 
+// MaxActiveWorkers returns the maximum number of workers that can be processing work at the same time.
+func (w *Pool) MaxActiveWorkers() int {
+	return int(atomic.LoadInt32(&w.maxActiveWorkers))
+}
+
+// SetMaxActiveWorkers changes the maximum number of workers that can be processing work at the same time,
+// which is capped at the number of workers the pool was created with. Lowering the limit does not interrupt
+// work in progress, but no new work is shared until the number of active workers drops below the new limit.
+func (w *Pool) SetMaxActiveWorkers(n int) {
+	if n < 0 {
+		n = 0
+	}
+
+	if n > w.numWorkers {
+		n = w.numWorkers
+	}
+
+	atomic.StoreInt32(&w.maxActiveWorkers, int32(n))
+}
+
+// tryReserveWorker reserves a worker if fewer than MaxActiveWorkers() are reserved.
+func (w *Pool) tryReserveWorker() bool {
+	for {
+		r := atomic.LoadInt32(&w.reservedWorkers)
+		if r >= atomic.LoadInt32(&w.maxActiveWorkers) {
+			return false
+		}
+
+		if atomic.CompareAndSwapInt32(&w.reservedWorkers, r, r+1) {
+			return true
+		}
+	}
+}
+
 // NewPool creates a worker pool that launches a given number of goroutines that can invoke shared work.
 func NewPool(numWorkers int) *Pool {
 	if numWorkers < 0 {
@@ -44,9 +84,10 @@ func NewPool(numWorkers int) *Pool {
 	w := &Pool{
 		// channel must be unbuffered so that it has exactly as many slots as there are goroutines capable of reading from it
 		// this way by pushing to the channel we can be sure that a pre-spun goroutine will pick it up soon.
-		work:      make(chan workItem),
-		closed:    make(chan struct{}),
-		semaphore: make(chan struct{}, numWorkers),
+		work:             make(chan workItem),
+		closed:           make(chan struct{}),
+		numWorkers:       numWorkers,
+		maxActiveWorkers: int32(numWorkers),
 	}
 
 	for i := 0; i < numWorkers; i++ {
@@ -61,7 +102,7 @@ func NewPool(numWorkers int) *Pool {
 					atomic.AddInt32(&w.activeWorkers, 1)
 					it.process(w, it.request)
 					atomic.AddInt32(&w.activeWorkers, -1)
-					<-w.semaphore
+					atomic.AddInt32(&w.reservedWorkers, -1)
 					it.wg.Done()
 
 				case <-w.closed:
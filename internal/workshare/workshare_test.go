@@ -126,3 +126,49 @@ func BenchmarkComputeTreeSum(b *testing.B) {
 		computeTreeSum(w, treeToWalk)
 	}
 }
+
+func TestMaxActiveWorkers(t *testing.T) {
+	w := workshare.NewPool(4)
+	defer w.Close()
+
+	require.Equal(t, 4, w.MaxActiveWorkers())
+
+	w.SetMaxActiveWorkers(10)
+	require.Equal(t, 4, w.MaxActiveWorkers())
+
+	w.SetMaxActiveWorkers(2)
+	require.Equal(t, 2, w.MaxActiveWorkers())
+
+	release := make(chan struct{})
+	block := func(c *workshare.Pool, request interface{}) {
+		<-release
+	}
+
+	var ag workshare.AsyncGroup
+
+	require.True(t, ag.CanShareWork(w))
+	ag.RunAsync(w, block, nil)
+	require.True(t, ag.CanShareWork(w))
+	ag.RunAsync(w, block, nil)
+	require.False(t, ag.CanShareWork(w))
+
+	// raising the limit allows more work to be shared right away.
+	w.SetMaxActiveWorkers(3)
+	require.True(t, ag.CanShareWork(w))
+	ag.RunAsync(w, block, nil)
+	require.False(t, ag.CanShareWork(w))
+
+	// lowering the limit does not affect work in progress.
+	w.SetMaxActiveWorkers(0)
+	require.False(t, ag.CanShareWork(w))
+
+	close(release)
+	ag.Wait()
+
+	require.False(t, ag.CanShareWork(w))
+
+	w.SetMaxActiveWorkers(1)
+	require.True(t, ag.CanShareWork(w))
+	ag.RunAsync(w, func(c *workshare.Pool, request interface{}) {}, nil)
+	ag.Wait()
+}
@@ -89,15 +89,9 @@ func (g *AsyncGroup) RunAsync(w *Pool, process ProcessFunc, request interface{})
 // If the function returns true, the use MUST call RunAsync() exactly once. This pattern avoids
 // allocations required to create asynchronous input if the worker pool is full.
 func (g *AsyncGroup) CanShareWork(w *Pool) bool {
-	select {
-	case w.semaphore <- struct{}{}:
-		// we successfully added token to the channel, because we have exactly the same number
-		// of workers as the capacity of the channel, one worker will wake up to process
-		// item from w.work, which will be added by RunAsync().
-		return true
-
-	default:
-		// all workers are busy.
-		return false
-	}
+	// because the number of reserved workers never exceeds the number of goroutines in the pool,
+	// after a successful reservation one worker will wake up to process item from w.work,
+	// which will be added by RunAsync().
+	// if the reservation fails, all allowed workers are busy.
+	return w.tryReserveWorker()
 }
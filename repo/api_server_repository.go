@@ -195,8 +195,16 @@ func (r *apiServerRepository) GetContent(ctx context.Context, contentID content.
 }
 
 func (r *apiServerRepository) WriteContent(ctx context.Context, data gather.Bytes, prefix content.ID, comp compression.HeaderID) (content.ID, error) {
+	contentID, _, err := r.WriteContentWithDedupInfo(ctx, data, prefix, comp)
+
+	return contentID, err
+}
+
+// WriteContentWithDedupInfo writes the content and reports it as new if it did not already exist.
+// The packed length of newly-written contents is not returned by the server, so the original length is reported instead.
+func (r *apiServerRepository) WriteContentWithDedupInfo(ctx context.Context, data gather.Bytes, prefix content.ID, comp compression.HeaderID) (content.ID, content.WriteInfo, error) {
 	if err := content.ValidatePrefix(prefix); err != nil {
-		return "", errors.Wrap(err, "invalid prefix")
+		return "", content.WriteInfo{}, errors.Wrap(err, "invalid prefix")
 	}
 
 	var hashOutput [128]byte
@@ -204,9 +212,9 @@ func (r *apiServerRepository) WriteContent(ctx context.Context, data gather.Byte
 	contentID := prefix + content.ID(hex.EncodeToString(r.h(hashOutput[:0], data)))
 
 	// avoid uploading the content body if it already exists.
-	if _, err := r.ContentInfo(ctx, contentID); err == nil {
+	if ci, err := r.ContentInfo(ctx, contentID); err == nil {
 		// content already exists
-		return contentID, nil
+		return contentID, content.WriteInfo{PackedLength: int64(ci.GetPackedLength())}, nil
 	}
 
 	r.wso.OnUpload(int64(data.Length()))
@@ -217,7 +225,7 @@ func (r *apiServerRepository) WriteContent(ctx context.Context, data gather.Byte
 	}
 
 	if err := r.cli.Put(ctx, "contents/"+string(contentID)+maybeCompression, data.ToByteSlice(), nil); err != nil {
-		return "", errors.Wrapf(err, "error writing content %v", contentID)
+		return "", content.WriteInfo{}, errors.Wrapf(err, "error writing content %v", contentID)
 	}
 
 	if prefix != "" {
@@ -225,7 +233,7 @@ func (r *apiServerRepository) WriteContent(ctx context.Context, data gather.Byte
 		r.contentCache.Put(ctx, string(contentID), data)
 	}
 
-	return contentID, nil
+	return contentID, content.WriteInfo{IsNew: true, PackedLength: int64(data.Length())}, nil
 }
 
 // UpdateDescription updates the description of a connected repository.
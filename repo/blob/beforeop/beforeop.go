@@ -30,6 +30,16 @@ func (s beforeOp) GetBlob(ctx context.Context, id blob.ID, offset, length int64,
 	return s.Storage.GetBlob(ctx, id, offset, length, output) // nolint:wrapcheck
 }
 
+func (s beforeOp) GetBlobRanges(ctx context.Context, id blob.ID, ranges []blob.Range, outputs []blob.OutputBuffer) error {
+	if s.onGetBlob != nil {
+		if err := s.onGetBlob(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return blob.GetBlobRanges(ctx, s.Storage, id, ranges, outputs) // nolint:wrapcheck
+}
+
 func (s beforeOp) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
 	if s.onGetMetadata != nil {
 		if err := s.onGetMetadata(ctx); err != nil {
@@ -40,6 +50,20 @@ func (s beforeOp) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, e
 	return s.Storage.GetMetadata(ctx, id) // nolint:wrapcheck
 }
 
+func (s beforeOp) BlobsExist(ctx context.Context, ids []blob.ID) (map[blob.ID]bool, error) {
+	if s.onGetMetadata != nil {
+		if err := s.onGetMetadata(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return blob.BlobsExist(ctx, s.Storage, ids) // nolint:wrapcheck
+}
+
+func (s beforeOp) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, cb func(bm blob.Metadata) error) error {
+	return blob.ListBlobsAfter(ctx, s.Storage, prefix, startAfter, cb) // nolint:wrapcheck
+}
+
 func (s beforeOp) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes, opts blob.PutOptions) error {
 	if s.onPutBlob != nil {
 		if err := s.onPutBlob(ctx, id, &opts); err != nil {
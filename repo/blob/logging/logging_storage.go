@@ -57,6 +57,52 @@ func (s *loggingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length
 	return err
 }
 
+func (s *loggingStorage) GetBlobRanges(ctx context.Context, id blob.ID, ranges []blob.Range, outputs []blob.OutputBuffer) error {
+	s.beginConcurrency()
+	defer s.endConcurrency()
+
+	timer := timetrack.StartTimer()
+	err := blob.GetBlobRanges(ctx, s.base, id, ranges, outputs)
+	dt := timer.Elapsed()
+
+	s.logger.Debugw(s.prefix+"GetBlobRanges",
+		"blobID", id,
+		"rangeCount", len(ranges),
+		"error", err,
+		"duration", dt,
+	)
+
+	// nolint:wrapcheck
+	return err
+}
+
+func (s *loggingStorage) BlobsExist(ctx context.Context, ids []blob.ID) (map[blob.ID]bool, error) {
+	s.beginConcurrency()
+	defer s.endConcurrency()
+
+	timer := timetrack.StartTimer()
+	result, err := blob.BlobsExist(ctx, s.base, ids)
+	dt := timer.Elapsed()
+
+	existCount := 0
+
+	for _, exists := range result {
+		if exists {
+			existCount++
+		}
+	}
+
+	s.logger.Debugw(s.prefix+"BlobsExist",
+		"blobCount", len(ids),
+		"existCount", existCount,
+		"error", err,
+		"duration", dt,
+	)
+
+	// nolint:wrapcheck
+	return result, err
+}
+
 func (s *loggingStorage) GetCapacity(ctx context.Context) (blob.Capacity, error) {
 	timer := timetrack.StartTimer()
 	c, err := s.base.GetCapacity(ctx)
@@ -151,6 +197,30 @@ func (s *loggingStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback
 	return err
 }
 
+func (s *loggingStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	s.beginConcurrency()
+	defer s.endConcurrency()
+
+	timer := timetrack.StartTimer()
+	cnt := 0
+	err := blob.ListBlobsAfter(ctx, s.base, prefix, startAfter, func(bi blob.Metadata) error {
+		cnt++
+		return callback(bi)
+	})
+	dt := timer.Elapsed()
+
+	s.logger.Debugw(s.prefix+"ListBlobsAfter",
+		"prefix", prefix,
+		"startAfter", startAfter,
+		"resultCount", cnt,
+		"error", err,
+		"duration", dt,
+	)
+
+	// nolint:wrapcheck
+	return err
+}
+
 func (s *loggingStorage) Close(ctx context.Context) error {
 	timer := timetrack.StartTimer()
 	err := s.base.Close(ctx)
@@ -0,0 +1,76 @@
+package blob
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnsupportedMethod is returned when invoking an optional method on a storage that does not implement it.
+// Storage wrappers implement all optional interfaces and return this error when the wrapped storage does not.
+var ErrUnsupportedMethod = errors.New("method not supported by storage")
+
+// AfterLister is an optional interface implemented by storage providers that can list blobs whose IDs sort after
+// a provided blob ID (marker) without listing the preceding ones.
+type AfterLister interface {
+	// ListBlobsAfter invokes the provided callback for each blob with the provided prefix whose ID is greater than
+	// startAfter, in lexicographical order.
+	ListBlobsAfter(ctx context.Context, blobIDPrefix, startAfter ID, cb func(bm Metadata) error) error
+}
+
+// ListBlobsAfter invokes the provided callback for each blob with the provided prefix whose ID is greater than
+// startAfter. Returns ErrUnsupportedMethod if the storage does not implement AfterLister.
+func ListBlobsAfter(ctx context.Context, r Reader, blobIDPrefix, startAfter ID, cb func(bm Metadata) error) error {
+	l, ok := r.(AfterLister)
+	if !ok {
+		return errors.Wrap(ErrUnsupportedMethod, "ListBlobsAfter")
+	}
+
+	// nolint:wrapcheck
+	return l.ListBlobsAfter(ctx, blobIDPrefix, startAfter, cb)
+}
+
+// Range describes a part of a blob.
+type Range struct {
+	Offset int64
+	Length int64
+}
+
+// RangeGetter is an optional interface implemented by storage providers that can read multiple ranges
+// of a blob using fewer requests than reading each range separately.
+type RangeGetter interface {
+	// GetBlobRanges reads the provided ranges of a blob into the corresponding outputs.
+	GetBlobRanges(ctx context.Context, id ID, ranges []Range, outputs []OutputBuffer) error
+}
+
+// GetBlobRanges reads the provided ranges of a blob into the corresponding outputs.
+// Returns ErrUnsupportedMethod if the storage does not implement RangeGetter.
+func GetBlobRanges(ctx context.Context, r Reader, id ID, ranges []Range, outputs []OutputBuffer) error {
+	g, ok := r.(RangeGetter)
+	if !ok {
+		return errors.Wrap(ErrUnsupportedMethod, "GetBlobRanges")
+	}
+
+	// nolint:wrapcheck
+	return g.GetBlobRanges(ctx, id, ranges, outputs)
+}
+
+// ExistenceChecker is an optional interface implemented by storage providers that can efficiently determine
+// which of the provided blobs exist without listing all blobs.
+type ExistenceChecker interface {
+	// BlobsExist returns the map indicating which of the provided blobs exist. Transient errors must not
+	// cause blobs to be reported as missing.
+	BlobsExist(ctx context.Context, ids []ID) (map[ID]bool, error)
+}
+
+// BlobsExist returns the map indicating which of the provided blobs exist.
+// Returns ErrUnsupportedMethod if the storage does not implement ExistenceChecker.
+func BlobsExist(ctx context.Context, r Reader, ids []ID) (map[ID]bool, error) {
+	c, ok := r.(ExistenceChecker)
+	if !ok {
+		return nil, errors.Wrap(ErrUnsupportedMethod, "BlobsExist")
+	}
+
+	// nolint:wrapcheck
+	return c.BlobsExist(ctx, ids)
+}
@@ -45,6 +45,21 @@ func (s readonlyStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback
 	return s.base.ListBlobs(ctx, prefix, callback)
 }
 
+func (s readonlyStorage) GetBlobRanges(ctx context.Context, id blob.ID, ranges []blob.Range, outputs []blob.OutputBuffer) error {
+	// nolint:wrapcheck
+	return blob.GetBlobRanges(ctx, s.base, id, ranges, outputs)
+}
+
+func (s readonlyStorage) BlobsExist(ctx context.Context, ids []blob.ID) (map[blob.ID]bool, error) {
+	// nolint:wrapcheck
+	return blob.BlobsExist(ctx, s.base, ids)
+}
+
+func (s readonlyStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListBlobsAfter(ctx, s.base, prefix, startAfter, callback)
+}
+
 func (s readonlyStorage) Close(ctx context.Context) error {
 	// nolint:wrapcheck
 	return s.base.Close(ctx)
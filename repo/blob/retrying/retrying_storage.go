@@ -8,6 +8,7 @@
 
 	"github.com/kopia/kopia/internal/retry"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/readonly"
 )
 
 // retryingStorage adds retry loop around all operations of the underlying storage.
@@ -55,6 +56,33 @@ func (s retryingStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
 	return err // nolint:wrapcheck
 }
 
+// GetBlobRanges implements blob.RangeGetter.
+func (s retryingStorage) GetBlobRanges(ctx context.Context, id blob.ID, ranges []blob.Range, outputs []blob.OutputBuffer) error {
+	// nolint:wrapcheck
+	return retry.WithExponentialBackoffNoValue(ctx, fmt.Sprintf("GetBlobRanges(%v,%v ranges)", id, len(ranges)), func() error {
+		for _, o := range outputs {
+			o.Reset()
+		}
+
+		// nolint:wrapcheck
+		return blob.GetBlobRanges(ctx, s.Storage, id, ranges, outputs)
+	}, isRetriable)
+}
+
+// BlobsExist implements blob.ExistenceChecker. The call is not retried as a whole, since storage
+// providers retry transient errors for each blob.
+func (s retryingStorage) BlobsExist(ctx context.Context, ids []blob.ID) (map[blob.ID]bool, error) {
+	// nolint:wrapcheck
+	return blob.BlobsExist(ctx, s.Storage, ids)
+}
+
+// ListBlobsAfter implements blob.AfterLister. Like ListBlobs, listings are not retried since the callback
+// would observe the same blobs multiple times.
+func (s retryingStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, cb func(bm blob.Metadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListBlobsAfter(ctx, s.Storage, prefix, startAfter, cb)
+}
+
 // NewWrapper returns a Storage wrapper that adds retry loop around all operations of the underlying storage.
 func NewWrapper(wrapped blob.Storage) blob.Storage {
 	return &retryingStorage{Storage: wrapped}
@@ -77,9 +105,15 @@ func isRetriable(err error) bool {
 	case errors.Is(err, blob.ErrUnsupportedPutBlobOption):
 		return false
 
+	case errors.Is(err, blob.ErrUnsupportedMethod):
+		return false
+
 	case errors.Is(err, blob.ErrBlobAlreadyExists):
 		return false
 
+	case errors.Is(err, readonly.ErrReadonly):
+		return false
+
 	default:
 		return true
 	}
@@ -0,0 +1,77 @@
+package s3
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/internal/retry"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// number of concurrent StatObject requests made by BlobsExist.
+const blobsExistParallelism = 16
+
+// BlobsExist implements blob.ExistenceChecker using concurrent StatObject requests instead of
+// listing all blobs. Transient errors are retried and if they persist, BlobsExist
+// fails instead of reporting the blob as missing.
+func (s *s3Storage) BlobsExist(ctx context.Context, ids []blob.ID) (map[blob.ID]bool, error) {
+	var mu sync.Mutex
+
+	result := make(map[blob.ID]bool, len(ids))
+
+	idch := make(chan blob.ID, len(ids))
+	for _, id := range ids {
+		idch <- id
+	}
+
+	close(idch)
+
+	eg, ctx := errgroup.WithContext(ctx)
+
+	for i := 0; i < blobsExistParallelism && i < len(ids); i++ {
+		eg.Go(func() error {
+			for id := range idch {
+				exists, err := s.blobExists(ctx, id)
+				if err != nil {
+					return err
+				}
+
+				mu.Lock()
+				result[id] = exists
+				mu.Unlock()
+			}
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err // nolint:wrapcheck
+	}
+
+	return result, nil
+}
+
+func (s *s3Storage) blobExists(ctx context.Context, id blob.ID) (bool, error) {
+	_, err := retry.WithExponentialBackoff(ctx, "BlobExists("+string(id)+")", func() (interface{}, error) {
+		return s.GetMetadata(ctx, id)
+	}, isRetriableBlobExistsError)
+
+	switch {
+	case err == nil:
+		return true, nil
+
+	case errors.Is(err, blob.ErrBlobNotFound):
+		return false, nil
+
+	default:
+		return false, errors.Wrapf(err, "unable to determine whether blob %v exists", id)
+	}
+}
+
+func isRetriableBlobExistsError(err error) bool {
+	return !errors.Is(err, blob.ErrBlobNotFound) && !errors.Is(err, blob.ErrInvalidCredentials)
+}
@@ -0,0 +1,193 @@
+package s3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/signer"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// defaultAdminRegion is the region used to sign requests to the MinIO admin API when none is configured.
+const defaultAdminRegion = "us-east-1"
+
+// errAdminAPIUnavailable is returned when the endpoint does not provide the MinIO admin API or the
+// credentials are not allowed to use it.
+var errAdminAPIUnavailable = errors.New("MinIO admin API is not available")
+
+// minioAdminClient makes requests to the MinIO admin API, which is not supported by minio-go and provides
+// inexpensive lookups of bucket quotas and usage.
+type minioAdminClient struct {
+	host   string
+	secure bool
+	region string
+	creds  *credentials.Credentials
+	client *http.Client
+}
+
+func newMinioAdminClient(ep s3Endpoint, region string, creds *credentials.Credentials, transport http.RoundTripper) *minioAdminClient {
+	if region == "" {
+		region = defaultAdminRegion
+	}
+
+	return &minioAdminClient{ep.host, ep.secure, region, creds, &http.Client{Transport: transport}}
+}
+
+// bucketQuota returns the quota of the bucket in bytes, zero if not configured.
+func (c *minioAdminClient) bucketQuota(ctx context.Context, bucket string) (uint64, error) {
+	var q struct {
+		Quota uint64 `json:"quota"`
+		Size  uint64 `json:"size"`
+	}
+
+	if err := c.get(ctx, "get-bucket-quota", url.Values{"bucket": {bucket}}, &q); err != nil {
+		return 0, err
+	}
+
+	// newer servers report the quota as size.
+	if q.Size != 0 {
+		return q.Size, nil
+	}
+
+	return q.Quota, nil
+}
+
+// bucketUsage returns the number of bytes used by all objects in the bucket, as of the last scan
+// of the server.
+func (c *minioAdminClient) bucketUsage(ctx context.Context, bucket string) (uint64, error) {
+	var u struct {
+		BucketsUsage map[string]struct {
+			Size uint64 `json:"size"`
+		} `json:"bucketsUsageInfo"`
+	}
+
+	if err := c.get(ctx, "datausageinfo", nil, &u); err != nil {
+		return 0, err
+	}
+
+	bu, ok := u.BucketsUsage[bucket]
+	if !ok {
+		return 0, errors.Wrapf(errAdminAPIUnavailable, "no usage of bucket %q", bucket)
+	}
+
+	return bu.Size, nil
+}
+
+func (c *minioAdminClient) get(ctx context.Context, method string, query url.Values, result interface{}) error {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     c.host,
+		Path:     "/minio/admin/v3/" + method,
+		RawQuery: query.Encode(),
+	}
+
+	if c.secure {
+		u.Scheme = "https"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return errors.Wrap(err, "unable to create request")
+	}
+
+	v, err := c.creds.Get()
+	if err != nil {
+		return errors.Wrap(err, "unable to get credentials")
+	}
+
+	if v.SignerType.IsAnonymous() {
+		return errors.Wrap(errAdminAPIUnavailable, "anonymous access")
+	}
+
+	emptyPayloadHash := sha256.Sum256(nil)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(emptyPayloadHash[:]))
+
+	resp, err := c.client.Do(signer.SignV4(*req, v.AccessKeyID, v.SecretAccessKey, v.SessionToken, c.region))
+	if err != nil {
+		return errors.Wrapf(errAdminAPIUnavailable, "error invoking %v: %v", method, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		// other servers don't implement the admin API and regular users of MinIO are not allowed to use it.
+		return errors.Wrapf(errAdminAPIUnavailable, "%v returned %v", method, resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return errors.Wrapf(errAdminAPIUnavailable, "invalid response of %v: %v", method, err)
+	}
+
+	return nil
+}
+
+// GetCapacity reports the usage of the bucket along with its quota, if any. With UseMinioAdminAPI, the usage
+// is looked up using the MinIO admin API and the quota configured in MinIO takes precedence over
+// CapacityQuotaBytes. When the usage is not available that way and CapacityFromListing is set, it is computed
+// by listing all blobs. Returns ErrNotAVolume when the usage can't be determined.
+func (s *s3Storage) GetCapacity(ctx context.Context) (blob.Capacity, error) {
+	quota := s.CapacityQuotaBytes
+
+	var (
+		used uint64
+		err  = errors.Wrap(errAdminAPIUnavailable, "not enabled")
+	)
+
+	if s.admin != nil {
+		used, err = s.admin.bucketUsage(ctx, s.BucketName)
+	}
+
+	switch {
+	case err == nil:
+		q, qerr := s.admin.bucketQuota(ctx, s.BucketName)
+		if qerr != nil && !errors.Is(qerr, errAdminAPIUnavailable) {
+			return blob.Capacity{}, qerr
+		}
+
+		if q != 0 {
+			quota = q
+		}
+
+	case !errors.Is(err, errAdminAPIUnavailable):
+		return blob.Capacity{}, err
+
+	case s.CapacityFromListing:
+		if used, err = s.usageFromListing(ctx); err != nil {
+			return blob.Capacity{}, err
+		}
+
+	default:
+		return blob.Capacity{}, errors.Wrap(blob.ErrNotAVolume, "bucket usage is not available")
+	}
+
+	c := blob.Capacity{
+		SizeB: quota,
+		UsedB: used,
+	}
+
+	if used < quota {
+		c.FreeB = quota - used
+	}
+
+	return c, nil
+}
+
+// usageFromListing returns the total size of all blobs, which is expensive for large buckets.
+func (s *s3Storage) usageFromListing(ctx context.Context) (uint64, error) {
+	var used uint64
+
+	if err := s.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		used += uint64(bm.Length)
+		return nil
+	}); err != nil {
+		return 0, errors.Wrap(err, "error listing blobs")
+	}
+
+	return used, nil
+}
@@ -0,0 +1,90 @@
+package s3
+
+import (
+	"context"
+	"encoding/base64"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	// checksumHeader is the native S3 header holding the CRC32C checksum of an object. When sent with
+	// an upload, the server rejects the upload if the contents don't match it.
+	checksumHeader = "X-Amz-Checksum-Crc32c"
+
+	// checksumModeHeader makes the server return the stored checksum when reading an entire object.
+	checksumModeHeader = "X-Amz-Checksum-Mode"
+)
+
+// ErrChecksumMismatch is returned when the downloaded contents of a blob don't match its stored checksum.
+var ErrChecksumMismatch = errors.New("blob checksum mismatch")
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksumKey is the context key holding the CRC32C checksum of the object uploaded by PutBlob().
+// minio-go does not expose the checksum in PutObjectOptions, so it is added at the HTTP level.
+type checksumKey struct{}
+
+func withChecksum(ctx context.Context, sum string) context.Context {
+	return context.WithValue(ctx, checksumKey{}, sum)
+}
+
+func checksumFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(checksumKey{}).(string)
+	return v
+}
+
+// crc32cChecksum returns the encoded CRC32C checksum of the provided data.
+func crc32cChecksum(data blob.Bytes) string {
+	h := crc32.New(crc32cTable)
+	data.WriteTo(h) // nolint:errcheck
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// verifyingBody verifies that the contents of the response body match the checksum returned by the server
+// and fails the read reaching the end of the body otherwise.
+type verifyingBody struct {
+	io.ReadCloser
+
+	h    hash.Hash32
+	want string
+}
+
+func (b *verifyingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.h.Write(p[0:n]) // nolint:errcheck
+
+	if errors.Is(err, io.EOF) {
+		if got := base64.StdEncoding.EncodeToString(b.h.Sum(nil)); got != b.want {
+			return n, errors.Wrapf(ErrChecksumMismatch, "got CRC32C %v, expected %v", got, b.want)
+		}
+	}
+
+	return n, err // nolint:wrapcheck
+}
+
+// maybeVerifyChecksum makes the response to a request of the entire object fail if its contents don't match
+// the checksum returned by the server, if any. Ranged reads don't return checksums, since they cover
+// the entire object.
+func maybeVerifyChecksum(req *http.Request, resp *http.Response) {
+	if req.Method != http.MethodGet || req.Header.Get(checksumModeHeader) == "" || resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	want := resp.Header.Get(checksumHeader)
+	if want == "" || strings.Contains(want, "-") {
+		// objects written by older versions or by servers not supporting checksums don't have one,
+		// checksums of objects uploaded in multiple parts are composite and don't cover the contents.
+		return
+	}
+
+	resp.Body = &verifyingBody{resp.Body, crc32.New(crc32cTable), want}
+}
@@ -0,0 +1,78 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// doNotRecreateKey is the context key marking requests that must not overwrite existing objects.
+type doNotRecreateKey struct{}
+
+func withDoNotRecreate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, doNotRecreateKey{}, true)
+}
+
+func isDoNotRecreate(ctx context.Context) bool {
+	v, _ := ctx.Value(doNotRecreateKey{}).(bool)
+	return v
+}
+
+// conditionalPutTransport adds 'If-None-Match: *' to requests creating objects on behalf of
+// PutBlob() with DoNotRecreate, which makes S3 reject them if the object already exists.
+// minio-go does not expose the header in PutObjectOptions, so it is added at the HTTP level.
+type conditionalPutTransport struct {
+	base http.RoundTripper
+}
+
+func (t conditionalPutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isDoNotRecreate(req.Context()) && isObjectCreationRequest(req) {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", "*")
+	}
+
+	// nolint:wrapcheck
+	return t.base.RoundTrip(req)
+}
+
+// isObjectCreationRequest determines whether the request creates an object, either with a single PUT
+// or by completing a multipart upload. Uploads of individual parts are not conditional.
+func isObjectCreationRequest(req *http.Request) bool {
+	q := req.URL.Query()
+
+	switch req.Method {
+	case http.MethodPut:
+		return q.Get("uploadId") == "" && q.Get("partNumber") == ""
+
+	case http.MethodPost:
+		return q.Get("uploadId") != ""
+
+	default:
+		return false
+	}
+}
+
+// translateConditionalPutError translates errors returned by conditional PutObject.
+func translateConditionalPutError(err error) error {
+	var me minio.ErrorResponse
+
+	if !errors.As(err, &me) {
+		return err
+	}
+
+	switch {
+	case me.StatusCode == http.StatusPreconditionFailed || me.Code == "PreconditionFailed":
+		return blob.ErrBlobAlreadyExists
+
+	case me.StatusCode == http.StatusNotImplemented || me.Code == "NotImplemented":
+		// the endpoint does not support conditional writes.
+		return errors.Wrap(blob.ErrUnsupportedPutBlobOption, "do-not-recreate")
+
+	default:
+		return err
+	}
+}
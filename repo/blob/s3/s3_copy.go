@@ -0,0 +1,101 @@
+package s3
+
+import (
+	"context"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// CopyBlob copies the contents of blob src to dst using server-side copy, without transferring them
+// through the client. Storage class, retention, legal hold and user metadata of the source are preserved.
+// Returns ErrBlobNotFound if the source blob does not exist.
+func (s *s3Storage) CopyBlob(ctx context.Context, src, dst blob.ID) error {
+	if s.Anonymous {
+		return errAnonymousReadOnly
+	}
+
+	srcName := s.getObjectNameString(src)
+
+	oi, err := s.cli.StatObject(ctx, s.BucketName, srcName, minio.GetObjectOptions{})
+	if err != nil {
+		return errors.Wrapf(translateError(err), "error getting metadata of %v", src)
+	}
+
+	dstOpts := minio.CopyDestOptions{
+		Bucket:          s.BucketName,
+		Object:          s.getObjectNameString(dst),
+		Encryption:      s.sse,
+		ReplaceMetadata: true,
+		UserMetadata:    copiedUserMetadata(&oi, oi.StorageClass),
+	}
+
+	dstOpts.Mode, dstOpts.RetainUntilDate = objectRetention(&oi)
+
+	if lh := minio.LegalHoldStatus(oi.Metadata.Get("X-Amz-Object-Lock-Legal-Hold")); lh == minio.LegalHoldEnabled {
+		dstOpts.LegalHold = lh
+	}
+
+	_, err = s.cli.CopyObject(ctx, dstOpts, minio.CopySrcOptions{
+		Bucket: s.BucketName,
+		Object: srcName,
+		// make sure the source has not been replaced since we got its metadata.
+		MatchETag: oi.ETag,
+	})
+	if err != nil {
+		return errors.Wrapf(translateError(err), "error copying %v to %v", src, dst)
+	}
+
+	return nil
+}
+
+// MoveBlob copies blob src to dst using server-side copy and deletes the source.
+func (s *s3Storage) MoveBlob(ctx context.Context, src, dst blob.ID) error {
+	if err := s.CopyBlob(ctx, src, dst); err != nil {
+		return err
+	}
+
+	if err := s.DeleteBlob(ctx, src); err != nil {
+		return errors.Wrapf(err, "error deleting %v after copying it to %v", src, dst)
+	}
+
+	return nil
+}
+
+// copiedUserMetadata returns the metadata of a copy of the provided object using the provided storage class.
+// Storage class can only be set by replacing metadata, which requires restating all of it, including
+// content type and user metadata such as modification time.
+func copiedUserMetadata(oi *minio.ObjectInfo, storageClass string) map[string]string {
+	userMetadata := map[string]string{
+		"Content-Type": "application/x-kopia",
+	}
+
+	for k, v := range oi.UserMetadata {
+		userMetadata[k] = v
+	}
+
+	if storageClass != "" {
+		userMetadata["X-Amz-Storage-Class"] = storageClass
+	}
+
+	return userMetadata
+}
+
+// objectRetention returns the retention mode and period of the object, if it is still retained.
+func objectRetention(oi *minio.ObjectInfo) (minio.RetentionMode, time.Time) {
+	mode := minio.RetentionMode(oi.Metadata.Get("X-Amz-Object-Lock-Mode"))
+	if !mode.IsValid() {
+		return "", time.Time{}
+	}
+
+	retainUntil, err := time.Parse(time.RFC3339, oi.Metadata.Get("X-Amz-Object-Lock-Retain-Until-Date"))
+	if err != nil || !retainUntil.After(clock.Now()) {
+		return "", time.Time{}
+	}
+
+	return mode, retainUntil
+}
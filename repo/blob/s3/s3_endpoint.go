@@ -0,0 +1,56 @@
+package s3
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// s3Endpoint describes the S3 endpoint, which can be specified either as host[:port] or as
+// a URL with a scheme and an optional base path, such as https://host/s3/.
+type s3Endpoint struct {
+	host   string
+	secure bool
+
+	// base path without the trailing slash, along with its escaped form, empty if none.
+	basePath    string
+	rawBasePath string
+}
+
+// parseEndpoint validates and normalizes the provided endpoint. When the endpoint includes a scheme,
+// it determines whether TLS is used, overriding doNotUseTLS.
+func parseEndpoint(endpoint string, doNotUseTLS bool) (s3Endpoint, error) {
+	if !strings.Contains(endpoint, "://") {
+		return s3Endpoint{host: endpoint, secure: !doNotUseTLS}, nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return s3Endpoint{}, errors.Wrapf(err, "invalid endpoint URL %q", endpoint)
+	}
+
+	ep := s3Endpoint{host: u.Host}
+
+	switch u.Scheme {
+	case "https":
+		ep.secure = true
+	case "http":
+		ep.secure = false
+	default:
+		return s3Endpoint{}, errors.Errorf("invalid endpoint URL %q, scheme must be http or https", endpoint)
+	}
+
+	if u.Host == "" {
+		return s3Endpoint{}, errors.Errorf("invalid endpoint URL %q, missing host", endpoint)
+	}
+
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return s3Endpoint{}, errors.Errorf("invalid endpoint URL %q, only scheme, host, port and path are allowed", endpoint)
+	}
+
+	ep.basePath = strings.TrimSuffix(u.Path, "/")
+	ep.rawBasePath = strings.TrimSuffix(u.EscapedPath(), "/")
+
+	return ep, nil
+}
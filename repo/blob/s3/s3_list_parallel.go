@@ -0,0 +1,98 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// number of ranges of object names listed in parallel, split on the two hex characters following the prefix.
+const parallelListShards = 256
+
+// listRange is a range of object names (startAfter, end], end == "" means unbounded.
+type listRange struct {
+	startAfter string
+	end        string
+}
+
+// parallelListRanges splits the object names with the provided prefix into ranges separated by
+// two-hex-character sub-prefixes. The ranges cover all object names, including ones that don't
+// continue with hex characters.
+func parallelListRanges(objectPrefix string) []listRange {
+	var result []listRange
+
+	startAfter := ""
+
+	for i := 1; i < parallelListShards; i++ {
+		boundary := fmt.Sprintf("%v%02x", objectPrefix, i)
+
+		result = append(result, listRange{startAfter, boundary})
+		startAfter = boundary
+	}
+
+	return append(result, listRange{startAfter, ""})
+}
+
+// listBlobsParallel lists ranges of blobs with the provided prefix using ListParallelism concurrent listings.
+// The callback is invoked on the calling goroutine, but blobs are not reported in lexicographical order.
+// The listing is canceled when the callback returns an error.
+func (s *s3Storage) listBlobsParallel(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ranges := make(chan listRange, parallelListShards)
+	for _, r := range parallelListRanges(s.getObjectNameString(prefix)) {
+		ranges <- r
+	}
+
+	close(ranges)
+
+	// channel to which listings will write blob.Metadata
+	result := make(chan blob.Metadata, 128) // nolint:gomnd
+
+	eg, ectx := errgroup.WithContext(ctx)
+
+	for i := 0; i < s.ListParallelism; i++ {
+		eg.Go(func() error {
+			for r := range ranges {
+				if err := s.listBlobsInRange(ectx, prefix, r.startAfter, r.end, func(bm blob.Metadata) error {
+					select {
+					case result <- bm:
+						return nil
+					case <-ectx.Done():
+						return ectx.Err()
+					}
+				}); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}
+
+	go func() {
+		_ = eg.Wait()
+
+		close(result)
+	}()
+
+	// invoke the callback on the current goroutine until it fails
+	for bm := range result {
+		if err := callback(bm); err != nil {
+			// stop all listings and wait for them to complete.
+			cancel()
+
+			for range result {
+			}
+
+			return err
+		}
+	}
+
+	// nolint:wrapcheck
+	return eg.Wait()
+}
@@ -0,0 +1,74 @@
+package s3
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/kopia/kopia/internal/timetrack"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// names of S3 operations reported in metrics.
+const (
+	opGetObject    = "GetObject"
+	opPutObject    = "PutObject"
+	opStatObject   = "StatObject"
+	opRemoveObject = "RemoveObject"
+	opListObjects  = "ListObjects"
+)
+
+// S3 request metrics.
+// nolint:gochecknoglobals,promlinter
+var (
+	metricRequestCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kopia_s3_request_count",
+		Help: "Number of S3 requests by operation and result",
+	}, []string{"op", "result"})
+
+	metricRequestBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kopia_s3_request_bytes",
+		Help: "Number of bytes transferred by successful S3 requests by operation",
+	}, []string{"op"})
+
+	metricRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kopia_s3_request_duration_seconds",
+		Help:    "Duration of S3 requests by operation and result",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14), // nolint:gomnd
+	}, []string{"op", "result"})
+)
+
+// requestResult returns the class of the (translated) error returned by S3 request, used as metric label.
+func requestResult(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, blob.ErrBlobNotFound):
+		return "not_found"
+	case errors.Is(err, blob.ErrBlobAlreadyExists):
+		return "already_exists"
+	case errors.Is(err, blob.ErrInvalidRange):
+		return "invalid_range"
+	case errors.Is(err, blob.ErrInvalidCredentials):
+		return "invalid_credentials"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+// reportRequest records the outcome of S3 request started at the provided time,
+// which transferred the provided number of bytes.
+func reportRequest(op string, timer timetrack.Timer, bytes int64, err error) {
+	result := requestResult(err)
+
+	metricRequestCount.WithLabelValues(op, result).Inc()
+	metricRequestDuration.WithLabelValues(op, result).Observe(timer.Elapsed().Seconds())
+
+	if err == nil && bytes > 0 {
+		metricRequestBytes.WithLabelValues(op).Add(float64(bytes))
+	}
+}
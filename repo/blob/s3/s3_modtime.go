@@ -0,0 +1,42 @@
+package s3
+
+import (
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/kopia/kopia/internal/timestampmeta"
+)
+
+// modTimeMetadataKey is the user metadata key (stored as x-amz-meta-kopia-mtime) holding the modification
+// time requested with PutBlob() when StoreModTimeInMetadata is enabled. The key and encoding are the same
+// as used by other storage providers.
+const modTimeMetadataKey = "Kopia-Mtime"
+
+// objectTimestamp returns the modification time stored in the user metadata of the object,
+// if present and valid, otherwise its LastModified time.
+func objectTimestamp(oi *minio.ObjectInfo) time.Time {
+	if t, ok := timestampmeta.FromValue(objectUserMetadata(oi, modTimeMetadataKey)); ok {
+		return t
+	}
+
+	return oi.LastModified
+}
+
+// objectUserMetadata returns the value of the provided user metadata key of the object or empty string.
+// Objects returned by StatObject() have user metadata keys without the x-amz-meta- prefix, while
+// listings that include metadata report them with the prefix.
+func objectUserMetadata(oi *minio.ObjectInfo, key string) string {
+	if v := oi.Metadata.Get("X-Amz-Meta-" + key); v != "" {
+		return v
+	}
+
+	for k, v := range oi.UserMetadata {
+		if strings.EqualFold(k, key) || strings.EqualFold(k, "X-Amz-Meta-"+key) {
+			return v
+		}
+	}
+
+	return ""
+}
@@ -6,6 +6,24 @@
 	"github.com/kopia/kopia/repo/blob/throttling"
 )
 
+// Supported credential providers.
+const (
+	// CredentialProviderStatic uses AccessKeyID, SecretAccessKey and SessionToken from Options.
+	CredentialProviderStatic = "static"
+
+	// CredentialProviderIAM uses credentials of the IAM role assigned to EC2 instance or ECS task, or
+	// the web identity when running with IAM roles for service accounts (IRSA).
+	CredentialProviderIAM = "iam"
+
+	// CredentialProviderWebIdentity uses the web identity token and role specified in AWS_WEB_IDENTITY_TOKEN_FILE
+	// and AWS_ROLE_ARN environment variables, as configured by IAM roles for service accounts (IRSA).
+	CredentialProviderWebIdentity = "web-identity"
+
+	// CredentialProviderEnv uses credentials from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+	// environment variables.
+	CredentialProviderEnv = "env"
+)
+
 // Options defines options for S3-based storage.
 type Options struct {
 	// BucketName is the name of the bucket where data is stored.
@@ -14,19 +32,106 @@ type Options struct {
 	// Prefix specifies additional string to prepend to all objects.
 	Prefix string `json:"prefix,omitempty"`
 
+	// Endpoint is either host[:port] or a URL with an optional base path, such as https://host/s3/.
 	Endpoint       string `json:"endpoint"`
 	DoNotUseTLS    bool   `json:"doNotUseTLS,omitempty"`
 	DoNotVerifyTLS bool   `json:"doNotVerifyTLS,omitempty"`
 
+	// UseTransferAcceleration routes requests through the S3 Transfer Acceleration endpoint,
+	// which must be enabled for the bucket. Only supported with the default AWS endpoint.
+	UseTransferAcceleration bool `json:"useTransferAcceleration,omitempty"`
+
+	// RootCAPEMFile specifies the name of the file with PEM-encoded root certificates used to verify
+	// TLS certificate of the endpoint instead of system root certificates.
+	RootCAPEMFile string `json:"rootCAFile,omitempty"`
+
+	// RootCAPEM specifies the raw PEM-encoded root certificates, takes precedence over RootCAPEMFile.
+	RootCAPEM []byte `json:"rootCA,omitempty"`
+
+	// CredentialProvider specifies how credentials are obtained, one of CredentialProvider* constants.
+	// Static credentials specified below are used by default.
+	CredentialProvider string `json:"credentialProvider,omitempty"`
+
 	AccessKeyID     string `json:"accessKeyID"`
 	SecretAccessKey string `json:"secretAccessKey" kopia:"sensitive"`
 	SessionToken    string `json:"sessionToken" kopia:"sensitive"`
 
+	// Anonymous enables unsigned requests without any credentials, which allows reading public buckets.
+	// Storage accessed anonymously is read-only.
+	Anonymous bool `json:"anonymous,omitempty"`
+
 	// Region is an optional region to pass in authorization header.
 	Region string `json:"region,omitempty"`
 
+	// ServerSideEncryption is an optional server-side encryption method applied to stored objects,
+	// either "AES256" (SSE-S3) or "aws:kms" (SSE-KMS).
+	ServerSideEncryption string `json:"serverSideEncryption,omitempty"`
+
+	// KMSKeyID is an optional ID of the KMS key used with "aws:kms" server-side encryption.
+	// If not specified, the default KMS key of the account is used.
+	KMSKeyID string `json:"kmsKeyID,omitempty"`
+
+	// Tags specifies object tags applied to all stored objects. Tags for particular blob ID prefixes
+	// can be overridden in the storage config.
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// ListParallelism specifies the number of concurrent listings used by ListBlobs, which does not
+	// report blobs in lexicographical order when greater than one.
+	ListParallelism int `json:"listParallelism,omitempty"`
+
+	// CapacityQuotaBytes is an optional quota of the bucket reported as its total capacity, when the server
+	// does not have a quota configured for the bucket.
+	CapacityQuotaBytes uint64 `json:"capacityQuotaBytes,omitempty"`
+
+	// UseMinioAdminAPI enables looking up usage and quota of the bucket using the MinIO admin API, which is only
+	// provided by MinIO servers to administrators. Requests to the API are signed using the credentials above,
+	// so it must only be enabled for trusted MinIO endpoints.
+	UseMinioAdminAPI bool `json:"useMinioAdminAPI,omitempty"`
+
+	// CapacityFromListing enables computing usage of the bucket by listing all blobs when it's not available
+	// from the MinIO admin API. The listing is repeated every time capacity is queried, which is expensive
+	// for large buckets.
+	CapacityFromListing bool `json:"capacityFromListing,omitempty"`
+
+	// StoreModTimeInMetadata enables PutBlob() with SetModTime by storing the requested modification time
+	// in user metadata of the object, which takes precedence over its LastModified time. ListBlobs() only
+	// reports such times when the server includes user metadata in listings (MinIO does, AWS S3 doesn't),
+	// otherwise it reports LastModified, which may differ from the time returned by GetMetadata().
+	StoreModTimeInMetadata bool `json:"storeModTimeInMetadata,omitempty"`
+
+	// UseCRC32CChecksums makes PutBlob() send the CRC32C checksum of each blob, which the server verifies
+	// and stores with the object, and GetBlob() verify it when reading entire blobs. Requires a server
+	// supporting S3 additional checksums. Blobs are uploaded using a single request in this mode.
+	UseCRC32CChecksums bool `json:"useCRC32CChecksums,omitempty"`
+
+	// RangeCoalescingGap is the maximum number of bytes between ranges of a blob that GetBlobRanges reads
+	// using a single request. Zero uses DefaultRangeCoalescingGap, negative only coalesces adjacent ranges.
+	RangeCoalescingGap int64 `json:"rangeCoalescingGap,omitempty"`
+
+	// HTTPTimeouts specifies optional timeouts of HTTP requests.
+	HTTPTimeouts *HTTPTimeouts `json:"httpTimeouts,omitempty"`
+
 	throttling.Limits
 
 	// PointInTime specifies a view of the (versioned) store at that time
 	PointInTime *time.Time `json:"pointInTime,omitempty"`
 }
+
+// HTTPTimeouts defines timeouts of HTTP connections to the S3 endpoint, zero values leave default timeouts unchanged.
+type HTTPTimeouts struct {
+	// DialTimeout is the maximum amount of time to wait for a connection to be established.
+	DialTimeout time.Duration `json:"dialTimeout,omitempty"`
+
+	// TLSHandshakeTimeout is the maximum amount of time to wait for a TLS handshake.
+	TLSHandshakeTimeout time.Duration `json:"tlsHandshakeTimeout,omitempty"`
+
+	// ResponseHeaderTimeout is the maximum amount of time to wait for response headers after sending a request.
+	ResponseHeaderTimeout time.Duration `json:"responseHeaderTimeout,omitempty"`
+
+	// IdleConnTimeout is the maximum amount of time an idle connection remains open.
+	IdleConnTimeout time.Duration `json:"idleConnTimeout,omitempty"`
+}
+
+func (t *HTTPTimeouts) isSet() bool {
+	return t != nil && *t != HTTPTimeouts{}
+}
@@ -0,0 +1,123 @@
+package s3
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// DefaultRangeCoalescingGap is the default maximum number of bytes between two ranges
+// read by GetBlobRanges in a single request.
+const DefaultRangeCoalescingGap = 256 << 10
+
+// rangeEnd returns the offset just past the end of the provided range.
+func rangeEnd(r blob.Range) int64 {
+	return r.Offset + r.Length
+}
+
+// rangeGroup is a set of ranges fetched with a single request.
+type rangeGroup struct {
+	span    blob.Range
+	indexes []int
+}
+
+// GetBlobRanges implements blob.RangeGetter. Nearby ranges are coalesced and read using a single request
+// spanning all of them, as long as the gaps between them don't exceed RangeCoalescingGap.
+func (s *s3Storage) GetBlobRanges(ctx context.Context, b blob.ID, ranges []blob.Range, outputs []blob.OutputBuffer) error {
+	if len(ranges) != len(outputs) {
+		return errors.Errorf("got %v ranges but %v outputs", len(ranges), len(outputs))
+	}
+
+	groups, err := coalesceRanges(ranges, s.rangeCoalescingGap())
+	if err != nil {
+		return err
+	}
+
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	for _, g := range groups {
+		if err := s.getBlobWithVersion(ctx, b, latestVersionID, g.span.Offset, g.span.Length, &tmp); err != nil {
+			return err
+		}
+
+		data := tmp.ToByteSlice()
+
+		for _, i := range g.indexes {
+			r := ranges[i]
+			start := r.Offset - g.span.Offset
+
+			outputs[i].Reset()
+
+			if _, err := outputs[i].Write(data[start : start+r.Length]); err != nil {
+				return errors.Wrap(err, "error writing output")
+			}
+		}
+	}
+
+	// empty ranges don't belong to any group.
+	for i, r := range ranges {
+		if r.Length == 0 {
+			outputs[i].Reset()
+		}
+	}
+
+	return nil
+}
+
+func (s *s3Storage) rangeCoalescingGap() int64 {
+	switch {
+	case s.RangeCoalescingGap == 0:
+		return DefaultRangeCoalescingGap
+	case s.RangeCoalescingGap < 0:
+		return 0
+	default:
+		return s.RangeCoalescingGap
+	}
+}
+
+// coalesceRanges groups the provided ranges, such that gaps between ranges in each group don't exceed maxGap.
+// Overlapping and adjacent ranges are always grouped, empty ranges are omitted.
+func coalesceRanges(ranges []blob.Range, maxGap int64) ([]rangeGroup, error) {
+	var indexes []int
+
+	for i, r := range ranges {
+		if r.Offset < 0 || r.Length < 0 {
+			return nil, errors.Wrapf(blob.ErrInvalidRange, "invalid range %v+%v", r.Offset, r.Length)
+		}
+
+		if r.Length > 0 {
+			indexes = append(indexes, i)
+		}
+	}
+
+	sort.Slice(indexes, func(a, b int) bool {
+		return ranges[indexes[a]].Offset < ranges[indexes[b]].Offset
+	})
+
+	var result []rangeGroup
+
+	for _, i := range indexes {
+		r := ranges[i]
+
+		if n := len(result); n > 0 && r.Offset-rangeEnd(result[n-1].span) <= maxGap {
+			g := &result[n-1]
+
+			if rangeEnd(r) > rangeEnd(g.span) {
+				g.span.Length = rangeEnd(r) - g.span.Offset
+			}
+
+			g.indexes = append(g.indexes, i)
+
+			continue
+		}
+
+		result = append(result, rangeGroup{span: r, indexes: []int{i}})
+	}
+
+	return result, nil
+}
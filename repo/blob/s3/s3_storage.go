@@ -5,38 +5,58 @@
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/minio/minio-go/v7/pkg/tags"
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/iocopy"
+	"github.com/kopia/kopia/internal/timestampmeta"
+	"github.com/kopia/kopia/internal/timetrack"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/readonly"
 	"github.com/kopia/kopia/repo/blob/retrying"
 )
 
 const (
 	s3storageType   = "s3"
 	latestVersionID = ""
+
+	defaultAWSEndpoint   = "s3.amazonaws.com"
+	s3AccelerateEndpoint = "s3-accelerate.amazonaws.com"
+
+	// server-side encryption methods.
+	serverSideEncryptionAES256 = "AES256"
+	serverSideEncryptionKMS    = "aws:kms"
 )
 
+// errAnonymousReadOnly is returned by all mutations of storage accessed anonymously.
+var errAnonymousReadOnly = errors.Wrap(readonly.ErrReadonly, "bucket is accessed anonymously")
+
 type s3Storage struct {
 	Options
 
 	cli *minio.Client
 
 	storageConfig *StorageConfig
-}
 
-func (s *s3Storage) GetCapacity(ctx context.Context) (blob.Capacity, error) {
-	return blob.Capacity{}, blob.ErrNotAVolume
+	// server-side encryption to apply to stored objects, nil if not enabled.
+	sse encrypt.ServerSide
+
+	// client of the MinIO admin API, nil unless UseMinioAdminAPI is set.
+	admin *minioAdminClient
 }
 
 func (s *s3Storage) GetBlob(ctx context.Context, b blob.ID, offset, length int64, output blob.OutputBuffer) error {
@@ -44,9 +64,12 @@ func (s *s3Storage) GetBlob(ctx context.Context, b blob.ID, offset, length int64
 }
 
 // getBlobWithVersion returns full or partial contents of a blob with given ID and version.
+// Full reads are verified against the checksum stored with the object, partial reads are not.
 func (s *s3Storage) getBlobWithVersion(ctx context.Context, b blob.ID, version string, offset, length int64, output blob.OutputBuffer) error {
 	output.Reset()
 
+	timer := timetrack.StartTimer()
+
 	attempt := func() error {
 		opt := minio.GetObjectOptions{VersionID: version}
 
@@ -64,6 +87,11 @@ func (s *s3Storage) getBlobWithVersion(ctx context.Context, b blob.ID, version s
 			}
 		}
 
+		if length < 0 && s.UseCRC32CChecksums {
+			// the checksum covers the entire object, so partial reads are returned unverified.
+			opt.Set(checksumModeHeader, "ENABLED")
+		}
+
 		o, err := s.cli.GetObject(ctx, s.BucketName, s.getObjectNameString(b), opt)
 		if err != nil {
 			return errors.Wrap(err, "GetObject")
@@ -80,9 +108,14 @@ func (s *s3Storage) getBlobWithVersion(ctx context.Context, b blob.ID, version s
 	}
 
 	if err := attempt(); err != nil {
-		return translateError(err)
+		err = translateError(err)
+		reportRequest(opGetObject, timer, 0, err)
+
+		return err
 	}
 
+	reportRequest(opGetObject, timer, int64(output.Length()), nil)
+
 	// nolint:wrapcheck
 	return blob.EnsureLengthExactly(output.Length(), length)
 }
@@ -125,23 +158,41 @@ func (s *s3Storage) getVersionMetadata(ctx context.Context, b blob.ID, version s
 		VersionID: version,
 	}
 
+	timer := timetrack.StartTimer()
+
 	oi, err := s.cli.StatObject(ctx, s.BucketName, s.getObjectNameString(b), opts)
+	err = translateError(err)
+
+	reportRequest(opStatObject, timer, 0, err)
+
 	if err != nil {
-		return versionMetadata{}, errors.Wrap(translateError(err), "StatObject")
+		return versionMetadata{}, errors.Wrap(err, "StatObject")
 	}
 
 	return infoToVersionMetadata(s.Prefix, &oi), nil
 }
 
 func (s *s3Storage) PutBlob(ctx context.Context, b blob.ID, data blob.Bytes, opts blob.PutOptions) error {
-	switch {
-	case opts.DoNotRecreate:
-		return errors.Wrap(blob.ErrUnsupportedPutBlobOption, "do-not-recreate")
-	case !opts.SetModTime.IsZero():
+	if s.Anonymous {
+		return errAnonymousReadOnly
+	}
+
+	if !opts.SetModTime.IsZero() && !s.StoreModTimeInMetadata {
 		return blob.ErrSetTimeUnsupported
 	}
 
+	timer := timetrack.StartTimer()
+
 	_, err := s.putBlob(ctx, b, data, opts)
+	if err != nil && opts.DoNotRecreate {
+		err = translateConditionalPutError(err)
+	}
+
+	reportRequest(opPutObject, timer, int64(data.Length()), translateError(err))
+
+	if err != nil && opts.DoNotRecreate {
+		return err
+	}
 
 	if opts.GetModTime != nil {
 		bm, err2 := s.GetMetadata(ctx, b)
@@ -158,29 +209,55 @@ func (s *s3Storage) PutBlob(ctx context.Context, b blob.ID, data blob.Bytes, opt
 func (s *s3Storage) putBlob(ctx context.Context, b blob.ID, data blob.Bytes, opts blob.PutOptions) (versionMetadata, error) {
 	var (
 		storageClass    = s.storageConfig.getStorageClassForBlobID(b)
+		userTags        = s.storageConfig.getTagsForBlobID(b, s.Tags)
 		retentionMode   minio.RetentionMode
 		retainUntilDate time.Time
+		legalHold       minio.LegalHoldStatus
+		userMetadata    map[string]string
 	)
 
-	if opts.RetentionPeriod != 0 {
-		retentionMode = minio.RetentionMode(opts.RetentionMode)
+	if mode, period := s.storageConfig.getRetentionForBlobID(b, opts.RetentionMode, opts.RetentionPeriod); period != 0 {
+		retentionMode = minio.RetentionMode(mode)
 		if !retentionMode.IsValid() {
-			return versionMetadata{}, errors.Errorf("invalid retention mode: %q", opts.RetentionMode)
+			return versionMetadata{}, errors.Errorf("invalid retention mode: %q", mode)
 		}
 
-		retainUntilDate = clock.Now().Add(opts.RetentionPeriod).UTC()
+		retainUntilDate = clock.Now().Add(period).UTC()
+	}
+
+	if opts.LegalHold {
+		legalHold = minio.LegalHoldEnabled
+	}
+
+	if !opts.SetModTime.IsZero() {
+		userMetadata = timestampmeta.ToMap(opts.SetModTime, modTimeMetadataKey)
+	}
+
+	if opts.DoNotRecreate {
+		ctx = withDoNotRecreate(ctx)
+	}
+
+	if s.UseCRC32CChecksums {
+		// the server verifies the uploaded contents against the checksum, see rewritingTransport.
+		ctx = withChecksum(ctx, crc32cChecksum(data))
 	}
 
 	uploadInfo, err := s.cli.PutObject(ctx, s.BucketName, s.getObjectNameString(b), data.Reader(), int64(data.Length()), minio.PutObjectOptions{
 		ContentType: "application/x-kopia",
 		// The Content-MD5 header is required for any request to upload an object
-		// with a retention period configured using Amazon S3 Object Lock.
+		// with a retention period or legal hold configured using Amazon S3 Object Lock.
 		// Unconditionally computing the content MD5, potentially incurring
 		// a slightly higher CPU overhead.
-		SendContentMd5:  true,
-		StorageClass:    storageClass,
-		RetainUntilDate: retainUntilDate,
-		Mode:            retentionMode,
+		SendContentMd5:       true,
+		StorageClass:         storageClass,
+		RetainUntilDate:      retainUntilDate,
+		Mode:                 retentionMode,
+		LegalHold:            legalHold,
+		ServerSideEncryption: s.sse,
+		UserTags:             userTags,
+		UserMetadata:         userMetadata,
+		// the checksum covers the entire blob, so it must be uploaded with a single request.
+		DisableMultipart: s.UseCRC32CChecksums,
 	})
 
 	if isInvalidCredentials(err) {
@@ -196,10 +273,15 @@ func (s *s3Storage) putBlob(ctx context.Context, b blob.ID, data blob.Bytes, opt
 	if errors.Is(err, io.EOF) && uploadInfo.Size == 0 {
 		// special case empty stream
 		_, err = s.cli.PutObject(ctx, s.BucketName, s.getObjectNameString(b), bytes.NewBuffer(nil), 0, minio.PutObjectOptions{
-			ContentType:     "application/x-kopia",
-			StorageClass:    storageClass,
-			RetainUntilDate: retainUntilDate,
-			Mode:            retentionMode,
+			ContentType:          "application/x-kopia",
+			SendContentMd5:       true,
+			StorageClass:         storageClass,
+			RetainUntilDate:      retainUntilDate,
+			Mode:                 retentionMode,
+			LegalHold:            legalHold,
+			ServerSideEncryption: s.sse,
+			UserTags:             userTags,
+			UserMetadata:         userMetadata,
 		})
 	}
 
@@ -207,18 +289,32 @@ func (s *s3Storage) putBlob(ctx context.Context, b blob.ID, data blob.Bytes, opt
 		return versionMetadata{}, err // nolint:wrapcheck
 	}
 
+	timestamp := uploadInfo.LastModified
+	if !opts.SetModTime.IsZero() {
+		timestamp = opts.SetModTime
+	}
+
 	return versionMetadata{
 		Metadata: blob.Metadata{
 			BlobID:    b,
 			Length:    uploadInfo.Size,
-			Timestamp: uploadInfo.LastModified,
+			Timestamp: timestamp,
 		},
 		Version: uploadInfo.VersionID,
 	}, nil
 }
 
 func (s *s3Storage) DeleteBlob(ctx context.Context, b blob.ID) error {
+	if s.Anonymous {
+		return errAnonymousReadOnly
+	}
+
+	timer := timetrack.StartTimer()
+
 	err := translateError(s.cli.RemoveObject(ctx, s.BucketName, s.getObjectNameString(b), minio.RemoveObjectOptions{}))
+
+	reportRequest(opRemoveObject, timer, 0, err)
+
 	if errors.Is(err, blob.ErrBlobNotFound) {
 		return nil
 	}
@@ -230,13 +326,46 @@ func (s *s3Storage) getObjectNameString(b blob.ID) string {
 	return s.Prefix + string(b)
 }
 
+// ListBlobs invokes the provided callback for all blobs with the provided prefix. When ListParallelism
+// is greater than one, multiple ranges of blob IDs are listed concurrently and blobs are not reported
+// in lexicographical order.
 func (s *s3Storage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	if s.ListParallelism > 1 {
+		return s.listBlobsParallel(ctx, prefix, callback)
+	}
+
+	return s.listBlobsInRange(ctx, prefix, "", "", callback)
+}
+
+// ListBlobsAfter implements blob.AfterLister by listing objects starting after the provided blob ID.
+func (s *s3Storage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	return s.listBlobsInRange(ctx, prefix, s.getObjectNameString(startAfter), "", callback)
+}
+
+// listBlobsInRange invokes the provided callback for blobs with the provided prefix, whose object names
+// are greater than startAfter and, if end is not empty, less than or equal to end.
+func (s *s3Storage) listBlobsInRange(ctx context.Context, prefix blob.ID, startAfter, end string, callback func(blob.Metadata) error) error {
+	timer := timetrack.StartTimer()
+
+	// the duration of listing includes time spent in the callback.
+	err := s.listObjectsInRange(ctx, prefix, startAfter, end, callback)
+
+	reportRequest(opListObjects, timer, 0, err)
+
+	return err
+}
+
+func (s *s3Storage) listObjectsInRange(ctx context.Context, prefix blob.ID, startAfter, end string, callback func(blob.Metadata) error) error {
 	ctx, cancel := context.WithCancel(ctx)
 
 	defer cancel()
 
 	oi := s.cli.ListObjects(ctx, s.BucketName, minio.ListObjectsOptions{
-		Prefix: s.getObjectNameString(prefix),
+		Prefix:     s.getObjectNameString(prefix),
+		StartAfter: startAfter,
+		// modification times stored in metadata can only be reported by servers which include
+		// user metadata in listings, such as MinIO, otherwise LastModified is reported.
+		WithMetadata: s.StoreModTimeInMetadata,
 	})
 	for o := range oi {
 		if err := o.Err; err != nil {
@@ -247,10 +376,14 @@ func (s *s3Storage) ListBlobs(ctx context.Context, prefix blob.ID, callback func
 			return err
 		}
 
+		if end != "" && o.Key > end {
+			return nil
+		}
+
 		bm := blob.Metadata{
 			BlobID:    blob.ID(o.Key[len(s.Prefix):]),
 			Length:    o.Size,
-			Timestamp: o.LastModified,
+			Timestamp: objectTimestamp(&o),
 		}
 
 		if bm.BlobID == ConfigName {
@@ -294,6 +427,87 @@ func getCustomTransport(insecureSkipVerify bool) (transport *http.Transport) {
 	return customTransport
 }
 
+func getTransportWithRootCAs(rootCAs *x509.CertPool) *http.Transport {
+	return &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}}
+}
+
+// newTransport returns HTTP transport for the provided options or nil if the default transport should be used.
+func newTransport(opt *Options, rootCAs *x509.CertPool) (*http.Transport, error) {
+	var transport *http.Transport
+
+	switch {
+	case rootCAs != nil:
+		// explicitly trusted root certificates take precedence over disabling verification.
+		transport = getTransportWithRootCAs(rootCAs)
+
+	case opt.DoNotVerifyTLS:
+		transport = getCustomTransport(true)
+
+	case opt.HTTPTimeouts.isSet():
+		t, err := minio.DefaultTransport(!opt.DoNotUseTLS)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create default transport")
+		}
+
+		transport = t
+
+	default:
+		return nil, nil
+	}
+
+	applyHTTPTimeouts(transport, opt.HTTPTimeouts)
+
+	return transport, nil
+}
+
+// applyHTTPTimeouts overrides timeouts of the provided transport with the ones that are set.
+func applyHTTPTimeouts(transport *http.Transport, t *HTTPTimeouts) {
+	if t == nil {
+		return
+	}
+
+	if t.DialTimeout != 0 {
+		transport.DialContext = (&net.Dialer{Timeout: t.DialTimeout}).DialContext
+	}
+
+	if t.TLSHandshakeTimeout != 0 {
+		transport.TLSHandshakeTimeout = t.TLSHandshakeTimeout
+	}
+
+	if t.ResponseHeaderTimeout != 0 {
+		transport.ResponseHeaderTimeout = t.ResponseHeaderTimeout
+	}
+
+	if t.IdleConnTimeout != 0 {
+		transport.IdleConnTimeout = t.IdleConnTimeout
+	}
+}
+
+// loadRootCAs returns the pool of root certificates specified in the options or nil if not specified.
+func loadRootCAs(opt *Options) (*x509.CertPool, error) {
+	data := opt.RootCAPEM
+
+	if len(data) == 0 {
+		if opt.RootCAPEMFile == "" {
+			return nil, nil
+		}
+
+		v, err := os.ReadFile(opt.RootCAPEMFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read root CA file")
+		}
+
+		data = v
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("unable to parse root CA certificates, expected PEM-encoded certificates")
+	}
+
+	return pool, nil
+}
+
 // New creates new S3-backed storage with specified options:
 //
 // - the 'BucketName' field is required and all other parameters are optional.
@@ -312,7 +526,110 @@ func New(ctx context.Context, opt *Options) (blob.Storage, error) {
 }
 
 func newStorage(ctx context.Context, opt *Options) (*s3Storage, error) {
-	return newStorageWithCredentials(ctx, credentials.NewStaticV4(opt.AccessKeyID, opt.SecretAccessKey, opt.SessionToken), opt)
+	creds, err := newCredentials(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStorageWithCredentials(ctx, creds, opt)
+}
+
+// newCredentials returns credentials obtained using the provider specified in the options.
+// Credentials other than static ones are refreshed automatically when they expire.
+func newCredentials(opt *Options) (*credentials.Credentials, error) {
+	if opt.Anonymous {
+		if opt.CredentialProvider != "" && opt.CredentialProvider != CredentialProviderStatic {
+			return nil, errors.Errorf("anonymous access can't be used with credential provider %q", opt.CredentialProvider)
+		}
+
+		if opt.AccessKeyID != "" || opt.SecretAccessKey != "" || opt.SessionToken != "" {
+			return nil, errors.New("anonymous access can't be used with access keys")
+		}
+
+		// minio sends unsigned requests when both keys are empty.
+		return credentials.NewStaticV4("", "", ""), nil
+	}
+
+	switch opt.CredentialProvider {
+	case "", CredentialProviderStatic:
+		return credentials.NewStaticV4(opt.AccessKeyID, opt.SecretAccessKey, opt.SessionToken), nil
+
+	case CredentialProviderIAM:
+		return credentials.NewIAM(""), nil
+
+	case CredentialProviderWebIdentity:
+		for _, name := range []string{"AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN"} {
+			if os.Getenv(name) == "" {
+				return nil, errors.Errorf("web identity credentials require %v environment variable", name)
+			}
+		}
+
+		// IAM provider obtains credentials using the web identity when the environment variables are set.
+		return credentials.NewIAM(""), nil
+
+	case CredentialProviderEnv:
+		return credentials.NewEnvAWS(), nil
+
+	default:
+		return nil, errors.Errorf("unsupported credential provider %q", opt.CredentialProvider)
+	}
+}
+
+// newServerSideEncryption returns server-side encryption settings corresponding to the provided options.
+func newServerSideEncryption(opt *Options) (encrypt.ServerSide, error) {
+	switch opt.ServerSideEncryption {
+	case "":
+		if opt.KMSKeyID != "" {
+			return nil, errors.Errorf("KMS key ID requires %q server-side encryption", serverSideEncryptionKMS)
+		}
+
+		return nil, nil
+
+	case serverSideEncryptionAES256:
+		if opt.KMSKeyID != "" {
+			return nil, errors.Errorf("KMS key ID requires %q server-side encryption", serverSideEncryptionKMS)
+		}
+
+		return encrypt.NewSSE(), nil
+
+	case serverSideEncryptionKMS:
+		sse, err := encrypt.NewSSEKMS(opt.KMSKeyID, nil)
+
+		return sse, errors.Wrap(err, "invalid KMS server-side encryption settings")
+
+	default:
+		return nil, errors.Errorf("unsupported server-side encryption %q, must be %q or %q", opt.ServerSideEncryption, serverSideEncryptionAES256, serverSideEncryptionKMS)
+	}
+}
+
+// validateTags ensures that the provided tags can be applied to S3 objects.
+func validateTags(t map[string]string) error {
+	if len(t) == 0 {
+		return nil
+	}
+
+	_, err := tags.MapToObjectTags(t)
+
+	return errors.Wrap(err, "invalid object tags")
+}
+
+// newMinioOptions returns options of the client using the provided transport, which is wrapped to make
+// changes to requests not supported by minio-go only when they are needed.
+func newMinioOptions(ep s3Endpoint, opt *Options, creds *credentials.Credentials, transport http.RoundTripper) *minio.Options {
+	minioOpts := &minio.Options{
+		Creds:     creds,
+		Secure:    ep.secure,
+		Region:    opt.Region,
+		Transport: conditionalPutTransport{transport},
+	}
+
+	if ep.basePath != "" || opt.UseCRC32CChecksums {
+		minioOpts.Transport = newRewritingTransport(minioOpts.Transport, ep, creds)
+		// the transport uses the actual scheme of the endpoint, see rewritingTransport.
+		minioOpts.Secure = true
+	}
+
+	return minioOpts
 }
 
 func newStorageWithCredentials(ctx context.Context, creds *credentials.Credentials, opt *Options) (*s3Storage, error) {
@@ -320,24 +637,73 @@ func newStorageWithCredentials(ctx context.Context, creds *credentials.Credentia
 		return nil, errors.New("bucket name must be specified")
 	}
 
-	minioOpts := &minio.Options{
-		Creds:  creds,
-		Secure: !opt.DoNotUseTLS,
-		Region: opt.Region,
+	sse, err := newServerSideEncryption(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateTags(opt.Tags); err != nil {
+		return nil, err
+	}
+
+	rootCAs, err := loadRootCAs(opt)
+	if err != nil {
+		return nil, err
 	}
 
-	if opt.DoNotVerifyTLS {
-		minioOpts.Transport = getCustomTransport(true)
+	endpoint := opt.Endpoint
+
+	if opt.UseTransferAcceleration {
+		if endpoint != "" && endpoint != defaultAWSEndpoint {
+			return nil, errors.Errorf("transfer acceleration can't be used with custom endpoint %q", endpoint)
+		}
+
+		// accelerated requests use virtual-hosted addressing, which does not support bucket names with dots.
+		if strings.Contains(opt.BucketName, ".") {
+			return nil, errors.Errorf("transfer acceleration can't be used with bucket %q, whose name contains dots", opt.BucketName)
+		}
+
+		endpoint = defaultAWSEndpoint
 	}
 
-	cli, err := minio.New(opt.Endpoint, minioOpts)
+	ep, err := parseEndpoint(endpoint, opt.DoNotUseTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := newTransport(opt, rootCAs)
+	if err != nil {
+		return nil, err
+	}
+
+	if transport == nil {
+		if transport, err = minio.DefaultTransport(ep.secure); err != nil {
+			return nil, errors.Wrap(err, "unable to create default transport")
+		}
+	}
+
+	minioOpts := newMinioOptions(ep, opt, creds, transport)
+
+	cli, err := minio.New(ep.host, minioOpts)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create client")
 	}
 
+	if opt.UseTransferAcceleration {
+		// all requests referring to the bucket, including checking its existence and reading
+		// the storage config, go through the accelerated endpoint.
+		cli.SetS3TransferAccelerate(s3AccelerateEndpoint)
+	}
+
 	ok, err := cli.BucketExists(ctx, opt.BucketName)
 	if err != nil {
-		return nil, errors.Wrapf(err, "unable to determine if bucket %q exists", opt.BucketName)
+		// public buckets often allow anonymous reads of objects, but not of the bucket itself,
+		// in which case missing bucket will be reported by subsequent reads.
+		if !opt.Anonymous || minio.ToErrorResponse(err).Code != "AccessDenied" {
+			return nil, errors.Wrapf(err, "unable to determine if bucket %q exists", opt.BucketName)
+		}
+
+		ok = true
 	}
 
 	if !ok {
@@ -348,6 +714,11 @@ func newStorageWithCredentials(ctx context.Context, creds *credentials.Credentia
 		Options:       *opt,
 		cli:           cli,
 		storageConfig: &StorageConfig{},
+		sse:           sse,
+	}
+
+	if opt.UseMinioAdminAPI {
+		s.admin = newMinioAdminClient(ep, opt.Region, creds, minioOpts.Transport)
 	}
 
 	var scOutput gather.WriteBuffer
@@ -356,6 +727,18 @@ func newStorageWithCredentials(ctx context.Context, creds *credentials.Credentia
 		if scErr := s.storageConfig.Load(scOutput.Bytes().Reader()); scErr != nil {
 			return nil, errors.Wrapf(scErr, "error parsing storage config for bucket %q", opt.BucketName)
 		}
+
+		for _, o := range s.storageConfig.BlobOptions {
+			if tagErr := validateTags(o.Tags); tagErr != nil {
+				return nil, errors.Wrapf(tagErr, "invalid storage config for bucket %q", opt.BucketName)
+			}
+		}
+
+		for prefix, r := range s.storageConfig.Retention {
+			if r.Period < 0 || (r.Period != 0 && !r.Mode.IsValid()) {
+				return nil, errors.Errorf("invalid retention %v %v for prefix %q in storage config for bucket %q", r.Mode, r.Period, prefix, opt.BucketName)
+			}
+		}
 	} else if !errors.Is(getBlobErr, blob.ErrBlobNotFound) {
 		return nil, errors.Wrapf(getBlobErr, "error retrieving storage config from bucket %q", opt.BucketName)
 	}
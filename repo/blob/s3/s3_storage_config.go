@@ -4,6 +4,7 @@
 	"encoding/json"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -13,15 +14,25 @@
 // ConfigName is the name of the hidden storage config file in a S3 bucket.
 const ConfigName = ".storageconfig"
 
-// PrefixAndStorageClass defines the storage class to use for a particular blob ID prefix.
+// PrefixAndStorageClass defines the storage class and object tags to use for a particular blob ID prefix.
 type PrefixAndStorageClass struct {
-	Prefix       blob.ID `json:"prefix"`
-	StorageClass string  `json:"storageClass"`
+	Prefix       blob.ID           `json:"prefix"`
+	StorageClass string            `json:"storageClass"`
+	Tags         map[string]string `json:"tags,omitempty"`
+}
+
+// PrefixRetention defines the object-lock retention to use for a particular blob ID prefix.
+type PrefixRetention struct {
+	Mode   blob.RetentionMode `json:"mode"`
+	Period time.Duration      `json:"period"`
 }
 
 // StorageConfig contains storage configuration optionally persisted in the storage itself.
 type StorageConfig struct {
 	BlobOptions []PrefixAndStorageClass `json:"blobOptions,omitempty"`
+
+	// retention keyed by blob ID prefix, the longest matching prefix wins.
+	Retention map[blob.ID]PrefixRetention `json:"retention,omitempty"`
 }
 
 // Load loads the StorageConfig from the provided reader.
@@ -43,3 +54,54 @@ func (p *StorageConfig) getStorageClassForBlobID(id blob.ID) string {
 
 	return ""
 }
+
+// getTagsForBlobID returns object tags for the provided blob ID, which are the provided default tags
+// overridden by tags defined for the first matching prefix.
+func (p *StorageConfig) getTagsForBlobID(id blob.ID, defaultTags map[string]string) map[string]string {
+	for _, o := range p.BlobOptions {
+		if !strings.HasPrefix(string(id), string(o.Prefix)) {
+			continue
+		}
+
+		if len(o.Tags) == 0 {
+			break
+		}
+
+		result := map[string]string{}
+
+		for k, v := range defaultTags {
+			result[k] = v
+		}
+
+		for k, v := range o.Tags {
+			result[k] = v
+		}
+
+		return result
+	}
+
+	return defaultTags
+}
+
+// getRetentionForBlobID returns the retention mode and period for the provided blob ID, which are taken from
+// the longest matching prefix in Retention or the provided defaults if no prefix matches.
+func (p *StorageConfig) getRetentionForBlobID(id blob.ID, defaultMode blob.RetentionMode, defaultPeriod time.Duration) (blob.RetentionMode, time.Duration) {
+	var (
+		match blob.ID
+		found bool
+	)
+
+	for prefix := range p.Retention {
+		if strings.HasPrefix(string(id), string(prefix)) && (!found || len(prefix) > len(match)) {
+			match, found = prefix, true
+		}
+	}
+
+	if !found {
+		return defaultMode, defaultPeriod
+	}
+
+	r := p.Retention[match]
+
+	return r.Mode, r.Period
+}
@@ -0,0 +1,115 @@
+package s3
+
+import (
+	"context"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// TransitionOldBlobs changes the storage class of blobs with the provided prefix that were last modified
+// more than olderThan ago to toClass, by copying each object onto itself. Blob IDs, contents and user
+// metadata are preserved, but LastModified times of transitioned blobs are updated.
+//
+// Each blob is transitioned atomically, so the operation is safe to interrupt by canceling the context.
+// Blobs that are already stored using toClass are skipped. Returns the number of transitioned blobs.
+func (s *s3Storage) TransitionOldBlobs(ctx context.Context, prefix blob.ID, olderThan time.Duration, toClass string) (int, error) {
+	if toClass == "" {
+		return 0, errors.New("storage class must be specified")
+	}
+
+	if s.Anonymous {
+		return 0, errAnonymousReadOnly
+	}
+
+	cutoff := clock.Now().Add(-olderThan)
+
+	var candidates []blob.ID
+
+	if err := s.listObjectsWithStorageClass(ctx, prefix, func(o minio.ObjectInfo) error {
+		bid := blob.ID(o.Key[len(s.Prefix):])
+
+		switch {
+		case bid == ConfigName:
+		case !o.LastModified.Before(cutoff):
+		case storageClassOrDefault(o.StorageClass) == storageClassOrDefault(toClass):
+		default:
+			candidates = append(candidates, bid)
+		}
+
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	for i, bid := range candidates {
+		if err := ctx.Err(); err != nil {
+			return i, errors.Wrap(err, "transition canceled")
+		}
+
+		if err := s.copyWithStorageClass(ctx, bid, toClass); err != nil {
+			return i, errors.Wrapf(err, "error transitioning %v to %v", bid, toClass)
+		}
+	}
+
+	return len(candidates), nil
+}
+
+func (s *s3Storage) listObjectsWithStorageClass(ctx context.Context, prefix blob.ID, callback func(o minio.ObjectInfo) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+
+	defer cancel()
+
+	for o := range s.cli.ListObjects(ctx, s.BucketName, minio.ListObjectsOptions{
+		Prefix:    s.getObjectNameString(prefix),
+		Recursive: true,
+	}) {
+		if err := o.Err; err != nil {
+			return translateError(err)
+		}
+
+		if err := callback(o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *s3Storage) copyWithStorageClass(ctx context.Context, b blob.ID, storageClass string) error {
+	objectName := s.getObjectNameString(b)
+
+	oi, err := s.cli.StatObject(ctx, s.BucketName, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return errors.Wrap(translateError(err), "error getting metadata")
+	}
+
+	_, err = s.cli.CopyObject(ctx, minio.CopyDestOptions{
+		Bucket:          s.BucketName,
+		Object:          objectName,
+		Encryption:      s.sse,
+		ReplaceMetadata: true,
+		UserMetadata:    copiedUserMetadata(&oi, storageClass),
+	}, minio.CopySrcOptions{
+		Bucket: s.BucketName,
+		Object: objectName,
+		// make sure the object has not been replaced since we got its metadata.
+		MatchETag: oi.ETag,
+	})
+
+	return translateError(err)
+}
+
+// storageClassOrDefault returns the provided storage class or STANDARD if not specified, since
+// S3 does not report the storage class of objects stored using the default class.
+func storageClassOrDefault(sc string) string {
+	if sc == "" {
+		return "STANDARD"
+	}
+
+	return sc
+}
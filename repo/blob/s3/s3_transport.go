@@ -0,0 +1,140 @@
+package s3
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/signer"
+	"github.com/pkg/errors"
+)
+
+const (
+	signV4Algorithm  = "AWS4-HMAC-SHA256"
+	streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+)
+
+// rewritingTransport makes changes to requests which are not supported by minio-go. It prefixes paths
+// of all requests with the base path of the endpoint, since minio-go only supports endpoints at the host
+// root. With UseCRC32CChecksums, it adds the native CRC32C checksum header to object uploads made by PutBlob(),
+// which makes the server verify the uploaded contents, and verifies checksums returned when reading entire
+// objects. It is only used when the endpoint has a base path or checksums are enabled.
+//
+// Requests reach the transport already signed, so modified requests are signed again. Streaming signatures
+// of uploads are chained to the original signature and can't be redone, so minio-go must be configured
+// as secure, which disables them, and the transport switches the scheme back to http for insecure endpoints.
+type rewritingTransport struct {
+	base  http.RoundTripper
+	creds *credentials.Credentials
+
+	secure      bool
+	basePath    string
+	rawBasePath string
+}
+
+func newRewritingTransport(base http.RoundTripper, ep s3Endpoint, creds *credentials.Credentials) rewritingTransport {
+	return rewritingTransport{base, creds, ep.secure, ep.basePath, ep.rawBasePath}
+}
+
+func (t rewritingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	if !t.secure {
+		req.URL.Scheme = "http"
+	}
+
+	modified := false
+
+	if t.basePath != "" {
+		req.URL.Path = t.basePath + req.URL.Path
+		if req.URL.RawPath != "" {
+			req.URL.RawPath = t.rawBasePath + req.URL.RawPath
+		}
+
+		modified = true
+	}
+
+	if sum := checksumFromContext(req.Context()); sum != "" && isObjectUploadRequest(req) {
+		req.Header.Set(checksumHeader, sum)
+
+		modified = true
+	}
+
+	if modified {
+		var err error
+
+		if req, err = t.resign(req); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err // nolint:wrapcheck
+	}
+
+	maybeVerifyChecksum(req, resp)
+
+	return resp, nil
+}
+
+// resign signs the request again using the region of its original signature, anonymous requests are
+// returned unmodified.
+func (t rewritingTransport) resign(req *http.Request) (*http.Request, error) {
+	auth := req.Header.Get("Authorization")
+	if auth == "" {
+		return req, nil
+	}
+
+	if req.Header.Get("X-Amz-Content-Sha256") == streamingPayload {
+		return nil, errors.Errorf("streaming signatures can't be signed again")
+	}
+
+	region, err := signatureRegion(auth)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := t.creds.Get()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get credentials")
+	}
+
+	req.Header.Del("Authorization")
+
+	return signer.SignV4(*req, v.AccessKeyID, v.SecretAccessKey, v.SessionToken, region), nil
+}
+
+// isObjectUploadRequest determines whether the request uploads contents of an object with a single PUT,
+// as opposed to copying an object or uploading a part of it.
+func isObjectUploadRequest(req *http.Request) bool {
+	return req.Method == http.MethodPut && isObjectCreationRequest(req) && req.Header.Get("X-Amz-Copy-Source") == ""
+}
+
+// signatureRegion returns the region from the credential scope of the provided V4 authorization header,
+// such as 'AWS4-HMAC-SHA256 Credential=AKID/20220101/us-east-1/s3/aws4_request, ...'.
+func signatureRegion(auth string) (string, error) {
+	const credentialPrefix = "Credential="
+
+	if !strings.HasPrefix(auth, signV4Algorithm+" ") {
+		return "", errors.Errorf("only V4 signatures can be signed again")
+	}
+
+	p := strings.Index(auth, credentialPrefix)
+	if p < 0 {
+		return "", errors.Errorf("missing credential scope in authorization header")
+	}
+
+	scope := auth[p+len(credentialPrefix):]
+	if e := strings.Index(scope, ","); e >= 0 {
+		scope = scope[0:e]
+	}
+
+	// access key ID, date, region, service, terminator.
+	parts := strings.Split(scope, "/")
+	if len(parts) != 5 { // nolint:gomnd
+		return "", errors.Errorf("invalid credential scope %q", scope)
+	}
+
+	return parts[2], nil
+}
@@ -99,7 +99,7 @@ func infoToVersionMetadata(prefix string, oi *minio.ObjectInfo) versionMetadata
 	bm := blob.Metadata{
 		BlobID:    toBlobID(oi.Key, prefix),
 		Length:    oi.Size,
-		Timestamp: oi.LastModified,
+		Timestamp: objectTimestamp(oi),
 	}
 
 	return versionMetadata{
@@ -59,6 +59,8 @@ type Capacity struct {
 	SizeB uint64 `json:"capacity,omitempty"`
 	// Available (writeable) space in bytes.
 	FreeB uint64 `json:"available"`
+	// Used space in bytes, if known.
+	UsedB uint64 `json:"used,omitempty"`
 }
 
 // Volume defines disk/volume access API to blob storage.
@@ -115,6 +117,10 @@ type PutOptions struct {
 	RetentionMode   RetentionMode
 	RetentionPeriod time.Duration
 
+	// if true, the blob is placed under a legal hold, which prevents its deletion until the hold is removed,
+	// regardless of the retention period.
+	LegalHold bool
+
 	// if true, PutBlob will fail with ErrBlobAlreadyExists if a blob with the same ID exists.
 	DoNotRecreate bool
 
@@ -124,10 +130,10 @@ type PutOptions struct {
 	GetModTime *time.Time // if != nil, populate the value pointed at with the actual modification time
 }
 
-// HasRetentionOptions returns true when blob-retention settings have been
+// HasRetentionOptions returns true when blob-retention settings (including legal hold) have been
 // specified, otherwise retruns false.
 func (o PutOptions) HasRetentionOptions() bool {
-	return o.RetentionPeriod != 0 || o.RetentionMode != ""
+	return o.RetentionPeriod != 0 || o.RetentionMode != "" || o.LegalHold
 }
 
 // Storage encapsulates API for connecting to blob storage.
@@ -71,17 +71,58 @@ func (s *throttlingStorage) GetBlob(ctx context.Context, id blob.ID, offset, len
 	return err // nolint:wrapcheck
 }
 
+// GetBlobRanges implements blob.RangeGetter, only the bytes of the requested ranges count towards
+// the download limit, not the gaps read when the storage coalesces nearby ranges.
+func (s *throttlingStorage) GetBlobRanges(ctx context.Context, id blob.ID, ranges []blob.Range, outputs []blob.OutputBuffer) error {
+	var acquired int64
+
+	for _, r := range ranges {
+		acquired += r.Length
+	}
+
+	s.throttler.BeforeOperation(ctx, operationGetBlob)
+	s.throttler.BeforeDownload(ctx, acquired)
+
+	err := blob.GetBlobRanges(ctx, s.Storage, id, ranges, outputs)
+
+	var downloaded int64
+
+	for _, o := range outputs {
+		downloaded += int64(o.Length())
+	}
+
+	if downloaded < acquired {
+		s.throttler.ReturnUnusedDownloadBytes(ctx, acquired-downloaded)
+	}
+
+	return err // nolint:wrapcheck
+}
+
 func (s *throttlingStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
 	s.throttler.BeforeOperation(ctx, operationGetMetadata)
 
 	return s.Storage.GetMetadata(ctx, id) // nolint:wrapcheck
 }
 
+// BlobsExist implements blob.ExistenceChecker, checking each blob counts as a GetMetadata operation.
+func (s *throttlingStorage) BlobsExist(ctx context.Context, ids []blob.ID) (map[blob.ID]bool, error) {
+	for range ids {
+		s.throttler.BeforeOperation(ctx, operationGetMetadata)
+	}
+
+	return blob.BlobsExist(ctx, s.Storage, ids) // nolint:wrapcheck
+}
+
 func (s *throttlingStorage) ListBlobs(ctx context.Context, blobIDPrefix blob.ID, cb func(bm blob.Metadata) error) error {
 	s.throttler.BeforeOperation(ctx, operationListBlobs)
 	return s.Storage.ListBlobs(ctx, blobIDPrefix, cb) // nolint:wrapcheck
 }
 
+func (s *throttlingStorage) ListBlobsAfter(ctx context.Context, blobIDPrefix, startAfter blob.ID, cb func(bm blob.Metadata) error) error {
+	s.throttler.BeforeOperation(ctx, operationListBlobs)
+	return blob.ListBlobsAfter(ctx, s.Storage, blobIDPrefix, startAfter, cb) // nolint:wrapcheck
+}
+
 func (s *throttlingStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes, opts blob.PutOptions) error {
 	s.throttler.BeforeOperation(ctx, operationPutBlob)
 	s.throttler.BeforeUpload(ctx, int64(data.Length()))
@@ -22,6 +22,13 @@ type Compressor interface {
 	Decompress(output io.Writer, input io.Reader, withHeader bool) error
 }
 
+// DictionaryCompressor is implemented by compressors which support pre-trained dictionaries.
+// Data compressed with a dictionary can only be decompressed with the same dictionary.
+type DictionaryCompressor interface {
+	CompressWithDictionary(output io.Writer, input io.Reader, dict []byte) error
+	DecompressWithDictionary(output io.Writer, input io.Reader, dict []byte, withHeader bool) error
+}
+
 // maps of registered compressors by header ID and name.
 // nolint:gochecknoglobals
 var (
@@ -18,7 +18,7 @@ func init() {
 }
 
 func newZstdCompressor(id HeaderID, level zstd.EncoderLevel) Compressor {
-	return &zstdCompressor{id, compressionHeader(id), sync.Pool{
+	return &zstdCompressor{id, compressionHeader(id), level, sync.Pool{
 		New: func() interface{} {
 			w, err := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(level))
 			mustSucceed(err)
@@ -27,9 +27,12 @@ func newZstdCompressor(id HeaderID, level zstd.EncoderLevel) Compressor {
 	}}
 }
 
+var _ DictionaryCompressor = (*zstdCompressor)(nil)
+
 type zstdCompressor struct {
 	id     HeaderID
 	header []byte
+	level  zstd.EncoderLevel
 	pool   sync.Pool
 }
 
@@ -78,3 +81,47 @@ func (c *zstdCompressor) Decompress(output io.Writer, input io.Reader, withHeade
 
 	return nil
 }
+
+func (c *zstdCompressor) CompressWithDictionary(output io.Writer, input io.Reader, dict []byte) error {
+	if _, err := output.Write(c.header); err != nil {
+		return errors.Wrap(err, "unable to write header")
+	}
+
+	// encoders with dictionaries are not pooled, since the dictionary is fixed at creation time.
+	w, err := zstd.NewWriter(output, zstd.WithEncoderLevel(c.level), zstd.WithEncoderDict(dict))
+	if err != nil {
+		return errors.Wrap(err, "unable to create zstd encoder with dictionary")
+	}
+
+	if err := iocopy.JustCopy(w, input); err != nil {
+		w.Close() //nolint:errcheck
+
+		return errors.Wrap(err, "compression error")
+	}
+
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "compression close error")
+	}
+
+	return nil
+}
+
+func (c *zstdCompressor) DecompressWithDictionary(output io.Writer, input io.Reader, dict []byte, withHeader bool) error {
+	if withHeader {
+		if err := verifyCompressionHeader(input, c.header); err != nil {
+			return err
+		}
+	}
+
+	r, err := zstd.NewReader(input, zstd.WithDecoderDicts(dict))
+	if err != nil {
+		return errors.Wrap(err, "unable to open zstd stream")
+	}
+	defer r.Close()
+
+	if err := iocopy.JustCopy(output, r); err != nil {
+		return errors.Wrap(err, "decompression error")
+	}
+
+	return nil
+}
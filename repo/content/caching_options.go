@@ -19,10 +19,13 @@ type CachingOptions struct {
 	CacheDirectory            string          `json:"cacheDirectory,omitempty"`
 	MaxCacheSizeBytes         int64           `json:"maxCacheSize,omitempty"`
 	MaxMetadataCacheSizeBytes int64           `json:"maxMetadataCacheSize,omitempty"`
+	MaxIndexCacheBytes        int64           `json:"maxIndexCacheSize,omitempty"` // zero means unlimited
 	MaxListCacheDuration      DurationSeconds `json:"maxListCacheDuration,omitempty"`
 	MinMetadataSweepAge       DurationSeconds `json:"minMetadataSweepAge,omitempty"`
 	MinContentSweepAge        DurationSeconds `json:"minContentSweepAge,omitempty"`
 	MinIndexSweepAge          DurationSeconds `json:"minIndexSweepAge,omitempty"`
+	MaxOpenIndexSegments      int             `json:"maxOpenIndexSegments,omitempty"` // zero means unlimited
+	IndexFetchParallelism     int             `json:"indexFetchParallelism,omitempty"`
 	HMACSecret                []byte          `json:"-"`
 }
 
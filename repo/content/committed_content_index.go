@@ -26,8 +26,9 @@
 
 type committedContentIndex struct {
 	// +checkatomic
-	rev   int64
-	cache committedContentIndexCache
+	rev      int64
+	counters committedIndexCounters
+	cache    committedContentIndexCache
 
 	mu sync.Mutex
 	// +checklocks:mu
@@ -36,9 +37,20 @@ type committedContentIndex struct {
 	inUse map[blob.ID]index.Index
 	// +checklocks:mu
 	merged index.Merged
+	// +checklocks:mu
+	closed bool
+	// +checklocks:mu
+	cancelCombine context.CancelFunc // cancels background combining of small indexes
+
+	// combineWG tracks background combining of small indexes.
+	combineWG sync.WaitGroup
 
 	v1PerContentOverhead uint32
 	indexVersion         int
+	fetchParallelism     int
+
+	// segments limits the number of open index segments, nil if unlimited.
+	segments *indexSegmentLRU
 
 	// fetchOne loads one index blob
 	fetchOne func(ctx context.Context, blobID blob.ID, output *gather.WriteBuffer) error
@@ -51,6 +63,7 @@ type committedContentIndexCache interface {
 	addContentToCache(ctx context.Context, indexBlob blob.ID, data gather.Bytes) error
 	openIndex(ctx context.Context, indexBlob blob.ID) (index.Index, error)
 	expireUnused(ctx context.Context, used []blob.ID) error
+	openIndexStats() (count, bytes int64)
 }
 
 func (c *committedContentIndex) revision() int64 {
@@ -61,19 +74,29 @@ func (c *committedContentIndex) getContent(contentID ID) (Info, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	info, err := c.merged.GetInfo(contentID)
+	return getContentFromMerged(c.merged, c.deletionWatermark, &c.counters, contentID)
+}
+
+func getContentFromMerged(m index.Merged, deletionWatermark time.Time, counters *committedIndexCounters, contentID ID) (Info, error) {
+	info, err := m.GetInfo(contentID)
 	if info != nil {
-		if shouldIgnore(info, c.deletionWatermark) {
+		if shouldIgnore(info, deletionWatermark) {
+			counters.reportGetContentNotFound()
 			return nil, ErrContentNotFound
 		}
 
+		counters.reportGetContentHit()
+
 		return info, nil
 	}
 
 	if err == nil {
+		counters.reportGetContentNotFound()
 		return nil, ErrContentNotFound
 	}
 
+	counters.reportGetContentError()
+
 	return nil, errors.Wrap(err, "error getting content info from index")
 }
 
@@ -111,7 +134,7 @@ func (c *committedContentIndex) addIndexBlob(ctx context.Context, indexBlobID bl
 
 	c.log.Debugf("use-new-committed-index %v", indexBlobID)
 
-	ndx, err := c.cache.openIndex(ctx, indexBlobID)
+	ndx, err := c.openIndex(ctx, indexBlobID)
 	if err != nil {
 		return errors.Wrapf(err, "unable to open pack index %q", indexBlobID)
 	}
@@ -123,14 +146,41 @@ func (c *committedContentIndex) addIndexBlob(ctx context.Context, indexBlobID bl
 }
 
 func (c *committedContentIndex) listContents(r IDRange, cb func(i Info) error) error {
+	return c.snapshot().IterateContents(r, cb)
+}
+
+// snapshot returns the view of currently used index segments and deletion watermark.
+func (c *committedContentIndex) snapshot() *CommittedIndexSnapshot {
 	c.mu.Lock()
-	m := append(index.Merged(nil), c.merged...)
-	deletionWatermark := c.deletionWatermark
-	c.mu.Unlock()
+	defer c.mu.Unlock()
 
+	return &CommittedIndexSnapshot{
+		merged:            append(index.Merged(nil), c.merged...),
+		deletionWatermark: c.deletionWatermark,
+		counters:          &c.counters,
+	}
+}
+
+// CommittedIndexSnapshot is a consistent view of committed contents, which is not affected by index
+// refreshes that happen after it was taken. It does not include contents written in the current session
+// that have not been flushed and becomes invalid when the content manager is closed.
+type CommittedIndexSnapshot struct {
+	merged            index.Merged
+	deletionWatermark time.Time
+	counters          *committedIndexCounters
+}
+
+// ContentInfo returns information about the committed content with the provided ID.
+func (s *CommittedIndexSnapshot) ContentInfo(contentID ID) (Info, error) {
+	return getContentFromMerged(s.merged, s.deletionWatermark, s.counters, contentID)
+}
+
+// IterateContents invokes the callback for all committed contents in the provided range, excluding deleted
+// contents older than the deletion watermark.
+func (s *CommittedIndexSnapshot) IterateContents(r IDRange, cb func(i Info) error) error {
 	// nolint:wrapcheck
-	return m.Iterate(r, func(i Info) error {
-		if shouldIgnore(i, deletionWatermark) {
+	return s.merged.Iterate(r, func(i Info) error {
+		if shouldIgnore(i, s.deletionWatermark) {
 			return nil
 		}
 
@@ -164,7 +214,7 @@ func (c *committedContentIndex) merge(ctx context.Context, indexFiles []blob.ID)
 	}()
 
 	for _, e := range indexFiles {
-		ndx, err := c.cache.openIndex(ctx, e)
+		ndx, err := c.openIndex(ctx, e)
 		if err != nil {
 			return nil, nil, errors.Wrapf(err, "unable to open pack index %q", e)
 		}
@@ -173,16 +223,47 @@ func (c *committedContentIndex) merge(ctx context.Context, indexFiles []blob.ID)
 		used[e] = ndx
 	}
 
-	mergedAndCombined, err := c.combineSmallIndexes(merged)
+	return
+}
+
+// openIndex opens the provided index blob from the cache. When the number of open index segments is limited,
+// the returned index may be closed when not recently used and it will be reopened on demand.
+func (c *committedContentIndex) openIndex(ctx context.Context, indexBlobID blob.ID) (index.Index, error) {
+	ndx, err := c.cache.openIndex(ctx, indexBlobID)
+	if err != nil || c.segments == nil {
+		return ndx, err
+	}
+
+	return c.segments.add(indexBlobID, ndx, func() (index.Index, error) {
+		// reopening happens outside of the context of the original request.
+		return c.reopenIndex(context.Background(), indexBlobID)
+	}), nil
+}
+
+// reopenIndex opens the provided index blob after it has been evicted from the set of open segments,
+// downloading it again if it has been removed from the cache in the meantime.
+func (c *committedContentIndex) reopenIndex(ctx context.Context, indexBlobID blob.ID) (index.Index, error) {
+	has, err := c.cache.hasIndexBlobID(ctx, indexBlobID)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "unable to combine small indexes")
+		return nil, errors.Wrapf(err, "error determining whether index blob %v is cached", indexBlobID)
 	}
 
-	c.log.Debugf("combined %v into %v index segments", len(merged), len(mergedAndCombined))
+	if !has {
+		var data gather.WriteBuffer
+		defer data.Close()
 
-	merged = mergedAndCombined
+		c.counters.reportFetchIndexBlob()
 
-	return
+		if err := c.fetchOne(ctx, indexBlobID, &data); err != nil {
+			return nil, errors.Wrapf(err, "error loading index blob %v", indexBlobID)
+		}
+
+		if err := c.cache.addContentToCache(ctx, indexBlobID, data.Bytes()); err != nil {
+			return nil, errors.Wrap(err, "unable to add to committed content cache")
+		}
+	}
+
+	return c.cache.openIndex(ctx, indexBlobID)
 }
 
 // Uses indexFiles for indexing. An error is returned if the
@@ -199,41 +280,121 @@ func (c *committedContentIndex) use(ctx context.Context, indexFiles []blob.ID, i
 
 	c.log.Debugf("use-indexes %v", indexFiles)
 
-	mergedAndCombined, newInUse, err := c.merge(ctx, indexFiles)
+	merged, newInUse, err := c.merge(ctx, indexFiles)
 	if err != nil {
 		return err
 	}
 
 	atomic.AddInt64(&c.rev, 1)
 
-	c.merged = mergedAndCombined
+	c.merged = merged
 	c.inUse = newInUse
 
 	if err := c.cache.expireUnused(ctx, indexFiles); err != nil {
 		c.log.Errorf("unable to expire unused index files: %v", err)
 	}
 
+	c.startCombiningSmallIndexes(merged)
+
 	return nil
 }
 
-func (c *committedContentIndex) combineSmallIndexes(m index.Merged) (index.Merged, error) {
-	var toKeep, toMerge index.Merged
+// startCombiningSmallIndexes starts combining small indexes among the provided segments in the background,
+// queries continue to use the uncombined segments until combining completes. Combining started for
+// previously used indexes is canceled.
+// +checklocks:c.mu
+func (c *committedContentIndex) startCombiningSmallIndexes(m index.Merged) {
+	if c.cancelCombine != nil {
+		c.cancelCombine()
+		c.cancelCombine = nil
+	}
+
+	toMerge := smallIndexes(m)
+	if len(toMerge) <= 1 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancelCombine = cancel
+
+	c.combineWG.Add(1)
+
+	go func() {
+		defer c.combineWG.Done()
+		defer cancel()
+
+		combined, err := c.combineIndexes(ctx, toMerge)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				c.log.Errorf("unable to combine small indexes: %v", err)
+			}
+
+			return
+		}
+
+		if !c.replaceCombinedIndexes(ctx, toMerge, combined) {
+			combined.Close() //nolint:errcheck
+		}
+	}()
+}
+
+// replaceCombinedIndexes replaces the provided segments with their combined index and returns true,
+// unless combining has been canceled in the meantime.
+func (c *committedContentIndex) replaceCombinedIndexes(ctx context.Context, toMerge index.Merged, combined index.Index) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// checked under lock, since both use() and close() cancel combining while holding it.
+	if ctx.Err() != nil || c.closed {
+		return false
+	}
+
+	isCombined := map[index.Index]bool{}
+	for _, ndx := range toMerge {
+		isCombined[ndx] = true
+	}
+
+	// segments may have been added since combining started, they are preserved.
+	var newMerged index.Merged
+
+	for _, ndx := range c.merged {
+		if !isCombined[ndx] {
+			newMerged = append(newMerged, ndx)
+		}
+	}
+
+	c.log.Debugf("combined %v into %v index segments", len(c.merged), len(newMerged)+1)
+
+	c.merged = append(newMerged, combined)
+
+	// segments have changed, callers caching contents must invalidate them.
+	atomic.AddInt64(&c.rev, 1)
+
+	return true
+}
+
+// smallIndexes returns indexes with fewer entries than smallIndexEntryCountThreshold.
+func smallIndexes(m index.Merged) index.Merged {
+	var result index.Merged
 
 	for _, ndx := range m {
 		if ndx.ApproximateCount() < smallIndexEntryCountThreshold {
-			toMerge = append(toMerge, ndx)
-		} else {
-			toKeep = append(toKeep, ndx)
+			result = append(result, ndx)
 		}
 	}
 
-	if len(toMerge) <= 1 {
-		return m, nil
-	}
+	return result
+}
 
+// combineIndexes combines the provided indexes into a single in-memory index.
+func (c *committedContentIndex) combineIndexes(ctx context.Context, toMerge index.Merged) (index.Index, error) {
 	b := index.Builder{}
 
 	for _, ndx := range toMerge {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.Wrap(err, "combining canceled")
+		}
+
 		if err := ndx.Iterate(index.AllIDs, func(i Info) error {
 			b.Add(i)
 			return nil
@@ -248,15 +409,28 @@ func (c *committedContentIndex) combineSmallIndexes(m index.Merged) (index.Merge
 		return nil, errors.Wrap(err, "error building combined in-memory index")
 	}
 
-	combined, err := index.Open(bytes.NewReader(buf.Bytes()), c.v1PerContentOverhead)
+	combined, err := index.Open(index.BytesReaderAt(buf.Bytes()), c.v1PerContentOverhead)
 	if err != nil {
 		return nil, errors.Wrap(err, "error opening combined in-memory index")
 	}
 
-	return append(toKeep, combined), nil
+	return combined, nil
 }
 
 func (c *committedContentIndex) close() error {
+	c.mu.Lock()
+	c.closed = true
+
+	if c.cancelCombine != nil {
+		c.cancelCombine()
+		c.cancelCombine = nil
+	}
+
+	c.mu.Unlock()
+
+	// wait for background combining to stop, so that it does not use indexes after they are closed.
+	c.combineWG.Wait()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -269,20 +443,45 @@ func (c *committedContentIndex) close() error {
 	return nil
 }
 
-func (c *committedContentIndex) fetchIndexBlobs(ctx context.Context, indexBlobs []blob.ID) error {
-	ch, err := c.missingIndexBlobs(ctx, indexBlobs)
+// IndexFetchProgressFunc is invoked after each index blob is downloaded with the number of downloaded blobs
+// and the total number of blobs to download. It may be invoked concurrently from multiple goroutines.
+type IndexFetchProgressFunc func(downloaded, total int)
+
+func (c *committedContentIndex) fetchIndexBlobs(ctx context.Context, indexBlobs []blob.ID, progress IndexFetchProgressFunc) error {
+	missing, err := c.missingIndexBlobs(ctx, indexBlobs)
 	if err != nil {
 		return err
 	}
 
-	if len(ch) == 0 {
+	if len(missing) == 0 {
 		return nil
 	}
 
-	c.log.Debugf("Downloading %v new index blobs...", len(indexBlobs))
+	c.log.Debugf("Downloading %v new index blobs using %v goroutines...", len(missing), c.fetchParallelism)
+
+	var downloaded int32
+
+	// buffered to keep all workers busy.
+	ch := make(chan blob.ID, c.fetchParallelism)
 
 	eg, ctx := errgroup.WithContext(ctx)
-	for i := 0; i < parallelFetches; i++ {
+
+	eg.Go(func() error {
+		defer close(ch)
+
+		for _, indexBlobID := range missing {
+			select {
+			case ch <- indexBlobID:
+			case <-ctx.Done():
+				// one of the workers failed, its error is returned.
+				return nil
+			}
+		}
+
+		return nil
+	})
+
+	for i := 0; i < c.fetchParallelism; i++ {
 		eg.Go(func() error {
 			var data gather.WriteBuffer
 			defer data.Close()
@@ -290,6 +489,8 @@ func (c *committedContentIndex) fetchIndexBlobs(ctx context.Context, indexBlobs
 			for indexBlobID := range ch {
 				data.Reset()
 
+				c.counters.reportFetchIndexBlob()
+
 				if err := c.fetchOne(ctx, indexBlobID, &data); err != nil {
 					return errors.Wrapf(err, "error loading index blob %v", indexBlobID)
 				}
@@ -297,6 +498,10 @@ func (c *committedContentIndex) fetchIndexBlobs(ctx context.Context, indexBlobs
 				if err := c.addIndexBlob(ctx, indexBlobID, data.Bytes(), false); err != nil {
 					return errors.Wrap(err, "unable to add to committed content cache")
 				}
+
+				if progress != nil {
+					progress(int(atomic.AddInt32(&downloaded, 1)), len(missing))
+				}
 			}
 			return nil
 		})
@@ -311,10 +516,9 @@ func (c *committedContentIndex) fetchIndexBlobs(ctx context.Context, indexBlobs
 	return nil
 }
 
-// missingIndexBlobs returns a closed channel filled with blob IDs that are not in committedContents cache.
-func (c *committedContentIndex) missingIndexBlobs(ctx context.Context, blobs []blob.ID) (<-chan blob.ID, error) {
-	ch := make(chan blob.ID, len(blobs))
-	defer close(ch)
+// missingIndexBlobs returns IDs of the provided index blobs that are not in committedContents cache.
+func (c *committedContentIndex) missingIndexBlobs(ctx context.Context, blobs []blob.ID) ([]blob.ID, error) {
+	var missing []blob.ID
 
 	for _, id := range blobs {
 		has, err := c.cache.hasIndexBlobID(ctx, id)
@@ -323,11 +527,11 @@ func (c *committedContentIndex) missingIndexBlobs(ctx context.Context, blobs []b
 		}
 
 		if !has {
-			ch <- id
+			missing = append(missing, id)
 		}
 	}
 
-	return ch, nil
+	return missing, nil
 }
 
 func newCommittedContentIndex(caching *CachingOptions,
@@ -341,20 +545,41 @@ func newCommittedContentIndex(caching *CachingOptions,
 
 	if caching.CacheDirectory != "" {
 		dirname := filepath.Join(caching.CacheDirectory, "indexes")
-		cache = &diskCommittedContentIndexCache{dirname, clock.Now, v1PerContentOverhead, log, minSweepAge}
+		cache = &diskCommittedContentIndexCache{
+			dirname:              dirname,
+			timeNow:              clock.Now,
+			v1PerContentOverhead: v1PerContentOverhead,
+			log:                  log,
+			minSweepAge:          minSweepAge,
+			maxSizeBytes:         caching.MaxIndexCacheBytes,
+
+			sizeEvictionGracePeriod: defaultIndexCacheSizeEvictionGracePeriod,
+		}
 	} else {
 		cache = &memoryCommittedContentIndexCache{
 			contents:             map[blob.ID]index.Index{},
+			sizes:                map[blob.ID]int64{},
 			v1PerContentOverhead: v1PerContentOverhead,
 		}
 	}
 
-	return &committedContentIndex{
+	c := &committedContentIndex{
 		cache:                cache,
 		inUse:                map[blob.ID]index.Index{},
 		v1PerContentOverhead: v1PerContentOverhead,
 		indexVersion:         indexVersion,
+		fetchParallelism:     caching.IndexFetchParallelism,
 		fetchOne:             fetchOne,
 		log:                  log,
 	}
+
+	if c.fetchParallelism <= 0 {
+		c.fetchParallelism = parallelFetches
+	}
+
+	if caching.MaxOpenIndexSegments > 0 {
+		c.segments = newIndexSegmentLRU(caching.MaxOpenIndexSegments)
+	}
+
+	return c
 }
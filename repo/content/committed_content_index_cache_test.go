@@ -2,6 +2,8 @@
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -21,7 +23,13 @@ func TestCommittedContentIndexCache_Disk(t *testing.T) {
 
 	ta := faketime.NewClockTimeWithOffset(0)
 
-	testCache(t, &diskCommittedContentIndexCache{testutil.TempDirectory(t), ta.NowFunc(), 3, logging.Printf(t.Logf, "test"), DefaultIndexCacheSweepAge}, ta)
+	testCache(t, &diskCommittedContentIndexCache{
+		dirname:              testutil.TempDirectory(t),
+		timeNow:              ta.NowFunc(),
+		v1PerContentOverhead: 3,
+		log:                  logging.Printf(t.Logf, "test"),
+		minSweepAge:          DefaultIndexCacheSweepAge,
+	}, ta)
 }
 
 func TestCommittedContentIndexCache_Memory(t *testing.T) {
@@ -29,6 +37,7 @@ func TestCommittedContentIndexCache_Memory(t *testing.T) {
 
 	testCache(t, &memoryCommittedContentIndexCache{
 		contents:             map[blob.ID]index.Index{},
+		sizes:                map[blob.ID]int64{},
 		v1PerContentOverhead: 3,
 	}, nil)
 }
@@ -125,3 +134,91 @@ func mustBuildIndex(t *testing.T, b index.Builder) gather.Bytes {
 
 	return gather.FromSlice(buf.Bytes())
 }
+
+func TestCommittedContentIndexCache_DiskMaxSize(t *testing.T) {
+	t.Parallel()
+
+	const numIndexes = 5
+
+	ctx := testlogging.Context(t)
+	ta := faketime.NewClockTimeWithOffset(0)
+
+	var indexSize int64
+
+	c := &diskCommittedContentIndexCache{
+		dirname:              testutil.TempDirectory(t),
+		timeNow:              ta.NowFunc(),
+		v1PerContentOverhead: 3,
+		log:                  logging.Printf(t.Logf, "test"),
+		minSweepAge:          DefaultIndexCacheSweepAge,
+
+		sizeEvictionGracePeriod: 90 * time.Second,
+	}
+
+	for i := 0; i < numIndexes; i++ {
+		cid := ID(fmt.Sprintf("c%v", i))
+		data := mustBuildIndex(t, index.Builder{
+			cid: &InfoStruct{PackBlobID: "p1234", ContentID: cid},
+		})
+
+		indexSize = int64(data.Length())
+
+		ndxID := blob.ID(fmt.Sprintf("ndx%v", i))
+		require.NoError(t, c.addContentToCache(ctx, ndxID, data))
+
+		// opening the index marks it as recently used.
+		ta.Advance(time.Minute)
+
+		ndx, err := c.openIndex(ctx, ndxID)
+		require.NoError(t, err)
+		require.NoError(t, ndx.Close())
+	}
+
+	// mark the oldest index as the most recently used one.
+	ta.Advance(time.Minute)
+
+	ndx, err := c.openIndex(ctx, "ndx0")
+	require.NoError(t, err)
+	require.NoError(t, ndx.Close())
+
+	// no size limit, all indexes are too new to be removed.
+	require.NoError(t, c.expireUnused(ctx, []blob.ID{"ndx1"}))
+	require.Equal(t, []blob.ID{"ndx0", "ndx1", "ndx2", "ndx3", "ndx4"}, cachedIndexBlobIDs(ctx, t, c, numIndexes))
+
+	// the cache grows past the limit, least recently used indexes are evicted except the used ones.
+	c.maxSizeBytes = 3 * indexSize
+
+	require.NoError(t, c.expireUnused(ctx, []blob.ID{"ndx1"}))
+	require.Equal(t, []blob.ID{"ndx0", "ndx1", "ndx4"}, cachedIndexBlobIDs(ctx, t, c, numIndexes))
+
+	// used indexes are never evicted, even if they exceed the limit, neither are indexes opened recently,
+	// which may be in use by other processes.
+	c.maxSizeBytes = 1
+
+	require.NoError(t, c.expireUnused(ctx, []blob.ID{"ndx0", "ndx1"}))
+	require.Equal(t, []blob.ID{"ndx0", "ndx1", "ndx4"}, cachedIndexBlobIDs(ctx, t, c, numIndexes))
+
+	ta.Advance(time.Minute)
+
+	require.NoError(t, c.expireUnused(ctx, []blob.ID{"ndx0", "ndx1"}))
+	require.Equal(t, []blob.ID{"ndx0", "ndx1"}, cachedIndexBlobIDs(ctx, t, c, numIndexes))
+}
+
+func cachedIndexBlobIDs(ctx context.Context, t *testing.T, c *diskCommittedContentIndexCache, numIndexes int) []blob.ID {
+	t.Helper()
+
+	var result []blob.ID
+
+	for i := 0; i < numIndexes; i++ {
+		ndxID := blob.ID(fmt.Sprintf("ndx%v", i))
+
+		has, err := c.hasIndexBlobID(ctx, ndxID)
+		require.NoError(t, err)
+
+		if has {
+			result = append(result, ndxID)
+		}
+	}
+
+	return result
+}
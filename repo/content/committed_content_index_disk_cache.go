@@ -4,6 +4,7 @@
 	"context"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -19,6 +20,10 @@
 
 const (
 	simpleIndexSuffix = ".sndx"
+
+	// defaultIndexCacheSizeEvictionGracePeriod is the default minimum age of unused index files evicted
+	// when the cache exceeds its size limit.
+	defaultIndexCacheSizeEvictionGracePeriod = 10 * time.Minute
 )
 
 type diskCommittedContentIndexCache struct {
@@ -27,6 +32,14 @@ type diskCommittedContentIndexCache struct {
 	v1PerContentOverhead uint32
 	log                  logging.Logger
 	minSweepAge          time.Duration
+	maxSizeBytes         int64 // zero means unlimited
+
+	// index files are only evicted because of the size limit when they have not been opened for this long,
+	// since they may be in use by other processes sharing the cache directory, which only protect the
+	// indexes they use by updating their modification time when opening them.
+	sizeEvictionGracePeriod time.Duration
+
+	openIndexCounters
 }
 
 func (c *diskCommittedContentIndexCache) indexBlobPath(indexBlobID blob.ID) string {
@@ -47,6 +60,14 @@ func (c *diskCommittedContentIndexCache) openIndex(ctx context.Context, indexBlo
 		return nil, errors.Wrapf(err, "error openind index from %v", indexBlobID)
 	}
 
+	c.reportOpenIndex(int64(f.Len()))
+
+	// update modification time, so that recently used indexes are evicted last.
+	now := c.timeNow()
+	if err := os.Chtimes(fullpath, now, now); err != nil {
+		c.log.Debugf("unable to update modification time of %v: %v", fullpath, err)
+	}
+
 	return ndx, nil
 }
 
@@ -146,7 +167,8 @@ func writeTempFileAtomic(dirname string, data []byte) (string, error) {
 func (c *diskCommittedContentIndexCache) expireUnused(ctx context.Context, used []blob.ID) error {
 	c.log.Debugw("expireUnused",
 		"except", used,
-		"minSweepAge", c.minSweepAge)
+		"minSweepAge", c.minSweepAge,
+		"maxSizeBytes", c.maxSizeBytes)
 
 	entries, err := os.ReadDir(c.dirname)
 	if err != nil {
@@ -155,6 +177,8 @@ func (c *diskCommittedContentIndexCache) expireUnused(ctx context.Context, used
 
 	remaining := map[blob.ID]os.FileInfo{}
 
+	var totalSize int64
+
 	for _, ent := range entries {
 		fi, err := ent.Info()
 		if os.IsNotExist(err) {
@@ -169,6 +193,7 @@ func (c *diskCommittedContentIndexCache) expireUnused(ctx context.Context, used
 		if strings.HasSuffix(ent.Name(), simpleIndexSuffix) {
 			n := strings.TrimSuffix(ent.Name(), simpleIndexSuffix)
 			remaining[blob.ID(n)] = fi
+			totalSize += fi.Size()
 		}
 	}
 
@@ -176,22 +201,75 @@ func (c *diskCommittedContentIndexCache) expireUnused(ctx context.Context, used
 		delete(remaining, u)
 	}
 
+	var tooNew []os.FileInfo
+
 	for _, rem := range remaining {
 		if c.timeNow().Sub(rem.ModTime()) > c.minSweepAge {
 			c.log.Debugw("removing unused",
 				"name", rem.Name(),
 				"mtime", rem.ModTime())
 
-			if err := os.Remove(filepath.Join(c.dirname, rem.Name())); err != nil {
-				c.log.Errorf("unable to remove unused index file: %v", err)
+			if c.removeUnused(rem) {
+				totalSize -= rem.Size()
 			}
 		} else {
 			c.log.Debugw("keeping unused index because it's too new",
 				"name", rem.Name(),
 				"mtime", rem.ModTime(),
 				"threshold", c.minSweepAge)
+
+			tooNew = append(tooNew, rem)
+		}
+	}
+
+	if c.maxSizeBytes <= 0 || totalSize <= c.maxSizeBytes {
+		return nil
+	}
+
+	// the cache is over the size limit, evict least recently used unused indexes regardless of their age,
+	// except ones that have been opened very recently.
+	sort.Slice(tooNew, func(i, j int) bool {
+		return tooNew[i].ModTime().Before(tooNew[j].ModTime())
+	})
+
+	for _, rem := range tooNew {
+		if totalSize <= c.maxSizeBytes {
+			break
+		}
+
+		if c.timeNow().Sub(rem.ModTime()) < c.sizeEvictionGracePeriod {
+			c.log.Debugw("keeping unused index over size limit because it was recently opened",
+				"name", rem.Name(),
+				"mtime", rem.ModTime(),
+				"gracePeriod", c.sizeEvictionGracePeriod)
+
+			// remaining indexes are even newer.
+			break
+		}
+
+		c.log.Debugw("removing unused index because cache is too large",
+			"name", rem.Name(),
+			"mtime", rem.ModTime(),
+			"totalSize", totalSize,
+			"maxSizeBytes", c.maxSizeBytes)
+
+		if c.removeUnused(rem) {
+			totalSize -= rem.Size()
 		}
 	}
 
 	return nil
 }
+
+// removeUnused removes the provided unused index file and returns true if it was removed, possibly by another
+// process sharing the cache directory. Failure to remove the file (e.g. because it's open on Windows) is not fatal.
+func (c *diskCommittedContentIndexCache) removeUnused(fi os.FileInfo) bool {
+	err := os.Remove(filepath.Join(c.dirname, fi.Name()))
+	if err == nil || os.IsNotExist(err) {
+		return true
+	}
+
+	c.log.Errorf("unable to remove unused index file: %v", err)
+
+	return false
+}
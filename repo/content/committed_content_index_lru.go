@@ -0,0 +1,285 @@
+package content
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content/index"
+)
+
+// indexSegmentLRU limits the number of index segments that are open at the same time by closing
+// least recently used segments, which are transparently reopened when queried again.
+type indexSegmentLRU struct {
+	maxOpen int
+
+	mu sync.Mutex
+	// +checklocks:mu
+	open *list.List // of *lazyIndex, most recently used first
+}
+
+// lazyIndex is an index segment that can be closed when evicted from indexSegmentLRU and reopened on demand.
+// Infos returned from it are detached from the underlying index, so that they remain valid after eviction.
+type lazyIndex struct {
+	blobID           blob.ID
+	approximateCount int
+	reopen           func() (index.Index, error)
+	lru              *indexSegmentLRU
+
+	timeRangeOnce sync.Once
+	timeMin       time.Time
+	timeMax       time.Time
+
+	// serializes reopening of this segment, which happens without holding lru.mu.
+	reopenMu sync.Mutex
+
+	// +checklocks:lru.mu
+	ndx index.Index
+	// +checklocks:lru.mu
+	refs int
+	// +checklocks:lru.mu
+	elem *list.Element
+	// +checklocks:lru.mu
+	closed bool
+}
+
+func newIndexSegmentLRU(maxOpen int) *indexSegmentLRU {
+	return &indexSegmentLRU{
+		maxOpen: maxOpen,
+		open:    list.New(),
+	}
+}
+
+// add returns index segment wrapping the provided open index, which will be reopened using the provided function
+// after being evicted.
+func (c *indexSegmentLRU) add(blobID blob.ID, ndx index.Index, reopen func() (index.Index, error)) *lazyIndex {
+	l := &lazyIndex{
+		blobID:           blobID,
+		approximateCount: ndx.ApproximateCount(),
+		reopen:           reopen,
+		lru:              c,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l.ndx = ndx
+	l.elem = c.open.PushFront(l)
+
+	c.evictLocked()
+
+	return l
+}
+
+// openCount returns the number of currently open index segments.
+func (c *indexSegmentLRU) openCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.open.Len()
+}
+
+// evictLocked closes least recently used segments that are not in use until the number of open segments
+// does not exceed the limit. Segments in use are closed when they are released.
+// +checklocks:c.mu
+func (c *indexSegmentLRU) evictLocked() {
+	for e := c.open.Back(); e != nil && c.open.Len() > c.maxOpen; {
+		prev := e.Prev()
+
+		if l := e.Value.(*lazyIndex); l.refs == 0 { // nolint:forcetypeassert
+			l.closeLocked()
+		}
+
+		e = prev
+	}
+}
+
+// +checklocks:l.lru.mu
+func (l *lazyIndex) closeLocked() {
+	l.lru.open.Remove(l.elem)
+	l.elem = nil
+
+	l.ndx.Close() //nolint:errcheck
+	l.ndx = nil
+}
+
+// acquire returns the underlying index, reopening it if needed. The index remains open until release() is called.
+func (l *lazyIndex) acquire() (index.Index, error) {
+	if ndx, ok, err := l.acquireOpen(); ok || err != nil {
+		return ndx, err
+	}
+
+	// reopening may involve retries and downloading the index blob, so it's done without holding the lock
+	// shared by all segments.
+	l.reopenMu.Lock()
+	defer l.reopenMu.Unlock()
+
+	// another goroutine may have reopened the index while we were waiting.
+	if ndx, ok, err := l.acquireOpen(); ok || err != nil {
+		return ndx, err
+	}
+
+	ndx, err := l.reopen()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to reopen index %v", l.blobID)
+	}
+
+	l.lru.mu.Lock()
+	defer l.lru.mu.Unlock()
+
+	if l.closed {
+		ndx.Close() //nolint:errcheck
+		return nil, errors.Errorf("index %v already closed", l.blobID)
+	}
+
+	l.ndx = ndx
+	l.elem = l.lru.open.PushFront(l)
+	l.refs++
+
+	l.lru.evictLocked()
+
+	return l.ndx, nil
+}
+
+// acquireOpen acquires the underlying index if it's currently open, otherwise returns false.
+func (l *lazyIndex) acquireOpen() (index.Index, bool, error) {
+	l.lru.mu.Lock()
+	defer l.lru.mu.Unlock()
+
+	if l.closed {
+		return nil, false, errors.Errorf("index %v already closed", l.blobID)
+	}
+
+	if l.ndx == nil {
+		return nil, false, nil
+	}
+
+	l.lru.open.MoveToFront(l.elem)
+	l.refs++
+
+	l.lru.evictLocked()
+
+	return l.ndx, true, nil
+}
+
+func (l *lazyIndex) release() {
+	l.lru.mu.Lock()
+	defer l.lru.mu.Unlock()
+
+	l.refs--
+
+	if l.refs > 0 {
+		return
+	}
+
+	if l.closed {
+		l.closeLocked()
+		return
+	}
+
+	l.lru.evictLocked()
+}
+
+func (l *lazyIndex) ApproximateCount() int {
+	return l.approximateCount
+}
+
+func (l *lazyIndex) GetInfo(contentID ID) (Info, error) {
+	ndx, err := l.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	defer l.release()
+
+	info, err := ndx.GetInfo(contentID)
+	if info == nil {
+		return nil, errors.Wrapf(err, "error getting info from index %v", l.blobID)
+	}
+
+	return index.ToInfoStruct(info), nil
+}
+
+func (l *lazyIndex) GetInfos(contentIDs []ID) (map[ID]Info, error) {
+	ndx, err := l.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	defer l.release()
+
+	infos, err := ndx.GetInfos(contentIDs)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting infos from index %v", l.blobID)
+	}
+
+	for k, v := range infos {
+		infos[k] = index.ToInfoStruct(v)
+	}
+
+	return infos, nil
+}
+
+// TimeRange returns the time range of the index, which is computed once. Returns zero times if the index
+// can't be reopened.
+func (l *lazyIndex) TimeRange() (min, max time.Time) {
+	l.timeRangeOnce.Do(func() {
+		ndx, err := l.acquire()
+		if err != nil {
+			return
+		}
+
+		defer l.release()
+
+		l.timeMin, l.timeMax = ndx.TimeRange()
+	})
+
+	return l.timeMin, l.timeMax
+}
+
+func (l *lazyIndex) Iterate(r index.IDRange, cb func(Info) error) error {
+	ndx, err := l.acquire()
+	if err != nil {
+		return err
+	}
+
+	defer l.release()
+
+	// nolint:wrapcheck
+	return ndx.Iterate(r, func(i Info) error {
+		return cb(index.ToInfoStruct(i))
+	})
+}
+
+func (l *lazyIndex) IterateReverse(r index.IDRange, cb func(Info) error) error {
+	ndx, err := l.acquire()
+	if err != nil {
+		return err
+	}
+
+	defer l.release()
+
+	// nolint:wrapcheck
+	return ndx.IterateReverse(r, func(i Info) error {
+		return cb(index.ToInfoStruct(i))
+	})
+}
+
+// Close closes the underlying index once it is no longer in use. The index can't be reopened afterwards.
+func (l *lazyIndex) Close() error {
+	l.lru.mu.Lock()
+	defer l.lru.mu.Unlock()
+
+	l.closed = true
+
+	if l.ndx != nil && l.refs == 0 {
+		l.closeLocked()
+	}
+
+	return nil
+}
+
+var _ index.Index = (*lazyIndex)(nil)
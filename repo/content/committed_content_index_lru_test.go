@@ -0,0 +1,162 @@
+package content
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content/index"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+func TestCommittedContentIndexMaxOpenSegments(t *testing.T) {
+	t.Parallel()
+
+	const (
+		numIndexes        = 5
+		contentsPerIndex  = 10
+		maxOpenIndexFiles = 2
+	)
+
+	ctx := testlogging.Context(t)
+
+	c := newCommittedContentIndex(&CachingOptions{
+		CacheDirectory:       testutil.TempDirectory(t),
+		MaxOpenIndexSegments: maxOpenIndexFiles,
+	}, 3, index.Version2, func(ctx context.Context, blobID blob.ID, output *gather.WriteBuffer) error {
+		return blob.ErrBlobNotFound
+	}, logging.Printf(t.Logf, "test"), DefaultIndexCacheSweepAge)
+
+	var all []ID
+
+	packOf := map[ID]blob.ID{}
+
+	for i := 0; i < numIndexes; i++ {
+		b := index.Builder{}
+
+		for j := 0; j < contentsPerIndex; j++ {
+			cid := ID(fmt.Sprintf("%02x%02x", i, j))
+			packOf[cid] = blob.ID(fmt.Sprintf("p%v", i))
+			b.Add(&InfoStruct{ContentID: cid, PackBlobID: packOf[cid]})
+			all = append(all, cid)
+		}
+
+		require.NoError(t, c.addIndexBlob(ctx, blob.ID(fmt.Sprintf("ndx%v", i)), mustBuildIndex(t, b), true))
+		require.LessOrEqual(t, c.segments.openCount(), maxOpenIndexFiles)
+	}
+
+	// info remains valid after the index it came from has been evicted.
+	first, err := c.getContent(all[0])
+	require.NoError(t, err)
+
+	for _, cid := range all {
+		info, err := c.getContent(cid)
+		require.NoError(t, err)
+		require.Equal(t, cid, info.GetContentID())
+		require.LessOrEqual(t, c.segments.openCount(), maxOpenIndexFiles)
+	}
+
+	require.Equal(t, blob.ID("p0"), first.GetPackBlobID())
+
+	_, err = c.getContent("ffff")
+	require.ErrorIs(t, err, ErrContentNotFound)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for _, cid := range all {
+				info, err := c.getContent(cid)
+				if err != nil || info.GetContentID() != cid {
+					t.Errorf("unexpected result for %v: %v %v", cid, info, err)
+				}
+			}
+		}()
+	}
+
+	var listed []ID
+
+	require.NoError(t, c.listContents(index.AllIDs, func(i Info) error {
+		listed = append(listed, i.GetContentID())
+		require.Equal(t, packOf[i.GetContentID()], i.GetPackBlobID())
+
+		return nil
+	}))
+
+	wg.Wait()
+
+	require.Equal(t, all, listed)
+	require.LessOrEqual(t, c.segments.openCount(), maxOpenIndexFiles)
+
+	require.NoError(t, c.close())
+	require.Equal(t, 0, c.segments.openCount())
+}
+
+func TestCommittedContentIndexReopenEvictedFromCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+	cacheDir := testutil.TempDirectory(t)
+
+	indexBlobs := map[blob.ID]gather.Bytes{}
+
+	var fetched int32
+
+	c := newCommittedContentIndex(&CachingOptions{
+		CacheDirectory:       cacheDir,
+		MaxOpenIndexSegments: 1,
+	}, 3, index.Version2, func(ctx context.Context, blobID blob.ID, output *gather.WriteBuffer) error {
+		atomic.AddInt32(&fetched, 1)
+
+		data, ok := indexBlobs[blobID]
+		if !ok {
+			return blob.ErrBlobNotFound
+		}
+
+		_, err := data.WriteTo(output)
+
+		return err
+	}, logging.Printf(t.Logf, "test"), DefaultIndexCacheSweepAge)
+
+	for i := 0; i < 2; i++ {
+		b := index.Builder{}
+		b.Add(&InfoStruct{ContentID: ID(fmt.Sprintf("%02x", i)), PackBlobID: blob.ID(fmt.Sprintf("p%v", i))})
+
+		indexBlobID := blob.ID(fmt.Sprintf("ndx%v", i))
+		indexBlobs[indexBlobID] = mustBuildIndex(t, b)
+
+		require.NoError(t, c.addIndexBlob(ctx, indexBlobID, indexBlobs[indexBlobID], true))
+	}
+
+	// the first segment has been closed, remove it from the cache as if it was evicted by another process.
+	require.Equal(t, 1, c.segments.openCount())
+	require.NoError(t, os.Remove(filepath.Join(cacheDir, "indexes", "ndx0"+simpleIndexSuffix)))
+
+	info, err := c.getContent("00")
+	require.NoError(t, err)
+	require.Equal(t, blob.ID("p0"), info.GetPackBlobID())
+	require.EqualValues(t, 1, atomic.LoadInt32(&fetched))
+
+	require.NoError(t, c.close())
+}
+
+func TestCommittedContentIndexUnlimitedSegments(t *testing.T) {
+	t.Parallel()
+
+	c := newCommittedContentIndex(&CachingOptions{}, 3, index.Version2, nil, logging.Printf(t.Logf, "test"), DefaultIndexCacheSweepAge)
+	require.Nil(t, c.segments)
+}
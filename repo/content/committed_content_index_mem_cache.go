@@ -1,7 +1,6 @@
 package content
 
 import (
-	"bytes"
 	"context"
 	"sync"
 
@@ -17,8 +16,12 @@ type memoryCommittedContentIndexCache struct {
 
 	// +checklocks:mu
 	contents map[blob.ID]index.Index
+	// +checklocks:mu
+	sizes map[blob.ID]int64
 
 	v1PerContentOverhead uint32 // +checklocksignore
+
+	openIndexCounters
 }
 
 func (m *memoryCommittedContentIndexCache) hasIndexBlobID(ctx context.Context, indexBlobID blob.ID) (bool, error) {
@@ -32,12 +35,13 @@ func (m *memoryCommittedContentIndexCache) addContentToCache(ctx context.Context
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	ndx, err := index.Open(bytes.NewReader(data.ToByteSlice()), m.v1PerContentOverhead)
+	ndx, err := index.Open(index.BytesReaderAt(data.ToByteSlice()), m.v1PerContentOverhead)
 	if err != nil {
 		return errors.Wrapf(err, "error opening index blob %v", indexBlobID)
 	}
 
 	m.contents[indexBlobID] = ndx
+	m.sizes[indexBlobID] = int64(data.Length())
 
 	return nil
 }
@@ -51,6 +55,8 @@ func (m *memoryCommittedContentIndexCache) openIndex(ctx context.Context, indexB
 		return nil, errors.Errorf("content not found in cache: %v", indexBlobID)
 	}
 
+	m.reportOpenIndex(m.sizes[indexBlobID])
+
 	return v, nil
 }
 
@@ -59,14 +65,17 @@ func (m *memoryCommittedContentIndexCache) expireUnused(ctx context.Context, use
 	defer m.mu.Unlock()
 
 	n := map[blob.ID]index.Index{}
+	sizes := map[blob.ID]int64{}
 
 	for _, u := range used {
 		if v, ok := m.contents[u]; ok {
 			n[u] = v
+			sizes[u] = m.sizes[u]
 		}
 	}
 
 	m.contents = n
+	m.sizes = sizes
 
 	return nil
 }
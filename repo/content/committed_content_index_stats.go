@@ -0,0 +1,88 @@
+package content
+
+import "sync/atomic"
+
+// CommittedIndexStats exposes statistics about queries of committed content indexes.
+type CommittedIndexStats struct {
+	// number of content lookups and their outcomes.
+	GetContentCount    int64 `json:"getContentCount"`
+	GetContentHits     int64 `json:"getContentHits"`
+	GetContentNotFound int64 `json:"getContentNotFound"`
+
+	// number of index blobs fetched from the storage.
+	FetchIndexBlobCount int64 `json:"fetchIndexBlobCount"`
+
+	// number of index blobs opened from the local cache and their total size in bytes.
+	OpenIndexCount int64 `json:"openIndexCount"`
+	OpenIndexBytes int64 `json:"openIndexBytes"`
+
+	// number of index segments each lookup is performed against.
+	SegmentCount int `json:"segmentCount"`
+}
+
+// committedIndexCounters holds counters of committedContentIndex.
+type committedIndexCounters struct {
+	// +checkatomic
+	getContentCount int64
+	// +checkatomic
+	getContentHits int64
+	// +checkatomic
+	getContentNotFound int64
+	// +checkatomic
+	fetchIndexBlobCount int64
+}
+
+func (c *committedIndexCounters) reportGetContentHit() {
+	atomic.AddInt64(&c.getContentCount, 1)
+	atomic.AddInt64(&c.getContentHits, 1)
+}
+
+func (c *committedIndexCounters) reportGetContentNotFound() {
+	atomic.AddInt64(&c.getContentCount, 1)
+	atomic.AddInt64(&c.getContentNotFound, 1)
+}
+
+func (c *committedIndexCounters) reportGetContentError() {
+	atomic.AddInt64(&c.getContentCount, 1)
+}
+
+func (c *committedIndexCounters) reportFetchIndexBlob() {
+	atomic.AddInt64(&c.fetchIndexBlobCount, 1)
+}
+
+// openIndexCounters counts indexes opened by committedContentIndexCache implementations.
+type openIndexCounters struct {
+	// +checkatomic
+	openIndexCount int64
+	// +checkatomic
+	openIndexBytes int64
+}
+
+func (c *openIndexCounters) reportOpenIndex(length int64) {
+	atomic.AddInt64(&c.openIndexCount, 1)
+	atomic.AddInt64(&c.openIndexBytes, length)
+}
+
+// openIndexStats returns the number of opened indexes and their total size in bytes.
+func (c *openIndexCounters) openIndexStats() (count, bytes int64) {
+	return atomic.LoadInt64(&c.openIndexCount), atomic.LoadInt64(&c.openIndexBytes)
+}
+
+// stats returns statistics of the committed content index.
+func (c *committedContentIndex) stats() CommittedIndexStats {
+	c.mu.Lock()
+	segmentCount := len(c.merged)
+	c.mu.Unlock()
+
+	openCount, openBytes := c.cache.openIndexStats()
+
+	return CommittedIndexStats{
+		GetContentCount:     atomic.LoadInt64(&c.counters.getContentCount),
+		GetContentHits:      atomic.LoadInt64(&c.counters.getContentHits),
+		GetContentNotFound:  atomic.LoadInt64(&c.counters.getContentNotFound),
+		FetchIndexBlobCount: atomic.LoadInt64(&c.counters.fetchIndexBlobCount),
+		OpenIndexCount:      openCount,
+		OpenIndexBytes:      openBytes,
+		SegmentCount:        segmentCount,
+	}
+}
@@ -0,0 +1,258 @@
+package content
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content/index"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+func TestCommittedContentIndexCombinesSmallIndexesInBackground(t *testing.T) {
+	t.Parallel()
+
+	const numIndexes = 10
+
+	ctx := testlogging.Context(t)
+	c := newCommittedContentIndex(&CachingOptions{}, 3, index.Version2, nil, logging.Printf(t.Logf, "test"), DefaultIndexCacheSweepAge)
+
+	var indexBlobIDs []blob.ID
+
+	for i := 0; i < numIndexes; i++ {
+		cid := ID(fmt.Sprintf("%04x", i))
+		indexBlobID := blob.ID(fmt.Sprintf("ndx%v", i))
+
+		require.NoError(t, c.addIndexBlob(ctx, indexBlobID, mustBuildIndex(t, index.Builder{
+			cid: &InfoStruct{ContentID: cid, PackBlobID: "p1"},
+		}), false))
+
+		indexBlobIDs = append(indexBlobIDs, indexBlobID)
+	}
+
+	rev := c.revision()
+
+	require.NoError(t, c.use(ctx, indexBlobIDs, time.Time{}))
+
+	// all contents are available immediately, regardless of whether the indexes have been combined.
+	for i := 0; i < numIndexes; i++ {
+		_, err := c.getContent(ID(fmt.Sprintf("%04x", i)))
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		return len(c.merged) == 1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// revision is bumped both when indexes are used and when combined indexes are swapped in.
+	require.GreaterOrEqual(t, c.revision(), rev+2)
+
+	for i := 0; i < numIndexes; i++ {
+		_, err := c.getContent(ID(fmt.Sprintf("%04x", i)))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, c.close())
+}
+
+func TestCommittedContentIndexCloseDuringCombine(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+	c := newCommittedContentIndex(&CachingOptions{}, 3, index.Version2, nil, logging.Printf(t.Logf, "test"), DefaultIndexCacheSweepAge)
+
+	var indexBlobIDs []blob.ID
+
+	for i := 0; i < 3; i++ {
+		indexBlobID := blob.ID(fmt.Sprintf("ndx%v", i))
+
+		require.NoError(t, c.addIndexBlob(ctx, indexBlobID, mustBuildIndex(t, index.Builder{
+			"abcd": &InfoStruct{ContentID: "abcd", PackBlobID: "p1"},
+		}), false))
+
+		indexBlobIDs = append(indexBlobIDs, indexBlobID)
+	}
+
+	require.NoError(t, c.use(ctx, indexBlobIDs, time.Time{}))
+	require.NoError(t, c.close())
+
+	// close() waits for background combining, which can no longer swap in the combined index.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	require.Nil(t, c.cancelCombine)
+	require.True(t, c.closed)
+}
+
+func TestCommittedContentIndexStats(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+
+	indexData := map[blob.ID]gather.Bytes{
+		"ndx1": mustBuildIndex(t, index.Builder{
+			"c1": &InfoStruct{ContentID: "c1", PackBlobID: "p1"},
+		}),
+		"ndx2": mustBuildIndex(t, index.Builder{
+			"c2": &InfoStruct{ContentID: "c2", PackBlobID: "p2"},
+		}),
+	}
+
+	c := newCommittedContentIndex(&CachingOptions{}, 3, index.Version2, func(ctx context.Context, blobID blob.ID, output *gather.WriteBuffer) error {
+		_, err := indexData[blobID].WriteTo(output)
+		return err
+	}, logging.Printf(t.Logf, "test"), DefaultIndexCacheSweepAge)
+
+	require.NoError(t, c.fetchIndexBlobs(ctx, []blob.ID{"ndx1", "ndx2"}, nil))
+
+	// already fetched.
+	require.NoError(t, c.fetchIndexBlobs(ctx, []blob.ID{"ndx1"}, nil))
+
+	require.NoError(t, c.addIndexBlob(ctx, "ndx1", indexData["ndx1"], true))
+	require.NoError(t, c.addIndexBlob(ctx, "ndx2", indexData["ndx2"], true))
+
+	_, err := c.getContent("c1")
+	require.NoError(t, err)
+
+	_, err = c.getContent("c2")
+	require.NoError(t, err)
+
+	_, err = c.getContent("c3")
+	require.ErrorIs(t, err, ErrContentNotFound)
+
+	require.Equal(t, CommittedIndexStats{
+		GetContentCount:     3,
+		GetContentHits:      2,
+		GetContentNotFound:  1,
+		FetchIndexBlobCount: 2,
+		OpenIndexCount:      2,
+		OpenIndexBytes:      int64(indexData["ndx1"].Length() + indexData["ndx2"].Length()),
+		SegmentCount:        2,
+	}, c.stats())
+
+	require.NoError(t, c.close())
+}
+
+func TestCommittedContentIndexFetchParallelism(t *testing.T) {
+	t.Parallel()
+
+	const (
+		numIndexes  = 20
+		parallelism = 3
+	)
+
+	ctx := testlogging.Context(t)
+
+	var (
+		mu                      sync.Mutex
+		running, maxRunning     int
+		progressCalls, maxSoFar int
+		indexBlobIDs            []blob.ID
+	)
+
+	data := mustBuildIndex(t, index.Builder{
+		"c1": &InfoStruct{ContentID: "c1", PackBlobID: "p1"},
+	})
+
+	c := newCommittedContentIndex(&CachingOptions{IndexFetchParallelism: parallelism}, 3, index.Version2, func(ctx context.Context, blobID blob.ID, output *gather.WriteBuffer) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		_, err := data.WriteTo(output)
+
+		return err
+	}, logging.Printf(t.Logf, "test"), DefaultIndexCacheSweepAge)
+
+	for i := 0; i < numIndexes; i++ {
+		indexBlobIDs = append(indexBlobIDs, blob.ID(fmt.Sprintf("ndx%v", i)))
+	}
+
+	require.NoError(t, c.fetchIndexBlobs(ctx, indexBlobIDs, func(downloaded, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if total != numIndexes {
+			t.Errorf("unexpected total %v", total)
+		}
+
+		progressCalls++
+		if downloaded > maxSoFar {
+			maxSoFar = downloaded
+		}
+	}))
+
+	require.LessOrEqual(t, maxRunning, parallelism)
+	require.Equal(t, numIndexes, progressCalls)
+	require.Equal(t, numIndexes, maxSoFar)
+
+	// default parallelism is used when not specified.
+	require.Equal(t, parallelFetches, newCommittedContentIndex(&CachingOptions{}, 3, index.Version2, nil, logging.Printf(t.Logf, "test"), DefaultIndexCacheSweepAge).fetchParallelism)
+}
+
+func TestCommittedContentIndexSnapshot(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+	c := newCommittedContentIndex(&CachingOptions{}, 3, index.Version2, nil, logging.Printf(t.Logf, "test"), DefaultIndexCacheSweepAge)
+
+	deletedTime := time.Unix(1000, 0)
+
+	require.NoError(t, c.addIndexBlob(ctx, "ndx1", mustBuildIndex(t, index.Builder{
+		"c1": &InfoStruct{ContentID: "c1", PackBlobID: "p1"},
+		"c2": &InfoStruct{ContentID: "c2", PackBlobID: "p1", Deleted: true, TimestampSeconds: deletedTime.Unix()},
+	}), false))
+
+	require.NoError(t, c.use(ctx, []blob.ID{"ndx1"}, time.Time{}))
+
+	snap := c.snapshot()
+
+	require.NoError(t, c.addIndexBlob(ctx, "ndx2", mustBuildIndex(t, index.Builder{
+		"c3": &InfoStruct{ContentID: "c3", PackBlobID: "p2"},
+	}), false))
+
+	// switch to a different set of indexes and advance the deletion watermark past deletion of c2.
+	require.NoError(t, c.use(ctx, []blob.ID{"ndx2"}, deletedTime))
+
+	_, err := c.getContent("c1")
+	require.ErrorIs(t, err, ErrContentNotFound)
+
+	// snapshot still resolves contents using indexes and watermark at the time it was taken.
+	for _, cid := range []ID{"c1", "c2"} {
+		_, err = snap.ContentInfo(cid)
+		require.NoError(t, err)
+	}
+
+	_, err = snap.ContentInfo("c3")
+	require.ErrorIs(t, err, ErrContentNotFound)
+
+	var listed []ID
+
+	require.NoError(t, snap.IterateContents(index.AllIDs, func(i Info) error {
+		listed = append(listed, i.GetContentID())
+		return nil
+	}))
+
+	require.Equal(t, []ID{"c1", "c2"}, listed)
+
+	require.NoError(t, c.close())
+}
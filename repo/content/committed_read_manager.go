@@ -8,8 +8,10 @@
 	"sync/atomic"
 	"time"
 
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/kopia/kopia/internal/cache"
 	"github.com/kopia/kopia/internal/clock"
@@ -91,6 +93,12 @@ type SharedManager struct {
 	enc               *encryptedBlobMgr
 	timeNow           func() time.Time
 
+	// recently used compression dictionaries, keyed by blob ID.
+	compressionDictionaries *lru.Cache
+
+	// collapses concurrent loads of the same compression dictionary.
+	compressionDictionaryLoads singleflight.Group
+
 	// lock to protect the set of commtited indexes
 	// shared lock will be acquired when writing new content to allow it to happen in parallel
 	// exclusive lock will be acquired during compaction or refresh.
@@ -118,6 +126,8 @@ type SharedManager struct {
 	contextLogger      logging.Logger
 	internalLogManager *internalLogManager
 	internalLogger     *zap.SugaredLogger // backing logger for 'sharedBaseLogger'
+
+	onIndexFetchProgress IndexFetchProgressFunc
 }
 
 // Crypter returns the crypter.
@@ -213,7 +223,7 @@ func (sm *SharedManager) loadPackIndexesLocked(ctx context.Context) error {
 			indexBlobIDs = append(indexBlobIDs, b.BlobID)
 		}
 
-		err = sm.committedContents.fetchIndexBlobs(ctx, indexBlobIDs)
+		err = sm.committedContents.fetchIndexBlobs(ctx, indexBlobIDs, sm.onIndexFetchProgress)
 		if err == nil {
 			err = sm.committedContents.use(ctx, indexBlobIDs, ignoreDeletedBefore)
 			if err != nil {
@@ -245,7 +255,7 @@ func (sm *SharedManager) getCacheForContentID(id ID) cache.ContentCache {
 	return sm.contentCache
 }
 
-func (sm *SharedManager) decryptContentAndVerify(payload gather.Bytes, bi Info, output *gather.WriteBuffer) error {
+func (sm *SharedManager) decryptContentAndVerify(ctx context.Context, payload gather.Bytes, bi Info, output *gather.WriteBuffer) error {
 	sm.Stats.readContent(payload.Length())
 
 	var hashBuf [hashing.MaxHashSize]byte
@@ -279,6 +289,10 @@ func (sm *SharedManager) decryptContentAndVerify(payload gather.Bytes, bi Info,
 		return errors.Errorf("unsupported compressor %x", h)
 	}
 
+	if dictID := bi.GetCompressionDictionaryID(); dictID != "" {
+		return sm.decompressWithDictionary(ctx, c, dictID, tmp.Bytes(), output)
+	}
+
 	if err := c.Decompress(output, tmp.Bytes().Reader(), true); err != nil {
 		return errors.Wrap(err, "error decompressing")
 	}
@@ -286,6 +300,24 @@ func (sm *SharedManager) decryptContentAndVerify(payload gather.Bytes, bi Info,
 	return nil
 }
 
+func (sm *SharedManager) decompressWithDictionary(ctx context.Context, c compression.Compressor, dictID blob.ID, input gather.Bytes, output *gather.WriteBuffer) error {
+	dc, ok := c.(compression.DictionaryCompressor)
+	if !ok {
+		return errors.Errorf("compressor %x does not support dictionaries", c.HeaderID())
+	}
+
+	dict, err := sm.getCompressionDictionary(ctx, dictID)
+	if err != nil {
+		return err
+	}
+
+	if err := dc.DecompressWithDictionary(output, input.Reader(), dict, true); err != nil {
+		return errors.Wrap(err, "error decompressing with dictionary")
+	}
+
+	return nil
+}
+
 func (sm *SharedManager) decryptAndVerify(encrypted gather.Bytes, iv []byte, output *gather.WriteBuffer) error {
 	if err := sm.crypter.Encryptor.Decrypt(encrypted, iv, output); err != nil {
 		sm.Stats.foundInvalidContent()
@@ -473,6 +505,11 @@ func (sm *SharedManager) setupReadManagerCaches(ctx context.Context, caching *Ca
 	return nil
 }
 
+// CommittedIndexStats returns statistics of queries of committed content indexes.
+func (sm *SharedManager) CommittedIndexStats() CommittedIndexStats {
+	return sm.committedContents.stats()
+}
+
 // EpochManager returns the epoch manager.
 func (sm *SharedManager) EpochManager() (*epoch.Manager, bool) {
 	ibm1, ok := sm.indexBlobManager.(*indexBlobManagerV1)
@@ -568,7 +605,7 @@ func NewSharedManager(ctx context.Context, st blob.Storage, f *FormattingOptions
 		actualIndexVersion = legacyIndexVersion
 	}
 
-	if actualIndexVersion < index.Version1 || actualIndexVersion > index.Version2 {
+	if actualIndexVersion < index.Version1 || actualIndexVersion > index.Version3 {
 		return nil, errors.Errorf("index version %v is not supported", actualIndexVersion)
 	}
 
@@ -602,8 +639,11 @@ func NewSharedManager(ctx context.Context, st blob.Storage, f *FormattingOptions
 		internalLogManager:      ilm,
 		internalLogger:          internalLog,
 		contextLogger:           logging.Module(FormatLogModule)(ctx),
+		onIndexFetchProgress:    opts.OnIndexFetchProgress,
 	}
 
+	sm.compressionDictionaries, _ = lru.New(maxCachedCompressionDictionaries)
+
 	// remember logger defined for the context.
 	sm.log = sm.namedLogger("shared-manager")
 
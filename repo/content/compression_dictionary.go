@@ -0,0 +1,98 @@
+package content
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/ctxutil"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/compression"
+)
+
+// CompressionDictionaryBlobPrefix is the prefix of blobs holding compression dictionaries
+// referenced by index entries of contents compressed with them.
+const CompressionDictionaryBlobPrefix blob.ID = "d"
+
+// maxCachedCompressionDictionaries is the maximum number of compression dictionaries kept in memory.
+const maxCachedCompressionDictionaries = 16
+
+// WriteCompressionDictionary writes the provided pre-trained compression dictionary to an encrypted blob
+// and returns its ID, which is stored in index entries of contents compressed with the dictionary.
+func (bm *WriteManager) WriteCompressionDictionary(ctx context.Context, dict gather.Bytes) (blob.ID, error) {
+	if dict.Length() == 0 {
+		return "", errors.Errorf("empty compression dictionary")
+	}
+
+	md, err := bm.enc.encryptAndWriteBlob(ctx, dict, CompressionDictionaryBlobPrefix, "")
+	if err != nil {
+		return "", errors.Wrap(err, "error writing compression dictionary")
+	}
+
+	return md.BlobID, nil
+}
+
+// WriteContentWithCompressionDictionary is like WriteContent but compresses the content using the provided
+// compression dictionary previously written using WriteCompressionDictionary. Contents that already exist
+// are not rewritten, regardless of how they were compressed.
+func (bm *WriteManager) WriteContentWithCompressionDictionary(ctx context.Context, data gather.Bytes, prefix ID, comp compression.HeaderID, dictID blob.ID) (ID, error) {
+	if dictID == "" {
+		return "", errors.Errorf("compression dictionary not provided")
+	}
+
+	contentID, _, err := bm.writeContent(ctx, data, prefix, comp, dictID)
+
+	return contentID, err
+}
+
+// compressWithDictionary compresses the data using the provided compression dictionary.
+func (sm *SharedManager) compressWithDictionary(ctx context.Context, c compression.Compressor, dictID blob.ID, data gather.Bytes, output *gather.WriteBuffer) error {
+	dc, ok := c.(compression.DictionaryCompressor)
+	if !ok {
+		return errors.Errorf("compressor %x does not support dictionaries", c.HeaderID())
+	}
+
+	dict, err := sm.getCompressionDictionary(ctx, dictID)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(dc.CompressWithDictionary(output, data.Reader(), dict), "error compressing with dictionary")
+}
+
+// getCompressionDictionary returns the contents of compression dictionary blob, which is loaded once
+// and kept in memory while it remains among the most recently used dictionaries.
+func (sm *SharedManager) getCompressionDictionary(ctx context.Context, blobID blob.ID) ([]byte, error) {
+	if d, ok := sm.compressionDictionaries.Get(blobID); ok {
+		return d.([]byte), nil // nolint:forcetypeassert
+	}
+
+	// concurrent loads of the same dictionary share a single fetch, which runs on a context detached
+	// from the caller, so that cancellation of the caller that started it does not fail the others.
+	ch := sm.compressionDictionaryLoads.DoChan(string(blobID), func() (interface{}, error) {
+		var tmp gather.WriteBuffer
+		defer tmp.Close()
+
+		if err := sm.enc.getEncryptedBlob(ctxutil.Detach(ctx), blobID, &tmp); err != nil {
+			return nil, errors.Wrapf(err, "unable to read compression dictionary %v", blobID)
+		}
+
+		d := tmp.ToByteSlice()
+		sm.compressionDictionaries.Add(blobID, d)
+
+		return d, nil
+	})
+
+	select {
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, r.Err // nolint:wrapcheck
+		}
+
+		return r.Val.([]byte), nil // nolint:forcetypeassert
+
+	case <-ctx.Done():
+		return nil, errors.Wrapf(ctx.Err(), "canceled while loading compression dictionary %v", blobID)
+	}
+}
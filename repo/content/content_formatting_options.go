@@ -67,7 +67,7 @@ func (f *FormattingOptions) ResolveFormatVersion() error {
 type MutableParameters struct {
 	Version         FormatVersion    `json:"version,omitempty"`         // version number, must be "1" or "2"
 	MaxPackSize     int              `json:"maxPackSize,omitempty"`     // maximum size of a pack object
-	IndexVersion    int              `json:"indexVersion,omitempty"`    // force particular index format version (1,2,..)
+	IndexVersion    int              `json:"indexVersion,omitempty"`    // force particular index format version (1,2,3)
 	EpochParameters epoch.Parameters `json:"epochParameters,omitempty"` // epoch manager parameters
 }
 
@@ -81,8 +81,8 @@ func (v *MutableParameters) Validate() error {
 		return errors.Errorf("max pack size too big, must be <= %v", units.BytesStringBase2(maxValidPackSize))
 	}
 
-	if v.IndexVersion < 0 || v.IndexVersion > index.Version2 {
-		return errors.Errorf("invalid index version, supported versions are 1 & 2")
+	if v.IndexVersion < 0 || v.IndexVersion > index.Version3 {
+		return errors.Errorf("invalid index version, supported versions are 1, 2 & 3")
 	}
 
 	if err := v.EpochParameters.Validate(); err != nil {
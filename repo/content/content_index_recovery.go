@@ -121,6 +121,98 @@ func findPostamble(b []byte) *packContentPostamble {
 	return decodePostamble(payload)
 }
 
+// maxPackPostambleSize is the maximum size of pack postamble, including its trailing length byte.
+const maxPackPostambleSize = 256
+
+// VerifyPackBlobTrailer reads the last bytes of a given pack blob using a ranged read and verifies
+// that the blob ends with a valid pack postamble (correct checksum and version) that points at
+// a local index immediately preceding it.
+//
+// Pack blobs begin with a random preamble, so the trailer is the only part of a pack that
+// can be validated cheaply without the encryption keys.
+func VerifyPackBlobTrailer(ctx context.Context, br blob.Reader, packFile blob.ID, packFileLength int64) error {
+	readLength := int64(maxPackPostambleSize)
+	if packFileLength < readLength {
+		readLength = packFileLength
+	}
+
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	if err := br.GetBlob(ctx, packFile, packFileLength-readLength, readLength, &tmp); err != nil {
+		return errors.Wrapf(err, "error reading trailer of %v", packFile)
+	}
+
+	b := tmp.ToByteSlice()
+
+	postamble := findPostamble(b)
+	if postamble == nil {
+		return errors.Errorf("pack blob %v does not end with a valid postamble", packFile)
+	}
+
+	postambleLength := int64(b[len(b)-1]) + 1
+
+	if got, want := int64(postamble.localIndexOffset)+int64(postamble.localIndexLength), packFileLength-postambleLength; got != want {
+		return errors.Errorf("pack blob %v has invalid local index location: ends at %v, expected %v", packFile, got, want)
+	}
+
+	return nil
+}
+
+// packHeaderLengthSize is the number of bytes used to store the length of the repository format
+// blob copy at the beginning of a pack blob, both before and after it.
+const packHeaderLengthSize = 2
+
+// VerifyPackBlobHeader reads the beginning of the pack blob containing the provided content
+// using a ranged read and verifies that it holds a valid pack header.
+//
+// Unless password change is enabled, pack blobs start with a length-framed copy of the repository
+// format blob, which is followed by a random preamble and the first content of the pack.
+func (sm *SharedManager) VerifyPackBlobHeader(ctx context.Context, first Info) error {
+	headerLength := int64(first.GetPackOffset()) + int64(first.GetPackedLength())
+
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	if err := sm.st.GetBlob(ctx, first.GetPackBlobID(), 0, headerLength, &tmp); err != nil {
+		return errors.Wrapf(err, "error reading header of %v", first.GetPackBlobID())
+	}
+
+	return sm.parsePackBlobHeader(ctx, tmp.ToByteSlice(), first)
+}
+
+// parsePackBlobHeader verifies the framing of the repository format blob copy and decrypts
+// the provided content, which verifies its authentication tag and compression header.
+func (sm *SharedManager) parsePackBlobHeader(ctx context.Context, b []byte, first Info) error {
+	packFile := first.GetPackBlobID()
+
+	if got, want := int64(len(b)), int64(first.GetPackOffset())+int64(first.GetPackedLength()); got != want {
+		return errors.Errorf("pack blob %v has truncated header: got %v bytes, expected %v", packFile, got, want)
+	}
+
+	prefix := b[0:first.GetPackOffset()]
+
+	if len(sm.repositoryFormatBytes) > 0 {
+		if len(prefix) < 2*packHeaderLengthSize {
+			return errors.Errorf("pack blob %v is missing repository format header", packFile)
+		}
+
+		l := int(binary.LittleEndian.Uint16(prefix))
+		if end := packHeaderLengthSize + l; end+packHeaderLengthSize > len(prefix) || int(binary.LittleEndian.Uint16(prefix[end:])) != l {
+			return errors.Errorf("pack blob %v has invalid repository format header", packFile)
+		}
+	}
+
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	if err := sm.decryptContentAndVerify(ctx, gather.FromSlice(b[first.GetPackOffset():]), first, &tmp); err != nil {
+		return errors.Wrapf(err, "pack blob %v has invalid first content %v", packFile, first.GetContentID())
+	}
+
+	return nil
+}
+
 func decodePostamble(payload []byte) *packContentPostamble {
 	flags, n := binary.Uvarint(payload)
 	if n <= 0 {
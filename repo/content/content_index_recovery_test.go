@@ -109,3 +109,89 @@ func (s *contentManagerSuite) TestContentIndexRecovery(t *testing.T) {
 	verifyContent(ctx, t, bm, content2, seededRandomData(11, 100))
 	verifyContent(ctx, t, bm, content3, seededRandomData(12, 100))
 }
+
+func (s *contentManagerSuite) TestVerifyPackBlobTrailer(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	keyTime := map[blob.ID]time.Time{}
+	st := blobtesting.NewMapStorage(data, keyTime, nil)
+
+	bm := s.newTestContentManagerWithCustomTime(t, st, nil)
+	defer bm.Close(ctx)
+
+	writeContentAndVerify(ctx, t, bm, seededRandomData(10, 100))
+	writeContentAndVerify(ctx, t, bm, seededRandomData(11, 100))
+	require.NoError(t, bm.Flush(ctx))
+
+	var packs []blob.Metadata
+
+	for _, prefix := range PackBlobIDPrefixes {
+		require.NoError(t, st.ListBlobs(ctx, prefix, func(bi blob.Metadata) error {
+			packs = append(packs, bi)
+			return nil
+		}))
+	}
+
+	require.NotEmpty(t, packs)
+
+	for _, bi := range packs {
+		require.NoError(t, VerifyPackBlobTrailer(ctx, st, bi.BlobID, bi.Length))
+	}
+
+	// corrupt the last byte of the first pack
+	p := packs[0]
+	data[p.BlobID][len(data[p.BlobID])-1] ^= 1
+
+	require.Error(t, VerifyPackBlobTrailer(ctx, st, p.BlobID, p.Length))
+
+	// truncated pack
+	data[p.BlobID] = data[p.BlobID][0 : len(data[p.BlobID])-10]
+
+	require.Error(t, VerifyPackBlobTrailer(ctx, st, p.BlobID, p.Length-10))
+}
+
+func (s *contentManagerSuite) TestVerifyPackBlobHeader(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	keyTime := map[blob.ID]time.Time{}
+	st := blobtesting.NewMapStorage(data, keyTime, nil)
+
+	bm := s.newTestContentManagerWithTweaks(t, st, &contentManagerTestTweaks{
+		ManagerOptions: ManagerOptions{
+			// <length><format blob><length>
+			RepositoryFormatBytes: []byte{3, 0, 'a', 'b', 'c', 3, 0},
+		},
+	})
+	defer bm.Close(ctx)
+
+	writeContentAndVerify(ctx, t, bm, seededRandomData(10, 100))
+	writeContentAndVerify(ctx, t, bm, seededRandomData(11, 100))
+	require.NoError(t, bm.Flush(ctx))
+
+	firstContents := map[blob.ID]Info{}
+
+	require.NoError(t, bm.IterateContents(ctx, IterateOptions{}, func(ci Info) error {
+		if prev, ok := firstContents[ci.GetPackBlobID()]; !ok || ci.GetPackOffset() < prev.GetPackOffset() {
+			firstContents[ci.GetPackBlobID()] = ci
+		}
+
+		return nil
+	}))
+
+	require.NotEmpty(t, firstContents)
+
+	for _, ci := range firstContents {
+		require.NoError(t, bm.VerifyPackBlobHeader(ctx, ci))
+	}
+
+	for packFile, ci := range firstContents {
+		// corrupt the length following the repository format blob copy
+		data[packFile][5] ^= 1
+		require.ErrorContains(t, bm.VerifyPackBlobHeader(ctx, ci), "invalid repository format header")
+		data[packFile][5] ^= 1
+
+		// corrupt the first byte of the first content
+		data[packFile][ci.GetPackOffset()] ^= 1
+		require.ErrorContains(t, bm.VerifyPackBlobHeader(ctx, ci), "invalid first content")
+	}
+}
@@ -256,10 +256,12 @@ func (bm *WriteManager) maybeRetryWritingFailedPacksUnlocked(ctx context.Context
 	return nil
 }
 
-func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, data gather.Bytes, isDeleted bool, comp compression.HeaderID, isRewrite bool) error {
+// addToPackUnlocked adds the content to a pending pack and returns its packed length and whether it was added,
+// which is not the case when a concurrent writer has added the same content first.
+func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, data gather.Bytes, isDeleted bool, comp compression.HeaderID, dictID blob.ID, isRewrite bool) (packedLength int64, added bool, err error) {
 	// see if the current index is old enough to cause automatic flush.
 	if err := bm.maybeFlushBasedOnTimeUnlocked(ctx); err != nil {
-		return errors.Wrap(err, "unable to flush old pending writes")
+		return 0, false, errors.Wrap(err, "unable to flush old pending writes")
 	}
 
 	prefix := packPrefixForContentID(contentID)
@@ -268,18 +270,18 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 	defer compressedAndEncrypted.Close()
 
 	// encrypt and compress before taking lock
-	actualComp, err := bm.maybeCompressAndEncryptDataForPacking(data, contentID, comp, &compressedAndEncrypted)
+	actualComp, actualDictID, err := bm.maybeCompressAndEncryptDataForPacking(ctx, data, contentID, comp, dictID, &compressedAndEncrypted)
 	if err != nil {
-		return errors.Wrapf(err, "unable to encrypt %q", contentID)
+		return 0, false, errors.Wrapf(err, "unable to encrypt %q", contentID)
 	}
 
 	bm.lock()
 
 	if !isRewrite {
-		if _, _, err = bm.getContentInfoReadLocked(ctx, contentID); err == nil {
+		if _, existing, lookupErr := bm.getContentInfoReadLocked(ctx, contentID); lookupErr == nil && !existing.GetDeleted() {
 			// we lost the race while compressing the content, the content now exists.
 			bm.unlock()
-			return nil
+			return int64(existing.GetPackedLength()), false, nil
 		}
 	}
 
@@ -302,33 +304,34 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 
 		if err = bm.writePackAndAddToIndexLocked(ctx, pp); err != nil {
 			bm.unlock()
-			return errors.Wrap(err, "error writing previously failed pack")
+			return 0, false, errors.Wrap(err, "error writing previously failed pack")
 		}
 	}
 
 	pp, err := bm.getOrCreatePendingPackInfoLocked(ctx, prefix)
 	if err != nil {
 		bm.unlock()
-		return errors.Wrap(err, "unable to create pending pack")
+		return 0, false, errors.Wrap(err, "unable to create pending pack")
 	}
 
 	info := &InfoStruct{
 		Deleted:          isDeleted,
 		ContentID:        contentID,
 		PackBlobID:       pp.packBlobID,
-		PackOffset:       uint32(pp.currentPackData.Length()),
+		PackOffset:       uint64(pp.currentPackData.Length()),
 		TimestampSeconds: bm.timeNow().Unix(),
 		FormatVersion:    byte(bm.writeFormatVersion),
-		OriginalLength:   uint32(data.Length()),
+		OriginalLength:   uint64(data.Length()),
 	}
 
 	if _, err := compressedAndEncrypted.Bytes().WriteTo(pp.currentPackData); err != nil {
 		bm.unlock()
-		return errors.Wrapf(err, "unable to append %q to pack data", contentID)
+		return 0, false, errors.Wrapf(err, "unable to append %q to pack data", contentID)
 	}
 
 	info.CompressionHeaderID = actualComp
-	info.PackedLength = uint32(pp.currentPackData.Length()) - info.PackOffset
+	info.CompressionDictionaryID = actualDictID
+	info.PackedLength = uint64(pp.currentPackData.Length()) - info.PackOffset
 
 	pp.currentPackItems[contentID] = info
 
@@ -346,11 +349,11 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 	// save to storage in parallel.
 	if shouldWrite {
 		if err := bm.acquireLockAndWritePackAndAddToIndex(ctx, pp); err != nil {
-			return errors.Wrap(err, "unable to write pack")
+			return 0, false, errors.Wrap(err, "unable to write pack")
 		}
 	}
 
-	return nil
+	return int64(info.PackedLength), true, nil
 }
 
 // DisableIndexFlush increments the counter preventing automatic index flushes.
@@ -438,7 +441,7 @@ func (bm *WriteManager) flushPackIndexesLocked(ctx context.Context) error {
 	}
 
 	if len(bm.packIndexBuilder) > 0 {
-		dataShards, closeShards, err := bm.packIndexBuilder.BuildShards(bm.indexVersion, true, bm.indexShardSize)
+		dataShards, closeShards, err := bm.packIndexBuilder.BuildShards(bm.indexVersion, true, bm.indexShardSize, index.BuildOptions{})
 		if err != nil {
 			return errors.Wrap(err, "unable to build pack index")
 		}
@@ -678,7 +681,9 @@ func (bm *WriteManager) rewriteContent(ctx context.Context, contentID ID, onlyRe
 		isDeleted = false
 	}
 
-	return bm.addToPackUnlocked(ctx, contentID, data.Bytes(), isDeleted, bi.GetCompressionHeaderID(), true)
+	_, _, err = bm.addToPackUnlocked(ctx, contentID, data.Bytes(), isDeleted, bi.GetCompressionHeaderID(), bi.GetCompressionDictionaryID(), true)
+
+	return err
 }
 
 func packPrefixForContentID(contentID ID) blob.ID {
@@ -734,14 +739,41 @@ func (bm *WriteManager) SupportsContentCompression() bool {
 // WriteContent saves a given content of data to a pack group with a provided name and returns a contentID
 // that's based on the contents of data written.
 func (bm *WriteManager) WriteContent(ctx context.Context, data gather.Bytes, prefix ID, comp compression.HeaderID) (ID, error) {
+	contentID, _, err := bm.WriteContentWithDedupInfo(ctx, data, prefix, comp)
+
+	return contentID, err
+}
+
+// WriteInfo describes the outcome of writing a content.
+type WriteInfo struct {
+	// IsNew is true if the content was newly added to the repository as opposed to being deduplicated
+	// against an existing content.
+	IsNew bool
+
+	// IsNewUnknown is true if the repository could not determine whether the content was new, which is the case
+	// when writing asynchronously to a repository server. IsNew is false in that case.
+	IsNewUnknown bool
+
+	// PackedLength is the length of the content as stored in the repository after compression and encryption.
+	PackedLength int64
+}
+
+// WriteContentWithDedupInfo is like WriteContent but also returns whether the content was newly added
+// to the repository and its packed length, without having to look up the content after writing it.
+func (bm *WriteManager) WriteContentWithDedupInfo(ctx context.Context, data gather.Bytes, prefix ID, comp compression.HeaderID) (ID, WriteInfo, error) {
+	return bm.writeContent(ctx, data, prefix, comp, "")
+}
+
+// writeContent writes the content compressed using the optional compression dictionary.
+func (bm *WriteManager) writeContent(ctx context.Context, data gather.Bytes, prefix ID, comp compression.HeaderID, dictID blob.ID) (ID, WriteInfo, error) {
 	if err := bm.maybeRetryWritingFailedPacksUnlocked(ctx); err != nil {
-		return "", err
+		return "", WriteInfo{}, err
 	}
 
 	reportContentWriteBytes(int64(data.Length()))
 
 	if err := ValidatePrefix(prefix); err != nil {
-		return "", err
+		return "", WriteInfo{}, err
 	}
 
 	var hashOutput [hashing.MaxHashSize]byte
@@ -755,7 +787,7 @@ func (bm *WriteManager) WriteContent(ctx context.Context, data gather.Bytes, pre
 	// content already tracked
 	if err == nil {
 		if !bi.GetDeleted() {
-			return contentID, nil
+			return contentID, WriteInfo{PackedLength: int64(bi.GetPackedLength())}, nil
 		}
 
 		bm.log.Debugf("write-content %v previously-deleted", contentID)
@@ -763,7 +795,12 @@ func (bm *WriteManager) WriteContent(ctx context.Context, data gather.Bytes, pre
 		bm.log.Debugf("write-content %v new", contentID)
 	}
 
-	return contentID, bm.addToPackUnlocked(ctx, contentID, data, false, comp, false)
+	packedLength, added, err := bm.addToPackUnlocked(ctx, contentID, data, false, comp, dictID, false)
+	if err != nil {
+		return "", WriteInfo{}, err
+	}
+
+	return contentID, WriteInfo{IsNew: added, PackedLength: packedLength}, nil
 }
 
 // GetContent gets the contents of a given content. If the content is not found returns ErrContentNotFound.
@@ -850,6 +887,16 @@ func (bm *WriteManager) DisableIndexRefresh() {
 	atomic.StoreInt32(&bm.disableIndexRefresh, 1)
 }
 
+// CommittedIndexSnapshot returns a snapshot of committed contents, against which many lookups and
+// iterations resolve consistently, even if indexes are refreshed in the meantime.
+func (bm *WriteManager) CommittedIndexSnapshot(ctx context.Context) (*CommittedIndexSnapshot, error) {
+	if err := bm.maybeRefreshIndexes(ctx); err != nil {
+		return nil, err
+	}
+
+	return bm.committedContents.snapshot(), nil
+}
+
 // +checklocksacquire:bm.mu
 func (bm *WriteManager) lock() {
 	bm.mu.Lock()
@@ -876,6 +923,7 @@ type ManagerOptions struct {
 	DisableInternalLog    bool
 	RetentionMode         string
 	RetentionPeriod       time.Duration
+	OnIndexFetchProgress  IndexFetchProgressFunc // reports progress of downloading index blobs
 }
 
 // CloneOrDefault returns a clone of provided ManagerOptions or default empty struct if nil.
@@ -93,6 +93,23 @@ func ParseIndexBlob(ctx context.Context, blobID blob.ID, encrypted gather.Bytes,
 	return results, errors.Wrap(err, "error iterating index entries")
 }
 
+// VerifyIndexBlobStructure performs deep verification of the structure of a given index blob.
+func VerifyIndexBlobStructure(ctx context.Context, blobID blob.ID, encrypted gather.Bytes, crypter *Crypter) error {
+	var data gather.WriteBuffer
+	defer data.Close()
+
+	if err := crypter.DecryptBLOB(encrypted, blobID, &data); err != nil {
+		return errors.Wrap(err, "unable to decrypt index blob")
+	}
+
+	ndx, err := index.Open(data.Bytes(), uint32(crypter.Encryptor.Overhead()))
+	if err != nil {
+		return errors.Wrapf(err, "unable to open index blob")
+	}
+
+	return errors.Wrap(index.VerifyStructure(ndx), "invalid index structure")
+}
+
 func addBlobsToIndex(ndx map[blob.ID]*IndexBlobInfo, blobs []blob.Metadata) {
 	for _, it := range blobs {
 		if ndx[it.BlobID] == nil {
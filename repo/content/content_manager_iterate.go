@@ -18,6 +18,10 @@ type IterateOptions struct {
 	Range          IDRange
 	IncludeDeleted bool
 	Parallel       int
+
+	// PackingRatios, when set, accumulates packing ratios of all iterated contents,
+	// it must not be accessed until IterateContents returns.
+	PackingRatios *PackingRatioHistogram
 }
 
 // IterateCallback is the function type used as a callback during content iteration.
@@ -132,10 +136,14 @@ func (bm *WriteManager) IterateContents(ctx context.Context, opts IterateOptions
 			return nil
 		}
 
+		if opts.PackingRatios != nil {
+			opts.PackingRatios.Add(i)
+		}
+
 		return callback(i)
 	}
 
-	if len(uncommitted) == 0 && opts.IncludeDeleted && opts.Range == index.AllIDs && opts.Parallel <= 1 {
+	if len(uncommitted) == 0 && opts.IncludeDeleted && opts.Range == index.AllIDs && opts.Parallel <= 1 && opts.PackingRatios == nil {
 		// fast path, invoke callback directly
 		invokeCallback = callback
 	}
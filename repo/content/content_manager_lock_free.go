@@ -20,12 +20,19 @@
 
 const indexBlobCompactionWarningThreshold = 1000
 
-func (sm *SharedManager) maybeCompressAndEncryptDataForPacking(data gather.Bytes, contentID ID, comp compression.HeaderID, output *gather.WriteBuffer) (compression.HeaderID, error) {
+// maybeCompressAndEncryptDataForPacking compresses and encrypts the data, optionally using the provided compression
+// dictionary, returning the compression header and dictionary actually used, which are empty if the data was not
+// compressible enough.
+func (sm *SharedManager) maybeCompressAndEncryptDataForPacking(ctx context.Context, data gather.Bytes, contentID ID, comp compression.HeaderID, dictID blob.ID, output *gather.WriteBuffer) (compression.HeaderID, blob.ID, error) {
 	var hashOutput [hashing.MaxHashSize]byte
 
 	iv, err := getPackedContentIV(hashOutput[:], contentID)
 	if err != nil {
-		return NoCompression, errors.Wrapf(err, "unable to get packed content IV for %q", contentID)
+		return NoCompression, "", errors.Wrapf(err, "unable to get packed content IV for %q", contentID)
+	}
+
+	if dictID != "" && comp == NoCompression {
+		return NoCompression, "", errors.Errorf("compression dictionary requires compression")
 	}
 
 	// If the content is prefixed (which represents Kopia's own metadata as opposed to user data),
@@ -38,7 +45,7 @@ func (sm *SharedManager) maybeCompressAndEncryptDataForPacking(data gather.Bytes
 	// nolint:nestif
 	if comp != NoCompression {
 		if sm.format.IndexVersion < index.Version2 {
-			return NoCompression, errors.Errorf("compression is not enabled for this repository")
+			return NoCompression, "", errors.Errorf("compression is not enabled for this repository")
 		}
 
 		var tmp gather.WriteBuffer
@@ -47,28 +54,33 @@ func (sm *SharedManager) maybeCompressAndEncryptDataForPacking(data gather.Bytes
 		// allocate temporary buffer to hold the compressed bytes.
 		c := compression.ByHeaderID[comp]
 		if c == nil {
-			return NoCompression, errors.Errorf("unsupported compressor %x", comp)
+			return NoCompression, "", errors.Errorf("unsupported compressor %x", comp)
 		}
 
-		if err = c.Compress(&tmp, data.Reader()); err != nil {
-			return NoCompression, errors.Wrap(err, "compression error")
+		if dictID != "" {
+			if err = sm.compressWithDictionary(ctx, c, dictID, data, &tmp); err != nil {
+				return NoCompression, "", err
+			}
+		} else if err = c.Compress(&tmp, data.Reader()); err != nil {
+			return NoCompression, "", errors.Wrap(err, "compression error")
 		}
 
 		if cd := tmp.Length(); cd >= data.Length() {
 			// data was not compressible enough.
 			comp = NoCompression
+			dictID = ""
 		} else {
 			data = tmp.Bytes()
 		}
 	}
 
 	if err := sm.crypter.Encryptor.Encrypt(data, iv, output); err != nil {
-		return NoCompression, errors.Wrap(err, "unable to encrypt")
+		return NoCompression, "", errors.Wrap(err, "unable to encrypt")
 	}
 
 	sm.Stats.encrypted(data.Length())
 
-	return comp, nil
+	return comp, dictID, nil
 }
 
 func writeRandomBytesToBuffer(b *gather.WriteBuffer, count int) error {
@@ -100,7 +112,13 @@ func ValidatePrefix(prefix ID) error {
 func contentCacheKeyForInfo(bi Info) string {
 	// append format-specific information
 	// see https://github.com/kopia/kopia/issues/1843 for an explanation
-	return fmt.Sprintf("%v.%x.%x.%x", bi.GetContentID(), bi.GetCompressionHeaderID(), bi.GetFormatVersion(), bi.GetEncryptionKeyID())
+	key := fmt.Sprintf("%v.%x.%x.%x", bi.GetContentID(), bi.GetCompressionHeaderID(), bi.GetFormatVersion(), bi.GetEncryptionKeyID())
+
+	if d := bi.GetCompressionDictionaryID(); d != "" {
+		key += "." + string(d)
+	}
+
+	return key
 }
 
 func (bm *WriteManager) getContentDataReadLocked(ctx context.Context, pp *pendingPackInfo, bi Info, output *gather.WriteBuffer) error {
@@ -117,7 +135,7 @@ func (bm *WriteManager) getContentDataReadLocked(ctx context.Context, pp *pendin
 		return errors.Wrap(err, "error getting cached content")
 	}
 
-	return bm.decryptContentAndVerify(payload.Bytes(), bi, output)
+	return bm.decryptContentAndVerify(ctx, payload.Bytes(), bi, output)
 }
 
 func (bm *WriteManager) preparePackDataContent(pp *pendingPackInfo) (index.Builder, error) {
@@ -8,6 +8,7 @@
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"math/rand"
 	"reflect"
 	"strings"
@@ -34,6 +35,7 @@
 	"github.com/kopia/kopia/repo/blob/logging"
 	"github.com/kopia/kopia/repo/compression"
 	"github.com/kopia/kopia/repo/content/index"
+	"github.com/kopia/kopia/repo/hashing"
 )
 
 const (
@@ -1486,6 +1488,62 @@ func (s *contentManagerSuite) TestDeleteAndRecreate(t *testing.T) {
 	}
 }
 
+func (s *contentManagerSuite) TestWriteContentWithDedupInfo(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	bm := s.newTestContentManager(t, st)
+	defer bm.Close(ctx)
+
+	payload := seededRandomData(10, 100)
+
+	// when writing the same content concurrently, only one writer adds it.
+	const numWriters = 10
+
+	var (
+		wg       sync.WaitGroup
+		newCount int32
+	)
+
+	for i := 0; i < numWriters; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, wi, err := bm.WriteContentWithDedupInfo(ctx, gather.FromSlice(payload), "", NoCompression)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if wi.IsNew {
+				atomic.AddInt32(&newCount, 1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	require.EqualValues(t, 1, atomic.LoadInt32(&newCount))
+
+	// previously-deleted content is added again.
+	cid, wi, err := bm.WriteContentWithDedupInfo(ctx, gather.FromSlice(payload), "", NoCompression)
+	require.NoError(t, err)
+	require.False(t, wi.IsNew)
+	require.NoError(t, bm.Flush(ctx))
+	require.NoError(t, bm.DeleteContent(ctx, cid))
+
+	_, wi, err = bm.WriteContentWithDedupInfo(ctx, gather.FromSlice(payload), "", NoCompression)
+	require.NoError(t, err)
+	require.True(t, wi.IsNew)
+
+	ci, err := bm.ContentInfo(ctx, cid)
+	require.NoError(t, err)
+	require.False(t, ci.GetDeleted())
+}
+
 func (s *contentManagerSuite) TestIterateContents(t *testing.T) {
 	ctx := testlogging.Context(t)
 	data := blobtesting.DataMap{}
@@ -1627,6 +1685,54 @@ func (s *contentManagerSuite) TestIterateContents(t *testing.T) {
 	}
 }
 
+func (s *contentManagerSuite) TestIterateContentsPackingRatios(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+	bm := s.newTestContentManager(t, st)
+
+	contentID1 := writeContentAndVerify(ctx, t, bm, seededRandomData(10, 100))
+	require.NoError(t, bm.Flush(ctx))
+	contentID2 := writeContentAndVerify(ctx, t, bm, seededRandomData(11, 200))
+
+	var h PackingRatioHistogram
+
+	var wantPacked int64
+
+	require.NoError(t, bm.IterateContents(ctx, IterateOptions{PackingRatios: &h}, func(ci Info) error {
+		wantPacked += int64(ci.GetPackedLength())
+		return nil
+	}))
+
+	// random data is not compressed and grows because of encryption overhead.
+	last := PackingRatioBuckets - 1
+	require.Equal(t, int64(2), h.Count[last])
+	require.Equal(t, int64(300), h.OriginalBytes[last])
+	require.Equal(t, wantPacked, h.PackedBytes[last])
+
+	// only contents in range are counted.
+	var h2 PackingRatioHistogram
+
+	require.NoError(t, bm.IterateContents(ctx, IterateOptions{Range: index.PrefixRange(contentID1), PackingRatios: &h2}, func(ci Info) error {
+		require.NotEqual(t, contentID2, ci.GetContentID())
+		return nil
+	}))
+
+	require.Equal(t, int64(1), h2.Count[last])
+	require.Equal(t, int64(100), h2.OriginalBytes[last])
+}
+
+func TestPackingRatio(t *testing.T) {
+	require.Zero(t, PackingRatio(&InfoStruct{}))
+	require.Equal(t, 0.25, PackingRatio(&InfoStruct{OriginalLength: 100, PackedLength: 25}))
+
+	require.Equal(t, 0, PackingRatioBucket(0))
+	require.Equal(t, 2, PackingRatioBucket(0.25))
+	require.Equal(t, 9, PackingRatioBucket(0.999))
+	require.Equal(t, PackingRatioBuckets-1, PackingRatioBucket(1))
+	require.Equal(t, PackingRatioBuckets-1, PackingRatioBucket(1.5))
+}
+
 func (s *contentManagerSuite) TestFindUnreferencedBlobs(t *testing.T) {
 	ctx := testlogging.Context(t)
 	data := blobtesting.DataMap{}
@@ -2023,8 +2129,8 @@ func (s *contentManagerSuite) TestCompression_CompressibleData(t *testing.T) {
 	require.NoError(t, err)
 
 	// gzip-compressed length
-	require.Equal(t, uint32(79), ci.GetPackedLength())
-	require.Equal(t, uint32(len(compressibleData)), ci.GetOriginalLength())
+	require.Equal(t, uint64(79), ci.GetPackedLength())
+	require.Equal(t, uint64(len(compressibleData)), ci.GetOriginalLength())
 	require.Equal(t, headerID, ci.GetCompressionHeaderID())
 
 	verifyContent(ctx, t, bm, cid, compressibleData)
@@ -2063,7 +2169,7 @@ func (s *contentManagerSuite) TestCompression_NonCompressibleData(t *testing.T)
 
 	// verify compression did not occur
 	require.True(t, ci.GetPackedLength() > ci.GetOriginalLength())
-	require.Equal(t, uint32(len(nonCompressibleData)), ci.GetOriginalLength())
+	require.Equal(t, uint64(len(nonCompressibleData)), ci.GetOriginalLength())
 	require.Equal(t, NoCompression, ci.GetCompressionHeaderID())
 
 	require.NoError(t, bm.Flush(ctx))
@@ -2075,6 +2181,168 @@ func (s *contentManagerSuite) TestCompression_NonCompressibleData(t *testing.T)
 	verifyContent(ctx, t, bm2, cid, nonCompressibleData)
 }
 
+// testZstdDictionaryHeader is the header of a zstd dictionary (magic, ID, entropy tables and
+// initial repeat offsets), which is followed by dictionary content.
+const testZstdDictionaryHeader = "37a430eccb8cc5401a10e06b74ffffffffebaafa9cdc5b4a2913ff9e46494444444448d305000000803f6a000000040001118a020600008083a495a58b010000400c8281003000000000000000104c9e2f00000000000054905136020000000000000000000000000000010000000400000008000000"
+
+func (s *contentManagerSuite) TestCompression_Dictionary(t *testing.T) {
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+	bm := s.newTestContentManagerWithTweaks(t, st, &contentManagerTestTweaks{
+		indexVersion: index.Version2,
+	})
+
+	ctx := testlogging.Context(t)
+
+	dictHeader, err := hex.DecodeString(testZstdDictionaryHeader)
+	require.NoError(t, err)
+
+	dict := append(dictHeader, strings.Repeat(`{"name":"file","owner":"user","mode":"0644"}`+"\n", 4)...)
+	original := []byte(`{"name":"file1","owner":"user3","mode":"0644"}` + "\n")
+
+	dictID, err := bm.WriteCompressionDictionary(ctx, gather.FromSlice(dict))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(dictID), string(CompressionDictionaryBlobPrefix)))
+
+	// compress and encrypt the content the same way it's stored in a pack.
+	c := compression.ByHeaderID[compression.HeaderZstdDefault]
+
+	var compressed, encrypted gather.WriteBuffer
+	defer compressed.Close()
+	defer encrypted.Close()
+
+	require.NoError(t, c.(compression.DictionaryCompressor).CompressWithDictionary(&compressed, bytes.NewReader(original), dict))
+
+	// the content can't be decompressed without the dictionary.
+	require.Error(t, c.Decompress(io.Discard, compressed.Bytes().Reader(), true))
+
+	cid := ID("0123456789abcdef0123456789abcdef")
+
+	var hashBuf [hashing.MaxHashSize]byte
+
+	iv, err := getPackedContentIV(hashBuf[:], cid)
+	require.NoError(t, err)
+	require.NoError(t, bm.crypter.Encryptor.Encrypt(compressed.Bytes(), iv, &encrypted))
+
+	bi := &InfoStruct{
+		ContentID:               cid,
+		CompressionHeaderID:     compression.HeaderZstdDefault,
+		CompressionDictionaryID: dictID,
+	}
+
+	// dictionary is loaded from the repository by a new manager.
+	bm2 := s.newTestContentManagerWithTweaks(t, st, &contentManagerTestTweaks{
+		indexVersion: index.Version2,
+	})
+
+	var output gather.WriteBuffer
+	defer output.Close()
+
+	require.NoError(t, bm2.decryptContentAndVerify(ctx, encrypted.Bytes(), bi, &output))
+	require.Equal(t, original, output.ToByteSlice())
+
+	// missing dictionary is reported as an error.
+	bi.CompressionDictionaryID = CompressionDictionaryBlobPrefix + "0123456789abcdef0123456789abcdef"
+
+	output.Reset()
+	require.Error(t, bm2.decryptContentAndVerify(ctx, encrypted.Bytes(), bi, &output))
+
+	// contents written with the dictionary can be read by a new manager.
+	compressible := bytes.Repeat(original, 10)
+
+	_, err = bm.WriteContentWithCompressionDictionary(ctx, gather.FromSlice(compressible), "", NoCompression, dictID)
+	require.Error(t, err)
+
+	cid, err = bm.WriteContentWithCompressionDictionary(ctx, gather.FromSlice(compressible), "", compression.HeaderZstdDefault, dictID)
+	require.NoError(t, err)
+	require.NoError(t, bm.Flush(ctx))
+
+	bm3 := s.newTestContentManagerWithTweaks(t, st, &contentManagerTestTweaks{
+		indexVersion: index.Version2,
+	})
+
+	ci, err := bm3.ContentInfo(ctx, cid)
+	require.NoError(t, err)
+	require.Equal(t, compression.HeaderZstdDefault, ci.GetCompressionHeaderID())
+	require.Equal(t, dictID, ci.GetCompressionDictionaryID())
+
+	verifyContent(ctx, t, bm3, cid, compressible)
+}
+
+// blockingDictionaryStorage blocks reads of compression dictionaries until released.
+type blockingDictionaryStorage struct {
+	blob.Storage
+
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s blockingDictionaryStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64, output blob.OutputBuffer) error {
+	if strings.HasPrefix(string(id), string(CompressionDictionaryBlobPrefix)) {
+		close(s.started)
+		<-s.release
+
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "context error")
+		}
+	}
+
+	return s.Storage.GetBlob(ctx, id, offset, length, output)
+}
+
+func (s *contentManagerSuite) TestCompressionDictionaryCache(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+	bm := s.newTestContentManager(t, st)
+
+	var dictIDs []blob.ID
+
+	for i := 0; i < maxCachedCompressionDictionaries+2; i++ {
+		dictID, err := bm.WriteCompressionDictionary(ctx, gather.FromSlice(seededRandomData(i, 100)))
+		require.NoError(t, err)
+
+		dictIDs = append(dictIDs, dictID)
+	}
+
+	// the number of dictionaries kept in memory is bounded.
+	bm2 := s.newTestContentManager(t, st)
+
+	for i, dictID := range dictIDs {
+		d, err := bm2.getCompressionDictionary(ctx, dictID)
+		require.NoError(t, err)
+		require.Equal(t, seededRandomData(i, 100), d)
+	}
+
+	require.Equal(t, maxCachedCompressionDictionaries, bm2.compressionDictionaries.Len())
+
+	// canceling the caller that started the load does not abort the load shared with other callers.
+	bst := blockingDictionaryStorage{st, make(chan struct{}), make(chan struct{})}
+	bm3 := s.newTestContentManager(t, bst)
+
+	ctx1, cancel1 := context.WithCancel(ctx)
+	errCh := make(chan error, 1)
+
+	go func() {
+		_, err := bm3.getCompressionDictionary(ctx1, dictIDs[0])
+		errCh <- err
+	}()
+
+	<-bst.started
+	cancel1()
+	require.ErrorIs(t, <-errCh, context.Canceled)
+
+	close(bst.release)
+
+	require.Eventually(t, func() bool {
+		return bm3.compressionDictionaries.Contains(dictIDs[0])
+	}, 5*time.Second, 10*time.Millisecond)
+
+	d, err := bm3.getCompressionDictionary(ctx, dictIDs[0])
+	require.NoError(t, err)
+	require.Equal(t, seededRandomData(0, 100), d)
+}
+
 func (s *contentManagerSuite) TestContentCachingByFormat(t *testing.T) {
 	data := blobtesting.DataMap{}
 	st := blobtesting.NewMapStorage(data, nil, nil)
@@ -6,6 +6,8 @@
 	"strings"
 	"sync"
 
+	"github.com/pkg/errors"
+
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/repo/blob"
 )
@@ -82,6 +84,7 @@ func (bm *WriteManager) PrefetchContents(ctx context.Context, contentIDs []ID, h
 	type work struct {
 		blobID    blob.ID
 		contentID ID
+		contents  []Info // contents of a single blob, fetched together
 	}
 
 	workCh := make(chan work)
@@ -92,12 +95,13 @@ type work struct {
 		defer close(workCh)
 
 		for b, infos := range contentsByBlob {
-			if o.shouldPrefetchEntireBlob(infos) {
+			switch {
+			case o.shouldPrefetchEntireBlob(infos):
 				workCh <- work{blobID: b}
-			} else {
-				for _, bi := range infos {
-					workCh <- work{contentID: bi.GetContentID()}
-				}
+			case len(infos) > 1:
+				workCh <- work{contents: infos}
+			default:
+				workCh <- work{contentID: infos[0].GetContentID()}
 			}
 		}
 	}()
@@ -113,6 +117,8 @@ type work struct {
 
 			for w := range workCh {
 				switch {
+				case len(w.contents) > 0:
+					bm.prefetchContentsFromBlob(ctx, w.contents, &tmp)
 				case strings.HasPrefix(string(w.blobID), string(PackBlobIDPrefixRegular)):
 					if err := bm.contentCache.PrefetchBlob(ctx, w.blobID); err != nil {
 						bm.log.Debugw("error prefetching data blob", "blobID", w.blobID, "err", err)
@@ -136,3 +142,37 @@ type work struct {
 
 	return prefetched
 }
+
+// prefetchContentsFromBlob fetches the provided contents of a single pack blob into the cache, reading them
+// at once when the storage supports reading multiple ranges of a blob, one by one otherwise.
+func (bm *WriteManager) prefetchContentsFromBlob(ctx context.Context, infos []Info, tmp *gather.WriteBuffer) {
+	var (
+		keys   []string
+		ranges []blob.Range
+	)
+
+	for _, bi := range infos {
+		keys = append(keys, contentCacheKeyForInfo(bi))
+		ranges = append(ranges, blob.Range{Offset: int64(bi.GetPackOffset()), Length: int64(bi.GetPackedLength())})
+	}
+
+	blobID := infos[0].GetPackBlobID()
+
+	err := bm.getCacheForContentID(infos[0].GetContentID()).PrefetchContents(ctx, blobID, keys, ranges)
+	if err == nil {
+		return
+	}
+
+	if !errors.Is(err, blob.ErrUnsupportedMethod) {
+		bm.log.Debugw("error prefetching contents", "blobID", blobID, "err", err)
+		return
+	}
+
+	for _, bi := range infos {
+		tmp.Reset()
+
+		if _, err := bm.getContentDataAndInfo(ctx, bi.GetContentID(), tmp); err != nil {
+			bm.log.Debugw("error prefetching content", "contentID", bi.GetContentID(), "err", err)
+		}
+	}
+}
@@ -14,6 +14,7 @@ type Reader interface {
 	ContentInfo(ctx context.Context, id ID) (Info, error)
 	IterateContents(ctx context.Context, opts IterateOptions, callback IterateCallback) error
 	IteratePacks(ctx context.Context, opts IteratePackOptions, callback IteratePacksCallback) error
+	VerifyPackBlobHeader(ctx context.Context, first Info) error
 	ListActiveSessions(ctx context.Context) (map[SessionID]*SessionInfo, error)
 	EpochManager() (*epoch.Manager, bool)
 }
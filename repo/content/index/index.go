@@ -3,6 +3,7 @@
 
 import (
 	"io"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -21,8 +22,17 @@ type Index interface {
 	ApproximateCount() int
 	GetInfo(contentID ID) (Info, error)
 
+	// returns information about all provided contents that are present in the index, keyed by content ID
+	GetInfos(contentIDs []ID) (map[ID]Info, error)
+
+	// returns the minimum and maximum timestamps of contents in the index, zero if the index is empty
+	TimeRange() (min, max time.Time)
+
 	// invoked the provided callback for all entries such that entry.ID >= startID and entry.ID < endID
 	Iterate(r IDRange, cb func(Info) error) error
+
+	// invokes the provided callback for all entries such that entry.ID >= startID and entry.ID < endID in descending order
+	IterateReverse(r IDRange, cb func(Info) error) error
 }
 
 // IDRange represents a range of IDs.
@@ -55,6 +65,28 @@ func PrefixRange(prefix ID) IDRange {
 // nolint:gochecknoglobals
 var AllNonPrefixedIDs = IDRange{"0", "g"}
 
+// BytesReaderAt is an io.ReaderAt over an in-memory index, v2 and v3 indexes opened from it
+// access the byte slice directly instead of copying each entry.
+type BytesReaderAt []byte
+
+// ReadAt implements io.ReaderAt.
+func (b BytesReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.Errorf("negative offset")
+	}
+
+	if off >= int64(len(b)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
 // Open reads an Index from a given reader. The caller must call Close() when the index is no longer used.
 func Open(readerAt io.ReaderAt, v1PerContentOverhead uint32) (Index, error) {
 	h, err := v1ReadHeader(readerAt)
@@ -69,6 +101,9 @@ func Open(readerAt io.ReaderAt, v1PerContentOverhead uint32) (Index, error) {
 	case Version2:
 		return openV2PackIndex(readerAt)
 
+	case Version3:
+		return openV3PackIndex(readerAt)
+
 	default:
 		return nil, errors.Errorf("invalid header format: %v", h.version)
 	}
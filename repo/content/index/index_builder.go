@@ -7,6 +7,7 @@
 	"runtime"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -18,6 +19,14 @@
 // Builder prepares and writes content index.
 type Builder map[ID]Info
 
+// BuildOptions customizes which entries of the Builder are written to the index.
+type BuildOptions struct {
+	// DropDeletedBefore, when not zero, causes deleted entries with timestamps before it to be omitted
+	// from the index instead of being written as tombstones. Dropping a tombstone resurrects the content
+	// if any older index still references it, so the caller must choose the value conservatively.
+	DropDeletedBefore time.Time
+}
+
 // Clone returns a deep Clone of the Builder.
 func (b Builder) Clone() Builder {
 	if b == nil {
@@ -37,9 +46,7 @@ func (b Builder) Clone() Builder {
 func (b Builder) Add(i Info) {
 	cid := i.GetContentID()
 
-	if contentInfoGreaterThan(i, b[cid]) {
-		b[cid] = i
-	}
+	b[cid] = preferredInfo(b[cid], i)
 }
 
 // base36Value stores a base-36 reverse lookup such that ASCII character corresponds to its
@@ -140,6 +147,9 @@ func (b Builder) BuildStable(output io.Writer, version int) error {
 	case Version2:
 		return b.buildV2(output)
 
+	case Version3:
+		return b.buildV3(output)
+
 	default:
 		return errors.Errorf("unsupported index version: %v", version)
 	}
@@ -180,15 +190,41 @@ func (b Builder) shard(maxShardSize int) []Builder {
 	return nonEmpty
 }
 
+// withoutDeletedBefore returns the builder without deleted entries with timestamps before the provided time.
+// The receiver is not modified.
+func (b Builder) withoutDeletedBefore(t time.Time) Builder {
+	if t.IsZero() {
+		return b
+	}
+
+	var result Builder
+
+	for cid, i := range b {
+		if i.GetDeleted() && i.Timestamp().Before(t) {
+			if result == nil {
+				result = b.Clone()
+			}
+
+			delete(result, cid)
+		}
+	}
+
+	if result == nil {
+		return b
+	}
+
+	return result
+}
+
 // BuildShards builds the set of index shards ensuring no more than the provided number of contents are in each index.
 // Returns shard bytes and function to clean up after the shards have been written.
-func (b Builder) BuildShards(indexVersion int, stable bool, shardSize int) ([]gather.Bytes, func(), error) {
+func (b Builder) BuildShards(indexVersion int, stable bool, shardSize int, opt BuildOptions) ([]gather.Bytes, func(), error) {
 	if shardSize == 0 {
 		return nil, nil, errors.Errorf("invalid shard size")
 	}
 
 	var (
-		shardedBuilders = b.shard(shardSize)
+		shardedBuilders = b.withoutDeletedBefore(opt.DropDeletedBefore).shard(shardSize)
 		dataShardsBuf   []*gather.WriteBuffer
 		dataShards      []gather.Bytes
 		randomSuffix    [32]byte
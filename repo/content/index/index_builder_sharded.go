@@ -0,0 +1,123 @@
+package index
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// SortedInfoIterator invokes the provided callback for all entries in ascending order of content IDs
+// until the callback returns an error, which is propagated to the caller. The iterator may be invoked
+// more than once and must produce the same entries each time.
+type SortedInfoIterator func(cb func(Info) error) error
+
+// SortedIterator returns SortedInfoIterator over the contents of the builder, which can be used
+// as a shard of ShardedBuilder.
+func (b Builder) SortedIterator() SortedInfoIterator {
+	return iterateSortedInfos(b.sortedContents())
+}
+
+// ShardedBuilder builds an index by stream-merging already-sorted shards of entries without holding
+// all of them in memory at once. Entries with the same content ID present in multiple shards are
+// resolved the same way as in Builder.Add().
+//
+// Building makes two passes over the shards, the first one computes the layout of the index and the
+// second one writes the entries, so the output is identical to building the index in memory.
+type ShardedBuilder []SortedInfoIterator
+
+// BuildStable writes the pack index to the provided output.
+func (sb ShardedBuilder) BuildStable(output io.Writer, version int) error {
+	merged := sb.mergedIterator()
+
+	s := newSortedInfosSummary()
+
+	if err := merged(func(i Info) error {
+		s.add(i)
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "error summarizing index shards")
+	}
+
+	var (
+		b   *indexBuilderV2
+		err error
+	)
+
+	switch version {
+	case Version2:
+		b, err = newIndexBuilderV2(s)
+
+	case Version3:
+		b, err = newIndexBuilderV3(s)
+
+	default:
+		return errors.Errorf("unsupported index version for sharded build: %v", version)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return b.build(output, merged)
+}
+
+// mergedIterator returns SortedInfoIterator that merges all the shards, returning an error if any of
+// the shards is not sorted.
+func (sb ShardedBuilder) mergedIterator() SortedInfoIterator {
+	return func(cb func(Info) error) error {
+		done := make(chan bool)
+		shardErrors := make([]error, len(sb))
+
+		var chans []<-chan Info
+
+		for i, shard := range sb {
+			ch := make(chan Info, 1)
+			chans = append(chans, ch)
+
+			go func(i int, shard SortedInfoIterator, ch chan Info) {
+				defer close(ch)
+
+				shardErrors[i] = shard(func(it Info) error {
+					select {
+					case <-done:
+						return errEndOfIteration
+					case ch <- it:
+						return nil
+					}
+				})
+			}(i, shard, ch)
+		}
+
+		var last Info
+
+		err := mergeSortedChannels(&nextInfoHeap{}, chans, func(it Info) error {
+			if last != nil && it.GetContentID() <= last.GetContentID() {
+				return errors.Errorf("index shards are not sorted: %v after %v", it.GetContentID(), last.GetContentID())
+			}
+
+			last = it
+
+			return cb(it)
+		})
+
+		close(done)
+
+		// drain channels to make sure all shard iterations have completed.
+		for _, ch := range chans {
+			for range ch {
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		for _, serr := range shardErrors {
+			if serr != nil {
+				return errors.Wrap(serr, "error iterating index shard")
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedBuilder(t *testing.T) {
+	const (
+		numContents = 1000
+		numShards   = 4
+	)
+
+	for _, version := range []int{Version2, Version3} {
+		version := version
+
+		t.Run(fmt.Sprintf("v%v", version), func(t *testing.T) {
+			all := Builder{}
+			shards := make([]Builder, numShards)
+
+			for i := range shards {
+				shards[i] = Builder{}
+			}
+
+			for i := 0; i < numContents; i++ {
+				info := &InfoStruct{
+					ContentID:           deterministicContentID("sharded", i),
+					TimestampSeconds:    int64(i % 3),
+					Deleted:             i%11 == 0,
+					PackBlobID:          deterministicPackBlobID(i % 17),
+					PackOffset:          deterministicPackedOffset(i),
+					PackedLength:        deterministicPackedLength(i),
+					OriginalLength:      deterministicOriginalLength(i, version),
+					FormatVersion:       deterministicFormatVersion(i % 3),
+					CompressionHeaderID: deterministicCompressionHeaderID(i%5, version),
+				}
+
+				all.Add(info)
+				shards[i%numShards].Add(info)
+
+				// some contents are present in multiple shards with different timestamps.
+				if i%7 == 0 {
+					newer := *info
+					newer.TimestampSeconds += 10
+					newer.PackBlobID = deterministicPackBlobID(i % 19)
+
+					all.Add(&newer)
+					shards[(i+1)%numShards].Add(&newer)
+				}
+			}
+
+			var want, got bytes.Buffer
+
+			require.NoError(t, all.BuildStable(&want, version))
+
+			var sb ShardedBuilder
+
+			for _, s := range shards {
+				sb = append(sb, s.SortedIterator())
+			}
+
+			require.NoError(t, sb.BuildStable(&got, version))
+			require.Equal(t, want.Bytes(), got.Bytes())
+		})
+	}
+}
+
+func TestShardedBuilderUnsortedShard(t *testing.T) {
+	sb := ShardedBuilder{
+		iterateSortedInfos([]Info{
+			&InfoStruct{ContentID: deterministicContentID("unsorted", 1)},
+		}),
+		iterateSortedInfos([]Info{
+			&InfoStruct{ContentID: "ffff"},
+			&InfoStruct{ContentID: "eeee"},
+		}),
+	}
+
+	var buf bytes.Buffer
+
+	err := sb.BuildStable(&buf, Version2)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not sorted")
+}
+
+func TestShardedBuilderShardError(t *testing.T) {
+	someErr := errors.Errorf("some error")
+
+	sb := ShardedBuilder{
+		Builder{"aaaa": &InfoStruct{ContentID: "aaaa"}}.SortedIterator(),
+		func(cb func(Info) error) error {
+			if err := cb(&InfoStruct{ContentID: "bbbb"}); err != nil {
+				return err
+			}
+
+			return someErr
+		},
+	}
+
+	var buf bytes.Buffer
+
+	require.ErrorIs(t, sb.BuildStable(&buf, Version2), someErr)
+	require.Error(t, sb.BuildStable(&buf, Version1))
+}
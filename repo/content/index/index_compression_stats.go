@@ -0,0 +1,47 @@
+package index
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/compression"
+)
+
+// CompressionStat contains statistics about contents compressed using a particular compression method.
+type CompressionStat struct {
+	Count          int64 `json:"count"`
+	OriginalLength int64 `json:"originalLength"`
+	PackedLength   int64 `json:"packedLength"`
+}
+
+// CompressionStats iterates all non-deleted entries in the provided index and returns their statistics grouped by
+// compression header ID. Contents whose format can't be determined are reported under a separate
+// header ID that does not correspond to any compression method.
+func CompressionStats(ndx Index) (map[compression.HeaderID]CompressionStat, error) {
+	result := map[compression.HeaderID]CompressionStat{}
+
+	if err := ndx.Iterate(AllIDs, func(i Info) error {
+		if !i.GetDeleted() {
+			AddCompressionStat(result, i)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating index")
+	}
+
+	return result, nil
+}
+
+// AddCompressionStat adds the provided content to the statistics of its compression header ID.
+func AddCompressionStat(stats map[compression.HeaderID]CompressionStat, i Info) {
+	st := stats[i.GetCompressionHeaderID()]
+	st.Count++
+	st.OriginalLength += int64(i.GetOriginalLength())
+	st.PackedLength += int64(i.GetPackedLength())
+	stats[i.GetCompressionHeaderID()] = st
+}
+
+// CompressionStats returns statistics of contents in the index grouped by compression header ID.
+func (b *indexV2) CompressionStats() (map[compression.HeaderID]CompressionStat, error) {
+	return CompressionStats(b)
+}
@@ -0,0 +1,52 @@
+package index
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/repo/compression"
+)
+
+func TestCompressionStats(t *testing.T) {
+	b := Builder{}
+
+	b.Add(&InfoStruct{ContentID: "aabbcc", PackBlobID: "p1", OriginalLength: 100, PackedLength: 60, CompressionHeaderID: 1})
+	b.Add(&InfoStruct{ContentID: "bbccdd", PackBlobID: "p1", OriginalLength: 200, PackedLength: 100, CompressionHeaderID: 1})
+	b.Add(&InfoStruct{ContentID: "ccddee", PackBlobID: "p2", OriginalLength: 50, PackedLength: 50})
+	b.Add(&InfoStruct{ContentID: "ddeeff", PackBlobID: "p2", OriginalLength: 70, PackedLength: 70, Deleted: true})
+	b.Add(&InfoStruct{ContentID: "eeff00", PackBlobID: "p2", OriginalLength: 300, PackedLength: 30, CompressionHeaderID: 2})
+
+	for _, version := range []int{Version2, Version3} {
+		var buf bytes.Buffer
+
+		require.NoError(t, b.BuildStable(&buf, version))
+
+		ndx, err := Open(bytes.NewReader(buf.Bytes()), fakeEncryptionOverhead)
+		require.NoError(t, err)
+
+		stats, err := ndx.(*indexV2).CompressionStats() // nolint:forcetypeassert
+		require.NoError(t, err)
+		require.Equal(t, map[compression.HeaderID]CompressionStat{
+			0: {Count: 1, OriginalLength: 50, PackedLength: 50},
+			1: {Count: 2, OriginalLength: 300, PackedLength: 160},
+			2: {Count: 1, OriginalLength: 300, PackedLength: 30},
+		}, stats)
+
+		// truncate the format table, so that entries referring to formats other than the first one
+		// can't be resolved.
+		data := append([]byte(nil), buf.Bytes()...)
+		data[12] = 1
+
+		ndx, err = Open(bytes.NewReader(data), fakeEncryptionOverhead)
+		require.NoError(t, err)
+
+		stats, err = CompressionStats(ndx)
+		require.NoError(t, err)
+		require.Equal(t, map[compression.HeaderID]CompressionStat{
+			1:                          {Count: 2, OriginalLength: 300, PackedLength: 160},
+			invalidCompressionHeaderID: {Count: 2, OriginalLength: 350, PackedLength: 80},
+		}, stats)
+	}
+}
@@ -5,6 +5,11 @@ func decodeBigEndianUint48(d string) int64 {
 	return int64(d[0])<<40 | int64(d[1])<<32 | int64(d[2])<<24 | int64(d[3])<<16 | int64(d[4])<<8 | int64(d[5])
 }
 
+func decodeBigEndianUint40(d string) uint64 {
+	_ = d[4] // early bounds check
+	return uint64(d[0])<<32 | uint64(d[1])<<24 | uint64(d[2])<<16 | uint64(d[3])<<8 | uint64(d[4])
+}
+
 func decodeBigEndianUint32(d string) uint32 {
 	_ = d[3] // early bounds check
 	return uint32(d[0])<<24 | uint32(d[1])<<16 | uint32(d[2])<<8 | uint32(d[3])
@@ -26,3 +31,12 @@ func encodeBigEndianUint24(b []byte, v uint32) {
 	b[1] = byte(v >> 8)  // nolint:gomnd
 	b[2] = byte(v)
 }
+
+func encodeBigEndianUint40(b []byte, v uint64) {
+	_ = b[4]             // early bounds check
+	b[0] = byte(v >> 32) // nolint:gomnd
+	b[1] = byte(v >> 24) // nolint:gomnd
+	b[2] = byte(v >> 16) // nolint:gomnd
+	b[3] = byte(v >> 8)  // nolint:gomnd
+	b[4] = byte(v)
+}
@@ -0,0 +1,91 @@
+package index
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// timeRange lazily computes and caches the range of timestamps of contents in an index,
+// which requires reading all entries.
+type timeRange struct {
+	once sync.Once
+	min  time.Time
+	max  time.Time
+}
+
+// get returns the cached time range of the provided index, computing it on first use.
+// If the index can't be read, it returns the widest range of timestamps that can be represented
+// in the index, so callers never incorrectly conclude that a content is not present.
+func (r *timeRange) get(ndx Index, widest func() (min, max time.Time)) (min, max time.Time) {
+	r.once.Do(func() {
+		var minTS, maxTS int64
+
+		cnt := 0
+
+		if err := ndx.Iterate(AllIDs, func(i Info) error {
+			ts := i.GetTimestampSeconds()
+
+			if cnt == 0 || ts < minTS {
+				minTS = ts
+			}
+
+			if cnt == 0 || ts > maxTS {
+				maxTS = ts
+			}
+
+			cnt++
+
+			return nil
+		}); err != nil {
+			r.min, r.max = widest()
+			return
+		}
+
+		if cnt > 0 {
+			r.min, r.max = time.Unix(minTS, 0), time.Unix(maxTS, 0)
+		}
+	})
+
+	return r.min, r.max
+}
+
+// TimeRange returns the minimum and maximum timestamps of contents in the index, including deleted ones.
+// Both times are zero if the index is empty.
+func (b *indexV1) TimeRange() (min, max time.Time) {
+	return b.timeRange.get(b, func() (min, max time.Time) {
+		// v1 stores 48-bit timestamps.
+		return time.Unix(0, 0), time.Unix(1<<48-1, 0)
+	})
+}
+
+// TimeRange returns the minimum and maximum timestamps of contents in the index, including deleted ones.
+// Both times are zero if the index is empty.
+func (b *indexV2) TimeRange() (min, max time.Time) {
+	return b.timeRange.get(b, func() (min, max time.Time) {
+		base := int64(b.hdr.baseTimestamp)
+
+		return time.Unix(base, 0), time.Unix(base+math.MaxUint32, 0)
+	})
+}
+
+// TimeRange returns the union of time ranges of all underlying indexes.
+// Both times are zero if all indexes are empty.
+func (m Merged) TimeRange() (min, max time.Time) {
+	for _, ndx := range m {
+		nmin, nmax := ndx.TimeRange()
+		if nmin.IsZero() {
+			continue
+		}
+
+		if min.IsZero() || nmin.Before(min) {
+			min = nmin
+		}
+
+		if max.IsZero() || nmax.After(max) {
+			max = nmax
+		}
+	}
+
+	return min, max
+}
@@ -0,0 +1,82 @@
+package index
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeRange(t *testing.T) {
+	b := Builder{}
+
+	b.Add(&InfoStruct{ContentID: "aabbcc", PackBlobID: "p1", TimestampSeconds: 300})
+	b.Add(&InfoStruct{ContentID: "bbccdd", PackBlobID: "p1", TimestampSeconds: 100})
+	b.Add(&InfoStruct{ContentID: "ccddee", PackBlobID: "p2", TimestampSeconds: 500, Deleted: true})
+	b.Add(&InfoStruct{ContentID: "ddeeff", PackBlobID: "p2", TimestampSeconds: 200})
+
+	for _, version := range []int{Version1, Version2, Version3} {
+		var buf bytes.Buffer
+
+		require.NoError(t, b.Build(&buf, version))
+
+		ndx, err := Open(bytes.NewReader(buf.Bytes()), fakeEncryptionOverhead)
+		require.NoError(t, err)
+
+		min, max := ndx.TimeRange()
+		require.Equal(t, time.Unix(100, 0), min, "version %v", version)
+		require.Equal(t, time.Unix(500, 0), max, "version %v", version)
+	}
+
+	empty, err := indexWithItemsVersion(Version1)
+	require.NoError(t, err)
+
+	min, max := empty.TimeRange()
+	require.True(t, min.IsZero())
+	require.True(t, max.IsZero())
+}
+
+func TestTimeRangeUnreadable(t *testing.T) {
+	var buf bytes.Buffer
+
+	require.NoError(t, Builder{
+		"aabbcc": &InfoStruct{ContentID: "aabbcc", PackBlobID: "p1", TimestampSeconds: 300},
+	}.Build(&buf, Version2))
+
+	ndx, err := Open(bytes.NewReader(buf.Bytes()), fakeEncryptionOverhead)
+	require.NoError(t, err)
+
+	// make the entries unreadable.
+	ndx.(*indexV2).readerAt = bytes.NewReader(nil) // nolint:forcetypeassert
+
+	min, max := ndx.TimeRange()
+	require.Equal(t, time.Unix(0, 0), min)
+	require.Equal(t, time.Unix(math.MaxUint32, 0), max)
+}
+
+func TestMergedTimeRange(t *testing.T) {
+	i1, err := indexWithItems(
+		&InfoStruct{ContentID: "aabbcc", TimestampSeconds: 100, PackBlobID: "xx"},
+		&InfoStruct{ContentID: "ddeeff", TimestampSeconds: 200, PackBlobID: "xx"},
+	)
+	require.NoError(t, err)
+
+	i2, err := indexWithItemsVersion(Version1)
+	require.NoError(t, err)
+
+	i3, err := indexWithItemsVersion(Version3,
+		&InfoStruct{ContentID: "aabbcc", TimestampSeconds: 50, PackBlobID: "zz"},
+		&InfoStruct{ContentID: "k010203", TimestampSeconds: 150, PackBlobID: "zz"},
+	)
+	require.NoError(t, err)
+
+	min, max := Merged{i1, i2, i3}.TimeRange()
+	require.Equal(t, time.Unix(50, 0), min)
+	require.Equal(t, time.Unix(200, 0), max)
+
+	min, max = Merged{i2}.TimeRange()
+	require.True(t, min.IsZero())
+	require.True(t, max.IsZero())
+}
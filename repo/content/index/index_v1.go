@@ -22,6 +22,8 @@
 	v1DeletedMarker = 0x80000000
 	v1MaxEntrySize  = 256 // maximum length of content ID + per-entry data combined
 	v1EntryLength   = 20
+	v1MaxPackOffset = 1 << 31
+	v1MaxLength     = 1 << 32
 )
 
 // FormatV1 describes a format of a single pack index. The actual structure is not used,
@@ -81,18 +83,18 @@ func (e indexEntryInfoV1) GetDeleted() bool {
 	return e.data[12]&0x80 != 0
 }
 
-func (e indexEntryInfoV1) GetPackOffset() uint32 {
+func (e indexEntryInfoV1) GetPackOffset() uint64 {
 	const packOffsetMask = 1<<31 - 1
-	return decodeBigEndianUint32(e.data[12:]) & packOffsetMask
+	return uint64(decodeBigEndianUint32(e.data[12:]) & packOffsetMask)
 }
 
 // bytes 16..19: 4 bytes, big endian, content length.
-func (e indexEntryInfoV1) GetPackedLength() uint32 {
-	return decodeBigEndianUint32(e.data[16:])
+func (e indexEntryInfoV1) GetPackedLength() uint64 {
+	return uint64(decodeBigEndianUint32(e.data[16:]))
 }
 
-func (e indexEntryInfoV1) GetOriginalLength() uint32 {
-	return e.GetPackedLength() - e.b.v1PerContentOverhead
+func (e indexEntryInfoV1) GetOriginalLength() uint64 {
+	return e.GetPackedLength() - uint64(e.b.v1PerContentOverhead)
 }
 
 func (e indexEntryInfoV1) Timestamp() time.Time {
@@ -107,6 +109,10 @@ func (e indexEntryInfoV1) GetEncryptionKeyID() byte {
 	return 0
 }
 
+func (e indexEntryInfoV1) GetCompressionDictionaryID() blob.ID {
+	return ""
+}
+
 var _ Info = indexEntryInfoV1{}
 
 type indexV1 struct {
@@ -115,6 +121,8 @@ type indexV1 struct {
 	// v1 index does not explicitly store per-content length so we compute it from packed length and fixed overhead
 	// provided by the encryptor.
 	v1PerContentOverhead uint32
+
+	timeRange timeRange
 }
 
 func (b *indexV1) ApproximateCount() int {
@@ -158,6 +166,42 @@ func (b *indexV1) Iterate(r IDRange, cb func(Info) error) error {
 	return nil
 }
 
+// IterateReverse invokes the provided callback function for a range of contents in the index, sorted
+// in reverse alphabetical order.
+func (b *indexV1) IterateReverse(r IDRange, cb func(Info) error) error {
+	endPos, err := b.findEntryPosition(r.EndID)
+	if err != nil {
+		return errors.Wrap(err, "could not find ending position")
+	}
+
+	stride := b.hdr.keySize + b.hdr.valueSize
+	entry := make([]byte, stride)
+
+	for i := endPos - 1; i >= 0; i-- {
+		if err := readAtAll(b.readerAt, entry, int64(v1HeaderSize+stride*i)); err != nil {
+			return errors.Wrap(err, "unable to read from index")
+		}
+
+		key := entry[0:b.hdr.keySize]
+
+		contentID := bytesToContentID(key)
+		if contentID < r.StartID {
+			break
+		}
+
+		i, err := b.entryToInfo(contentID, entry[b.hdr.keySize:])
+		if err != nil {
+			return errors.Wrap(err, "invalid index data")
+		}
+
+		if err := cb(i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (b *indexV1) findEntryPosition(contentID ID) (int, error) {
 	stride := b.hdr.keySize + b.hdr.valueSize
 
@@ -272,6 +316,24 @@ func (b *indexV1) GetInfo(contentID ID) (Info, error) {
 	return b.entryToInfo(contentID, e)
 }
 
+// GetInfos returns information about the provided contents that are present in the index.
+func (b *indexV1) GetInfos(contentIDs []ID) (map[ID]Info, error) {
+	result := map[ID]Info{}
+
+	for _, cid := range contentIDs {
+		i, err := b.GetInfo(cid)
+		if err != nil {
+			return nil, err
+		}
+
+		if i != nil {
+			result[cid] = i
+		}
+	}
+
+	return result, nil
+}
+
 func (b *indexV1) entryToInfo(contentID ID, entryData []byte) (Info, error) {
 	if len(entryData) != v1EntryLength {
 		return nil, errors.Errorf("invalid entry length: %v", len(entryData))
@@ -380,6 +442,10 @@ func (b *indexBuilderV1) writeEntry(w io.Writer, it Info, entry []byte) error {
 		return errors.Errorf("encryption key ID not supported in index v1")
 	}
 
+	if it.GetCompressionDictionaryID() != "" {
+		return errors.Errorf("compression dictionaries not supported in index v1")
+	}
+
 	if err := b.formatEntry(entry, it); err != nil {
 		return errors.Wrap(err, "unable to format entry")
 	}
@@ -407,15 +473,23 @@ func (b *indexBuilderV1) formatEntry(entry []byte, it Info) error {
 		return errors.Errorf("empty pack content ID for %v", it.GetContentID())
 	}
 
+	if it.GetPackOffset() >= v1MaxPackOffset {
+		return errors.Errorf("pack offset %v is too high", it.GetPackOffset())
+	}
+
+	if it.GetPackedLength() >= v1MaxLength {
+		return errors.Errorf("packed length %v is too high", it.GetPackedLength())
+	}
+
 	binary.BigEndian.PutUint32(entryPackFileOffset, b.extraDataOffset+b.packBlobIDOffsets[packBlobID])
 
 	if it.GetDeleted() {
-		binary.BigEndian.PutUint32(entryPackedOffset, it.GetPackOffset()|v1DeletedMarker)
+		binary.BigEndian.PutUint32(entryPackedOffset, uint32(it.GetPackOffset())|v1DeletedMarker)
 	} else {
-		binary.BigEndian.PutUint32(entryPackedOffset, it.GetPackOffset())
+		binary.BigEndian.PutUint32(entryPackedOffset, uint32(it.GetPackOffset()))
 	}
 
-	binary.BigEndian.PutUint32(entryPackedLength, it.GetPackedLength())
+	binary.BigEndian.PutUint32(entryPackedLength, uint32(it.GetPackedLength()))
 	timestampAndFlags |= uint64(it.GetFormatVersion()) << 8 // nolint:gomnd
 	timestampAndFlags |= uint64(len(packBlobID))
 	binary.BigEndian.PutUint64(entryTimestampAndFlags, timestampAndFlags)
@@ -452,5 +526,5 @@ func v1ReadHeader(readerAt io.ReaderAt) (v1HeaderInfo, error) {
 }
 
 func openV1PackIndex(hdr v1HeaderInfo, readerAt io.ReaderAt, overhead uint32) (Index, error) {
-	return &indexV1{hdr, readerAt, overhead}, nil
+	return &indexV1{hdr: hdr, readerAt: readerAt, v1PerContentOverhead: overhead}, nil
 }
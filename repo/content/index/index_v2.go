@@ -85,13 +85,26 @@
 
 // layout of v2 format entry
 //    0-3: compressionID - 32 bit (corresponding to compression.HeaderID)
+//      4: format version
+//      5: encryption key ID
 //
+// optional bytes - present in all format entries if any format uses a compression dictionary:
+//      6: length of compression dictionary blob ID - 0 if the format does not use a dictionary
+//   7-10: offset of compression dictionary blob ID (within extra data)
 const (
-	v2FormatInfoSize = 6
-
-	v2FormatOffsetCompressionID   = 0
-	v2FormatOffsetFormatVersion   = 4
-	v2FormatOffsetEncryptionKeyID = 5
+	v2FormatInfoSize               = 6
+	v2FormatInfoWithDictionarySize = 11
+
+	v2FormatOffsetCompressionID      = 0
+	v2FormatOffsetFormatVersion      = 4
+	v2FormatOffsetEncryptionKeyID    = 5
+	v2FormatOffsetDictionaryIDLength = 6
+	v2FormatOffsetDictionaryIDOffset = 7
+
+	// set in the entry size field of the header when format entries include compression dictionaries.
+	// older readers reject such indexes because of invalid entry size instead of reading compressed
+	// contents without their dictionary.
+	v2EntrySizeFlagDictionaries = 0x8000
 )
 
 // FormatV2 describes a format of a single pack index. The actual structure is not used,
@@ -133,6 +146,7 @@ type indexV2FormatInfo struct {
 	compressionHeaderID compression.HeaderID
 	formatVersion       byte
 	encryptionKeyID     byte
+	dictionaryID        blob.ID // empty if compression does not use a dictionary
 }
 
 type indexV2EntryInfo struct {
@@ -153,26 +167,26 @@ func (e indexV2EntryInfo) GetDeleted() bool {
 	return e.data[v2EntryOffsetPackOffsetAndFlags]&v2EntryDeletedFlag != 0
 }
 
-func (e indexV2EntryInfo) GetPackOffset() uint32 {
-	return decodeBigEndianUint32(e.data[v2EntryOffsetPackOffsetAndFlags:]) & v2EntryPackOffsetMask
+func (e indexV2EntryInfo) GetPackOffset() uint64 {
+	return uint64(decodeBigEndianUint32(e.data[v2EntryOffsetPackOffsetAndFlags:]) & v2EntryPackOffsetMask)
 }
 
-func (e indexV2EntryInfo) GetOriginalLength() uint32 {
+func (e indexV2EntryInfo) GetOriginalLength() uint64 {
 	v := decodeBigEndianUint24(e.data[v2EntryOffsetOriginalLength:])
 	if len(e.data) > v2EntryOffsetHighLengthBits {
 		v |= uint32(e.data[v2EntryOffsetHighLengthBits]>>v2EntryHighLengthBitsOriginalLengthShift) << v2EntryHighLengthShift
 	}
 
-	return v
+	return uint64(v)
 }
 
-func (e indexV2EntryInfo) GetPackedLength() uint32 {
+func (e indexV2EntryInfo) GetPackedLength() uint64 {
 	v := decodeBigEndianUint24(e.data[v2EntryOffsetPackedLength:])
 	if len(e.data) > v2EntryOffsetHighLengthBits {
 		v |= uint32(e.data[v2EntryOffsetHighLengthBits]&v2EntryHghLengthBitsPackedLengthMask) << v2EntryHighLengthShift
 	}
 
-	return v
+	return uint64(v)
 }
 
 func (e indexV2EntryInfo) formatIDIndex() int {
@@ -185,7 +199,7 @@ func (e indexV2EntryInfo) formatIDIndex() int {
 
 func (e indexV2EntryInfo) GetFormatVersion() byte {
 	fid := e.formatIDIndex()
-	if fid > len(e.b.formats) {
+	if fid >= len(e.b.formats) {
 		return invalidFormatVersion
 	}
 
@@ -194,7 +208,7 @@ func (e indexV2EntryInfo) GetFormatVersion() byte {
 
 func (e indexV2EntryInfo) GetCompressionHeaderID() compression.HeaderID {
 	fid := e.formatIDIndex()
-	if fid > len(e.b.formats) {
+	if fid >= len(e.b.formats) {
 		return invalidCompressionHeaderID
 	}
 
@@ -203,20 +217,33 @@ func (e indexV2EntryInfo) GetCompressionHeaderID() compression.HeaderID {
 
 func (e indexV2EntryInfo) GetEncryptionKeyID() byte {
 	fid := e.formatIDIndex()
-	if fid > len(e.b.formats) {
+	if fid >= len(e.b.formats) {
 		return invalidEncryptionKeyID
 	}
 
 	return e.b.formats[fid].encryptionKeyID
 }
 
-func (e indexV2EntryInfo) GetPackBlobID() blob.ID {
+func (e indexV2EntryInfo) GetCompressionDictionaryID() blob.ID {
+	fid := e.formatIDIndex()
+	if fid >= len(e.b.formats) {
+		return invalidBlobID
+	}
+
+	return e.b.formats[fid].dictionaryID
+}
+
+func (e indexV2EntryInfo) packIDIndex() uint32 {
 	packIDIndex := uint32(decodeBigEndianUint16(e.data[v2EntryOffsetPackBlobID:]))
 	if len(e.data) > v2EntryOffsetExtendedPackBlobID {
 		packIDIndex |= uint32(e.data[v2EntryOffsetExtendedPackBlobID]) << v2EntryExtendedPackBlobIDShift
 	}
 
-	return e.b.getPackBlobIDByIndex(packIDIndex)
+	return packIDIndex
+}
+
+func (e indexV2EntryInfo) GetPackBlobID() blob.ID {
+	return e.b.getPackBlobIDByIndex(e.packIDIndex())
 }
 
 func (e indexV2EntryInfo) Timestamp() time.Time {
@@ -234,6 +261,8 @@ type v2HeaderInfo struct {
 	formatCount   byte
 	baseTimestamp uint32 // base timestamp in unix seconds
 
+	formatInfoSize int // size of each format entry, depends on whether compression dictionaries are used
+
 	// calculated
 	entriesOffset int64
 	formatsOffset int64
@@ -244,7 +273,11 @@ type v2HeaderInfo struct {
 type indexV2 struct {
 	hdr      v2HeaderInfo
 	readerAt io.ReaderAt
-	formats  []indexV2FormatInfo
+	// data of indexes opened from BytesReaderAt, which is sliced directly instead of being read into buffers.
+	data      []byte
+	formats   []indexV2FormatInfo
+	integrity *v2IntegrityInfo // nil for indexes written without integrity information
+	timeRange timeRange
 }
 
 func (b *indexV2) getPackBlobIDByIndex(ndx uint32) blob.ID {
@@ -252,9 +285,10 @@ func (b *indexV2) getPackBlobIDByIndex(ndx uint32) blob.ID {
 		return invalidBlobID
 	}
 
-	var buf [v2PackInfoSize]byte
+	var packInfoBuf [v2PackInfoSize]byte
 
-	if err := readAtAll(b.readerAt, buf[:], b.hdr.packsOffset+int64(v2PackInfoSize*ndx)); err != nil {
+	buf, err := b.readAt(packInfoBuf[:], b.hdr.packsOffset+int64(v2PackInfoSize*ndx))
+	if err != nil {
 		return invalidBlobID
 	}
 
@@ -263,11 +297,12 @@ func (b *indexV2) getPackBlobIDByIndex(ndx uint32) blob.ID {
 
 	var nameBuf [256]byte
 
-	if err := readAtAll(b.readerAt, nameBuf[0:nameLength], int64(nameOffset)); err != nil {
+	name, err := b.readAt(nameBuf[0:nameLength], int64(nameOffset))
+	if err != nil {
 		return invalidBlobID
 	}
 
-	return blob.ID(nameBuf[0:nameLength])
+	return blob.ID(name)
 }
 
 func (b *indexV2) ApproximateCount() int {
@@ -284,10 +319,10 @@ func (b *indexV2) Iterate(r IDRange, cb func(Info) error) error {
 	}
 
 	var entryBuf [v2MaxEntrySize]byte
-	entry := entryBuf[0:b.hdr.entryStride]
 
 	for i := startPos; i < b.hdr.entryCount; i++ {
-		if err := readAtAll(b.readerAt, entry, b.entryOffset(i)); err != nil {
+		entry, err := b.readEntry(i, entryBuf[:])
+		if err != nil {
 			return errors.Wrap(err, "unable to read from index")
 		}
 
@@ -311,13 +346,72 @@ func (b *indexV2) Iterate(r IDRange, cb func(Info) error) error {
 	return nil
 }
 
+// IterateReverse invokes the provided callback function for a range of contents in the index, sorted
+// in reverse alphabetical order. The iteration ends when the callback returns an error, which is propagated
+// to the caller or when all contents have been visited.
+func (b *indexV2) IterateReverse(r IDRange, cb func(Info) error) error {
+	endPos, err := b.findEntryPosition(r.EndID)
+	if err != nil {
+		return errors.Wrap(err, "could not find ending position")
+	}
+
+	var entryBuf [v2MaxEntrySize]byte
+
+	for i := endPos - 1; i >= 0; i-- {
+		entry, err := b.readEntry(i, entryBuf[:])
+		if err != nil {
+			return errors.Wrap(err, "unable to read from index")
+		}
+
+		key := entry[0:b.hdr.keySize]
+
+		contentID := bytesToContentID(key)
+		if contentID < r.StartID {
+			break
+		}
+
+		i, err := b.entryToInfo(contentID, entry[b.hdr.keySize:])
+		if err != nil {
+			return errors.Wrap(err, "invalid index data")
+		}
+
+		if err := cb(i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (b *indexV2) entryOffset(p int) int64 {
 	return b.hdr.entriesOffset + b.hdr.entryStride*int64(p)
 }
 
+// readEntry returns the entry at the provided position, using buf if the entry needs to be read.
+func (b *indexV2) readEntry(p int, buf []byte) ([]byte, error) {
+	return b.readAt(buf[0:b.hdr.entryStride], b.entryOffset(p))
+}
+
+// readAt returns len(buf) bytes at the provided offset, which are sliced directly from the data of
+// in-memory indexes and read into buf otherwise.
+func (b *indexV2) readAt(buf []byte, offset int64) ([]byte, error) {
+	if b.data == nil {
+		if err := readAtAll(b.readerAt, buf, offset); err != nil {
+			return nil, err
+		}
+
+		return buf, nil
+	}
+
+	if offset < 0 || offset+int64(len(buf)) > int64(len(b.data)) {
+		return nil, errors.Errorf("incomplete read at offset %v, expected %v bytes", offset, len(buf))
+	}
+
+	return b.data[offset : offset+int64(len(buf))], nil
+}
+
 func (b *indexV2) findEntryPosition(contentID ID) (int, error) {
-	var entryArr [v2MaxEntrySize]byte
-	entryBuf := entryArr[0:b.hdr.entryStride]
+	var entryBuf [v2MaxEntrySize]byte
 
 	var readErr error
 
@@ -326,12 +420,13 @@ func (b *indexV2) findEntryPosition(contentID ID) (int, error) {
 			return false
 		}
 
-		if err := readAtAll(b.readerAt, entryBuf, b.entryOffset(p)); err != nil {
+		entry, err := b.readEntry(p, entryBuf[:])
+		if err != nil {
 			readErr = err
 			return false
 		}
 
-		return bytesToContentID(entryBuf[0:b.hdr.keySize]) >= contentID
+		return bytesToContentID(entry[0:b.hdr.keySize]) >= contentID
 	})
 
 	return pos, readErr
@@ -345,12 +440,13 @@ func (b *indexV2) findEntryPositionExact(idBytes, entryBuf []byte) (int, error)
 			return false
 		}
 
-		if err := readAtAll(b.readerAt, entryBuf, b.entryOffset(p)); err != nil {
+		entry, err := b.readEntry(p, entryBuf)
+		if err != nil {
 			readErr = err
 			return false
 		}
 
-		return contentIDBytesGreaterOrEqual(entryBuf[0:b.hdr.keySize], idBytes)
+		return contentIDBytesGreaterOrEqual(entry[0:b.hdr.keySize], idBytes)
 	})
 
 	return pos, readErr
@@ -382,12 +478,13 @@ func (b *indexV2) findEntry(output []byte, contentID ID) ([]byte, error) {
 		return nil, nil
 	}
 
-	if err := readAtAll(b.readerAt, entryBuf, b.entryOffset(position)); err != nil {
+	entry, err := b.readEntry(position, entryBuf)
+	if err != nil {
 		return nil, errors.Wrap(err, "error reading header")
 	}
 
-	if bytes.Equal(entryBuf[0:len(key)], key) {
-		return append(output, entryBuf[len(key):]...), nil
+	if bytes.Equal(entry[0:len(key)], key) {
+		return append(output, entry[len(key):]...), nil
 	}
 
 	return nil, nil
@@ -409,7 +506,130 @@ func (b *indexV2) GetInfo(contentID ID) (Info, error) {
 	return b.entryToInfo(contentID, e)
 }
 
+// GetInfos returns information about the provided contents that are present in the index.
+// The content IDs are sorted and located in a single forward pass over the index, which is
+// cheaper than looking them up individually.
+func (b *indexV2) GetInfos(contentIDs []ID) (map[ID]Info, error) {
+	result := make(map[ID]Info, len(contentIDs))
+
+	// empty index blob, this is possible when compaction removes exactly everything
+	if b.hdr.keySize == unknownKeySize {
+		return result, nil
+	}
+
+	// content IDs are generally ordered the same way as their binary representation.
+	sorted := append([]ID(nil), contentIDs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	var (
+		hashBuf     [maxContentIDSize]byte
+		prevHashBuf [maxContentIDSize]byte
+		entryArr    [v2MaxEntrySize]byte
+	)
+
+	entryBuf := entryArr[0:b.hdr.entryStride]
+	prevKey := prevHashBuf[:0]
+
+	pos := 0
+
+	for _, contentID := range sorted {
+		key := contentIDToBytes(hashBuf[:0], contentID)
+		if len(key) != b.hdr.keySize {
+			return nil, errors.Errorf("invalid content ID: %q (%v vs %v)", contentID, len(key), b.hdr.keySize)
+		}
+
+		// the exception are IDs that are not hex-encoded, in which case we need to start over.
+		if bytes.Compare(key, prevKey) < 0 {
+			pos = 0
+		}
+
+		prevKey = append(prevKey[:0], key...)
+
+		p, err := b.findEntryPositionFrom(pos, key, entryBuf)
+		if err != nil {
+			return nil, err
+		}
+
+		pos = p
+
+		if pos >= b.hdr.entryCount {
+			continue
+		}
+
+		entry, err := b.readEntry(pos, entryBuf)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading entry")
+		}
+
+		if !bytes.Equal(entry[0:len(key)], key) {
+			continue
+		}
+
+		i, err := b.entryToInfo(contentID, entry[len(key):])
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid index data")
+		}
+
+		result[contentID] = i
+	}
+
+	return result, nil
+}
+
+// findEntryPositionFrom returns the position of the first entry at or after startPos whose key is
+// greater or equal to the provided key. It probes entries at exponentially growing distances
+// from startPos before doing binary search, so nearby keys are found with few reads.
+func (b *indexV2) findEntryPositionFrom(startPos int, idBytes, entryBuf []byte) (int, error) {
+	lo, step := startPos, 1
+
+	// find the range [lo,hi) that contains the position.
+	hi := lo
+
+	for hi < b.hdr.entryCount {
+		entry, err := b.readEntry(hi, entryBuf)
+		if err != nil {
+			return 0, err
+		}
+
+		if contentIDBytesGreaterOrEqual(entry[0:b.hdr.keySize], idBytes) {
+			break
+		}
+
+		lo = hi + 1
+		hi += step
+		step *= 2
+	}
+
+	if hi > b.hdr.entryCount {
+		hi = b.hdr.entryCount
+	}
+
+	var readErr error
+
+	pos := lo + sort.Search(hi-lo, func(p int) bool {
+		if readErr != nil {
+			return false
+		}
+
+		entry, err := b.readEntry(lo+p, entryBuf)
+		if err != nil {
+			readErr = err
+			return false
+		}
+
+		return contentIDBytesGreaterOrEqual(entry[0:b.hdr.keySize], idBytes)
+	})
+
+	return pos, readErr
+}
+
 func (b *indexV2) entryToInfo(contentID ID, entryData []byte) (Info, error) {
+	if b.hdr.version == Version3 {
+		return b.entryToInfoV3(contentID, entryData)
+	}
+
 	if len(entryData) < v2EntryMinLength {
 		return nil, errors.Errorf("invalid entry length: %v", len(entryData))
 	}
@@ -428,7 +648,10 @@ func (b *indexV2) Close() error {
 }
 
 type indexBuilderV2 struct {
+	version                int
 	packBlobIDOffsets      map[blob.ID]uint32
+	dictionaryIDOffsets    map[blob.ID]uint32
+	formatInfoSize         int
 	entryCount             int
 	keyLength              int
 	entrySize              int
@@ -443,54 +666,88 @@ func indexV2FormatInfoFromInfo(v Info) indexV2FormatInfo {
 		formatVersion:       v.GetFormatVersion(),
 		compressionHeaderID: v.GetCompressionHeaderID(),
 		encryptionKeyID:     v.GetEncryptionKeyID(),
+		dictionaryID:        v.GetCompressionDictionaryID(),
 	}
 }
 
-// buildUniqueFormatToIndexMap builds a map of unique indexV2FormatInfo to their numeric identifiers.
-func buildUniqueFormatToIndexMap(sortedInfos []Info) map[indexV2FormatInfo]byte {
-	result := map[indexV2FormatInfo]byte{}
+// sortedInfosSummary describes a sorted list of Info written to an index, accumulated in a single pass.
+type sortedInfosSummary struct {
+	entryCount         int
+	keyLength          int
+	uniqueFormat2Index map[indexV2FormatInfo]byte // unique formats to their numeric identifiers
+	packID2Index       map[blob.ID]int            // unique blob IDs to their numeric identifiers
+	maxPackedLength    uint64
+	maxOriginalLength  uint64
+	maxPackOffset      uint64
+	maxPackEnd         uint64
+}
 
-	for _, v := range sortedInfos {
-		key := indexV2FormatInfoFromInfo(v)
-		if _, ok := result[key]; !ok {
-			result[key] = byte(len(result))
-		}
+// add updates the summary with the provided Info, which must be added in the order of content IDs.
+func (s *sortedInfosSummary) add(v Info) {
+	if s.entryCount == 0 {
+		var hashBuf [maxContentIDSize]byte
+
+		s.keyLength = len(contentIDToBytes(hashBuf[:0], v.GetContentID()))
 	}
 
-	return result
-}
+	s.entryCount++
 
-// buildPackIDToIndexMap builds a map of unqiue blob IDs to their numeric identifiers.
-func buildPackIDToIndexMap(sortedInfos []Info) map[blob.ID]int {
-	result := map[blob.ID]int{}
+	key := indexV2FormatInfoFromInfo(v)
+	if _, ok := s.uniqueFormat2Index[key]; !ok {
+		s.uniqueFormat2Index[key] = byte(len(s.uniqueFormat2Index))
+	}
 
-	for _, v := range sortedInfos {
-		blobID := v.GetPackBlobID()
-		if _, ok := result[blobID]; !ok {
-			result[blobID] = len(result)
-		}
+	blobID := v.GetPackBlobID()
+	if _, ok := s.packID2Index[blobID]; !ok {
+		s.packID2Index[blobID] = len(s.packID2Index)
 	}
 
-	return result
+	if l := v.GetPackedLength(); l > s.maxPackedLength {
+		s.maxPackedLength = l
+	}
+
+	if l := v.GetOriginalLength(); l > s.maxOriginalLength {
+		s.maxOriginalLength = l
+	}
+
+	if l := v.GetPackOffset(); l > s.maxPackOffset {
+		s.maxPackOffset = l
+	}
+
+	if l := v.GetPackOffset() + v.GetPackedLength(); l > s.maxPackEnd {
+		s.maxPackEnd = l
+	}
 }
 
-// maxContentLengths computes max content lengths in the builder.
-func maxContentLengths(sortedInfos []Info) (maxPackedLength, maxOriginalLength, maxPackOffset uint32) {
+func newSortedInfosSummary() *sortedInfosSummary {
+	return &sortedInfosSummary{
+		keyLength:          -1,
+		uniqueFormat2Index: map[indexV2FormatInfo]byte{},
+		packID2Index:       map[blob.ID]int{},
+	}
+}
+
+func summarizeSortedInfos(sortedInfos []Info) *sortedInfosSummary {
+	s := newSortedInfosSummary()
+
 	for _, v := range sortedInfos {
-		if l := v.GetPackedLength(); l > maxPackedLength {
-			maxPackedLength = l
-		}
+		s.add(v)
+	}
 
-		if l := v.GetOriginalLength(); l > maxOriginalLength {
-			maxOriginalLength = l
-		}
+	return s
+}
 
-		if l := v.GetPackOffset(); l > maxPackOffset {
-			maxPackOffset = l
+// iterateSortedInfos returns SortedInfoIterator over the provided slice.
+func iterateSortedInfos(sortedInfos []Info) SortedInfoIterator {
+	return func(cb func(Info) error) error {
+		for _, v := range sortedInfos {
+			if err := cb(v); err != nil {
+				return err
+			}
 		}
-	}
 
-	return
+		return nil
+	}
 }
 
 func max(a, b int) int {
@@ -501,11 +758,10 @@ func max(a, b int) int {
 	return b
 }
 
-func newIndexBuilderV2(sortedInfos []Info) (*indexBuilderV2, error) {
+func newIndexBuilderV2(s *sortedInfosSummary) (*indexBuilderV2, error) {
 	entrySize := v2EntryOffsetFormatID
 
-	// compute a map of unique formats to their indexes.
-	uniqueFormat2Index := buildUniqueFormatToIndexMap(sortedInfos)
+	uniqueFormat2Index := s.uniqueFormat2Index
 	if len(uniqueFormat2Index) > v2MaxFormatCount {
 		return nil, errors.Errorf("unsupported - too many unique formats %v (max %v)", len(uniqueFormat2Index), v2MaxFormatCount)
 	}
@@ -515,7 +771,7 @@ func newIndexBuilderV2(sortedInfos []Info) (*indexBuilderV2, error) {
 		entrySize = max(entrySize, v2EntryOffsetFormatIDEnd)
 	}
 
-	packID2Index := buildPackIDToIndexMap(sortedInfos)
+	packID2Index := s.packID2Index
 	if len(packID2Index) > v2MaxUniquePackIDCount {
 		return nil, errors.Errorf("unsupported - too many unique pack IDs %v (max %v)", len(packID2Index), v2MaxUniquePackIDCount)
 	}
@@ -524,8 +780,8 @@ func newIndexBuilderV2(sortedInfos []Info) (*indexBuilderV2, error) {
 		entrySize = max(entrySize, v2EntryOffsetExtendedPackBlobIDEnd)
 	}
 
-	// compute maximum content length to determine how many bits we need to use to store it.
-	maxPackedLen, maxOriginalLength, maxPackOffset := maxContentLengths(sortedInfos)
+	// use maximum content length to determine how many bits we need to use to store it.
+	maxPackedLen, maxOriginalLength, maxPackOffset := s.maxPackedLength, s.maxOriginalLength, s.maxPackOffset
 
 	// contents >= 28 bits (256 MiB) can't be stored at all.
 	if maxPackedLen >= v2MaxContentLength || maxOriginalLength >= v2MaxContentLength {
@@ -541,19 +797,12 @@ func newIndexBuilderV2(sortedInfos []Info) (*indexBuilderV2, error) {
 		return nil, errors.Errorf("pack offset %v is too high", maxPackOffset)
 	}
 
-	keyLength := -1
-
-	if len(sortedInfos) > 0 {
-		var hashBuf [maxContentIDSize]byte
-
-		keyLength = len(contentIDToBytes(hashBuf[:0], sortedInfos[0].GetContentID()))
-	}
-
 	return &indexBuilderV2{
+		version:                Version2,
 		packBlobIDOffsets:      map[blob.ID]uint32{},
-		keyLength:              keyLength,
+		keyLength:              s.keyLength,
 		entrySize:              entrySize,
-		entryCount:             len(sortedInfos),
+		entryCount:             s.entryCount,
 		uniqueFormatInfo2Index: uniqueFormat2Index,
 		packID2Index:           packID2Index,
 	}, nil
@@ -563,90 +812,147 @@ func newIndexBuilderV2(sortedInfos []Info) (*indexBuilderV2, error) {
 func (b Builder) buildV2(output io.Writer) error {
 	sortedInfos := b.sortedContents()
 
-	b2, err := newIndexBuilderV2(sortedInfos)
+	b2, err := newIndexBuilderV2(summarizeSortedInfos(sortedInfos))
 	if err != nil {
 		return err
 	}
 
-	w := bufio.NewWriter(output)
+	return b2.build(output, iterateSortedInfos(sortedInfos))
+}
+
+// build writes the pack index in v2 layout (shared by v3) with entries provided by the iterator,
+// which must produce exactly the entries the index builder was created for.
+func (b *indexBuilderV2) build(output io.Writer, sortedInfos SortedInfoIterator) error {
+	bw := bufio.NewWriter(output)
+	checksum := newIndexChecksum()
+
+	// everything except integrity information is included in the checksum.
+	w := io.MultiWriter(bw, checksum)
 
 	// prepare extra data to be appended at the end of an index.
-	extraData := b2.prepareExtraData(sortedInfos)
+	extraData := b.prepareExtraData()
 
-	if b2.keyLength <= 1 {
-		return errors.Errorf("invalid key length: %v for %v", b2.keyLength, len(b))
+	if b.keyLength <= 1 {
+		return errors.Errorf("invalid key length: %v for %v", b.keyLength, b.entryCount)
 	}
 
 	// write header
 	header := make([]byte, v2IndexHeaderSize)
-	header[0] = Version2 // version
-	header[1] = byte(b2.keyLength)
-	binary.BigEndian.PutUint16(header[2:4], uint16(b2.entrySize))
-	binary.BigEndian.PutUint32(header[4:8], uint32(b2.entryCount))
-	binary.BigEndian.PutUint32(header[8:12], uint32(len(b2.packID2Index)))
-	header[12] = byte(len(b2.uniqueFormatInfo2Index))
-	binary.BigEndian.PutUint32(header[13:17], uint32(b2.baseTimestamp))
+	header[0] = byte(b.version)
+	header[1] = byte(b.keyLength)
+	entrySizeAndFlags := uint16(b.entrySize)
+	if b.formatInfoSize != v2FormatInfoSize {
+		entrySizeAndFlags |= v2EntrySizeFlagDictionaries
+	}
+
+	binary.BigEndian.PutUint16(header[2:4], entrySizeAndFlags)
+	binary.BigEndian.PutUint32(header[4:8], uint32(b.entryCount))
+	binary.BigEndian.PutUint32(header[8:12], uint32(len(b.packID2Index)))
+	header[12] = byte(len(b.uniqueFormatInfo2Index))
+	binary.BigEndian.PutUint32(header[13:17], uint32(b.baseTimestamp))
 
 	if _, err := w.Write(header); err != nil {
 		return errors.Wrap(err, "unable to write header")
 	}
 
 	// write sorted index entries
-	for _, it := range sortedInfos {
-		if err := b2.writeIndexEntry(w, it); err != nil {
-			return errors.Wrap(err, "unable to write entry")
-		}
+	entryCount := 0
+
+	if err := sortedInfos(func(it Info) error {
+		entryCount++
+
+		return errors.Wrap(b.writeIndexEntry(w, it), "unable to write entry")
+	}); err != nil {
+		return err
+	}
+
+	if entryCount != b.entryCount {
+		return errors.Errorf("inconsistent number of entries: %v vs %v", entryCount, b.entryCount)
 	}
 
 	// write pack ID entries in the index order of values from packID2Index (0, 1, 2, ...).
-	reversePackIDIndex := make([]blob.ID, len(b2.packID2Index))
-	for k, v := range b2.packID2Index {
+	reversePackIDIndex := make([]blob.ID, len(b.packID2Index))
+	for k, v := range b.packID2Index {
 		reversePackIDIndex[v] = k
 	}
 
 	// emit pack ID information in this order.
 	for _, e := range reversePackIDIndex {
-		if err := b2.writePackIDEntry(w, e); err != nil {
+		if err := b.writePackIDEntry(w, e); err != nil {
 			return errors.Wrap(err, "error writing format info entry")
 		}
 	}
 
 	// build a list of indexV2FormatInfo using the order of indexes from uniqueFormatInfo2Index.
-	reverseFormatInfoIndex := make([]indexV2FormatInfo, len(b2.uniqueFormatInfo2Index))
-	for k, v := range b2.uniqueFormatInfo2Index {
+	reverseFormatInfoIndex := make([]indexV2FormatInfo, len(b.uniqueFormatInfo2Index))
+	for k, v := range b.uniqueFormatInfo2Index {
 		reverseFormatInfoIndex[v] = k
 	}
 
 	// emit format information in this order.
 	for _, f := range reverseFormatInfoIndex {
-		if err := b2.writeFormatInfoEntry(w, f); err != nil {
+		if err := b.writeFormatInfoEntry(w, f); err != nil {
 			return errors.Wrap(err, "error writing format info entry")
 		}
 	}
 
-	if _, err := w.Write(extraData); err != nil {
+	checksum.Write(extraData) // nolint:errcheck
+
+	if err := writeIntegrityInfo(bw, checksum, b.extraDataOffset+uint32(len(extraData))); err != nil {
+		return err
+	}
+
+	if _, err := bw.Write(extraData); err != nil {
 		return errors.Wrap(err, "error writing extra data")
 	}
 
-	return errors.Wrap(w.Flush(), "error flushing index")
+	return errors.Wrap(bw.Flush(), "error flushing index")
 }
 
-func (b *indexBuilderV2) prepareExtraData(sortedInfos []Info) []byte {
+func (b *indexBuilderV2) prepareExtraData() []byte {
 	var extraData []byte
 
-	for _, it := range sortedInfos {
-		if it.GetPackBlobID() != "" {
-			if _, ok := b.packBlobIDOffsets[it.GetPackBlobID()]; !ok {
-				b.packBlobIDOffsets[it.GetPackBlobID()] = uint32(len(extraData))
-				extraData = append(extraData, []byte(it.GetPackBlobID())...)
-			}
+	// pack IDs are stored in the order of their first appearance in sorted entries.
+	reversePackIDIndex := make([]blob.ID, len(b.packID2Index))
+	for k, v := range b.packID2Index {
+		reversePackIDIndex[v] = k
+	}
+
+	for _, packID := range reversePackIDIndex {
+		if packID != "" {
+			b.packBlobIDOffsets[packID] = uint32(len(extraData))
+			extraData = append(extraData, []byte(packID)...)
+		}
+	}
+
+	// dictionary IDs follow pack IDs, format entries are only extended if any format uses a dictionary.
+	b.formatInfoSize = v2FormatInfoSize
+	b.dictionaryIDOffsets = map[blob.ID]uint32{}
+
+	// formats are visited in the order of their indexes to produce deterministic output.
+	reverseFormatInfoIndex := make([]indexV2FormatInfo, len(b.uniqueFormatInfo2Index))
+	for k, v := range b.uniqueFormatInfo2Index {
+		reverseFormatInfoIndex[v] = k
+	}
+
+	for _, f := range reverseFormatInfoIndex {
+		if f.dictionaryID == "" {
+			continue
+		}
+
+		b.formatInfoSize = v2FormatInfoWithDictionarySize
+
+		if _, ok := b.dictionaryIDOffsets[f.dictionaryID]; !ok {
+			b.dictionaryIDOffsets[f.dictionaryID] = uint32(len(extraData))
+			extraData = append(extraData, []byte(f.dictionaryID)...)
 		}
 	}
 
 	b.extraDataOffset = v2IndexHeaderSize                                         // fixed header
 	b.extraDataOffset += uint32(b.entryCount * (b.keyLength + b.entrySize))       // entries index
 	b.extraDataOffset += uint32(len(b.packID2Index) * v2PackInfoSize)             // pack information
-	b.extraDataOffset += uint32(len(b.uniqueFormatInfo2Index) * v2FormatInfoSize) // formats
+	b.extraDataOffset += uint32(len(b.uniqueFormatInfo2Index) * b.formatInfoSize) // formats
+	b.extraDataOffset += v2IntegrityInfoSize                                      // integrity information
 
 	return extraData
 }
@@ -683,18 +989,27 @@ func (b *indexBuilderV2) writePackIDEntry(w io.Writer, packID blob.ID) error {
 }
 
 func (b *indexBuilderV2) writeFormatInfoEntry(w io.Writer, f indexV2FormatInfo) error {
-	var buf [v2FormatInfoSize]byte
+	var buf [v2FormatInfoWithDictionarySize]byte
 
 	binary.BigEndian.PutUint32(buf[v2FormatOffsetCompressionID:], uint32(f.compressionHeaderID))
 	buf[v2FormatOffsetFormatVersion] = f.formatVersion
 	buf[v2FormatOffsetEncryptionKeyID] = f.encryptionKeyID
 
-	_, err := w.Write(buf[:])
+	if f.dictionaryID != "" {
+		buf[v2FormatOffsetDictionaryIDLength] = byte(len(f.dictionaryID))
+		binary.BigEndian.PutUint32(buf[v2FormatOffsetDictionaryIDOffset:], b.dictionaryIDOffsets[f.dictionaryID]+b.extraDataOffset)
+	}
+
+	_, err := w.Write(buf[0:b.formatInfoSize])
 
 	return errors.Wrap(err, "error writing format info entry")
 }
 
 func (b *indexBuilderV2) writeIndexValueEntry(w io.Writer, it Info) error {
+	if b.version == Version3 {
+		return b.writeIndexValueEntryV3(w, it)
+	}
+
 	var buf [v2EntryMaxLength]byte
 
 	//    0-3: timestamp bits 0..31 (relative to base time)
@@ -707,7 +1022,7 @@ func (b *indexBuilderV2) writeIndexValueEntry(w io.Writer, it Info) error {
 	//         flags:
 	//            isDeleted                    (1 bit)
 
-	packOffsetAndFlags := it.GetPackOffset()
+	packOffsetAndFlags := uint32(it.GetPackOffset())
 	if it.GetDeleted() {
 		packOffsetAndFlags |= v2DeletedMarker
 	}
@@ -716,11 +1031,11 @@ func (b *indexBuilderV2) writeIndexValueEntry(w io.Writer, it Info) error {
 
 	//   8-10: original length bits 0..23
 
-	encodeBigEndianUint24(buf[v2EntryOffsetOriginalLength:], it.GetOriginalLength())
+	encodeBigEndianUint24(buf[v2EntryOffsetOriginalLength:], uint32(it.GetOriginalLength()))
 
 	//  11-13: packed length bits 0..23
 
-	encodeBigEndianUint24(buf[v2EntryOffsetPackedLength:], it.GetPackedLength())
+	encodeBigEndianUint24(buf[v2EntryOffsetPackedLength:], uint32(it.GetPackedLength()))
 
 	//  14-15: pack ID (lower 16 bits)- index into Packs[]
 
@@ -751,6 +1066,12 @@ func (b *indexBuilderV2) writeIndexValueEntry(w io.Writer, it Info) error {
 }
 
 func openV2PackIndex(readerAt io.ReaderAt) (Index, error) {
+	return openPackIndexWithV2Layout(readerAt, v2EntryMinLength, v2EntryMaxLength)
+}
+
+// openPackIndexWithV2Layout opens an index that uses v2 layout of header, packs and formats (shared by v3),
+// validating that entry size is within the provided bounds.
+func openPackIndexWithV2Layout(readerAt io.ReaderAt, entryMinLength, entryMaxLength int) (Index, error) {
 	var header [v2IndexHeaderSize]byte
 
 	if err := readAtAll(readerAt, header[:], 0); err != nil {
@@ -760,14 +1081,20 @@ func openV2PackIndex(readerAt io.ReaderAt) (Index, error) {
 	hi := v2HeaderInfo{
 		version:       int(header[0]),
 		keySize:       int(header[1]),
-		entrySize:     int(binary.BigEndian.Uint16(header[2:4])),
+		entrySize:     int(binary.BigEndian.Uint16(header[2:4]) &^ v2EntrySizeFlagDictionaries),
 		entryCount:    int(binary.BigEndian.Uint32(header[4:8])),
 		packCount:     uint(binary.BigEndian.Uint32(header[8:12])),
 		formatCount:   header[12],
 		baseTimestamp: binary.BigEndian.Uint32(header[13:17]),
+
+		formatInfoSize: v2FormatInfoSize,
+	}
+
+	if binary.BigEndian.Uint16(header[2:4])&v2EntrySizeFlagDictionaries != 0 {
+		hi.formatInfoSize = v2FormatInfoWithDictionarySize
 	}
 
-	if hi.keySize <= 1 || hi.entrySize < v2EntryMinLength || hi.entrySize > v2EntryMaxLength || hi.entryCount < 0 || hi.formatCount > v2MaxFormatCount {
+	if hi.keySize <= 1 || hi.entrySize < entryMinLength || hi.entrySize > entryMaxLength || hi.entryCount < 0 || hi.formatCount > v2MaxFormatCount {
 		return nil, errors.Errorf("invalid header")
 	}
 
@@ -781,28 +1108,58 @@ func openV2PackIndex(readerAt io.ReaderAt) (Index, error) {
 	hi.formatsOffset = hi.packsOffset + int64(hi.packCount*v2PackInfoSize)
 
 	// pre-read formats section
-	formatsBuf := make([]byte, int(hi.formatCount)*v2FormatInfoSize)
+	formatsBuf := make([]byte, int(hi.formatCount)*hi.formatInfoSize)
 	if err := readAtAll(readerAt, formatsBuf, hi.formatsOffset); err != nil {
 		return nil, errors.Errorf("unable to read formats section")
 	}
 
+	integrity, err := readIntegrityInfo(readerAt, hi.formatsOffset+int64(len(formatsBuf)))
+	if err != nil {
+		return nil, err
+	}
+
+	formats, err := parseFormatsBuffer(readerAt, formatsBuf, int(hi.formatCount), hi.formatInfoSize)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := readerAt.(BytesReaderAt)
+
 	return &indexV2{
-		hdr:      hi,
-		readerAt: readerAt,
-		formats:  parseFormatsBuffer(formatsBuf, int(hi.formatCount)),
+		hdr:       hi,
+		readerAt:  readerAt,
+		data:      data,
+		formats:   formats,
+		integrity: integrity,
 	}, nil
 }
 
-func parseFormatsBuffer(formatsBuf []byte, cnt int) []indexV2FormatInfo {
+// parseFormatsBuffer parses format entries of the provided size, reading compression dictionary IDs
+// referenced by extended entries.
+func parseFormatsBuffer(readerAt io.ReaderAt, formatsBuf []byte, cnt, formatInfoSize int) ([]indexV2FormatInfo, error) {
 	formats := make([]indexV2FormatInfo, cnt)
 
 	for i := 0; i < cnt; i++ {
-		f := formatsBuf[v2FormatInfoSize*i:]
+		f := formatsBuf[formatInfoSize*i:]
 
 		formats[i].compressionHeaderID = compression.HeaderID(binary.BigEndian.Uint32(f[v2FormatOffsetCompressionID:]))
 		formats[i].formatVersion = f[v2FormatOffsetFormatVersion]
 		formats[i].encryptionKeyID = f[v2FormatOffsetEncryptionKeyID]
+
+		if formatInfoSize < v2FormatInfoWithDictionarySize {
+			continue
+		}
+
+		if l := int(f[v2FormatOffsetDictionaryIDLength]); l > 0 {
+			dictionaryID := make([]byte, l)
+
+			if err := readAtAll(readerAt, dictionaryID, int64(binary.BigEndian.Uint32(f[v2FormatOffsetDictionaryIDOffset:]))); err != nil {
+				return nil, errors.Wrap(err, "unable to read compression dictionary ID")
+			}
+
+			formats[i].dictionaryID = blob.ID(dictionaryID)
+		}
 	}
 
-	return formats
+	return formats, nil
 }
@@ -0,0 +1,109 @@
+package index
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ErrIndexCorrupt is returned when the index is truncated or its contents don't match the checksum.
+var ErrIndexCorrupt = errors.New("index truncated or corrupt")
+
+// layout of v2 integrity information, written between the formats section and extra data
+// and ignored by readers that don't know about it, since pack names are referenced by absolute offsets.
+//    0-3: magic number - never present in older indexes where pack names are stored at this offset
+//    4-7: total length of the index, excluding any random suffix
+//   8-11: CRC32C of the index excluding integrity information
+const (
+	v2IntegrityInfoSize = 12
+
+	v2IntegrityOffsetMagic  = 0
+	v2IntegrityOffsetLength = 4
+	v2IntegrityOffsetCRC    = 8
+
+	v2IntegrityMagic = 0xFFC2C3C4
+)
+
+// nolint:gochecknoglobals
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+type v2IntegrityInfo struct {
+	offset int64 // offset of integrity information within the index
+	length uint32
+	crc    uint32
+}
+
+func newIndexChecksum() hash.Hash32 {
+	return crc32.New(crc32cTable)
+}
+
+// writeIntegrityInfo writes integrity information of an index whose contents excluding the integrity
+// information itself have been written to the provided checksum.
+func writeIntegrityInfo(w io.Writer, checksum hash.Hash32, totalLength uint32) error {
+	var buf [v2IntegrityInfoSize]byte
+
+	binary.BigEndian.PutUint32(buf[v2IntegrityOffsetMagic:], v2IntegrityMagic)
+	binary.BigEndian.PutUint32(buf[v2IntegrityOffsetLength:], totalLength)
+	binary.BigEndian.PutUint32(buf[v2IntegrityOffsetCRC:], checksum.Sum32())
+
+	_, err := w.Write(buf[:])
+
+	return errors.Wrap(err, "error writing integrity info")
+}
+
+// readIntegrityInfo reads integrity information at the provided offset and verifies that the index
+// is not truncated. Returns nil if the index does not have integrity information.
+func readIntegrityInfo(readerAt io.ReaderAt, offset int64) (*v2IntegrityInfo, error) {
+	var buf [v2IntegrityInfoSize]byte
+
+	if err := readAtAll(readerAt, buf[:], offset); err != nil {
+		// older index without pack names or random suffix.
+		return nil, nil
+	}
+
+	if binary.BigEndian.Uint32(buf[v2IntegrityOffsetMagic:]) != v2IntegrityMagic {
+		return nil, nil
+	}
+
+	ii := &v2IntegrityInfo{
+		offset: offset,
+		length: binary.BigEndian.Uint32(buf[v2IntegrityOffsetLength:]),
+		crc:    binary.BigEndian.Uint32(buf[v2IntegrityOffsetCRC:]),
+	}
+
+	if int64(ii.length) < offset+v2IntegrityInfoSize {
+		return nil, errors.Wrapf(ErrIndexCorrupt, "invalid index length %v", ii.length)
+	}
+
+	var lastByte [1]byte
+
+	if err := readAtAll(readerAt, lastByte[:], int64(ii.length)-1); err != nil {
+		return nil, errors.Wrapf(ErrIndexCorrupt, "index shorter than %v bytes", ii.length)
+	}
+
+	return ii, nil
+}
+
+// verifyChecksum verifies that the checksum of the index matches its integrity information.
+func (ii *v2IntegrityInfo) verifyChecksum(readerAt io.ReaderAt) error {
+	checksum := newIndexChecksum()
+
+	if _, err := io.Copy(checksum, io.NewSectionReader(readerAt, 0, ii.offset)); err != nil {
+		return errors.Wrap(err, "error reading index")
+	}
+
+	afterOffset := ii.offset + v2IntegrityInfoSize
+
+	if _, err := io.Copy(checksum, io.NewSectionReader(readerAt, afterOffset, int64(ii.length)-afterOffset)); err != nil {
+		return errors.Wrap(err, "error reading index")
+	}
+
+	if got := checksum.Sum32(); got != ii.crc {
+		return errors.Wrapf(ErrIndexCorrupt, "checksum mismatch %x, expected %x", got, ii.crc)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexIntegrity(t *testing.T) {
+	b := Builder{}
+
+	for i := 0; i < 100; i++ {
+		b.Add(&InfoStruct{
+			ContentID:    deterministicContentID("integrity", i),
+			PackBlobID:   deterministicPackBlobID(i % 7),
+			PackOffset:   deterministicPackedOffset(i),
+			PackedLength: 100,
+		})
+	}
+
+	for _, version := range []int{Version2, Version3} {
+		version := version
+
+		t.Run(fmt.Sprintf("v%v", version), func(t *testing.T) {
+			var buf bytes.Buffer
+
+			require.NoError(t, b.Build(&buf, version))
+
+			valid := buf.Bytes()
+
+			ndx, err := Open(bytes.NewReader(valid), fakeEncryptionOverhead)
+			require.NoError(t, err)
+			require.NotNil(t, ndx.(*indexV2).integrity) // nolint:forcetypeassert
+			require.NoError(t, VerifyStructure(ndx))
+
+			// truncation is detected when opening, even if the truncated part is just pack names.
+			truncated := valid[0 : len(valid)-randomSuffixSize-1]
+
+			_, err = Open(bytes.NewReader(truncated), fakeEncryptionOverhead)
+			require.ErrorIs(t, err, ErrIndexCorrupt)
+
+			// corruption of entries is detected when verifying.
+			corrupted := append([]byte(nil), valid...)
+			corrupted[v2IndexHeaderSize+3]++
+
+			ndx, err = Open(bytes.NewReader(corrupted), fakeEncryptionOverhead)
+			require.NoError(t, err)
+			require.ErrorIs(t, VerifyStructure(ndx), ErrIndexCorrupt)
+
+			// indexes written without integrity information can still be read.
+			legacy := withoutIntegrityInfo(t, valid)
+
+			ndx, err = Open(bytes.NewReader(legacy), fakeEncryptionOverhead)
+			require.NoError(t, err)
+			require.Nil(t, ndx.(*indexV2).integrity) // nolint:forcetypeassert
+			require.NoError(t, VerifyStructure(ndx))
+
+			require.NoError(t, b.SortedIterator()(func(want Info) error {
+				got, err := ndx.GetInfo(want.GetContentID())
+				require.NoError(t, err)
+				require.Equal(t, ToInfoStruct(want), ToInfoStruct(got))
+
+				return nil
+			}))
+		})
+	}
+}
+
+// withoutIntegrityInfo returns the index in the layout used before integrity information was introduced.
+func withoutIntegrityInfo(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	ndx, err := Open(bytes.NewReader(data), fakeEncryptionOverhead)
+	require.NoError(t, err)
+
+	b := ndx.(*indexV2) // nolint:forcetypeassert
+	require.NotNil(t, b.integrity)
+
+	off := b.integrity.offset
+	result := append(append([]byte(nil), data[0:off]...), data[off+v2IntegrityInfoSize:]...)
+
+	// pack names are now stored earlier.
+	for i := 0; i < int(b.hdr.packCount); i++ {
+		p := result[b.hdr.packsOffset+int64(i*v2PackInfoSize)+1:]
+		binary.BigEndian.PutUint32(p, binary.BigEndian.Uint32(p)-v2IntegrityInfoSize)
+	}
+
+	return result
+}
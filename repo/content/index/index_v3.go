@@ -0,0 +1,264 @@
+package index
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/compression"
+)
+
+// Version3 identifies version 3 of the index, which uses the same header, packs and formats as Version2
+// but stores 40-bit content lengths and pack offsets, lifting the 256 MiB limit on content length
+// and 1 GiB limit on pack offset.
+const Version3 = 3
+
+// layout of v3 index entry:
+//    0-3: timestamp bits 0..31 (relative to base time)
+//    4-8: pack offset bits 0..39
+//   9-13: original length bits 0..39
+//  14-18: packed length bits 0..39
+//  19-20: pack ID (lower 16 bits)- index into Packs[]
+//     21: flags:
+//            isDeleted                    (1 bit)
+//
+// optional bytes:
+//     22: format ID - index into Formats[] - 0 - present if not all formats are identical
+//     23: pack ID - bits 16..23 - present if more than 2^16 packs are in a single index
+const (
+	v3EntryOffsetTimestampSeconds      = 0
+	v3EntryOffsetPackOffset            = 4
+	v3EntryOffsetOriginalLength        = 9
+	v3EntryOffsetPackedLength          = 14
+	v3EntryOffsetPackBlobID            = 19
+	v3EntryOffsetFlags                 = 21
+	v3EntryMinLength                   = v3EntryOffsetFormatID
+	v3EntryOffsetFormatID              = 22 // optional, assumed zero if missing
+	v3EntryOffsetFormatIDEnd           = v3EntryOffsetExtendedPackBlobID
+	v3EntryOffsetExtendedPackBlobID    = 23 // optional
+	v3EntryOffsetExtendedPackBlobIDEnd = v3EntryMaxLength
+	v3EntryMaxLength                   = 24
+
+	// lengths and pack offsets are stored using 40 bits (1 TiB).
+	v3MaxContentLength = 1 << 40
+	v3MaxPackOffset    = 1 << 40
+
+	// flags (at offset v3EntryOffsetFlags).
+	v3EntryDeletedFlag = 0x01
+)
+
+type indexV3EntryInfo struct {
+	data      string // basically a byte array, but immutable
+	contentID ID
+	b         *indexV2
+}
+
+func (e indexV3EntryInfo) GetContentID() ID {
+	return e.contentID
+}
+
+func (e indexV3EntryInfo) GetTimestampSeconds() int64 {
+	return int64(decodeBigEndianUint32(e.data[v3EntryOffsetTimestampSeconds:])) + int64(e.b.hdr.baseTimestamp)
+}
+
+func (e indexV3EntryInfo) GetDeleted() bool {
+	return e.data[v3EntryOffsetFlags]&v3EntryDeletedFlag != 0
+}
+
+func (e indexV3EntryInfo) GetPackOffset() uint64 {
+	return decodeBigEndianUint40(e.data[v3EntryOffsetPackOffset:])
+}
+
+func (e indexV3EntryInfo) GetOriginalLength() uint64 {
+	return decodeBigEndianUint40(e.data[v3EntryOffsetOriginalLength:])
+}
+
+func (e indexV3EntryInfo) GetPackedLength() uint64 {
+	return decodeBigEndianUint40(e.data[v3EntryOffsetPackedLength:])
+}
+
+func (e indexV3EntryInfo) formatIDIndex() int {
+	if len(e.data) > v3EntryOffsetFormatID {
+		return int(e.data[v3EntryOffsetFormatID])
+	}
+
+	return 0
+}
+
+func (e indexV3EntryInfo) GetFormatVersion() byte {
+	fid := e.formatIDIndex()
+	if fid >= len(e.b.formats) {
+		return invalidFormatVersion
+	}
+
+	return e.b.formats[fid].formatVersion
+}
+
+func (e indexV3EntryInfo) GetCompressionHeaderID() compression.HeaderID {
+	fid := e.formatIDIndex()
+	if fid >= len(e.b.formats) {
+		return invalidCompressionHeaderID
+	}
+
+	return e.b.formats[fid].compressionHeaderID
+}
+
+func (e indexV3EntryInfo) GetEncryptionKeyID() byte {
+	fid := e.formatIDIndex()
+	if fid >= len(e.b.formats) {
+		return invalidEncryptionKeyID
+	}
+
+	return e.b.formats[fid].encryptionKeyID
+}
+
+func (e indexV3EntryInfo) GetCompressionDictionaryID() blob.ID {
+	fid := e.formatIDIndex()
+	if fid >= len(e.b.formats) {
+		return invalidBlobID
+	}
+
+	return e.b.formats[fid].dictionaryID
+}
+
+func (e indexV3EntryInfo) packIDIndex() uint32 {
+	packIDIndex := uint32(decodeBigEndianUint16(e.data[v3EntryOffsetPackBlobID:]))
+	if len(e.data) > v3EntryOffsetExtendedPackBlobID {
+		packIDIndex |= uint32(e.data[v3EntryOffsetExtendedPackBlobID]) << v2EntryExtendedPackBlobIDShift
+	}
+
+	return packIDIndex
+}
+
+func (e indexV3EntryInfo) GetPackBlobID() blob.ID {
+	return e.b.getPackBlobIDByIndex(e.packIDIndex())
+}
+
+func (e indexV3EntryInfo) Timestamp() time.Time {
+	return time.Unix(e.GetTimestampSeconds(), 0)
+}
+
+var _ Info = indexV3EntryInfo{}
+
+func (b *indexV2) entryToInfoV3(contentID ID, entryData []byte) (Info, error) {
+	if len(entryData) < v3EntryMinLength {
+		return nil, errors.Errorf("invalid entry length: %v", len(entryData))
+	}
+
+	// convert to 'entryData' string to make it read-only
+	return indexV3EntryInfo{string(entryData), contentID, b}, nil
+}
+
+func newIndexBuilderV3(s *sortedInfosSummary) (*indexBuilderV2, error) {
+	entrySize := v3EntryOffsetFormatID
+
+	uniqueFormat2Index := s.uniqueFormat2Index
+	if len(uniqueFormat2Index) > v2MaxFormatCount {
+		return nil, errors.Errorf("unsupported - too many unique formats %v (max %v)", len(uniqueFormat2Index), v2MaxFormatCount)
+	}
+
+	// if have more than one format present, we need to store per-entry format identifier, otherwise assume 0.
+	if len(uniqueFormat2Index) > 1 {
+		entrySize = max(entrySize, v3EntryOffsetFormatIDEnd)
+	}
+
+	packID2Index := s.packID2Index
+	if len(packID2Index) > v2MaxUniquePackIDCount {
+		return nil, errors.Errorf("unsupported - too many unique pack IDs %v (max %v)", len(packID2Index), v2MaxUniquePackIDCount)
+	}
+
+	if len(packID2Index) > v2MaxShortPackIDCount {
+		entrySize = max(entrySize, v3EntryOffsetExtendedPackBlobIDEnd)
+	}
+
+	if s.maxPackedLength >= v3MaxContentLength || s.maxOriginalLength >= v3MaxContentLength {
+		return nil, errors.Errorf("maximum content length is too high: (packed %v, original %v, max %v)", s.maxPackedLength, s.maxOriginalLength, uint64(v3MaxContentLength))
+	}
+
+	if s.maxPackOffset >= v3MaxPackOffset {
+		return nil, errors.Errorf("pack offset %v is too high", s.maxPackOffset)
+	}
+
+	if s.maxPackEnd > v3MaxPackOffset {
+		return nil, errors.Errorf("content ending at pack offset %v is too high", s.maxPackEnd)
+	}
+
+	return &indexBuilderV2{
+		version:                Version3,
+		packBlobIDOffsets:      map[blob.ID]uint32{},
+		keyLength:              s.keyLength,
+		entrySize:              entrySize,
+		entryCount:             s.entryCount,
+		uniqueFormatInfo2Index: uniqueFormat2Index,
+		packID2Index:           packID2Index,
+	}, nil
+}
+
+// buildV3 writes the pack index to the provided output.
+func (b Builder) buildV3(output io.Writer) error {
+	sortedInfos := b.sortedContents()
+
+	b3, err := newIndexBuilderV3(summarizeSortedInfos(sortedInfos))
+	if err != nil {
+		return err
+	}
+
+	return b3.build(output, iterateSortedInfos(sortedInfos))
+}
+
+func (b *indexBuilderV2) writeIndexValueEntryV3(w io.Writer, it Info) error {
+	var buf [v3EntryMaxLength]byte
+
+	//    0-3: timestamp bits 0..31 (relative to base time)
+
+	binary.BigEndian.PutUint32(buf[v3EntryOffsetTimestampSeconds:], uint32(it.GetTimestampSeconds()-b.baseTimestamp))
+
+	//    4-8: pack offset bits 0..39
+
+	encodeBigEndianUint40(buf[v3EntryOffsetPackOffset:], it.GetPackOffset())
+
+	//   9-13: original length bits 0..39
+
+	encodeBigEndianUint40(buf[v3EntryOffsetOriginalLength:], it.GetOriginalLength())
+
+	//  14-18: packed length bits 0..39
+
+	encodeBigEndianUint40(buf[v3EntryOffsetPackedLength:], it.GetPackedLength())
+
+	//  19-20: pack ID (lower 16 bits)- index into Packs[]
+
+	packBlobIndex := b.packID2Index[it.GetPackBlobID()]
+	binary.BigEndian.PutUint16(buf[v3EntryOffsetPackBlobID:], uint16(packBlobIndex))
+
+	//     21: flags
+
+	if it.GetDeleted() {
+		buf[v3EntryOffsetFlags] |= v3EntryDeletedFlag
+	}
+
+	//     22: format ID - index into Formats[] - 0 - present if not all formats are identical
+
+	buf[v3EntryOffsetFormatID] = b.uniqueFormatInfo2Index[indexV2FormatInfoFromInfo(it)]
+
+	//     23: pack ID - bits 16..23 - present if more than 2^16 packs are in a single index
+
+	buf[v3EntryOffsetExtendedPackBlobID] = byte(packBlobIndex >> v2EntryExtendedPackBlobIDShift)
+
+	for i := b.entrySize; i < v3EntryMaxLength; i++ {
+		if buf[i] != 0 {
+			panic(fmt.Sprintf("encoding bug %x (entrySize=%v)", buf, b.entrySize))
+		}
+	}
+
+	_, err := w.Write(buf[0:b.entrySize])
+
+	return errors.Wrap(err, "error writing index value entry")
+}
+
+func openV3PackIndex(readerAt io.ReaderAt) (Index, error) {
+	return openPackIndexWithV2Layout(readerAt, v3EntryMinLength, v3EntryMaxLength)
+}
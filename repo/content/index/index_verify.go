@@ -0,0 +1,95 @@
+package index
+
+import (
+	"bytes"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// structureVerifier is implemented by indexes that support deep verification of their structure.
+type structureVerifier interface {
+	VerifyStructure() error
+}
+
+// entryIndexes is implemented by index entries that refer to packs and formats by their position in the index.
+type entryIndexes interface {
+	packIDIndex() uint32
+	formatIDIndex() int
+}
+
+// VerifyStructure performs deep verification of the structure of the provided index, reading all its entries.
+// Indexes that don't support it are assumed to be valid.
+func VerifyStructure(ndx Index) error {
+	if v, ok := ndx.(structureVerifier); ok {
+		return v.VerifyStructure()
+	}
+
+	return nil
+}
+
+// VerifyStructure verifies the checksum of the index, if present, walks all entries of the index and verifies
+// that they are sorted by content ID and that they only refer to packs and formats that are present in the index.
+func (b *indexV2) VerifyStructure() error {
+	if b.integrity != nil {
+		if err := b.integrity.verifyChecksum(b.readerAt); err != nil {
+			return err
+		}
+	}
+
+	var (
+		entryBuf [v2MaxEntrySize]byte
+		prevBuf  [v2MaxEntrySize]byte
+	)
+
+	entry := entryBuf[0:b.hdr.entryStride]
+	prevKey := prevBuf[0:0]
+
+	maxPackEnd := uint64(math.MaxUint32)
+	if b.hdr.version == Version3 {
+		maxPackEnd = v3MaxPackOffset
+	}
+
+	for i := 0; i < b.hdr.entryCount; i++ {
+		if err := readAtAll(b.readerAt, entry, b.entryOffset(i)); err != nil {
+			return errors.Wrapf(err, "unable to read entry %v", i)
+		}
+
+		key := entry[0:b.hdr.keySize]
+		contentID := bytesToContentID(key)
+
+		if i > 0 && (bytes.Compare(prevKey, key) >= 0 || bytesToContentID(prevKey) >= contentID) {
+			return errors.Errorf("entry %v is out of order: %v after %v", i, contentID, bytesToContentID(prevKey))
+		}
+
+		prevKey = append(prevKey[:0], key...)
+
+		info, err := b.entryToInfo(contentID, entry[b.hdr.keySize:])
+		if err != nil {
+			return errors.Wrapf(err, "invalid entry %v", i)
+		}
+
+		ei, ok := info.(entryIndexes)
+		if !ok {
+			return errors.Errorf("unexpected entry type %T", info)
+		}
+
+		if ndx := ei.packIDIndex(); ndx >= uint32(b.hdr.packCount) {
+			return errors.Errorf("entry %v of %v refers to pack %v, but index only has %v packs", i, contentID, ndx, b.hdr.packCount)
+		}
+
+		if ndx := ei.formatIDIndex(); ndx >= int(b.hdr.formatCount) {
+			return errors.Errorf("entry %v of %v refers to format %v, but index only has %v formats", i, contentID, ndx, b.hdr.formatCount)
+		}
+
+		if b.hdr.version == Version2 && info.GetPackOffset() >= v2MaxPackOffset {
+			return errors.Errorf("entry %v of %v has invalid pack offset %v", i, contentID, info.GetPackOffset())
+		}
+
+		if end := info.GetPackOffset() + info.GetPackedLength(); end > maxPackEnd {
+			return errors.Errorf("entry %v of %v ends at pack offset %v, which is out of bounds", i, contentID, end)
+		}
+	}
+
+	return nil
+}
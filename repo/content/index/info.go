@@ -30,13 +30,14 @@ type Info interface {
 	GetPackBlobID() blob.ID
 	GetTimestampSeconds() int64
 	Timestamp() time.Time
-	GetOriginalLength() uint32
-	GetPackedLength() uint32
-	GetPackOffset() uint32
+	GetOriginalLength() uint64
+	GetPackedLength() uint64
+	GetPackOffset() uint64
 	GetDeleted() bool
 	GetFormatVersion() byte
 	GetCompressionHeaderID() compression.HeaderID
 	GetEncryptionKeyID() byte
+	GetCompressionDictionaryID() blob.ID
 }
 
 // InfoStruct is an implementation of Info based on a structure.
@@ -44,13 +45,15 @@ type InfoStruct struct {
 	ContentID           ID                   `json:"contentID"`
 	PackBlobID          blob.ID              `json:"packFile,omitempty"`
 	TimestampSeconds    int64                `json:"time"`
-	OriginalLength      uint32               `json:"originalLength"`
-	PackedLength        uint32               `json:"length"`
-	PackOffset          uint32               `json:"packOffset,omitempty"`
+	OriginalLength      uint64               `json:"originalLength"`
+	PackedLength        uint64               `json:"length"`
+	PackOffset          uint64               `json:"packOffset,omitempty"`
 	Deleted             bool                 `json:"deleted"`
 	FormatVersion       byte                 `json:"formatVersion"`
 	CompressionHeaderID compression.HeaderID `json:"compression,omitempty"`
 	EncryptionKeyID     byte                 `json:"encryptionKeyID,omitempty"`
+
+	CompressionDictionaryID blob.ID `json:"compressionDictionary,omitempty"`
 }
 
 // GetContentID implements the Info interface.
@@ -63,13 +66,13 @@ func (i *InfoStruct) GetPackBlobID() blob.ID { return i.PackBlobID }
 func (i *InfoStruct) GetTimestampSeconds() int64 { return i.TimestampSeconds }
 
 // GetOriginalLength implements the Info interface.
-func (i *InfoStruct) GetOriginalLength() uint32 { return i.OriginalLength }
+func (i *InfoStruct) GetOriginalLength() uint64 { return i.OriginalLength }
 
 // GetPackedLength implements the Info interface.
-func (i *InfoStruct) GetPackedLength() uint32 { return i.PackedLength }
+func (i *InfoStruct) GetPackedLength() uint64 { return i.PackedLength }
 
 // GetPackOffset implements the Info interface.
-func (i *InfoStruct) GetPackOffset() uint32 { return i.PackOffset }
+func (i *InfoStruct) GetPackOffset() uint64 { return i.PackOffset }
 
 // GetDeleted implements the Info interface.
 func (i *InfoStruct) GetDeleted() bool { return i.Deleted }
@@ -83,6 +86,9 @@ func (i *InfoStruct) GetCompressionHeaderID() compression.HeaderID { return i.Co
 // GetEncryptionKeyID implements the Info interface.
 func (i *InfoStruct) GetEncryptionKeyID() byte { return i.EncryptionKeyID }
 
+// GetCompressionDictionaryID implements the Info interface.
+func (i *InfoStruct) GetCompressionDictionaryID() blob.ID { return i.CompressionDictionaryID }
+
 // Timestamp implements the Info interface.
 func (i *InfoStruct) Timestamp() time.Time {
 	return time.Unix(i.GetTimestampSeconds(), 0)
@@ -105,5 +111,7 @@ func ToInfoStruct(i Info) *InfoStruct {
 		FormatVersion:       i.GetFormatVersion(),
 		CompressionHeaderID: i.GetCompressionHeaderID(),
 		EncryptionKeyID:     i.GetEncryptionKeyID(),
+
+		CompressionDictionaryID: i.GetCompressionDictionaryID(),
 	}
 }
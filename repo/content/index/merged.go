@@ -2,11 +2,15 @@
 
 import (
 	"container/heap"
+	"runtime"
+	"sync"
 
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
 )
 
+var errEndOfIteration = errors.New("end of iteration")
+
 // Merged is an implementation of Index that transparently merges returns from underlying Indexes.
 type Merged []Index
 
@@ -32,6 +36,23 @@ func (m Merged) Close() error {
 	return errors.Wrap(err, "closing index shards")
 }
 
+// preferredInfo returns the entry that takes precedence among two entries with the same content ID,
+// where candidate comes from a later index than current. This is the single rule used to resolve
+// contents present in multiple indexes by GetInfo(), GetInfos() and iteration:
+//
+// 1. newer timestamp wins,
+// 2. given identical timestamps, non-deleted entry wins,
+// 3. given identical timestamps and deleted flags, highest pack blob ID wins,
+// 4. otherwise the entry from the earliest index wins.
+func preferredInfo(current, candidate Info) Info {
+	if contentInfoGreaterThan(candidate, current) {
+		return candidate
+	}
+
+	return current
+}
+
+// contentInfoGreaterThan returns true if a takes precedence over b.
 func contentInfoGreaterThan(a, b Info) bool {
 	if b == nil {
 		// everyrhing is greater than nil
@@ -58,8 +79,24 @@ func contentInfoGreaterThan(a, b Info) bool {
 	return a.GetPackBlobID() > b.GetPackBlobID()
 }
 
+// parallelGetInfoMinSegments is the minimum number of underlying indexes for which GetInfo
+// queries them concurrently. Looking up a content in a single index takes about 0.5us, so for
+// fewer indexes the cost of starting goroutines outweighs the gains (see BenchmarkMergedGetInfo).
+const parallelGetInfoMinSegments = 32
+
+// parallelGetInfoWorkers is the maximum number of goroutines used by GetInfo to query underlying indexes.
+const parallelGetInfoWorkers = 8
+
 // GetInfo returns information about a single content. If a content is not found, returns (nil,nil).
 func (m Merged) GetInfo(id ID) (Info, error) {
+	if len(m) >= parallelGetInfoMinSegments && runtime.GOMAXPROCS(0) > 1 {
+		return m.getInfoParallel(id)
+	}
+
+	return m.getInfoSerial(id)
+}
+
+func (m Merged) getInfoSerial(id ID) (Info, error) {
 	var best Info
 
 	for _, ndx := range m {
@@ -68,19 +105,85 @@ func (m Merged) GetInfo(id ID) (Info, error) {
 			return nil, errors.Wrapf(err, "error getting id %v from index shard", id)
 		}
 
-		if contentInfoGreaterThan(i, best) {
-			best = i
+		best = preferredInfo(best, i)
+	}
+
+	return best, nil
+}
+
+// getInfoParallel queries underlying indexes concurrently and resolves the results in the order
+// of indexes, so that the outcome is identical to getInfoSerial().
+func (m Merged) getInfoParallel(id ID) (Info, error) {
+	infos := make([]Info, len(m))
+	errs := make([]error, len(m))
+
+	workers := parallelGetInfoWorkers
+	if p := runtime.GOMAXPROCS(0); workers > p {
+		workers = p
+	}
+
+	if workers > len(m) {
+		workers = len(m)
+	}
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for n := w; n < len(m); n += workers {
+				infos[n], errs[n] = m[n].GetInfo(id)
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	var best Info
+
+	for n, i := range infos {
+		if err := errs[n]; err != nil {
+			return nil, errors.Wrapf(err, "error getting id %v from index shard", id)
 		}
+
+		best = preferredInfo(best, i)
 	}
 
 	return best, nil
 }
 
+// GetInfos returns information about the provided contents that are present in any of the underlying
+// indexes, resolving contents present in multiple indexes the same way as GetInfo().
+func (m Merged) GetInfos(ids []ID) (map[ID]Info, error) {
+	result := map[ID]Info{}
+
+	for _, ndx := range m {
+		infos, err := ndx.GetInfos(ids)
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting contents from index shard")
+		}
+
+		for id, i := range infos {
+			result[id] = preferredInfo(result[id], i)
+		}
+	}
+
+	return result, nil
+}
+
 type nextInfo struct {
 	it Info
 	ch <-chan Info
+
+	// position of the channel among merged channels.
+	pos int
 }
 
+// nextInfoHeap pops the lowest content ID first and entries with the same content ID
+// in the order of their channels, so that they can be resolved in the same order as GetInfo().
 type nextInfoHeap []*nextInfo
 
 func (h nextInfoHeap) Len() int { return len(h) }
@@ -89,7 +192,20 @@ func (h nextInfoHeap) Less(i, j int) bool {
 		return a < b
 	}
 
-	return !contentInfoGreaterThan(h[i].it, h[j].it)
+	return h[i].pos < h[j].pos
+}
+
+// nextInfoMaxHeap is a nextInfoHeap that pops the highest content ID first.
+type nextInfoMaxHeap struct {
+	nextInfoHeap
+}
+
+func (h nextInfoMaxHeap) Less(i, j int) bool {
+	if a, b := h.nextInfoHeap[i].it.GetContentID(), h.nextInfoHeap[j].it.GetContentID(); a != b {
+		return a > b
+	}
+
+	return h.nextInfoHeap[i].pos < h.nextInfoHeap[j].pos
 }
 
 func (h nextInfoHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
@@ -106,16 +222,21 @@ func (h *nextInfoHeap) Pop() interface{} {
 	return x
 }
 
-func iterateChan(r IDRange, ndx Index, done chan bool) <-chan Info {
+func iterateChan(r IDRange, ndx Index, reverse bool, done chan bool) <-chan Info {
 	ch := make(chan Info, 1)
 
+	iterate := ndx.Iterate
+	if reverse {
+		iterate = ndx.IterateReverse
+	}
+
 	go func() {
 		defer close(ch)
 
-		_ = ndx.Iterate(r, func(i Info) error {
+		_ = iterate(r, func(i Info) error {
 			select {
 			case <-done:
-				return errors.New("end of iteration")
+				return errEndOfIteration
 			case ch <- i:
 				return nil
 			}
@@ -128,26 +249,45 @@ func iterateChan(r IDRange, ndx Index, done chan bool) <-chan Info {
 // Iterate invokes the provided callback for all unique content IDs in the underlying sources until either
 // all contents have been visited or until an error is returned by the callback.
 func (m Merged) Iterate(r IDRange, cb func(i Info) error) error {
-	var minHeap nextInfoHeap
+	return m.iterate(r, &nextInfoHeap{}, false, cb)
+}
 
+// IterateReverse invokes the provided callback for all unique content IDs in the underlying sources in
+// descending order until either all contents have been visited or until an error is returned by the callback.
+func (m Merged) IterateReverse(r IDRange, cb func(i Info) error) error {
+	return m.iterate(r, &nextInfoMaxHeap{}, true, cb)
+}
+
+func (m Merged) iterate(r IDRange, h heap.Interface, reverse bool, cb func(i Info) error) error {
 	done := make(chan bool)
 
 	defer close(done)
 
+	var chans []<-chan Info
+
 	for _, ndx := range m {
-		ch := iterateChan(r, ndx, done)
+		chans = append(chans, iterateChan(r, ndx, reverse, done))
+	}
 
+	return mergeSortedChannels(h, chans, cb)
+}
+
+// mergeSortedChannels merges entries from the provided sorted channels in the order defined by the heap
+// and invokes the callback once for each unique content ID with the entry that takes precedence according
+// to preferredInfo(), resolving entries in the order of channels.
+func mergeSortedChannels(h heap.Interface, chans []<-chan Info, cb func(i Info) error) error {
+	for pos, ch := range chans {
 		it, ok := <-ch
 		if ok {
-			heap.Push(&minHeap, &nextInfo{it, ch})
+			heap.Push(h, &nextInfo{it, ch, pos})
 		}
 	}
 
 	var pendingItem Info
 
-	for len(minHeap) > 0 {
+	for h.Len() > 0 {
 		// nolint:forcetypeassert
-		min := heap.Pop(&minHeap).(*nextInfo)
+		min := heap.Pop(h).(*nextInfo)
 		if pendingItem == nil || pendingItem.GetContentID() != min.it.GetContentID() {
 			if pendingItem != nil {
 				if err := cb(pendingItem); err != nil {
@@ -156,13 +296,13 @@ func (m Merged) Iterate(r IDRange, cb func(i Info) error) error {
 			}
 
 			pendingItem = min.it
-		} else if contentInfoGreaterThan(min.it, pendingItem) {
-			pendingItem = min.it
+		} else {
+			pendingItem = preferredInfo(pendingItem, min.it)
 		}
 
 		it, ok := <-min.ch
 		if ok {
-			heap.Push(&minHeap, &nextInfo{it, min.ch})
+			heap.Push(h, &nextInfo{it, min.ch, min.pos})
 		}
 	}
 
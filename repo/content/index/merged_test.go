@@ -3,6 +3,7 @@
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
 	"reflect"
 	"testing"
 
@@ -44,7 +45,7 @@ func TestMerged(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, i)
 
-	require.Equal(t, uint32(33), i.GetPackOffset())
+	require.Equal(t, uint64(33), i.GetPackOffset())
 
 	require.NoError(t, m.Iterate(AllIDs, func(i Info) error {
 		if i.GetContentID() == "de1e1e" {
@@ -147,6 +148,100 @@ func TestMerged(t *testing.T) {
 	}
 }
 
+func TestMergedGetInfos(t *testing.T) {
+	i1, err := indexWithItems(
+		&InfoStruct{ContentID: "aabbcc", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 11},
+		&InfoStruct{ContentID: "ddeeff", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 111},
+		&InfoStruct{ContentID: "de1e1e", TimestampSeconds: 4, PackBlobID: "xx", PackOffset: 111},
+	)
+	require.NoError(t, err)
+
+	i2, err := indexWithItems(
+		&InfoStruct{ContentID: "aabbcc", TimestampSeconds: 3, PackBlobID: "yy", PackOffset: 33},
+		&InfoStruct{ContentID: "de1e1e", TimestampSeconds: 4, PackBlobID: "xx", PackOffset: 222, Deleted: true},
+		&InfoStruct{ContentID: "k010203", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 111},
+	)
+	require.NoError(t, err)
+
+	i3, err := indexWithItemsVersion(Version3,
+		&InfoStruct{ContentID: "aabbcc", TimestampSeconds: 2, PackBlobID: "zz", PackOffset: 22},
+		&InfoStruct{ContentID: "ddeeff", TimestampSeconds: 1, PackBlobID: "zz", PackOffset: 222},
+	)
+	require.NoError(t, err)
+
+	m := Merged{i1, i2, i3}
+
+	infos, err := m.GetInfos([]ID{"k010203", "aabbcc", "000000", "de1e1e", "ddeeff", "aabbcc"})
+	require.NoError(t, err)
+	require.Len(t, infos, 4)
+
+	for id, i := range infos {
+		want, err := m.GetInfo(id)
+		require.NoError(t, err)
+		require.Equal(t, ToInfoStruct(want), ToInfoStruct(i))
+	}
+
+	require.Equal(t, uint64(33), infos["aabbcc"].GetPackOffset())
+	require.False(t, infos["de1e1e"].GetDeleted())
+
+	infos, err = Merged{}.GetInfos([]ID{"aabbcc"})
+	require.NoError(t, err)
+	require.Empty(t, infos)
+}
+
+func TestMergedIterateReverse(t *testing.T) {
+	i1, err := indexWithItems(
+		&InfoStruct{ContentID: "aabbcc", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 11},
+		&InfoStruct{ContentID: "ddeeff", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 111},
+		&InfoStruct{ContentID: "z010203", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 111},
+		&InfoStruct{ContentID: "de1e1e", TimestampSeconds: 4, PackBlobID: "xx", PackOffset: 111},
+	)
+	require.NoError(t, err)
+
+	i2, err := indexWithItems(
+		&InfoStruct{ContentID: "aabbcc", TimestampSeconds: 3, PackBlobID: "yy", PackOffset: 33},
+		&InfoStruct{ContentID: "xaabbcc", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 111},
+		&InfoStruct{ContentID: "de1e1e", TimestampSeconds: 4, PackBlobID: "xx", PackOffset: 222, Deleted: true},
+	)
+	require.NoError(t, err)
+
+	i3, err := indexWithItemsVersion(Version1,
+		&InfoStruct{ContentID: "aabbcc", TimestampSeconds: 2, PackBlobID: "zz", PackOffset: 22},
+		&InfoStruct{ContentID: "ddeeff", TimestampSeconds: 1, PackBlobID: "zz", PackOffset: 222},
+		&InfoStruct{ContentID: "k010203", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 111},
+		&InfoStruct{ContentID: "k020304", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 111},
+	)
+	require.NoError(t, err)
+
+	m := Merged{i1, i2, i3}
+
+	for _, r := range []IDRange{AllIDs, AllNonPrefixedIDs, AllPrefixedIDs, {"a", "e"}, PrefixRange("dd"), {"dd", "df"}, {"e", "f"}} {
+		var forward, reverse []*InfoStruct
+
+		require.NoError(t, m.Iterate(r, func(i Info) error {
+			forward = append(forward, ToInfoStruct(i))
+			return nil
+		}))
+
+		require.NoError(t, m.IterateReverse(r, func(i Info) error {
+			reverse = append([]*InfoStruct{ToInfoStruct(i)}, reverse...)
+			return nil
+		}))
+
+		require.Equal(t, forward, reverse, "range %v", r)
+	}
+
+	// error is propagated.
+	someErr := errors.Errorf("some error")
+	require.ErrorIs(t, m.IterateReverse(AllIDs, func(i Info) error {
+		if i.GetContentID() == "aabbcc" {
+			return someErr
+		}
+
+		return nil
+	}), someErr)
+}
+
 type failingIndex struct {
 	Index
 	err error
@@ -224,6 +319,186 @@ func TestMergedIndexIsConsistent(t *testing.T) {
 	}
 }
 
+func TestMergedIterateMatchesGetInfo(t *testing.T) {
+	i1, err := indexWithItems(
+		// timestamp: newest wins.
+		&InfoStruct{ContentID: "aa0001", TimestampSeconds: 3, PackBlobID: "aa", PackOffset: 1, Deleted: true},
+		// deleted flag: non-deleted wins.
+		&InfoStruct{ContentID: "aa0002", TimestampSeconds: 1, PackBlobID: "zz", PackOffset: 1, Deleted: true},
+		// pack blob ID: highest wins.
+		&InfoStruct{ContentID: "aa0003", TimestampSeconds: 1, PackBlobID: "bb", PackOffset: 1},
+		// complete tie: earliest index wins.
+		&InfoStruct{ContentID: "aa0004", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 1},
+		&InfoStruct{ContentID: "aa0005", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 1},
+	)
+	require.NoError(t, err)
+
+	i2, err := indexWithItems(
+		&InfoStruct{ContentID: "aa0001", TimestampSeconds: 1, PackBlobID: "zz", PackOffset: 2},
+		&InfoStruct{ContentID: "aa0002", TimestampSeconds: 1, PackBlobID: "aa", PackOffset: 2},
+		&InfoStruct{ContentID: "aa0003", TimestampSeconds: 1, PackBlobID: "cc", PackOffset: 2},
+		&InfoStruct{ContentID: "aa0004", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 2},
+	)
+	require.NoError(t, err)
+
+	i3, err := indexWithItems(
+		&InfoStruct{ContentID: "aa0001", TimestampSeconds: 2, PackBlobID: "zz", PackOffset: 3},
+		&InfoStruct{ContentID: "aa0002", TimestampSeconds: 1, PackBlobID: "yy", PackOffset: 3, Deleted: true},
+		&InfoStruct{ContentID: "aa0003", TimestampSeconds: 1, PackBlobID: "aa", PackOffset: 3},
+		&InfoStruct{ContentID: "aa0004", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 3},
+	)
+	require.NoError(t, err)
+
+	cases := []Merged{
+		{i1, i2, i3},
+		{i1, i3, i2},
+		{i2, i1, i3},
+		{i2, i3, i1},
+		{i3, i1, i2},
+		{i3, i2, i1},
+	}
+
+	for _, m := range cases {
+		for _, iterate := range []func(r IDRange, cb func(i Info) error) error{m.Iterate, m.IterateReverse} {
+			seen := map[ID]bool{}
+
+			require.NoError(t, iterate(AllIDs, func(it Info) error {
+				require.False(t, seen[it.GetContentID()], "duplicate %v", it.GetContentID())
+				seen[it.GetContentID()] = true
+
+				want, err := m.GetInfo(it.GetContentID())
+				require.NoError(t, err)
+				require.Equal(t, want, it)
+
+				return nil
+			}))
+
+			require.Len(t, seen, 5)
+		}
+
+		mustGetPackOffset := func(id ID) uint64 {
+			i, err := m.GetInfo(id)
+			require.NoError(t, err)
+
+			return i.GetPackOffset()
+		}
+
+		require.Equal(t, uint64(1), mustGetPackOffset("aa0001"))
+		require.Equal(t, uint64(2), mustGetPackOffset("aa0002"))
+		require.Equal(t, uint64(2), mustGetPackOffset("aa0003"))
+		require.Equal(t, uint64(1), mustGetPackOffset("aa0005"))
+
+		// complete tie is resolved to the entry from the earliest index.
+		first, err := m[0].GetInfo("aa0004")
+		require.NoError(t, err)
+		require.Equal(t, first.GetPackOffset(), mustGetPackOffset("aa0004"))
+	}
+}
+
+func TestMergedParallelGetInfoIsConsistent(t *testing.T) {
+	const (
+		numIndexes = 2 * parallelGetInfoMinSegments
+		numIDs     = 20
+	)
+
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec
+
+	var (
+		m   Merged
+		ids []ID
+	)
+
+	for i := 0; i < numIDs; i++ {
+		ids = append(ids, ID(fmt.Sprintf("%06x", i)))
+	}
+
+	for n := 0; n < numIndexes; n++ {
+		var items []Info
+
+		for _, id := range ids {
+			if rnd.Intn(2) == 0 {
+				continue
+			}
+
+			// small ranges of values to produce plenty of ties.
+			items = append(items, &InfoStruct{
+				ContentID:        id,
+				TimestampSeconds: int64(rnd.Intn(3)),
+				Deleted:          rnd.Intn(2) == 0,
+				PackBlobID:       blob.ID(fmt.Sprintf("p%v", rnd.Intn(4))),
+				PackOffset:       uint64(n),
+			})
+		}
+
+		ndx, err := indexWithItems(items...)
+		require.NoError(t, err)
+
+		m = append(m, ndx)
+	}
+
+	for attempt := 0; attempt < 5; attempt++ {
+		rnd.Shuffle(len(m), func(i, j int) { m[i], m[j] = m[j], m[i] })
+
+		for _, id := range append(ids, "ffffff") {
+			want, err := m.getInfoSerial(id)
+			require.NoError(t, err)
+
+			got, err := m.getInfoParallel(id)
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+
+			got, err = m.GetInfo(id)
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+		}
+	}
+
+	someError := errors.Errorf("some error")
+
+	info, err := append(Merged{failingIndex{nil, someError}}, m...).getInfoParallel("000001")
+	require.ErrorIs(t, err, someError)
+	require.Nil(t, info)
+}
+
+func TestMergedV2AndV3(t *testing.T) {
+	i1, err := indexWithItemsVersion(Version2,
+		&InfoStruct{ContentID: "aabbcc", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 11, PackedLength: 100},
+		&InfoStruct{ContentID: "ddeeff", TimestampSeconds: 1, PackBlobID: "xx", PackOffset: 111, PackedLength: 100},
+		&InfoStruct{ContentID: "de1e1e", TimestampSeconds: 4, PackBlobID: "xx", PackOffset: 111, PackedLength: 100},
+	)
+	require.NoError(t, err)
+
+	i2, err := indexWithItemsVersion(Version3,
+		&InfoStruct{ContentID: "aabbcc", TimestampSeconds: 3, PackBlobID: "yy", PackOffset: 33, PackedLength: 5 << 30, OriginalLength: 6 << 30},
+		&InfoStruct{ContentID: "xaabbcc", TimestampSeconds: 1, PackBlobID: "yy", PackOffset: 7 << 30, PackedLength: 1 << 30},
+		&InfoStruct{ContentID: "de1e1e", TimestampSeconds: 4, PackBlobID: "yy", PackOffset: 222, Deleted: true},
+	)
+	require.NoError(t, err)
+
+	m := Merged{i1, i2}
+
+	require.Equal(t, 6, m.ApproximateCount())
+
+	i, err := m.GetInfo("aabbcc")
+	require.NoError(t, err)
+	require.Equal(t, blob.ID("yy"), i.GetPackBlobID())
+	require.Equal(t, uint64(33), i.GetPackOffset())
+	require.Equal(t, uint64(5<<30), i.GetPackedLength())
+	require.Equal(t, uint64(6<<30), i.GetOriginalLength())
+
+	i, err = m.GetInfo("xaabbcc")
+	require.NoError(t, err)
+	require.Equal(t, uint64(7<<30), i.GetPackOffset())
+
+	// given identical timestamps, non-deleted wins.
+	i, err = m.GetInfo("de1e1e")
+	require.NoError(t, err)
+	require.False(t, i.GetDeleted())
+	require.Equal(t, blob.ID("xx"), i.GetPackBlobID())
+
+	require.Equal(t, []ID{"aabbcc", "ddeeff", "de1e1e", "xaabbcc"}, iterateIDRange(t, m, AllIDs))
+}
+
 func iterateIDRange(t *testing.T, m Index, r IDRange) []ID {
 	t.Helper()
 
@@ -238,6 +513,10 @@ func iterateIDRange(t *testing.T, m Index, r IDRange) []ID {
 }
 
 func indexWithItems(items ...Info) (Index, error) {
+	return indexWithItemsVersion(Version2, items...)
+}
+
+func indexWithItemsVersion(version int, items ...Info) (Index, error) {
 	b := make(Builder)
 
 	for _, it := range items {
@@ -245,9 +524,59 @@ func indexWithItems(items ...Info) (Index, error) {
 	}
 
 	var buf bytes.Buffer
-	if err := b.Build(&buf, Version2); err != nil {
+	if err := b.Build(&buf, version); err != nil {
 		return nil, errors.Wrap(err, "build error")
 	}
 
 	return Open(bytes.NewReader(buf.Bytes()), fakeEncryptionOverhead)
 }
+
+func BenchmarkMergedGetInfo(b *testing.B) {
+	const contentsPerSegment = 10000
+
+	var all Merged
+
+	for n := 0; n < 256; n++ {
+		bld := Builder{}
+
+		for i := 0; i < contentsPerSegment; i++ {
+			bld.Add(&InfoStruct{
+				ContentID:    deterministicContentID(fmt.Sprintf("segment%v", n), i),
+				PackBlobID:   deterministicPackBlobID(i % 100),
+				PackOffset:   deterministicPackedOffset(i),
+				PackedLength: deterministicPackedLength(i),
+			})
+		}
+
+		var buf bytes.Buffer
+
+		require.NoError(b, bld.Build(&buf, Version2))
+
+		ndx, err := Open(bytes.NewReader(buf.Bytes()), fakeEncryptionOverhead)
+		require.NoError(b, err)
+
+		all = append(all, ndx)
+	}
+
+	id := deterministicContentID("segment0", contentsPerSegment/2)
+
+	for _, numSegments := range []int{4, 8, 16, 32, 64, 256} {
+		m := all[0:numSegments]
+
+		b.Run(fmt.Sprintf("Serial-%v", numSegments), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := m.getInfoSerial(id); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("Parallel-%v", numSegments), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := m.getInfoParallel(id); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -3,12 +3,15 @@
 import (
 	"bytes"
 	"crypto/sha1"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -45,14 +48,14 @@ func deterministicPackBlobID(id int) blob.ID {
 	return blob.ID(fmt.Sprintf("%x", h.Sum(nil)))
 }
 
-func deterministicPackedOffset(id int) uint32 {
+func deterministicPackedOffset(id int) uint64 {
 	s := rand.NewSource(int64(id + 1))
 	rnd := rand.New(s)
 
-	return uint32(rnd.Int31()) & (1<<28 - 1)
+	return uint64(rnd.Int31()) & (1<<28 - 1)
 }
 
-func deterministicOriginalLength(id, version int) uint32 {
+func deterministicOriginalLength(id, version int) uint64 {
 	if version == 1 {
 		return deterministicPackedLength(id) - fakeEncryptionOverhead
 	}
@@ -60,14 +63,14 @@ func deterministicOriginalLength(id, version int) uint32 {
 	s := rand.NewSource(int64(id + 4))
 	rnd := rand.New(s)
 
-	return uint32(rnd.Int31()) & (1<<28 - 1)
+	return uint64(rnd.Int31()) & (1<<28 - 1)
 }
 
-func deterministicPackedLength(id int) uint32 {
+func deterministicPackedLength(id int) uint64 {
 	s := rand.NewSource(int64(id + 2))
 	rnd := rand.New(s)
 
-	return uint32(rnd.Int31()) % v2MaxContentLength
+	return uint64(rnd.Int31()) % v2MaxContentLength
 }
 
 func deterministicFormatVersion(id int) byte {
@@ -90,6 +93,14 @@ func deterministicEncryptionKeyID(id, version int) byte {
 	return byte(id % 100)
 }
 
+func deterministicCompressionDictionaryID(id, version int) blob.ID {
+	if version == 1 || id%3 == 0 {
+		return ""
+	}
+
+	return blob.ID(fmt.Sprintf("d%v", id%7))
+}
+
 func randomUnixTime() int64 {
 	return int64(rand.Int31())
 }
@@ -102,6 +113,10 @@ func TestPackIndex_V2(t *testing.T) {
 	testPackIndex(t, Version2)
 }
 
+func TestPackIndex_V3(t *testing.T) {
+	testPackIndex(t, Version3)
+}
+
 // nolint:thelper,gocyclo,cyclop
 func testPackIndex(t *testing.T, version int) {
 	var infos []Info
@@ -118,6 +133,8 @@ func testPackIndex(t *testing.T, version int) {
 			OriginalLength:      deterministicOriginalLength(i, version),
 			CompressionHeaderID: deterministicCompressionHeaderID(i, version),
 			EncryptionKeyID:     deterministicEncryptionKeyID(i, version),
+
+			CompressionDictionaryID: deterministicCompressionDictionaryID(i, version),
 		})
 	}
 	// non-deleted content
@@ -132,12 +149,14 @@ func testPackIndex(t *testing.T, version int) {
 			OriginalLength:      deterministicOriginalLength(i, version),
 			CompressionHeaderID: deterministicCompressionHeaderID(i, version),
 			EncryptionKeyID:     deterministicEncryptionKeyID(i, version),
+
+			CompressionDictionaryID: deterministicCompressionDictionaryID(i, version),
 		})
 	}
 
 	// dear future reader, if this fails because the number of methods has changed,
 	// you need to add additional test cases above.
-	if cnt := reflect.TypeOf((*Info)(nil)).Elem().NumMethod(); cnt != 11 {
+	if cnt := reflect.TypeOf((*Info)(nil)).Elem().NumMethod(); cnt != 12 {
 		t.Fatalf("unexpected number of methods on content.Info: %v, must update the test", cnt)
 	}
 
@@ -204,6 +223,26 @@ func testPackIndex(t *testing.T, version int) {
 		require.Equal(t, ToInfoStruct(want), ToInfoStruct(info2))
 	}
 
+	var lookupIDs []ID
+
+	for i, want := range infos {
+		lookupIDs = append(lookupIDs, want.GetContentID(), deterministicContentID("no-such-content", i))
+	}
+
+	infos2, err := ndx.GetInfos(lookupIDs)
+	require.NoError(t, err)
+	require.Len(t, infos2, len(infoMap))
+
+	for cid, info2 := range infos2 {
+		want := infoMap[cid]
+		if version == 1 {
+			// v1 does not preserve original length.
+			want = withOriginalLength{want, want.GetPackedLength() - fakeEncryptionOverhead}
+		}
+
+		require.Equal(t, ToInfoStruct(want), ToInfoStruct(info2))
+	}
+
 	cnt := 0
 
 	require.NoError(t, ndx.Iterate(AllIDs, func(info2 Info) error {
@@ -222,6 +261,20 @@ func testPackIndex(t *testing.T, version int) {
 		t.Errorf("invalid number of iterations: %v, wanted %v", cnt, len(infoMap))
 	}
 
+	var forwardIDs, reverseIDs []ID
+
+	require.NoError(t, ndx.Iterate(AllIDs, func(info2 Info) error {
+		forwardIDs = append(forwardIDs, info2.GetContentID())
+		return nil
+	}))
+
+	require.NoError(t, ndx.IterateReverse(AllIDs, func(info2 Info) error {
+		reverseIDs = append([]ID{info2.GetContentID()}, reverseIDs...)
+		return nil
+	}))
+
+	require.Equal(t, forwardIDs, reverseIDs)
+
 	prefixes := []ID{"a", "b", "f", "0", "3", "aa", "aaa", "aab", "fff", "m", "x", "y", "m0", "ma"}
 
 	for i := 0; i < 100; i++ {
@@ -249,6 +302,45 @@ func testPackIndex(t *testing.T, version int) {
 		}))
 		t.Logf("found %v elements with prefix %q", cnt2, prefix)
 	}
+
+	// index opened from a byte slice returns the same results.
+	ndx2, err := Open(BytesReaderAt(data1), fakeEncryptionOverhead)
+	require.NoError(t, err)
+
+	defer ndx2.Close()
+
+	require.Equal(t, iterateInfoStructs(t, ndx, false), iterateInfoStructs(t, ndx2, false))
+	require.Equal(t, iterateInfoStructs(t, ndx, true), iterateInfoStructs(t, ndx2, true))
+
+	infos3, err := ndx2.GetInfos(lookupIDs)
+	require.NoError(t, err)
+	require.Len(t, infos3, len(infos2))
+
+	for cid, info3 := range infos3 {
+		require.Equal(t, ToInfoStruct(infos2[cid]), ToInfoStruct(info3))
+
+		info4, err := ndx2.GetInfo(cid)
+		require.NoError(t, err)
+		require.Equal(t, ToInfoStruct(infos2[cid]), ToInfoStruct(info4))
+	}
+}
+
+func iterateInfoStructs(t *testing.T, ndx Index, reverse bool) []*InfoStruct {
+	t.Helper()
+
+	var result []*InfoStruct
+
+	iterate := ndx.Iterate
+	if reverse {
+		iterate = ndx.IterateReverse
+	}
+
+	require.NoError(t, iterate(AllIDs, func(i Info) error {
+		result = append(result, ToInfoStruct(i))
+		return nil
+	}))
+
+	return result
 }
 
 func TestPackIndexPerContentLimits(t *testing.T) {
@@ -292,6 +384,162 @@ func TestPackIndexPerContentLimits(t *testing.T) {
 	}
 }
 
+func TestPackIndexV3PerContentLimits(t *testing.T) {
+	cases := []*InfoStruct{
+		{PackedLength: v2MaxContentLength},
+		{OriginalLength: v2MaxContentLength},
+		{PackedLength: math.MaxUint32, OriginalLength: math.MaxUint32},
+		{PackedLength: 5 << 30, OriginalLength: 6 << 30},
+		{PackedLength: v3MaxContentLength - 1, OriginalLength: v3MaxContentLength - 1},
+		{PackOffset: v2MaxPackOffset},
+		{PackOffset: math.MaxUint32, Deleted: true},
+		{PackOffset: 7 << 30},
+		{PackOffset: v3MaxPackOffset - 1, Deleted: true},
+	}
+
+	for _, info := range cases {
+		cid := deterministicContentID("hello-world", 1)
+		info.ContentID = cid
+
+		b := Builder{
+			cid: info,
+		}
+
+		var result bytes.Buffer
+
+		require.NoError(t, b.buildV3(&result))
+
+		pi, err := Open(bytes.NewReader(result.Bytes()), fakeEncryptionOverhead)
+		require.NoError(t, err)
+
+		got, err := pi.GetInfo(cid)
+		require.NoError(t, err)
+
+		require.Equal(t, ToInfoStruct(got), ToInfoStruct(info))
+	}
+
+	errorCases := []struct {
+		info   *InfoStruct
+		errMsg string
+	}{
+		{&InfoStruct{PackedLength: v3MaxContentLength}, "maximum content length is too high"},
+		{&InfoStruct{OriginalLength: v3MaxContentLength}, "maximum content length is too high"},
+		{&InfoStruct{PackOffset: v3MaxPackOffset}, "pack offset 1099511627776 is too high"},
+		{&InfoStruct{PackOffset: v3MaxPackOffset - 1, PackedLength: 2}, "content ending at pack offset 1099511627777 is too high"},
+	}
+
+	for _, tc := range errorCases {
+		cid := deterministicContentID("hello-world", 1)
+		tc.info.ContentID = cid
+
+		b := Builder{
+			cid: tc.info,
+		}
+
+		var result bytes.Buffer
+
+		err := b.buildV3(&result)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), tc.errMsg)
+	}
+}
+
+func TestPackIndexVerifyStructure(t *testing.T) {
+	cases := []struct {
+		version          int
+		packBlobIDOffset int
+		formatIDOffset   int
+		packOffsetOffset int
+		packOffsetSize   int
+	}{
+		{Version2, v2EntryOffsetPackBlobID, v2EntryOffsetFormatID, v2EntryOffsetPackOffsetAndFlags, 4},
+		{Version3, v3EntryOffsetPackBlobID, v3EntryOffsetFormatID, v3EntryOffsetPackOffset, 5},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(fmt.Sprintf("v%v", tc.version), func(t *testing.T) {
+			b := Builder{}
+
+			for i := 0; i < 10; i++ {
+				b.Add(&InfoStruct{
+					ContentID:     deterministicContentID("verify", i),
+					PackBlobID:    deterministicPackBlobID(i % 2),
+					FormatVersion: byte(i % 2),
+					PackedLength:  100,
+				})
+			}
+
+			var buf bytes.Buffer
+
+			require.NoError(t, b.BuildStable(&buf, tc.version))
+
+			valid := buf.Bytes()
+
+			ndx, err := Open(bytes.NewReader(valid), fakeEncryptionOverhead)
+			require.NoError(t, err)
+			require.NoError(t, VerifyStructure(ndx))
+
+			hdr := ndx.(*indexV2).hdr // nolint:forcetypeassert
+			entryValue := func(data []byte, n int) []byte {
+				off := int(hdr.entriesOffset) + n*int(hdr.entryStride)
+				return data[off+hdr.keySize : off+int(hdr.entryStride)]
+			}
+
+			corruptions := map[string]func(data []byte){
+				"unsorted": func(data []byte) {
+					e0 := int(hdr.entriesOffset)
+					e1 := e0 + int(hdr.entryStride)
+
+					tmp := append([]byte(nil), data[e0:e1]...)
+					copy(data[e0:e1], data[e1:e1+int(hdr.entryStride)])
+					copy(data[e1:e1+int(hdr.entryStride)], tmp)
+				},
+				"pack out of range": func(data []byte) {
+					entryValue(data, 3)[tc.packBlobIDOffset+1] = 2
+				},
+				"format out of range": func(data []byte) {
+					entryValue(data, 5)[tc.formatIDOffset] = 2
+				},
+				"pack offset out of range": func(data []byte) {
+					copy(entryValue(data, 7)[tc.packOffsetOffset:], bytes.Repeat([]byte{0xff}, tc.packOffsetSize))
+				},
+			}
+
+			for desc, corrupt := range corruptions {
+				data := append([]byte(nil), valid...)
+				corrupt(data)
+
+				ndx, err := Open(bytes.NewReader(data), fakeEncryptionOverhead)
+				require.NoError(t, err)
+				require.ErrorIs(t, VerifyStructure(ndx), ErrIndexCorrupt, desc)
+
+				// structural problems are detected even without the checksum.
+				ndx.(*indexV2).integrity = nil // nolint:forcetypeassert
+				require.Error(t, VerifyStructure(ndx), desc)
+			}
+
+			// entry referring to format that does not exist reports invalid format values.
+			data := append([]byte(nil), valid...)
+			corruptions["format out of range"](data)
+
+			ndx, err = Open(bytes.NewReader(data), fakeEncryptionOverhead)
+			require.NoError(t, err)
+
+			require.NoError(t, ndx.Iterate(AllIDs, func(i Info) error {
+				if i.(entryIndexes).formatIDIndex() == 2 { // nolint:forcetypeassert
+					require.Equal(t, byte(invalidFormatVersion), i.GetFormatVersion())
+					require.Equal(t, compression.HeaderID(invalidCompressionHeaderID), i.GetCompressionHeaderID())
+					require.Equal(t, byte(invalidEncryptionKeyID), i.GetEncryptionKeyID())
+				}
+
+				return nil
+			}))
+		})
+	}
+}
+
 func TestSortedContents(t *testing.T) {
 	b := Builder{}
 
@@ -343,6 +591,95 @@ func TestPackIndexV2TooManyUniqueFormats(t *testing.T) {
 	require.Equal(t, err.Error(), "unsupported - too many unique formats 256 (max 255)")
 }
 
+func TestPackIndexCompressionDictionary(t *testing.T) {
+	for _, version := range []int{Version2, Version3} {
+		version := version
+
+		t.Run(fmt.Sprintf("v%v", version), func(t *testing.T) {
+			var infos []*InfoStruct
+
+			b := Builder{}
+
+			for i := 0; i < 10; i++ {
+				info := &InfoStruct{
+					ContentID:           deterministicContentID("", i),
+					PackBlobID:          deterministicPackBlobID(i % 3),
+					FormatVersion:       1,
+					CompressionHeaderID: compression.HeaderZstdDefault,
+				}
+
+				// some formats share a dictionary, others don't use one at all.
+				switch i % 3 {
+				case 1:
+					info.CompressionDictionaryID = "d1234"
+				case 2:
+					info.CompressionDictionaryID = "d5678"
+				}
+
+				infos = append(infos, info)
+				b.Add(info)
+			}
+
+			var buf bytes.Buffer
+
+			require.NoError(t, b.Build(&buf, version))
+
+			// entry size is flagged, so that readers which don't understand dictionaries reject the index.
+			require.Greater(t, int(binary.BigEndian.Uint16(buf.Bytes()[2:4])), v2EntryMaxLength)
+
+			ndx, err := Open(bytes.NewReader(buf.Bytes()), fakeEncryptionOverhead)
+			require.NoError(t, err)
+
+			for _, info := range infos {
+				got, err := ndx.GetInfo(info.ContentID)
+				require.NoError(t, err)
+				require.Equal(t, info, ToInfoStruct(got))
+			}
+
+			require.NoError(t, ndx.(*indexV2).VerifyStructure())
+		})
+	}
+}
+
+func TestPackIndexWithoutCompressionDictionaryIsUnchanged(t *testing.T) {
+	b := Builder{}
+
+	for i := 0; i < 10; i++ {
+		b.Add(&InfoStruct{
+			ContentID:           deterministicContentID("", i),
+			PackBlobID:          deterministicPackBlobID(i),
+			FormatVersion:       1,
+			CompressionHeaderID: compression.HeaderID(1000 + i%2),
+		})
+	}
+
+	var buf bytes.Buffer
+
+	require.NoError(t, b.buildV2(&buf))
+
+	// without dictionaries, entry size is not flagged and format entries have the original size.
+	data := buf.Bytes()
+	entrySize := int(binary.BigEndian.Uint16(data[2:4]))
+	require.LessOrEqual(t, entrySize, v2EntryMaxLength)
+
+	keySize := int(data[1])
+	formatsOffset := v2IndexHeaderSize + 10*(keySize+entrySize) + 10*v2PackInfoSize
+	integrity, err := readIntegrityInfo(bytes.NewReader(data), int64(formatsOffset+2*v2FormatInfoSize))
+	require.NoError(t, err)
+	require.NotNil(t, integrity)
+
+	// v1 indexes can't reference dictionaries.
+	b1 := Builder{}
+	b1.Add(&InfoStruct{
+		ContentID:               deterministicContentID("", 11),
+		CompressionDictionaryID: "d1234",
+	})
+
+	err = b1.Build(io.Discard, Version1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "compression dictionaries not supported")
+}
+
 func fuzzTestIndexOpen(originalData []byte) {
 	// use consistent random
 	rnd := rand.New(rand.NewSource(12345))
@@ -437,6 +774,65 @@ func TestShard(t *testing.T) {
 		verifyAllShardedIDs(t, b.shard(2000), len(b), 5))
 }
 
+func TestBuildShardsDropDeletedBefore(t *testing.T) {
+	b := Builder{}
+
+	for i := 0; i < 10; i++ {
+		b.Add(&InfoStruct{
+			ContentID:        deterministicContentID("drop", i),
+			PackBlobID:       deterministicPackBlobID(i),
+			TimestampSeconds: int64(1000 + i),
+			Deleted:          i%2 == 0,
+		})
+	}
+
+	readShards := func(opt BuildOptions) map[ID]bool {
+		t.Helper()
+
+		shards, cleanup, err := b.BuildShards(Version2, true, 100, opt)
+		require.NoError(t, err)
+
+		defer cleanup()
+
+		result := map[ID]bool{}
+
+		for _, s := range shards {
+			ndx, oerr := Open(bytes.NewReader(s.ToByteSlice()), fakeEncryptionOverhead)
+			require.NoError(t, oerr)
+
+			require.NoError(t, ndx.Iterate(AllIDs, func(i Info) error {
+				result[i.GetContentID()] = i.GetDeleted()
+				return nil
+			}))
+		}
+
+		return result
+	}
+
+	// without the option all tombstones are written.
+	require.Len(t, readShards(BuildOptions{}), 10)
+
+	// deleted entries 0, 2 and 4 are older than the watermark, 1 and 3 are not deleted.
+	got := readShards(BuildOptions{DropDeletedBefore: time.Unix(1005, 0)})
+	require.Len(t, got, 7)
+
+	for i := 0; i < 10; i++ {
+		_, ok := got[deterministicContentID("drop", i)]
+		require.Equal(t, i%2 == 1 || i >= 5, ok, i)
+	}
+
+	// the builder itself is not modified.
+	require.Len(t, b, 10)
+
+	// dropping everything produces no shards.
+	shards, cleanup, err := Builder{
+		deterministicContentID("drop", 0): &InfoStruct{ContentID: deterministicContentID("drop", 0), TimestampSeconds: 1000, Deleted: true},
+	}.BuildShards(Version2, true, 100, BuildOptions{DropDeletedBefore: time.Unix(2000, 0)})
+	require.NoError(t, err)
+	require.Empty(t, shards)
+	cleanup()
+}
+
 func verifyAllShardedIDs(t *testing.T, sharded []Builder, numTotal, numShards int) []int {
 	t.Helper()
 
@@ -468,9 +864,103 @@ func verifyAllShardedIDs(t *testing.T, sharded []Builder, numTotal, numShards in
 
 type withOriginalLength struct {
 	Info
-	originalLength uint32
+	originalLength uint64
 }
 
-func (o withOriginalLength) GetOriginalLength() uint32 {
+func (o withOriginalLength) GetOriginalLength() uint64 {
 	return o.originalLength
 }
+
+func BenchmarkGetInfo(b *testing.B) {
+	ndx, ids := benchmarkIndexWithLookupIDs(b)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, id := range ids {
+			if _, err := ndx.GetInfo(id); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkGetInfos(b *testing.B) {
+	ndx, ids := benchmarkIndexWithLookupIDs(b)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ndx.GetInfos(ids); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIterate(b *testing.B) {
+	data, _ := benchmarkIndexDataWithLookupIDs(b)
+
+	for _, tc := range []struct {
+		name     string
+		readerAt io.ReaderAt
+	}{
+		{"BytesReader", bytes.NewReader(data)},
+		{"BytesReaderAt", BytesReaderAt(data)},
+	} {
+		ndx, err := Open(tc.readerAt, fakeEncryptionOverhead)
+		require.NoError(b, err)
+
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := ndx.Iterate(AllIDs, func(Info) error { return nil }); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func benchmarkIndexWithLookupIDs(b *testing.B) (Index, []ID) {
+	b.Helper()
+
+	data, ids := benchmarkIndexDataWithLookupIDs(b)
+
+	ndx, err := Open(bytes.NewReader(data), fakeEncryptionOverhead)
+	require.NoError(b, err)
+
+	return ndx, ids
+}
+
+func benchmarkIndexDataWithLookupIDs(b *testing.B) ([]byte, []ID) {
+	b.Helper()
+
+	const (
+		numContents = 100000
+		numLookups  = 10000
+	)
+
+	bld := Builder{}
+
+	var ids []ID
+
+	for i := 0; i < numContents; i++ {
+		cid := deterministicContentID("benchmark", i)
+
+		bld.Add(&InfoStruct{
+			ContentID:    cid,
+			PackBlobID:   deterministicPackBlobID(i % 100),
+			PackOffset:   deterministicPackedOffset(i),
+			PackedLength: deterministicPackedLength(i),
+		})
+
+		if i%(numContents/numLookups) == 0 {
+			ids = append(ids, cid)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	require.NoError(b, bld.Build(&buf, Version2))
+
+	return buf.Bytes(), ids
+}
@@ -469,7 +469,13 @@ func (m *indexBlobManagerV0) compactIndexBlobs(ctx context.Context, indexBlobs [
 	// we must do it after all input blobs have been merged, otherwise we may resurrect contents.
 	m.dropContentsFromBuilder(bld, opt)
 
-	dataShards, cleanupShards, err := bld.BuildShards(m.indexVersion, false, m.indexShardSize)
+	if !opt.DropDeletedBefore.IsZero() {
+		m.log.Debugf("drop-content-deleted-before %v", opt.DropDeletedBefore)
+	}
+
+	dataShards, cleanupShards, err := bld.BuildShards(m.indexVersion, false, m.indexShardSize, index.BuildOptions{
+		DropDeletedBefore: opt.DropDeletedBefore,
+	})
 	if err != nil {
 		return errors.Wrap(err, "unable to build an index")
 	}
@@ -497,19 +503,6 @@ func (m *indexBlobManagerV0) dropContentsFromBuilder(bld index.Builder, opt Comp
 			delete(bld, dc)
 		}
 	}
-
-	if !opt.DropDeletedBefore.IsZero() {
-		m.log.Debugf("drop-content-deleted-before %v", opt.DropDeletedBefore)
-
-		for _, i := range bld {
-			if i.GetDeleted() && i.Timestamp().Before(opt.DropDeletedBefore) {
-				m.log.Debugf("drop-from-index-old-deleted %v %v", i.GetContentID(), i.Timestamp())
-				delete(bld, i.GetContentID())
-			}
-		}
-
-		m.log.Debugf("finished drop-content-deleted-before %v", opt.DropDeletedBefore)
-	}
 }
 
 func addIndexBlobsToBuilder(ctx context.Context, enc *encryptedBlobMgr, bld index.Builder, indexBlobID blob.ID) error {
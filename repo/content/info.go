@@ -42,3 +42,48 @@ func IDsToStrings(input []ID) []string {
 
 	return result
 }
+
+// PackingRatio returns the ratio of the packed length of the provided content to its original length,
+// which includes the effects of compression and encryption overhead. It returns 0 for empty contents.
+func PackingRatio(i Info) float64 {
+	if i.GetOriginalLength() == 0 {
+		return 0
+	}
+
+	return float64(i.GetPackedLength()) / float64(i.GetOriginalLength())
+}
+
+// PackingRatioBuckets is the number of buckets of PackingRatioHistogram.
+const PackingRatioBuckets = 11
+
+// PackingRatioHistogram is the distribution of packing ratios of contents. Bucket N (N < 10) holds contents
+// whose packing ratio is at least N/10 and less than (N+1)/10, the last bucket holds contents that did not
+// get smaller when packed.
+type PackingRatioHistogram struct {
+	Count         [PackingRatioBuckets]int64
+	OriginalBytes [PackingRatioBuckets]int64
+	PackedBytes   [PackingRatioBuckets]int64
+}
+
+// PackingRatioBucket returns the index of the histogram bucket for the provided packing ratio.
+func PackingRatioBucket(ratio float64) int {
+	b := int(ratio * (PackingRatioBuckets - 1))
+
+	switch {
+	case b < 0:
+		return 0
+	case b >= PackingRatioBuckets:
+		return PackingRatioBuckets - 1
+	default:
+		return b
+	}
+}
+
+// Add adds the provided content to the histogram.
+func (h *PackingRatioHistogram) Add(i Info) {
+	b := PackingRatioBucket(PackingRatio(i))
+
+	h.Count[b]++
+	h.OriginalBytes[b] += int64(i.GetOriginalLength())
+	h.PackedBytes[b] += int64(i.GetPackedLength())
+}
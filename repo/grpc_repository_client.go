@@ -650,21 +650,33 @@ func (r *grpcRepositoryClient) doWrite(ctx context.Context, contentID content.ID
 }
 
 func (r *grpcRepositoryClient) WriteContent(ctx context.Context, data gather.Bytes, prefix content.ID, comp compression.HeaderID) (content.ID, error) {
+	contentID, _, err := r.WriteContentWithDedupInfo(ctx, data, prefix, comp)
+
+	return contentID, err
+}
+
+// WriteContentWithDedupInfo writes the content and reports it as deduplicated if it has been written recently.
+// Otherwise the content is sent to the server asynchronously, which does not report whether the content already
+// existed, so whether it is new is reported as unknown.
+// Since the write is asynchronous, the packed length is not known and the original length is reported instead.
+func (r *grpcRepositoryClient) WriteContentWithDedupInfo(ctx context.Context, data gather.Bytes, prefix content.ID, comp compression.HeaderID) (content.ID, content.WriteInfo, error) {
 	if err := content.ValidatePrefix(prefix); err != nil {
-		return "", errors.Wrap(err, "invalid prefix")
+		return "", content.WriteInfo{}, errors.Wrap(err, "invalid prefix")
 	}
 
 	// we will be writing asynchronously and server will reject this write, fail early.
 	if prefix == manifest.ContentPrefix {
-		return "", errors.Errorf("writing manifest contents not allowed")
+		return "", content.WriteInfo{}, errors.Errorf("writing manifest contents not allowed")
 	}
 
+	wi := content.WriteInfo{PackedLength: int64(data.Length())}
+
 	var hashOutput [128]byte
 
 	contentID := prefix + content.ID(hex.EncodeToString(r.h(hashOutput[:0], data)))
 
 	if r.recent.exists(contentID) {
-		return contentID, nil
+		return contentID, wi, nil
 	}
 
 	// acquire semaphore
@@ -682,7 +694,9 @@ func (r *grpcRepositoryClient) WriteContent(ctx context.Context, data gather.Byt
 		return r.doWrite(ctxutil.Detach(ctx), contentID, clone, prefix, comp)
 	})
 
-	return contentID, nil
+	wi.IsNewUnknown = true
+
+	return contentID, wi, nil
 }
 
 func (r *grpcInnerSession) WriteContent(ctx context.Context, data []byte, prefix content.ID, comp compression.HeaderID) (content.ID, error) {
@@ -333,14 +333,7 @@ func runTaskCleanupEpochManager(ctx context.Context, runParams RunParameters, s
 }
 
 func runTaskDropDeletedContentsFull(ctx context.Context, runParams RunParameters, s *Schedule, safety SafetyParameters) error {
-	var safeDropTime time.Time
-
-	if safety.RequireTwoGCCycles {
-		safeDropTime = findSafeDropTime(s.Runs[TaskSnapshotGarbageCollection], safety)
-	} else {
-		safeDropTime = runParams.rep.Time()
-	}
-
+	safeDropTime := safeDropTimeForSchedule(runParams.rep, s, safety)
 	if safeDropTime.IsZero() {
 		log(ctx).Infof("Not enough time has passed since previous successful Snapshot GC. Will try again next time.")
 		return nil
@@ -505,6 +498,26 @@ func maxEndTime(taskRuns ...[]RunInfo) time.Time {
 	return result
 }
 
+// SafeDropTime returns the latest timestamp such that contents deleted before it can no longer be
+// undeleted by concurrent snapshots, based on the history of snapshot GC runs.
+// Zero time is returned when there is no such timestamp yet.
+func SafeDropTime(ctx context.Context, rep repo.DirectRepository, safety SafetyParameters) (time.Time, error) {
+	s, err := GetSchedule(ctx, rep)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "unable to get maintenance schedule")
+	}
+
+	return safeDropTimeForSchedule(rep, s, safety), nil
+}
+
+func safeDropTimeForSchedule(rep repo.DirectRepository, s *Schedule, safety SafetyParameters) time.Time {
+	if !safety.RequireTwoGCCycles {
+		return rep.Time()
+	}
+
+	return findSafeDropTime(s.Runs[TaskSnapshotGarbageCollection], safety)
+}
+
 // findSafeDropTime returns the latest timestamp for which it is safe to drop content entries
 // deleted before that time, because at least two successful GC cycles have completed
 // and minimum required time between the GCs has passed.
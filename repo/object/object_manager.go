@@ -5,6 +5,7 @@
 	"context"
 	"io"
 	"sync"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 
@@ -34,7 +35,7 @@ type contentReader interface {
 type contentManager interface {
 	contentReader
 	SupportsContentCompression() bool
-	WriteContent(ctx context.Context, data gather.Bytes, prefix content.ID, comp compression.HeaderID) (content.ID, error)
+	WriteContentWithDedupInfo(ctx context.Context, data gather.Bytes, prefix content.ID, comp compression.HeaderID) (content.ID, content.WriteInfo, error)
 }
 
 // Format describes the format of objects in a repository.
@@ -49,6 +50,10 @@ type Manager struct {
 	contentMgr  contentManager
 	newSplitter splitter.Factory
 	writerPool  sync.Pool
+
+	splittersMutex sync.Mutex
+	// +checklocks:splittersMutex
+	splitterByName map[string]splitter.Factory
 }
 
 // NewWriter creates an ObjectWriter for writing to the repository.
@@ -56,11 +61,15 @@ func (om *Manager) NewWriter(ctx context.Context, opt WriterOptions) Writer {
 	w, _ := om.writerPool.Get().(*objectWriter)
 	w.ctx = ctx
 	w.om = om
-	w.splitter = om.newSplitter()
+	w.splitter, w.splitterError = om.newSplitterByName(opt.Splitter)
 	w.description = opt.Description
+	w.metadata = opt.Metadata
 	w.prefix = opt.Prefix
 	w.compressor = compression.ByName[opt.Compressor]
 	w.totalLength = 0
+	atomic.StoreInt64(&w.packedLength, 0)
+	atomic.StoreInt64(&w.newPackedLength, 0)
+	atomic.StoreInt64(&w.unknownPackedLength, 0)
 	w.currentPosition = 0
 
 	// point the slice at the embedded array, so that we avoid allocations most of the time
@@ -200,6 +209,45 @@ func PrefetchBackingContents(ctx context.Context, contentMgr contentManager, obj
 	return contentMgr.PrefetchContents(ctx, tracker.contentIDs(), hint), nil
 }
 
+// newSplitterByName returns a new splitter with the provided name, the repository default when the name
+// is empty. When the name is not supported, it returns the repository default along with an error.
+func (om *Manager) newSplitterByName(name string) (splitter.Splitter, error) {
+	f := om.splitterFactory(name)
+	if f == nil {
+		return om.newSplitter(), errors.Errorf("unsupported splitter %q", name)
+	}
+
+	return f(), nil
+}
+
+// splitterFactory returns the factory of splitters with the provided name or the repository default
+// when the name is empty, nil if the name is not supported.
+func (om *Manager) splitterFactory(name string) splitter.Factory {
+	if name == "" || name == om.Format.Splitter {
+		return om.newSplitter
+	}
+
+	om.splittersMutex.Lock()
+	defer om.splittersMutex.Unlock()
+
+	if f := om.splitterByName[name]; f != nil {
+		return f
+	}
+
+	f := splitter.GetFactory(name)
+	if f == nil {
+		return nil
+	}
+
+	if om.splitterByName == nil {
+		om.splitterByName = map[string]splitter.Factory{}
+	}
+
+	om.splitterByName[name] = splitter.Pooled(f)
+
+	return om.splitterByName[name]
+}
+
 // NewObjectManager creates an ObjectManager with the specified content manager and format.
 func NewObjectManager(ctx context.Context, bm contentManager, f Format) (*Manager, error) {
 	om := &Manager{
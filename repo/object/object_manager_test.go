@@ -41,6 +41,9 @@ type fakeContentManager struct {
 
 	supportsContentCompression bool
 	writeContentError          error
+
+	// when set, contents are reported as written without knowing whether they were new.
+	isNewUnknown bool
 }
 
 func (f *fakeContentManager) PrefetchContents(ctx context.Context, contentIDs []content.ID, hint string) []content.ID {
@@ -58,9 +61,9 @@ func (f *fakeContentManager) GetContent(ctx context.Context, contentID content.I
 	return nil, content.ErrContentNotFound
 }
 
-func (f *fakeContentManager) WriteContent(ctx context.Context, data gather.Bytes, prefix content.ID, comp compression.HeaderID) (content.ID, error) {
+func (f *fakeContentManager) WriteContentWithDedupInfo(ctx context.Context, data gather.Bytes, prefix content.ID, comp compression.HeaderID) (content.ID, content.WriteInfo, error) {
 	if f.writeContentError != nil {
-		return "", f.writeContentError
+		return "", content.WriteInfo{}, f.writeContentError
 	}
 
 	h := sha256.New()
@@ -70,12 +73,18 @@ func (f *fakeContentManager) WriteContent(ctx context.Context, data gather.Bytes
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	_, exists := f.data[contentID]
+
 	f.data[contentID] = data.ToByteSlice()
 	if f.compresionIDs != nil {
 		f.compresionIDs[contentID] = comp
 	}
 
-	return contentID, nil
+	if f.isNewUnknown {
+		return contentID, content.WriteInfo{IsNewUnknown: true, PackedLength: int64(data.Length())}, nil
+	}
+
+	return contentID, content.WriteInfo{IsNew: !exists, PackedLength: int64(data.Length())}, nil
 }
 
 func (f *fakeContentManager) SupportsContentCompression() bool {
@@ -87,7 +96,7 @@ func (f *fakeContentManager) ContentInfo(ctx context.Context, contentID content.
 	defer f.mu.Unlock()
 
 	if d, ok := f.data[contentID]; ok {
-		return &content.InfoStruct{ContentID: contentID, PackedLength: uint32(len(d))}, nil
+		return &content.InfoStruct{ContentID: contentID, PackedLength: uint64(len(d))}, nil
 	}
 
 	return nil, blob.ErrBlobNotFound
@@ -204,6 +213,74 @@ func TestCompression_ContentCompressionDisabled(t *testing.T) {
 	require.True(t, isCompressed) // oid will indicate compression
 }
 
+func TestWriterPackedLength(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	// this disables content compression, so compression happens in the object writer.
+	_, _, om := setupTest(t, nil)
+
+	data := bytes.Repeat([]byte{1, 2, 3, 4}, 1000)
+
+	w := om.NewWriter(ctx, WriterOptions{})
+	w.Write(data)
+	_, err := w.Result()
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), w.PackedLength())
+	w.Close()
+
+	w = om.NewWriter(ctx, WriterOptions{
+		Compressor: "gzip",
+	})
+	require.Zero(t, w.PackedLength())
+	w.Write(data)
+	_, err = w.Result()
+	require.NoError(t, err)
+	require.Positive(t, w.PackedLength())
+	require.Less(t, w.PackedLength(), int64(len(data)))
+	w.Close()
+}
+
+func TestWriterNewPackedLength(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, _, om := setupTest(t, nil)
+
+	data := bytes.Repeat([]byte{1, 2, 3, 4}, 1000)
+
+	w := om.NewWriter(ctx, WriterOptions{})
+	w.Write(data)
+	_, err := w.Result()
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), w.NewPackedLength())
+	w.Close()
+
+	// same data is deduplicated, so nothing new is written.
+	w = om.NewWriter(ctx, WriterOptions{})
+	w.Write(data)
+	_, err = w.Result()
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), w.PackedLength())
+	require.Zero(t, w.NewPackedLength())
+	w.Close()
+}
+
+func TestWriterUnknownPackedLength(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, fcm, om := setupTest(t, nil)
+
+	fcm.isNewUnknown = true
+
+	data := bytes.Repeat([]byte{1, 2, 3, 4}, 1000)
+
+	w := om.NewWriter(ctx, WriterOptions{})
+	w.Write(data)
+	_, err := w.Result()
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), w.PackedLength())
+	require.Equal(t, int64(len(data)), w.UnknownPackedLength())
+	require.Zero(t, w.NewPackedLength())
+	w.Close()
+}
+
 func TestWriterCompleteChunkInTwoWrites(t *testing.T) {
 	ctx := testlogging.Context(t)
 	_, _, om := setupTest(t, nil)
@@ -448,6 +525,90 @@ func TestIndirection(t *testing.T) {
 	}
 }
 
+func TestWriterSplitterOption(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	contentBytes := make([]byte, 5<<20)
+	_, err := rand.Read(contentBytes)
+	require.NoError(t, err)
+
+	cases := []struct {
+		splitter         string
+		wantDataContents int
+	}{
+		// repository default is FIXED-1M
+		{"", 5},
+		{"FIXED-1M", 5},
+		{"FIXED-4M", 2},
+	}
+
+	for _, tc := range cases {
+		_, _, om := setupTest(t, nil)
+
+		for i := 0; i < 2; i++ {
+			// second writer reuses pooled splitter.
+			writer := om.NewWriter(ctx, WriterOptions{Splitter: tc.splitter})
+
+			_, err = writer.Write(contentBytes)
+			require.NoError(t, err)
+
+			result, err := writer.Result()
+			require.NoError(t, err)
+
+			cids, err := VerifyObject(ctx, om.contentMgr, result)
+			require.NoError(t, err)
+
+			// data contents + index content
+			require.Len(t, cids, tc.wantDataContents+1, tc.splitter)
+
+			require.NoError(t, writer.Close())
+		}
+	}
+
+	_, _, om := setupTest(t, nil)
+
+	// unsupported splitters are reported when writing.
+	writer := om.NewWriter(ctx, WriterOptions{Splitter: "no-such-splitter"})
+	defer writer.Close()
+
+	_, err = writer.Write(contentBytes)
+	require.ErrorContains(t, err, "unsupported splitter")
+
+	_, err = writer.Result()
+	require.ErrorContains(t, err, "unsupported splitter")
+}
+
+func TestWriterMetadata(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	_, _, om := setupTest(t, nil)
+
+	write := func(opt WriterOptions) ID {
+		w := om.NewWriter(ctx, opt)
+		defer w.Close()
+
+		_, err := w.Write([]byte("hello world"))
+		require.NoError(t, err)
+
+		oid, err := w.Result()
+		require.NoError(t, err)
+
+		return oid
+	}
+
+	withMetadata := write(WriterOptions{Metadata: map[string]string{"path": "a/b"}})
+	withoutMetadata := write(WriterOptions{})
+
+	// metadata is out-of-band and does not affect object ID.
+	require.Equal(t, withoutMetadata, withMetadata)
+
+	// metadata is not retained by pooled writers.
+	w := om.NewWriter(ctx, WriterOptions{})
+	defer w.Close()
+
+	require.Nil(t, w.(*objectWriter).metadata)
+}
+
 func indirectionLevel(oid ID) int {
 	indexObjectID, ok := oid.IndexObjectID()
 	if !ok {
@@ -4,7 +4,9 @@
 	"context"
 	"encoding/json"
 	"io"
+	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 
@@ -31,6 +33,21 @@ type Writer interface {
 
 	// Result returns object ID representing all bytes written to the writer.
 	Result() (ID, error)
+
+	// PackedLength returns the total length of contents holding the data written to the writer,
+	// as stored in the repository after compression and encryption. Contents that were deduplicated
+	// are included. The value is complete after Result() returns.
+	PackedLength() int64
+
+	// NewPackedLength returns the part of PackedLength() taken by contents that were newly written
+	// to the repository rather than deduplicated against existing ones. The value is complete after
+	// Result() returns.
+	NewPackedLength() int64
+
+	// UnknownPackedLength returns the part of PackedLength() taken by contents for which the repository
+	// could not determine whether they were new or deduplicated, those are not included in NewPackedLength().
+	// The value is complete after Result() returns.
+	UnknownPackedLength() int64
 }
 
 type contentIDTracker struct {
@@ -74,6 +91,13 @@ type objectWriter struct {
 	buffer      gather.WriteBuffer
 	totalLength int64
 
+	// +checkatomic
+	packedLength int64
+	// +checkatomic
+	newPackedLength int64
+	// +checkatomic
+	unknownPackedLength int64
+
 	currentPosition int64
 
 	indirectIndexGrowMutex sync.Mutex
@@ -81,9 +105,13 @@ type objectWriter struct {
 	indirectIndexBuf       [4]indirectObjectEntry // small buffer so that we avoid allocations most of the time
 
 	description string
+	metadata    map[string]string
 
 	splitter splitter.Splitter
 
+	// error creating the requested splitter, reported by Write() and Result().
+	splitterError error
+
 	// provides mutual exclusion of all public APIs (Write, Result, Checkpoint)
 	mu sync.Mutex
 
@@ -113,6 +141,10 @@ func (w *objectWriter) Write(data []byte) (n int, err error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	if w.splitterError != nil {
+		return 0, w.splitterError
+	}
+
 	dataLen := len(data)
 	w.totalLength += int64(dataLen)
 
@@ -200,11 +232,20 @@ func (w *objectWriter) prepareAndWriteContentChunk(chunkID int, data gather.Byte
 		return errors.Wrap(err, "unable to prepare content bytes")
 	}
 
-	contentID, err := w.om.contentMgr.WriteContent(w.ctx, contentBytes, w.prefix, comp)
+	contentID, wi, err := w.om.contentMgr.WriteContentWithDedupInfo(w.ctx, contentBytes, w.prefix, comp)
 	if err != nil {
 		return errors.Wrapf(err, "unable to write content chunk %v of %v: %v", chunkID, w.description, err)
 	}
 
+	atomic.AddInt64(&w.packedLength, wi.PackedLength)
+
+	switch {
+	case wi.IsNew:
+		atomic.AddInt64(&w.newPackedLength, wi.PackedLength)
+	case wi.IsNewUnknown:
+		atomic.AddInt64(&w.unknownPackedLength, wi.PackedLength)
+	}
+
 	// update index under a lock
 	w.indirectIndexGrowMutex.Lock()
 	w.indirectIndex[chunkID].Object = maybeCompressedObjectID(contentID, isCompressed)
@@ -252,6 +293,10 @@ func (w *objectWriter) Result() (ID, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	if w.splitterError != nil {
+		return "", w.splitterError
+	}
+
 	// no need to hold a lock on w.indirectIndexGrowMutex, since growing index only happens synchronously
 	// and never in parallel with calling Result()
 	if w.buffer.Length() > 0 || len(w.indirectIndex) == 0 {
@@ -260,7 +305,44 @@ func (w *objectWriter) Result() (ID, error) {
 		}
 	}
 
-	return w.checkpointLocked()
+	oid, err := w.checkpointLocked()
+	if err == nil && len(w.metadata) > 0 {
+		w.logMetadata(oid)
+	}
+
+	return oid, err
+}
+
+// logMetadata emits the out-of-band metadata of the written object, so that it can be attributed to its origin.
+func (w *objectWriter) logMetadata(oid ID) {
+	keys := make([]string, 0, len(w.metadata))
+	for k := range w.metadata {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	kv := []interface{}{"oid", oid}
+	for _, k := range keys {
+		kv = append(kv, k, w.metadata[k])
+	}
+
+	log(w.ctx).Debugw("wrote object", kv...)
+}
+
+// PackedLength implements Writer.
+func (w *objectWriter) PackedLength() int64 {
+	return atomic.LoadInt64(&w.packedLength)
+}
+
+// NewPackedLength implements Writer.
+func (w *objectWriter) NewPackedLength() int64 {
+	return atomic.LoadInt64(&w.newPackedLength)
+}
+
+// UnknownPackedLength implements Writer.
+func (w *objectWriter) UnknownPackedLength() int64 {
+	return atomic.LoadInt64(&w.unknownPackedLength)
 }
 
 // Checkpoint returns object ID which represents portion of the object that has already been written.
@@ -335,4 +417,13 @@ type WriterOptions struct {
 	Prefix      content.ID // empty string or a single-character ('g'..'z')
 	Compressor  compression.Name
 	AsyncWrites int // allow up to N content writes to be asynchronous
+
+	// Splitter is the name of the splitter used to break the object into chunks, empty string to use
+	// the repository default. Chunk sizes are determined by the splitter (for example DYNAMIC-8M-BUZHASH),
+	// custom minimum, maximum and target chunk sizes are not supported.
+	Splitter string
+
+	// Metadata describes the origin of the object for diagnostic purposes, it is emitted in debug logs
+	// along with the object ID and does not affect the contents of the object or its ID.
+	Metadata map[string]string
 }
@@ -87,6 +87,50 @@ func (i ID) Validate() error {
 	return nil
 }
 
+// ValidID determines whether the provided string, in either textual or compact form, is a valid object ID.
+// It performs the same validation as ParseID without allocating.
+func ValidID(s string) bool {
+	if strings.HasPrefix(s, CompactIDPrefix) {
+		return validCompactID(s[len(CompactIDPrefix):])
+	}
+
+	return validTextID(s)
+}
+
+// validTextID determines whether the provided string is a valid object ID in the textual form.
+func validTextID(s string) bool {
+	s = strings.TrimLeft(s, "I")
+
+	if strings.HasPrefix(s, "D") || strings.HasPrefix(s, "Z") {
+		s = s[1:]
+	}
+
+	if len(s) <= 1 {
+		return false
+	}
+
+	// odd length - first character must be a single character between 'g' and 'z'
+	if len(s)%2 == 1 {
+		if s[0] < 'g' || s[0] > 'z' {
+			return false
+		}
+
+		s = s[1:]
+	}
+
+	for i := 0; i < len(s); i++ {
+		if !isHexDigit(s[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
 // IDsFromStrings converts strings to IDs.
 func IDsFromStrings(str []string) ([]ID, error) {
 	var result []ID
@@ -129,8 +173,12 @@ func IndirectObjectID(indexObjectID ID) ID {
 	return "I" + indexObjectID
 }
 
-// ParseID converts the specified string into object ID.
+// ParseID converts the specified string, in either textual or compact form, into object ID.
 func ParseID(s string) (ID, error) {
+	if strings.HasPrefix(s, CompactIDPrefix) {
+		return parseCompactID(s)
+	}
+
 	i := ID(s)
 	return i, i.Validate()
 }
@@ -0,0 +1,147 @@
+package object
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CompactIDPrefix is the discriminator character of compact object IDs, which never starts an ID in the textual form.
+const CompactIDPrefix = "_"
+
+// kinds of the content ID marker, stored in the lowest bits of the compact ID header.
+const (
+	compactMarkerNone = iota
+	compactMarkerDirect
+	compactMarkerCompressed
+
+	compactMarkerBits = 2
+	compactMarkerMask = 1<<compactMarkerBits - 1
+)
+
+// compactHeaderLength is the length of the header of compact object IDs, which consists of
+// the marker and content prefix byte followed by the number of indirections.
+const compactHeaderLength = 2
+
+// compactIDAlphabet is the alphabet of base64url encoding used by compact object IDs.
+const compactIDAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// maxCompactIndirection is the maximum number of indirections representable in the compact form.
+const maxCompactIndirection = 255
+
+// CompactString returns a compact, URL- and filename-safe representation of the object ID
+// which consists of CompactIDPrefix followed by the base64url encoding of the object ID structure.
+// IDs which can't be represented losslessly in the compact form are returned in their textual form,
+// which ParseID also accepts.
+func (i ID) CompactString() string {
+	b, ok := i.compactBytes()
+	if !ok {
+		return string(i)
+	}
+
+	return CompactIDPrefix + base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (i ID) compactBytes() ([]byte, bool) {
+	if !validTextID(string(i)) {
+		return nil, false
+	}
+
+	s := string(i)
+
+	indirection := len(s) - len(strings.TrimLeft(s, "I"))
+	if indirection > maxCompactIndirection {
+		return nil, false
+	}
+
+	s = s[indirection:]
+
+	var header byte
+
+	switch {
+	case strings.HasPrefix(s, "D"):
+		header = compactMarkerDirect
+		s = s[1:]
+
+	case strings.HasPrefix(s, "Z"):
+		header = compactMarkerCompressed
+		s = s[1:]
+	}
+
+	// odd length - first character is the content ID prefix between 'g' and 'z'
+	if len(s)%2 == 1 {
+		header |= (s[0] - 'g' + 1) << compactMarkerBits
+		s = s[1:]
+	}
+
+	data, err := hex.DecodeString(s)
+	if err != nil || hex.EncodeToString(data) != s {
+		// upper-case hex digits would not round-trip.
+		return nil, false
+	}
+
+	return append([]byte{header, byte(indirection)}, data...), true
+}
+
+// validCompactID determines whether the provided base64url payload of a compact object ID is valid,
+// decoding only the header without allocating.
+func validCompactID(s string) bool {
+	const bitsPerChar = 6
+
+	// a trailing single character can't encode a full byte.
+	if len(s)%4 == 1 || len(s)*bitsPerChar/8 <= compactHeaderLength {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(compactIDAlphabet, s[i]) < 0 {
+			return false
+		}
+	}
+
+	header := byte(strings.IndexByte(compactIDAlphabet, s[0])<<2 | strings.IndexByte(compactIDAlphabet, s[1])>>4) //nolint:gomnd
+
+	return header&compactMarkerMask <= compactMarkerCompressed && header>>compactMarkerBits <= 'z'-'g'+1
+}
+
+// parseCompactID parses the compact form of the object ID produced by CompactString.
+func parseCompactID(s string) (ID, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, CompactIDPrefix))
+	if err != nil {
+		return "", errors.Wrap(err, "invalid compact object ID encoding")
+	}
+
+	if len(b) <= compactHeaderLength {
+		return "", errors.Errorf("invalid compact object ID: %v", s)
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString(strings.Repeat("I", int(b[1])))
+
+	switch b[0] & compactMarkerMask {
+	case compactMarkerNone:
+	case compactMarkerDirect:
+		sb.WriteString("D")
+	case compactMarkerCompressed:
+		sb.WriteString("Z")
+	default:
+		return "", errors.Errorf("invalid compact object ID marker: %v", s)
+	}
+
+	if prefix := b[0] >> compactMarkerBits; prefix != 0 {
+		if prefix > 'z'-'g'+1 {
+			return "", errors.Errorf("invalid compact object ID content prefix: %v", s)
+		}
+
+		sb.WriteByte('g' + prefix - 1)
+	}
+
+	sb.WriteString(hex.EncodeToString(b[compactHeaderLength:]))
+
+	i := ID(sb.String())
+
+	return i, i.Validate()
+}
@@ -1,6 +1,8 @@
 package object
 
 import (
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -48,9 +50,52 @@ func TestParseObjectID(t *testing.T) {
 		} else if err == nil && !tc.isValid {
 			t.Errorf("unexpected success parsing %v", tc.text)
 		}
+
+		if got := ValidID(tc.text); got != tc.isValid {
+			t.Errorf("unexpected ValidID(%q): %v, want %v", tc.text, got, tc.isValid)
+		}
+	}
+}
+
+func TestValidIDMatchesParseID(t *testing.T) {
+	cases := []string{
+		"DF0F0",
+		"Zf0f0",
+		"ZDf0f0",
+		"DZf0f0",
+		"IZxf0f0",
+		"II",
+		"_",
+		"_A",
+		"_AAA",
+		"_AAAA",
+		"_AAAAA",
+		"_AwDw8A",
+		"_VADw8A",
+		"_!!!!",
+		"__",
+		ID("IDxf0f0").CompactString(),
+		ID("Zf0f0").CompactString(),
+	}
+
+	for _, s := range cases {
+		_, err := ParseID(s)
+		require.Equal(t, err == nil, ValidID(s), s)
 	}
 }
 
+func TestValidIDDoesNotAllocate(t *testing.T) {
+	compact := ID("IDxf0f0").CompactString()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		ValidID("IIDxd41d8cd98f00b204e9800998ecf8427e")
+		ValidID("Dxf0f")
+		ValidID(compact)
+	})
+
+	require.Zero(t, allocs)
+}
+
 func TestFromStrings(t *testing.T) {
 	ids, err := IDsFromStrings([]string{"f0f0", "f1f1"})
 	require.NoError(t, err)
@@ -65,3 +110,61 @@ func TestToStrings(t *testing.T) {
 	strs := IDsToStrings([]ID{"f0f0", "f1f1"})
 	require.Equal(t, []string{"f0f0", "f1f1"}, strs)
 }
+
+func TestCompactObjectIDRoundTrip(t *testing.T) {
+	cases := []ID{
+		"f0f0",
+		"Df0f0",
+		"IDf0f0",
+		"IIDf0f0",
+		"Dxf0f0",
+		"IDxf0f0",
+		"IIIDgf0f0",
+		"Zxf0f0",
+		"IZzf0f0",
+		"kf0f0",
+		"Dxd41d8cd98f00b204e9800998ecf8427ed41d8cd98f00b204e9800998ecf8427e",
+		ID(strings.Repeat("I", 255) + "Df0f0"),
+	}
+
+	for _, oid := range cases {
+		compact := oid.CompactString()
+		require.True(t, strings.HasPrefix(compact, CompactIDPrefix), compact)
+		require.Equal(t, compact, url.QueryEscape(compact))
+
+		parsed, err := ParseID(compact)
+		require.NoError(t, err, compact)
+		require.Equal(t, oid, parsed)
+	}
+
+	// compact form is shorter for typical object IDs.
+	oid := ID("IDxd41d8cd98f00b204e9800998ecf8427ed41d8cd98f00b204e9800998ecf8427e")
+	require.Less(t, len(oid.CompactString()), len(oid))
+}
+
+func TestCompactObjectIDFallback(t *testing.T) {
+	// IDs that can't be represented losslessly keep their textual form, which still parses.
+	for _, oid := range []ID{
+		"DF0F0",
+		ID(strings.Repeat("I", 256) + "Df0f0"),
+	} {
+		require.Equal(t, string(oid), oid.CompactString())
+
+		parsed, err := ParseID(oid.CompactString())
+		require.NoError(t, err)
+		require.Equal(t, oid, parsed)
+	}
+}
+
+func TestParseInvalidCompactObjectID(t *testing.T) {
+	for _, s := range []string{
+		"_",
+		"_!!!",
+		"_AAA",
+		"_AwDw8A",
+		"_VADw8A",
+	} {
+		_, err := ParseID(s)
+		require.Error(t, err, s)
+	}
+}
@@ -72,6 +72,8 @@ type Options struct {
 	TimeNowFunc        func() time.Time // Time provider
 	DisableInternalLog bool             // Disable internal log
 	UpgradeOwnerID     string           // Owner-ID of any upgrade in progress, when this is not set the access may be restricted
+
+	OnIndexFetchProgress func(downloaded, total int) // Reports progress of downloading index blobs
 }
 
 // ErrInvalidPassword is returned when repository password is invalid.
@@ -235,8 +237,9 @@ func readAndCacheRepoConfig(ctx context.Context, st blob.Storage, password strin
 func openWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, password string, options *Options, cacheOpts *content.CachingOptions, configFile string) (DirectRepository, error) {
 	cacheOpts = cacheOpts.CloneOrDefault()
 	cmOpts := &content.ManagerOptions{
-		TimeNow:            defaultTime(options.TimeNowFunc),
-		DisableInternalLog: options.DisableInternalLog,
+		TimeNow:              defaultTime(options.TimeNowFunc),
+		DisableInternalLog:   options.DisableInternalLog,
+		OnIndexFetchProgress: options.OnIndexFetchProgress,
 	}
 
 	var ufb *unpackedFormatBlob
@@ -110,6 +110,10 @@ func LoadSnapshot(ctx context.Context, rep repo.Repository, manifestID manifest.
 
 // SaveSnapshot persists given snapshot manifest and returns manifest ID.
 func SaveSnapshot(ctx context.Context, rep repo.RepositoryWriter, man *Manifest) (manifest.ID, error) {
+	if man.DryRun {
+		return "", errors.New("dry-run snapshot can't be saved")
+	}
+
 	if man.Source.Host == "" {
 		return "", errors.New("missing host")
 	}
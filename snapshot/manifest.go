@@ -26,6 +26,13 @@ type Manifest struct {
 	Stats            Stats  `json:"stats,omitempty"`
 	IncompleteReason string `json:"incomplete,omitempty"`
 
+	// ResumeFingerprint identifies the policies and uploader settings used to create a checkpoint,
+	// whose complete directories are only reused by snapshots created using the same ones.
+	ResumeFingerprint string `json:"resumeFingerprint,omitempty"`
+
+	// DryRun is set on manifests produced without writing any data, which must never be saved.
+	DryRun bool `json:"dryRun,omitempty"`
+
 	RootEntry *DirEntry `json:"rootEntry"`
 
 	RetentionReasons []string `json:"-"`
@@ -121,15 +128,18 @@ func (p *Permissions) UnmarshalJSON(b []byte) error {
 
 // DirEntry represents a directory entry as stored in JSON stream.
 type DirEntry struct {
-	Name        string               `json:"name,omitempty"`
-	Type        EntryType            `json:"type,omitempty"`
-	Permissions Permissions          `json:"mode,omitempty"`
-	FileSize    int64                `json:"size,omitempty"`
-	ModTime     time.Time            `json:"mtime,omitempty"`
-	UserID      uint32               `json:"uid,omitempty"`
-	GroupID     uint32               `json:"gid,omitempty"`
-	ObjectID    object.ID            `json:"obj,omitempty"`
-	DirSummary  *fs.DirectorySummary `json:"summ,omitempty"`
+	Name         string               `json:"name,omitempty"`
+	Type         EntryType            `json:"type,omitempty"`
+	Permissions  Permissions          `json:"mode,omitempty"`
+	FileSize     int64                `json:"size,omitempty"`
+	PackedSize   int64                `json:"packed,omitempty"`
+	ModTime      time.Time            `json:"mtime,omitempty"`
+	CreationTime *time.Time           `json:"btime,omitempty"`
+	UserID       uint32               `json:"uid,omitempty"`
+	GroupID      uint32               `json:"gid,omitempty"`
+	ObjectID     object.ID            `json:"obj,omitempty"`
+	DirSummary   *fs.DirectorySummary `json:"summ,omitempty"`
+	Xattrs       map[string][]byte    `json:"xattrs,omitempty"`
 }
 
 // HasDirEntry is implemented by objects that have a DirEntry associated with them.
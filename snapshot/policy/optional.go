@@ -31,3 +31,15 @@ func (b *OptionalInt) OrDefault(def int) int {
 func newOptionalInt(b OptionalInt) *OptionalInt {
 	return &b
 }
+
+// OptionalFloat64 provides convenience methods for manipulating optional floating point numbers.
+type OptionalFloat64 float64
+
+// OrDefault returns the value of the number or provided default if it's nil.
+func (b *OptionalFloat64) OrDefault(def float64) float64 {
+	if b == nil {
+		return def
+	}
+
+	return float64(*b)
+}
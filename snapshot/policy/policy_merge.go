@@ -67,6 +67,15 @@ func mergeOptionalInt(target **OptionalInt, src *OptionalInt, def *snapshot.Sour
 	}
 }
 
+func mergeOptionalFloat64(target **OptionalFloat64, src *OptionalFloat64, def *snapshot.SourceInfo, si snapshot.SourceInfo) {
+	if *target == nil && src != nil {
+		v := *src
+
+		*target = &v
+		*def = si
+	}
+}
+
 func mergeStringsReplace(target *[]string, src []string, def *snapshot.SourceInfo, si snapshot.SourceInfo) {
 	if len(*target) == 0 && len(src) > 0 {
 		*target = src
@@ -113,6 +122,13 @@ func mergeCompressionName(target *compression.Name, src compression.Name, def *s
 	}
 }
 
+func mergeString(target *string, src string, def *snapshot.SourceInfo, si snapshot.SourceInfo) {
+	if *target == "" && src != "" {
+		*target = src
+		*def = si
+	}
+}
+
 func mergeInt64(target *int64, src int64, def *snapshot.SourceInfo, si snapshot.SourceInfo) {
 	if *target == 0 && src != 0 {
 		*target = src
@@ -117,10 +117,22 @@ func testPolicyMergeSingleField(t *testing.T, fieldName string, typ reflect.Type
 		v1 = reflect.ValueOf(&ob1)
 		v2 = reflect.ValueOf(&ob2)
 
+	case "*policy.OptionalFloat64":
+		ob1 := policy.OptionalFloat64(1.5)
+		ob2 := policy.OptionalFloat64(7)
+
+		v0 = reflect.ValueOf((*policy.OptionalFloat64)(nil))
+		v1 = reflect.ValueOf(&ob1)
+		v2 = reflect.ValueOf(&ob2)
+
 	case "bool":
 		v0 = reflect.ValueOf(false)
 		v1 = reflect.ValueOf(false)
 		v2 = reflect.ValueOf(true)
+	case "string":
+		v0 = reflect.ValueOf("")
+		v1 = reflect.ValueOf("foo")
+		v2 = reflect.ValueOf("bar")
 	case "int64":
 		v0 = reflect.ValueOf(int64(0))
 		v1 = reflect.ValueOf(int64(1))
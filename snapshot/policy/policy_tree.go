@@ -1,6 +1,12 @@
 package policy
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"sort"
 	"strings"
 )
 
@@ -141,6 +147,35 @@ func (t *Tree) Child(name string) *Tree {
 	}
 }
 
+// Fingerprint returns a hash of the effective policies of all nodes of the tree, which changes whenever
+// any of them changes.
+func (t *Tree) Fingerprint() string {
+	h := sha256.New()
+	t.writeFingerprint(h, ".")
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (t *Tree) writeFingerprint(h hash.Hash, path string) {
+	fmt.Fprintf(h, "%q:", path)
+	json.NewEncoder(h).Encode(t.EffectivePolicy()) // nolint:errcheck,errchkjson
+
+	if t == nil {
+		return
+	}
+
+	var names []string
+	for name := range t.children {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.children[name].writeFingerprint(h, path+"/"+name)
+	}
+}
+
 // BuildTree builds a policy tree from the given map of paths to policies.
 // Each path must be relative and start with "." and be separated by slashes.
 func BuildTree(defined map[string]*Policy, defaultPolicy *Policy) *Tree {
@@ -101,6 +101,32 @@ func TestBuildTree(t *testing.T) {
 	verifyTreePolicy(t, n, "bar/baz/bleh/./././x", policyC, true)
 }
 
+func TestTreeFingerprint(t *testing.T) {
+	build := func(defined map[string]*Policy) string {
+		return BuildTree(defined, defPolicy).Fingerprint()
+	}
+
+	base := build(map[string]*Policy{".": policyA, "./foo": policyB, "./foo/bar": policyC})
+
+	if got := build(map[string]*Policy{".": policyA, "./foo": policyB, "./foo/bar": policyC}); got != base {
+		t.Errorf("fingerprint of identical tree differs: %v, want %v", got, base)
+	}
+
+	for _, defined := range []map[string]*Policy{
+		{".": policyA, "./foo": policyB, "./foo/bar": policyB},
+		{".": policyA, "./foo": policyB, "./foo/baz": policyC},
+		{".": policyA, "./foo": policyB},
+	} {
+		if got := build(defined); got == base {
+			t.Errorf("fingerprint of different tree %v did not change", defined)
+		}
+	}
+
+	if (*Tree)(nil).Fingerprint() != BuildTree(nil, DefaultPolicy).Fingerprint() {
+		t.Errorf("fingerprint of nil tree differs from tree with default policy")
+	}
+}
+
 func verifyTreePolicy(t *testing.T, n *Tree, path string, wantPolicy *Policy, wantInherited bool) {
 	t.Helper()
 
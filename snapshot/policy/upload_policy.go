@@ -3,25 +3,44 @@
 import (
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/repo/splitter"
 	"github.com/kopia/kopia/snapshot"
 )
 
+// Supported values of UploadPolicy.Symlinks.
+const (
+	// SymlinksStore stores symbolic links as links to their targets, which is the default.
+	SymlinksStore = "store"
+
+	// SymlinksFollow stores files and directories which symbolic links point to under the names of the links.
+	SymlinksFollow = "follow"
+)
+
 // UploadPolicy describes policy to apply when uploading snapshots.
 type UploadPolicy struct {
-	MaxParallelSnapshots *OptionalInt `json:"maxParallelSnapshots,omitempty"`
-	MaxParallelFileReads *OptionalInt `json:"maxParallelFileReads,omitempty"`
+	MaxParallelSnapshots *OptionalInt     `json:"maxParallelSnapshots,omitempty"`
+	MaxParallelFileReads *OptionalInt     `json:"maxParallelFileReads,omitempty"`
+	ForceHashPercentage  *OptionalFloat64 `json:"forceHashPercentage,omitempty"`
+	Splitter             string           `json:"splitter,omitempty"` // name of a supported splitter, which determines chunk sizes
+	Symlinks             string           `json:"symlinks,omitempty"`
 }
 
 // UploadPolicyDefinition specifies which policy definition provided the value of a particular field.
 type UploadPolicyDefinition struct {
 	MaxParallelSnapshots snapshot.SourceInfo `json:"maxParallelSnapshots,omitempty"`
 	MaxParallelFileReads snapshot.SourceInfo `json:"maxParallelFileReads,omitempty"`
+	ForceHashPercentage  snapshot.SourceInfo `json:"forceHashPercentage,omitempty"`
+	Splitter             snapshot.SourceInfo `json:"splitter,omitempty"`
+	Symlinks             snapshot.SourceInfo `json:"symlinks,omitempty"`
 }
 
 // Merge applies default values from the provided policy.
 func (p *UploadPolicy) Merge(src UploadPolicy, def *UploadPolicyDefinition, si snapshot.SourceInfo) {
 	mergeOptionalInt(&p.MaxParallelSnapshots, src.MaxParallelSnapshots, &def.MaxParallelSnapshots, si)
 	mergeOptionalInt(&p.MaxParallelFileReads, src.MaxParallelFileReads, &def.MaxParallelFileReads, si)
+	mergeOptionalFloat64(&p.ForceHashPercentage, src.ForceHashPercentage, &def.ForceHashPercentage, si)
+	mergeString(&p.Splitter, src.Splitter, &def.Splitter, si)
+	mergeString(&p.Symlinks, src.Symlinks, &def.Symlinks, si)
 }
 
 // ValidateUploadPolicy returns an error if manual field is set along with Upload fields.
@@ -30,5 +49,19 @@ func ValidateUploadPolicy(si snapshot.SourceInfo, p UploadPolicy) error {
 		return errors.Errorf("max parallel snapshots cannot be specified for paths, only global, username@hostname or @hostname")
 	}
 
+	if v := p.ForceHashPercentage; v != nil && (*v < 0 || *v > 100) {
+		return errors.Errorf("force hash percentage must be between 0 and 100")
+	}
+
+	if p.Splitter != "" && splitter.GetFactory(p.Splitter) == nil {
+		return errors.Errorf("unsupported splitter %q", p.Splitter)
+	}
+
+	switch p.Symlinks {
+	case "", SymlinksStore, SymlinksFollow:
+	default:
+		return errors.Errorf("unsupported symlink mode %q, must be %q or %q", p.Symlinks, SymlinksStore, SymlinksFollow)
+	}
+
 	return nil
 }
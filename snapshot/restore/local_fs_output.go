@@ -81,7 +81,7 @@ func (o *FilesystemOutput) BeginDirectory(ctx context.Context, relativePath stri
 // FinishDirectory implements restore.Output interface.
 func (o *FilesystemOutput) FinishDirectory(ctx context.Context, relativePath string, e fs.Directory) error {
 	path := filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))
-	if err := o.setAttributes(path, e, os.FileMode(0)); err != nil {
+	if err := o.setAttributes(ctx, path, e, os.FileMode(0)); err != nil {
 		return errors.Wrap(err, "error setting attributes")
 	}
 
@@ -107,7 +107,7 @@ func (o *FilesystemOutput) WriteFile(ctx context.Context, relativePath string, f
 		return errors.Wrap(err, "error creating file")
 	}
 
-	if err := o.setAttributes(path, f, os.FileMode(0)); err != nil {
+	if err := o.setAttributes(ctx, path, f, os.FileMode(0)); err != nil {
 		return errors.Wrap(err, "error setting attributes")
 	}
 
@@ -172,7 +172,7 @@ func (o *FilesystemOutput) CreateSymlink(ctx context.Context, relativePath strin
 		return errors.Wrap(err, "error creating symlink")
 	}
 
-	if err := o.setAttributes(path, e, os.FileMode(0)); err != nil {
+	if err := o.setAttributes(ctx, path, e, os.FileMode(0)); err != nil {
 		return errors.Wrap(err, "error setting attributes")
 	}
 
@@ -193,10 +193,10 @@ func (o *FilesystemOutput) SymlinkExists(ctx context.Context, relativePath strin
 	return (st.Mode() & os.ModeType) == os.ModeSymlink
 }
 
-// setAttributes sets permission, modification time and user/group ids
+// setAttributes sets permission, modification time, creation time and user/group ids
 // on targetPath. modclear will clear the specified FileMod bits. Pass 0
 // to not clear any.
-func (o *FilesystemOutput) setAttributes(targetPath string, e fs.Entry, modclear os.FileMode) error {
+func (o *FilesystemOutput) setAttributes(ctx context.Context, targetPath string, e fs.Entry, modclear os.FileMode) error {
 	le, err := localfs.NewEntry(targetPath)
 	if err != nil {
 		return errors.Wrap(err, "could not create local FS entry for "+targetPath)
@@ -237,6 +237,12 @@ func (o *FilesystemOutput) setAttributes(targetPath string, e fs.Entry, modclear
 		}
 	}
 
+	if ct := o.creationTimeToRestore(ctx, le, e); !ct.IsZero() {
+		if err = o.maybeIgnorePermissionError(setCreationTime(targetPath, ct)); err != nil {
+			return errors.Wrap(err, "could not change creation time on "+targetPath)
+		}
+	}
+
 	return nil
 }
 
@@ -281,6 +287,32 @@ func (o *FilesystemOutput) shouldUpdateTimes(local, remote fs.Entry) bool {
 	return !local.ModTime().Equal(remote.ModTime())
 }
 
+// creationTimeToRestore returns the creation time of the remote entry if it differs from the local one
+// or zero time if it's unknown or doesn't need to be restored.
+func (o *FilesystemOutput) creationTimeToRestore(ctx context.Context, local, remote fs.Entry) time.Time {
+	if o.SkipTimes || !canSetCreationTime {
+		return time.Time{}
+	}
+
+	rc, ok := remote.(fs.HasCreationTime)
+	if !ok {
+		return time.Time{}
+	}
+
+	rt, err := rc.CreationTime(ctx)
+	if err != nil || rt.IsZero() {
+		return time.Time{}
+	}
+
+	if lc, ok := local.(fs.HasCreationTime); ok {
+		if lt, lerr := lc.CreationTime(ctx); lerr == nil && lt.Equal(rt) {
+			return time.Time{}
+		}
+	}
+
+	return rt
+}
+
 func isWindows() bool {
 	return runtime.GOOS == "windows"
 }
@@ -27,3 +27,10 @@ func symlinkChtimes(linkPath string, atime, mtime time.Time) error {
 		unix.NsecToTimeval(mtime.UnixNano()),
 	})
 }
+
+// creation time can't be changed on this platform.
+const canSetCreationTime = false
+
+func setCreationTime(path string, btime time.Time) error {
+	return nil
+}
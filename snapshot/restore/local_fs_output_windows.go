@@ -43,3 +43,31 @@ func symlinkChtimes(linkPath string, atime, mtime time.Time) error {
 	// nolint:wrapcheck
 	return windows.SetFileTime(h, &ftw, &fta, &ftw)
 }
+
+const canSetCreationTime = true
+
+func setCreationTime(path string, btime time.Time) error {
+	ftc := windows.NsecToFiletime(btime.UnixNano())
+
+	path = atomicfile.MaybePrefixLongFilenameOnWindows(path)
+
+	fn, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return errors.Wrap(err, "UTF16PtrFromString")
+	}
+
+	// FILE_FLAG_BACKUP_SEMANTICS is required to open directories.
+	h, err := windows.CreateFile(
+		fn, windows.FILE_WRITE_ATTRIBUTES,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
+		nil, windows.OPEN_EXISTING,
+		windows.FILE_FLAG_OPEN_REPARSE_POINT|windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return errors.Wrapf(err, "CreateFile error on %v", path)
+	}
+
+	defer windows.CloseHandle(h) //nolint:errcheck
+
+	// nolint:wrapcheck
+	return windows.SetFileTime(h, &ftc, nil, nil)
+}
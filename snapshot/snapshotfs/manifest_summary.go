@@ -0,0 +1,118 @@
+package snapshotfs
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// ManifestSummaryVersion is the version of the schema of summaries produced by ManifestSummaryJSON.
+const ManifestSummaryVersion = 1
+
+// ManifestSummary is a stable, machine-readable summary of the outcome of a snapshot.
+type ManifestSummary struct {
+	Version int `json:"v"`
+
+	ID               manifest.ID         `json:"id,omitempty"`
+	Source           snapshot.SourceInfo `json:"source"`
+	Description      string              `json:"description,omitempty"`
+	StartTime        time.Time           `json:"startTime"`
+	EndTime          time.Time           `json:"endTime"`
+	DurationSeconds  float64             `json:"durationSeconds"`
+	RootObjectID     object.ID           `json:"rootID,omitempty"`
+	IncompleteReason string              `json:"incomplete,omitempty"`
+	DryRun           bool                `json:"dryRun,omitempty"`
+
+	// counts of files and directories processed by the uploader.
+	FileCount           int32 `json:"fileCount"`
+	DirCount            int32 `json:"dirCount"`
+	CachedFiles         int32 `json:"cachedFiles"`
+	NonCachedFiles      int32 `json:"nonCachedFiles"`
+	ExcludedFileCount   int32 `json:"excludedFileCount"`
+	ExcludedDirCount    int32 `json:"excludedDirCount"`
+	TotalFileSize       int64 `json:"totalSize"`
+	ExcludedTotalSize   int64 `json:"excludedTotalSize"`
+	NewContentBytes     int64 `json:"newContentBytes"`
+	UnknownContentBytes int64 `json:"unknownContentBytes"`
+	ErrorCount          int32 `json:"errorCount"`
+	IgnoredErrorCount   int32 `json:"ignoredErrorCount"`
+	SkippedSpecialCount int32 `json:"skippedSpecialCount"`
+
+	// totals from the directory summary of the root entry, nil if it has none.
+	Root *ManifestRootSummary `json:"root,omitempty"`
+}
+
+// ManifestRootSummary contains totals of the root directory of a snapshot.
+type ManifestRootSummary struct {
+	TotalFileSize     int64     `json:"size"`
+	TotalPackedSize   int64     `json:"packedSize"`
+	TotalFileCount    int64     `json:"files"`
+	TotalSymlinkCount int64     `json:"symlinks"`
+	TotalDirCount     int64     `json:"dirs"`
+	MaxModTime        time.Time `json:"maxTime"`
+	FatalErrorCount   int       `json:"numFailed"`
+	IgnoredErrorCount int       `json:"numIgnoredErrors"`
+}
+
+// NewManifestSummary returns the summary of the provided snapshot manifest.
+func NewManifestSummary(m *snapshot.Manifest) *ManifestSummary {
+	s := &ManifestSummary{
+		Version:          ManifestSummaryVersion,
+		ID:               m.ID,
+		Source:           m.Source,
+		Description:      m.Description,
+		StartTime:        m.StartTime,
+		EndTime:          m.EndTime,
+		DurationSeconds:  m.EndTime.Sub(m.StartTime).Seconds(),
+		IncompleteReason: m.IncompleteReason,
+		DryRun:           m.DryRun,
+
+		FileCount:           m.Stats.TotalFileCount,
+		DirCount:            m.Stats.TotalDirectoryCount,
+		CachedFiles:         m.Stats.CachedFiles,
+		NonCachedFiles:      m.Stats.NonCachedFiles,
+		ExcludedFileCount:   m.Stats.ExcludedFileCount,
+		ExcludedDirCount:    m.Stats.ExcludedDirCount,
+		TotalFileSize:       m.Stats.TotalFileSize,
+		ExcludedTotalSize:   m.Stats.ExcludedTotalFileSize,
+		NewContentBytes:     m.Stats.NewContentBytes,
+		UnknownContentBytes: m.Stats.UnknownContentBytes,
+		ErrorCount:          m.Stats.ErrorCount,
+		IgnoredErrorCount:   m.Stats.IgnoredErrorCount,
+		SkippedSpecialCount: m.Stats.SkippedSpecialCount,
+	}
+
+	if m.RootEntry != nil {
+		s.RootObjectID = m.RootEntry.ObjectID
+
+		if ds := m.RootEntry.DirSummary; ds != nil {
+			s.Root = &ManifestRootSummary{
+				TotalFileSize:     ds.TotalFileSize,
+				TotalPackedSize:   ds.TotalPackedSize,
+				TotalFileCount:    ds.TotalFileCount,
+				TotalSymlinkCount: ds.TotalSymlinkCount,
+				TotalDirCount:     ds.TotalDirCount,
+				MaxModTime:        ds.MaxModTime,
+				FatalErrorCount:   ds.FatalErrorCount,
+				IgnoredErrorCount: ds.IgnoredErrorCount,
+			}
+		}
+	}
+
+	return s
+}
+
+// ManifestSummaryJSON returns the summary of the provided snapshot manifest as JSON.
+func ManifestSummaryJSON(m *snapshot.Manifest) ([]byte, error) {
+	b, err := json.Marshal(NewManifestSummary(m))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal manifest summary")
+	}
+
+	return b, nil
+}
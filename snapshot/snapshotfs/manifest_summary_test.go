@@ -0,0 +1,73 @@
+package snapshotfs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestManifestSummaryJSON(t *testing.T) {
+	startTime := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	m := &snapshot.Manifest{
+		ID:               "some-id",
+		Source:           snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"},
+		StartTime:        startTime,
+		EndTime:          startTime.Add(90 * time.Second),
+		IncompleteReason: IncompleteReasonCanceled,
+		Stats: snapshot.Stats{
+			TotalFileCount:    10,
+			CachedFiles:       7,
+			NonCachedFiles:    3,
+			IgnoredErrorCount: 1,
+			ErrorCount:        2,
+		},
+		RootEntry: &snapshot.DirEntry{
+			ObjectID: "k123",
+			DirSummary: &fs.DirectorySummary{
+				TotalFileSize:     1000,
+				TotalFileCount:    10,
+				TotalDirCount:     2,
+				FatalErrorCount:   2,
+				IgnoredErrorCount: 1,
+			},
+		},
+	}
+
+	b, err := ManifestSummaryJSON(m)
+	require.NoError(t, err)
+
+	var s map[string]interface{}
+
+	require.NoError(t, json.Unmarshal(b, &s))
+	require.Equal(t, float64(ManifestSummaryVersion), s["v"])
+	require.Equal(t, "some-id", s["id"])
+	require.Equal(t, "k123", s["rootID"])
+	require.Equal(t, float64(90), s["durationSeconds"])
+	require.Equal(t, IncompleteReasonCanceled, s["incomplete"])
+	require.Equal(t, float64(10), s["fileCount"])
+	require.Equal(t, float64(7), s["cachedFiles"])
+	require.Equal(t, float64(2), s["errorCount"])
+	require.Equal(t, float64(1), s["ignoredErrorCount"])
+
+	root := s["root"].(map[string]interface{})
+	require.Equal(t, float64(1000), root["size"])
+	require.Equal(t, float64(2), root["dirs"])
+	require.Equal(t, float64(2), root["numFailed"])
+	require.Equal(t, float64(1), root["numIgnoredErrors"])
+
+	// manifests without root entries are summarized too.
+	b, err = ManifestSummaryJSON(&snapshot.Manifest{})
+	require.NoError(t, err)
+
+	var s2 map[string]interface{}
+
+	require.NoError(t, json.Unmarshal(b, &s2))
+	require.NotContains(t, s2, "root")
+	require.NotContains(t, s2, "rootID")
+}
@@ -75,6 +75,20 @@ func (e *repositoryEntry) Device() fs.DeviceInfo {
 	return fs.DeviceInfo{}
 }
 
+// Xattrs implements fs.HasXattrs.
+func (e *repositoryEntry) Xattrs(ctx context.Context) (map[string][]byte, error) {
+	return e.metadata.Xattrs, nil
+}
+
+// CreationTime implements fs.HasCreationTime.
+func (e *repositoryEntry) CreationTime(ctx context.Context) (time.Time, error) {
+	if e.metadata.CreationTime == nil {
+		return time.Time{}, nil
+	}
+
+	return *e.metadata.CreationTime, nil
+}
+
 func (e *repositoryEntry) DirEntry() *snapshot.DirEntry {
 	return e.metadata
 }
@@ -2,19 +2,30 @@
 
 import (
 	"context"
+	"io"
 	"path"
 	"runtime"
 	"sync"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/iocopy"
 	"github.com/kopia/kopia/internal/workshare"
 	"github.com/kopia/kopia/repo/object"
 )
 
 const walkersPerCPU = 4
 
+var (
+	// ErrMaxDepthExceeded is returned when the tree is deeper than TreeWalkerOptions.MaxDepth.
+	ErrMaxDepthExceeded = errors.New("maximum tree depth exceeded")
+
+	// ErrMaxVisitedExceeded is returned when the tree has more unique objects than TreeWalkerOptions.MaxVisited.
+	ErrMaxVisitedExceeded = errors.New("maximum number of visited objects exceeded")
+)
+
 // EntryCallback is invoked when walking the tree of snapshots.
 type EntryCallback func(ctx context.Context, entry fs.Entry, oid object.ID, entryPath string) error
 
@@ -26,6 +37,11 @@ type TreeWalker struct {
 	enqueued sync.Map
 	wp       *workshare.Pool
 
+	// +checkatomic
+	numVisited int64
+
+	visitedLimitReported sync.Once
+
 	mu sync.Mutex
 	// +checklocks:mu
 	numErrors int
@@ -48,7 +64,8 @@ func (w *TreeWalker) ReportError(ctx context.Context, entryPath string, err erro
 
 	repoFSLog(ctx).Errorf("error processing %v: %v", entryPath, err)
 
-	if len(w.errors) < w.options.MaxErrors {
+	// the first error is always retained, so that Err() can return it even when the number of errors is unlimited.
+	if len(w.errors) == 0 || len(w.errors) < w.options.MaxErrors {
 		w.errors = append(w.errors, err)
 	}
 
@@ -85,10 +102,27 @@ func (w *TreeWalker) TooManyErrors() bool {
 
 func (w *TreeWalker) alreadyProcessed(e fs.Entry) bool {
 	_, existing := w.enqueued.LoadOrStore(oidOf(e), struct{}{})
+	if !existing {
+		atomic.AddInt64(&w.numVisited, 1)
+	}
+
 	return existing
 }
 
-func (w *TreeWalker) processEntry(ctx context.Context, e fs.Entry, entryPath string) {
+// tooManyVisited reports the error and returns true if more than MaxVisited unique objects have been visited.
+func (w *TreeWalker) tooManyVisited(ctx context.Context, entryPath string) bool {
+	if w.options.MaxVisited <= 0 || atomic.LoadInt64(&w.numVisited) <= int64(w.options.MaxVisited) {
+		return false
+	}
+
+	w.visitedLimitReported.Do(func() {
+		w.ReportError(ctx, entryPath, errors.Wrapf(ErrMaxVisitedExceeded, "visited more than %v objects", w.options.MaxVisited))
+	})
+
+	return true
+}
+
+func (w *TreeWalker) processEntry(ctx context.Context, e fs.Entry, entryPath string, depth int) {
 	if ec := w.options.EntryCallback; ec != nil {
 		err := ec(ctx, e, oidOf(e), entryPath)
 		if err != nil {
@@ -97,12 +131,19 @@ func (w *TreeWalker) processEntry(ctx context.Context, e fs.Entry, entryPath str
 		}
 	}
 
+	if f, ok := e.(fs.File); ok && w.options.VerifyContents {
+		if err := readEntireFile(ctx, f); err != nil {
+			w.ReportError(ctx, entryPath, errors.Wrapf(err, "error reading %v", oidOf(e)))
+			return
+		}
+	}
+
 	if dir, ok := e.(fs.Directory); ok {
-		w.processDirEntry(ctx, dir, entryPath)
+		w.processDirEntry(ctx, dir, entryPath, depth)
 	}
 }
 
-func (w *TreeWalker) processDirEntry(ctx context.Context, dir fs.Directory, entryPath string) {
+func (w *TreeWalker) processDirEntry(ctx context.Context, dir fs.Directory, entryPath string, depth int) {
 	var ag workshare.AsyncGroup
 	defer ag.Wait()
 
@@ -112,6 +153,11 @@ func (w *TreeWalker) processDirEntry(ctx context.Context, dir fs.Directory, entr
 		return
 	}
 
+	if len(entries) > 0 && w.options.MaxDepth > 0 && depth >= w.options.MaxDepth {
+		w.ReportError(ctx, entryPath, errors.Wrapf(ErrMaxDepthExceeded, "directory at depth %v has entries", depth))
+		return
+	}
+
 	for _, ent := range entries {
 		ent := ent
 
@@ -125,16 +171,31 @@ func (w *TreeWalker) processDirEntry(ctx context.Context, dir fs.Directory, entr
 
 		childPath := path.Join(entryPath, ent.Name())
 
+		if w.tooManyVisited(ctx, childPath) {
+			break
+		}
+
 		if ag.CanShareWork(w.wp) {
 			ag.RunAsync(w.wp, func(c *workshare.Pool, request interface{}) {
-				w.processEntry(ctx, ent, childPath)
+				w.processEntry(ctx, ent, childPath, depth+1)
 			}, nil)
 		} else {
-			w.processEntry(ctx, ent, childPath)
+			w.processEntry(ctx, ent, childPath, depth+1)
 		}
 	}
 }
 
+// readEntireFile reads the contents of the provided file to ensure that all of its data can be retrieved.
+func readEntireFile(ctx context.Context, f fs.File) error {
+	r, err := f.Open(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to open file")
+	}
+	defer r.Close() //nolint:errcheck
+
+	return errors.Wrap(iocopy.JustCopy(io.Discard, r), "unable to read data")
+}
+
 // Process processes the snapshot tree entry.
 func (w *TreeWalker) Process(ctx context.Context, e fs.Entry, entryPath string) error {
 	if oidOf(e) == "" {
@@ -145,7 +206,11 @@ func (w *TreeWalker) Process(ctx context.Context, e fs.Entry, entryPath string)
 		return nil
 	}
 
-	w.processEntry(ctx, e, entryPath)
+	if w.tooManyVisited(ctx, entryPath) {
+		return w.Err()
+	}
+
+	w.processEntry(ctx, e, entryPath, 0)
 
 	return w.Err()
 }
@@ -160,7 +225,21 @@ type TreeWalkerOptions struct {
 	EntryCallback EntryCallback
 
 	Parallelism int
-	MaxErrors   int
+
+	// MaxErrors is the number of errors after which the walker stops processing entries,
+	// zero means 1 and a negative value means unlimited.
+	MaxErrors int
+
+	// MaxDepth is the maximum depth of entries below the root entry passed to Process(), zero means unlimited.
+	MaxDepth int
+
+	// MaxVisited is the maximum number of unique objects visited by the walker, zero means unlimited.
+	MaxVisited int
+
+	// VerifyContents causes the walker to read each file entirely after invoking EntryCallback, which detects
+	// data that can't be retrieved, such as contents in missing pack blobs. Files are read in parallel
+	// according to Parallelism.
+	VerifyContents bool
 }
 
 // NewTreeWalker creates new tree walker.
@@ -11,6 +11,7 @@
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/internal/mockfs"
 	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
@@ -167,3 +168,137 @@ func TestSnapshotTreeWalker_MultipleErrors(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, "encountered 2 errors", err.Error())
 }
+
+func TestSnapshotTreeWalker_SingleErrorUnlimited(t *testing.T) {
+	someErr1 := errors.Errorf("some error")
+
+	w, err := snapshotfs.NewTreeWalker(
+		snapshotfs.TreeWalkerOptions{
+			Parallelism: 1,
+			MaxErrors:   -1,
+			EntryCallback: func(ctx context.Context, entry fs.Entry, oid object.ID, entryPath string) error {
+				if entryPath == "root-dir/dir2/file21" {
+					return someErr1
+				}
+
+				return nil
+			},
+		})
+	require.NoError(t, err)
+
+	defer w.Close()
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	sourceRoot := mockfs.NewDirectory()
+	dir1 := sourceRoot.AddDir("dir1", 0o755)
+	dir2 := sourceRoot.AddDir("dir2", 0o755)
+
+	dir1.AddFile("file11", []byte{1, 2, 3}, 0o644)
+	dir2.AddFile("file21", []byte{1, 2, 3, 4}, 0o644)
+
+	u := snapshotfs.NewUploader(env.RepositoryWriter)
+	man, err := u.Upload(ctx, sourceRoot, nil, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	uploadedRoot, err := snapshotfs.SnapshotRoot(env.Repository, man)
+	require.NoError(t, err)
+
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+	require.ErrorIs(t, w.Process(ctx, uploadedRoot, "root-dir"), someErr1)
+}
+
+func TestSnapshotTreeWalker_Limits(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	sourceRoot := mockfs.NewDirectory()
+	sourceRoot.AddDir("a", 0o755).AddDir("b", 0o755).AddFile("f", []byte{1, 2, 3}, 0o644)
+
+	u := snapshotfs.NewUploader(env.RepositoryWriter)
+	man, err := u.Upload(ctx, sourceRoot, nil, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	uploadedRoot, err := snapshotfs.SnapshotRoot(env.Repository, man)
+	require.NoError(t, err)
+
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	cases := []struct {
+		desc          string
+		maxDepth      int
+		maxVisited    int
+		wantErr       error
+		wantCallbacks []string
+	}{
+		{desc: "unlimited", wantCallbacks: []string{"root", "root/a", "root/a/b", "root/a/b/f"}},
+		{desc: "depth at limit", maxDepth: 3, wantCallbacks: []string{"root", "root/a", "root/a/b", "root/a/b/f"}},
+		{desc: "depth exceeded", maxDepth: 2, wantErr: snapshotfs.ErrMaxDepthExceeded, wantCallbacks: []string{"root", "root/a", "root/a/b"}},
+		{desc: "visited at limit", maxVisited: 4, wantCallbacks: []string{"root", "root/a", "root/a/b", "root/a/b/f"}},
+		{desc: "visited exceeded", maxVisited: 2, wantErr: snapshotfs.ErrMaxVisitedExceeded, wantCallbacks: []string{"root", "root/a"}},
+	}
+
+	for _, tc := range cases {
+		var callbacks []string
+
+		w, err := snapshotfs.NewTreeWalker(
+			snapshotfs.TreeWalkerOptions{
+				Parallelism: 1,
+				MaxDepth:    tc.maxDepth,
+				MaxVisited:  tc.maxVisited,
+				EntryCallback: func(ctx context.Context, entry fs.Entry, oid object.ID, entryPath string) error {
+					callbacks = append(callbacks, entryPath)
+					return nil
+				},
+			})
+		require.NoError(t, err)
+
+		err = w.Process(ctx, uploadedRoot, "root")
+		w.Close()
+
+		if tc.wantErr != nil {
+			require.ErrorIs(t, err, tc.wantErr, tc.desc)
+		} else {
+			require.NoError(t, err, tc.desc)
+		}
+
+		require.Equal(t, tc.wantCallbacks, callbacks, tc.desc)
+	}
+}
+
+func TestSnapshotTreeWalker_VerifyContents(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	sourceRoot := mockfs.NewDirectory()
+	sourceRoot.AddDir("dir1", 0o755).AddFile("file11", []byte{1, 2, 3}, 0o644)
+
+	u := snapshotfs.NewUploader(env.RepositoryWriter)
+	man, err := u.Upload(ctx, sourceRoot, nil, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	uploadedRoot, err := snapshotfs.SnapshotRoot(env.Repository, man)
+	require.NoError(t, err)
+
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	process := func(verifyContents bool) error {
+		w, err := snapshotfs.NewTreeWalker(snapshotfs.TreeWalkerOptions{
+			VerifyContents: verifyContents,
+		})
+		require.NoError(t, err)
+
+		defer w.Close()
+
+		return w.Process(ctx, uploadedRoot, "root")
+	}
+
+	require.NoError(t, process(true))
+
+	// delete pack blobs with file data, directories are stored in separate packs.
+	require.NoError(t, env.RootStorage().ListBlobs(ctx, "p", func(bm blob.Metadata) error {
+		return env.RootStorage().DeleteBlob(ctx, bm.BlobID)
+	}))
+
+	// missing data is only detected when files are read.
+	require.NoError(t, process(false))
+	require.Error(t, process(true))
+}
@@ -9,17 +9,18 @@
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"sort"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/fs/ignorefs"
-	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/iocopy"
 	"github.com/kopia/kopia/internal/timetrack"
 	"github.com/kopia/kopia/internal/workshare"
@@ -33,6 +34,9 @@
 // DefaultCheckpointInterval is the default frequency of mid-upload checkpointing.
 const DefaultCheckpointInterval = 45 * time.Minute
 
+// DefaultFileReadRetryInterval is the default delay before the first retry of a failed file read.
+const DefaultFileReadRetryInterval = 1 * time.Second
+
 var (
 	uploadLog   = logging.Module("uploader")
 	estimateLog = logging.Module("estimate")
@@ -52,9 +56,11 @@
 
 // reasons why a snapshot is incomplete.
 const (
-	IncompleteReasonCheckpoint   = "checkpoint"
-	IncompleteReasonCanceled     = "canceled"
-	IncompleteReasonLimitReached = "limit reached"
+	IncompleteReasonCheckpoint    = "checkpoint"
+	IncompleteReasonCanceled      = "canceled"
+	IncompleteReasonLimitReached  = "limit reached"
+	IncompleteReasonSourceError   = "source error"
+	IncompleteReasonTooManyErrors = "too many errors"
 )
 
 // Uploader supports efficient uploading files and directories to repository.
@@ -62,20 +68,59 @@ type Uploader struct {
 	// values aligned to 8-bytes due to atomic access
 	// +checkatomic
 	totalWrittenBytes int64
+	// +checkatomic
+	bytesSinceCheckpoint int64
+	// +checkatomic
+	lastCheckpointTime int64 // UnixNano
 
 	Progress UploadProgress
 
+	// Minimum time between HashedBytes and CachedFile notifications delivered to Progress, which are
+	// coalesced in the meantime. 0=every event
+	ProgressUpdateInterval time.Duration
+
 	// automatically cancel the Upload after certain number of bytes
 	MaxUploadBytes int64
 
+	// exclude files larger than the specified number of bytes, 0=unlimited
+	// streaming files whose size is not known in advance are not subject to this limit.
+	MaxFileSize int64
+
+	// When set to true, sockets, named pipes and devices are omitted from the snapshot without reporting errors.
+	SkipSpecialFiles bool
+
+	// maximum number of failed entries recorded in each directory summary, 0=fs.MaxFailedEntriesPerDirectorySummary
+	MaxFailedEntriesPerDir int
+
+	// limit the rate of bytes written to the repository, shared across all parallel uploads, 0=unlimited
+	MaxUploadBytesPerSecond int64
+
 	// probability with cached entries will be ignored, must be [0..100]
 	// 0=always use cached object entries if possible
 	// 100=never use cached entries
 	ForceHashPercentage float64
 
+	// HashCache, when set, is consulted for object IDs of files that aren't found in previous snapshots
+	// and is updated with object IDs of uploaded and cached files. ForceHashPercentage applies to its entries too.
+	HashCache HashCache
+
 	// Number of files to hash and upload in parallel.
 	ParallelUploads int
 
+	// When set to true, the number of files uploaded in parallel starts at MinParallelUploads and is
+	// periodically adjusted up to the effective maximum based on the observed upload throughput.
+	AdaptiveParallelism bool
+
+	// Initial number of files to upload in parallel when AdaptiveParallelism is enabled.
+	MinParallelUploads int
+
+	// Interval between adjustments of the number of parallel uploads when AdaptiveParallelism is enabled.
+	ParallelismAdjustmentInterval time.Duration
+
+	// When set to true, files in each directory are scheduled for upload starting with the largest ones.
+	// This only affects the order of uploads, not the contents of directory manifests.
+	LargestFirst bool
+
 	// Enable snapshot actions
 	EnableActions bool
 
@@ -86,22 +131,101 @@ type Uploader struct {
 	// Fail the entire snapshot on source file/directory error.
 	FailFast bool
 
+	// Abort the entire snapshot when the source reports that it's out of space or failing with I/O errors.
+	AbortOnSourceIOError bool
+
+	// Abort the entire snapshot when the number of errors that are not ignored exceeds this value. 0=unlimited
+	MaxErrors int
+
+	// Number of times to retry opening and reading a file after a transient error, 0=no retries.
+	FileReadRetries int
+
+	// Delay before the first retry of a file read, doubled after each subsequent attempt.
+	// Zero or negative uses DefaultFileReadRetryInterval.
+	FileReadRetryInterval time.Duration
+
 	// How frequently to create checkpoint snapshot entries.
 	CheckpointInterval time.Duration
 
+	// Create a checkpoint after the specified number of bytes or uploaded files since the last one,
+	// in addition to CheckpointInterval. 0=disabled
+	CheckpointEveryBytes int64
+	CheckpointEveryFiles int
+
+	// Minimum time between checkpoints triggered by CheckpointEveryBytes or CheckpointEveryFiles.
+	MinCheckpointInterval time.Duration
+
 	// When set to true, do not ignore any files, regardless of policy settings.
 	DisableIgnoreRules bool
 
+	// When set to true, extended attributes of files and directories are stored in directory entries.
+	CaptureXattrs bool
+
+	// When set to true, creation times of files and directories are stored in directory entries
+	// on platforms that provide them.
+	CaptureCreationTime bool
+
+	// When set to true, the reason why each file was uploaded instead of being cached
+	// is reported to Progress and to the uploader log.
+	RecordUploadReasons bool
+
+	// When set to true, counts and sizes of uploaded files are accumulated per file extension
+	// and can be retrieved using ExtensionStats() after Upload returns.
+	RecordExtensionStats bool
+
+	// When set to true, objects written by the uploader carry metadata describing the source, path and type
+	// of their entries, which is emitted in debug logs and can be retrieved using WrittenObjectMetadata()
+	// after Upload returns. Metadata does not affect the objects or deduplication.
+	AttachObjectMetadata bool
+
+	// When set to true, the root object of the snapshot is read back and validated before Upload returns.
+	ValidateManifestAfterUpload bool
+
+	// When set to true, the uploader walks the source and computes statistics and directory summaries
+	// without writing any data to the repository. The resulting manifest has no object IDs and is
+	// marked as a dry run, so it can't be saved.
+	DryRun bool
+
+	// OnFileUploaded, when set, is invoked after each file, symlink or streaming file has been uploaded
+	// and added to its directory. Invocations are serialized, even when files are uploaded in parallel.
+	OnFileUploaded func(relativePath string, de *snapshot.DirEntry)
+
+	// OnCachedFile, when set, is invoked for each file reused from a previous snapshot
+	// (or a hard link to an already uploaded file) instead of being uploaded. Invocations are serialized.
+	OnCachedFile func(relativePath string, de *snapshot.DirEntry)
+
 	repo repo.RepositoryWriter
 
+	// source being uploaded.
+	sourceInfo snapshot.SourceInfo
+
+	// fingerprint of the policies and settings of the upload, see snapshot.Manifest.ResumeFingerprint.
+	resumeFingerprint string
+
 	// stats must be allocated on heap to enforce 64-bit alignment due to atomic access on ARM.
 	stats *snapshot.Stats
 
 	// +checkatomic
 	canceled int32
 
+	// +checkatomic
+	sourceIOError int32
+
+	// +checkatomic
+	tooManyErrors int32
+
+	// +checkatomic
+	filesSinceCheckpoint int32
+
 	getTicker func(time.Duration) <-chan time.Time
 
+	// now returns the current time used for checkpointing and for time stamping entries and manifests,
+	// can be replaced in tests.
+	now func() time.Time
+
+	// pending checkpoint request, buffered to coalesce multiple requests.
+	checkpointRequests chan struct{}
+
 	// for testing only, when set will write to a given channel whenever checkpoint completes
 	checkpointFinished chan struct{}
 
@@ -109,6 +233,24 @@ type Uploader struct {
 	disableEstimation bool
 
 	workerPool *workshare.Pool
+
+	uploadThrottle *uploadThrottle
+
+	// uploadCtx is canceled along with the upload in progress to stop throttling and retry waits.
+	uploadCtx context.Context //nolint:containedctx
+
+	cancelMutex sync.Mutex
+	// +checklocks:cancelMutex
+	cancelUploadCtx context.CancelFunc
+
+	hardLinks *hardLinkTracker
+
+	extStats *extensionStats
+
+	objectMetadataCollector *objectMetadataCollector
+
+	// serializes invocations of OnFileUploaded and OnCachedFile hooks.
+	fileHookMutex sync.Mutex
 }
 
 // IsCanceled returns true if the upload is canceled.
@@ -116,8 +258,25 @@ func (u *Uploader) IsCanceled() bool {
 	return u.incompleteReason() != ""
 }
 
+// maxFailedEntriesPerDir returns the maximum number of failed entries recorded in each directory summary.
+func (u *Uploader) maxFailedEntriesPerDir() int {
+	if u.MaxFailedEntriesPerDir > 0 {
+		return u.MaxFailedEntriesPerDir
+	}
+
+	return fs.MaxFailedEntriesPerDirectorySummary
+}
+
 //
 func (u *Uploader) incompleteReason() string {
+	if atomic.LoadInt32(&u.sourceIOError) != 0 {
+		return IncompleteReasonSourceError
+	}
+
+	if atomic.LoadInt32(&u.tooManyErrors) != 0 {
+		return IncompleteReasonTooManyErrors
+	}
+
 	if c := atomic.LoadInt32(&u.canceled) != 0; c {
 		return IncompleteReasonCanceled
 	}
@@ -150,16 +309,70 @@ func (u *Uploader) uploadFileInternal(ctx context.Context, parentCheckpointRegis
 		}
 	}
 
+	if u.DryRun {
+		de, err := u.dryRunEntry(f, f.Size())
+		if err != nil {
+			return nil, err
+		}
+
+		atomic.AddInt32(&u.stats.TotalFileCount, 1)
+		atomic.AddInt64(&u.stats.TotalFileSize, de.FileSize)
+
+		return de, nil
+	}
+
+	retryInterval := u.FileReadRetryInterval
+	if retryInterval <= 0 {
+		retryInterval = DefaultFileReadRetryInterval
+	}
+
+	for attempt := 0; ; attempt++ {
+		de, written, err := u.uploadFileAttempt(ctx, parentCheckpointRegistry, relativePath, f, pol, asyncWrites)
+		if err == nil {
+			atomic.AddInt32(&u.stats.TotalFileCount, 1)
+			atomic.AddInt64(&u.stats.TotalFileSize, de.FileSize)
+
+			if u.extStats != nil {
+				u.recordExtensionStats(f.Name(), de)
+			}
+
+			return de, nil
+		}
+
+		if attempt >= u.FileReadRetries || !isRetriableFileReadError(err) || u.IsCanceled() {
+			return nil, err
+		}
+
+		uploadLog(ctx).Debugf("error reading %v (attempt %v), retrying in %v: %v", relativePath, attempt+1, retryInterval, err)
+
+		// bytes of the failed attempt will be written again by the next one.
+		u.discardWrittenBytes(written)
+
+		if !u.sleepUnlessCanceled(ctx, retryInterval) {
+			return nil, errors.Wrap(errCanceled, "canceled while retrying file read")
+		}
+
+		retryInterval *= 2
+	}
+}
+
+// uploadFileAttempt makes a single attempt to open the file and write its contents to a new object,
+// returning the number of bytes written, including when the attempt fails.
+func (u *Uploader) uploadFileAttempt(ctx context.Context, parentCheckpointRegistry *checkpointRegistry, relativePath string, f fs.File, pol *policy.Policy, asyncWrites int) (*snapshot.DirEntry, int64, error) {
 	file, err := f.Open(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to open file")
+		return nil, 0, errors.Wrap(fileReadError{err}, "unable to open file")
 	}
 	defer file.Close() //nolint:errcheck
 
+	md := u.objectMetadata(snapshot.EntryTypeFile, relativePath)
+
 	writer := u.repo.NewObjectWriter(ctx, object.WriterOptions{
 		Description: "FILE:" + f.Name(),
 		Compressor:  pol.CompressionPolicy.CompressorForFile(f),
+		Splitter:    pol.UploadPolicy.Splitter,
 		AsyncWrites: asyncWrites,
+		Metadata:    md,
 	})
 	defer writer.Close() //nolint:errcheck
 
@@ -179,32 +392,108 @@ func (u *Uploader) uploadFileInternal(ctx context.Context, parentCheckpointRegis
 
 	defer parentCheckpointRegistry.removeCheckpointCallback(f)
 
-	written, err := u.copyWithProgress(writer, file, 0, f.Size())
+	written, err := u.copyWithProgress(ctx, writer, file, 0, f.Size())
 	if err != nil {
-		return nil, err
+		return nil, written, err
 	}
 
 	fi2, err := file.Entry()
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to get file entry after copying")
+		return nil, written, errors.Wrap(err, "unable to get file entry after copying")
 	}
 
 	r, err := writer.Result()
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to get result")
+		return nil, written, errors.Wrap(err, "unable to get result")
 	}
 
+	u.recordObjectMetadata(r, md)
+
 	de, err := newDirEntry(fi2, r)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to create dir entry")
+		return nil, written, errors.Wrap(err, "unable to create dir entry")
 	}
 
 	de.FileSize = written
+	de.PackedSize = writer.PackedLength()
+	atomic.AddInt64(&u.stats.NewContentBytes, writer.NewPackedLength())
+	atomic.AddInt64(&u.stats.UnknownContentBytes, writer.UnknownPackedLength())
 
-	atomic.AddInt32(&u.stats.TotalFileCount, 1)
-	atomic.AddInt64(&u.stats.TotalFileSize, de.FileSize)
+	return de, written, nil
+}
 
-	return de, nil
+// fileReadError wraps errors encountered when opening or reading source files.
+type fileReadError struct {
+	error
+}
+
+func (e fileReadError) Cause() error  { return e.error }
+func (e fileReadError) Unwrap() error { return e.error }
+
+// isSourceIOError determines whether the error indicates that the source filesystem ran out of space
+// or is failing, as opposed to an error affecting an individual entry.
+func isSourceIOError(err error) bool {
+	var (
+		fre fileReadError
+		dre dirReadError
+	)
+
+	if !errors.As(err, &fre) && !errors.As(err, &dre) {
+		return false
+	}
+
+	rc := rootCauseError(err)
+
+	return errors.Is(rc, syscall.ENOSPC) || errors.Is(rc, syscall.EIO)
+}
+
+// isRetriableFileReadError determines whether the error is a source file read error that may succeed when retried.
+func isRetriableFileReadError(err error) bool {
+	var fre fileReadError
+	if !errors.As(err, &fre) {
+		return false
+	}
+
+	switch {
+	case errors.Is(err, errCanceled), errors.Is(err, os.ErrNotExist), errors.Is(err, os.ErrPermission):
+		return false
+	default:
+		return true
+	}
+}
+
+// discardWrittenBytes reverts accounting of bytes written by copyWithProgress, which are not going to be
+// part of the snapshot.
+func (u *Uploader) discardWrittenBytes(n int64) {
+	if n == 0 {
+		return
+	}
+
+	atomic.AddInt64(&u.totalWrittenBytes, -n)
+	u.checkpointBytesWritten(-n)
+	u.Progress.HashedBytes(-n)
+}
+
+// sleepUnlessCanceled sleeps for the provided duration and returns false if the upload or the context
+// was canceled in the meantime.
+func (u *Uploader) sleepUnlessCanceled(ctx context.Context, d time.Duration) bool {
+	if u.IsCanceled() {
+		return false
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+
+	case <-u.uploadCtx.Done():
+		return false
+
+	case <-t.C:
+		return !u.IsCanceled()
+	}
 }
 
 func (u *Uploader) uploadSymlinkInternal(ctx context.Context, relativePath string, f fs.Symlink) (*snapshot.DirEntry, error) {
@@ -216,12 +505,19 @@ func (u *Uploader) uploadSymlinkInternal(ctx context.Context, relativePath strin
 		return nil, errors.Wrap(err, "unable to read symlink")
 	}
 
+	if u.DryRun {
+		return u.dryRunEntry(f, int64(len(target)))
+	}
+
+	md := u.objectMetadata(snapshot.EntryTypeSymlink, relativePath)
+
 	writer := u.repo.NewObjectWriter(ctx, object.WriterOptions{
 		Description: "SYMLINK:" + f.Name(),
+		Metadata:    md,
 	})
 	defer writer.Close() //nolint:errcheck
 
-	written, err := u.copyWithProgress(writer, bytes.NewBufferString(target), 0, f.Size())
+	written, err := u.copyWithProgress(ctx, writer, bytes.NewBufferString(target), 0, f.Size())
 	if err != nil {
 		return nil, err
 	}
@@ -231,17 +527,45 @@ func (u *Uploader) uploadSymlinkInternal(ctx context.Context, relativePath strin
 		return nil, errors.Wrap(err, "unable to get result")
 	}
 
+	u.recordObjectMetadata(r, md)
+
 	de, err := newDirEntry(f, r)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create dir entry")
 	}
 
 	de.FileSize = written
+	de.PackedSize = writer.PackedLength()
+	atomic.AddInt64(&u.stats.NewContentBytes, writer.NewPackedLength())
+	atomic.AddInt64(&u.stats.UnknownContentBytes, writer.UnknownPackedLength())
 
 	return de, nil
 }
 
-func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath string, f fs.StreamingFile) (*snapshot.DirEntry, error) {
+// partialStreamingFileSuffix is appended to names of entries of streaming files that were not uploaded completely,
+// since unlike regular files, a streaming file can't be identified when the upload is restarted.
+const partialStreamingFileSuffix = ".partial"
+
+// uploadStreamingFileInternal uploads the provided streaming file. When the upload is canceled, returns
+// the partial entry holding the data streamed so far along with the cancelation error.
+func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, parentCheckpointRegistry *checkpointRegistry, relativePath string, f fs.StreamingFile) (*snapshot.DirEntry, error) {
+	if u.DryRun {
+		// do not consume the stream, its size is reported as zero.
+		u.Progress.HashingFile(relativePath)
+		defer u.Progress.FinishedHashingFile(relativePath, 0)
+
+		de, err := u.dryRunEntry(f, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		de.ModTime = u.now()
+
+		atomic.AddInt32(&u.stats.TotalFileCount, 1)
+
+		return de, nil
+	}
+
 	reader, err := f.GetReader(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to get streaming file reader")
@@ -255,12 +579,36 @@ func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath
 		u.Progress.FinishedHashingFile(relativePath, streamSize)
 	}()
 
+	md := u.objectMetadata(snapshot.EntryTypeFile, relativePath)
+
 	writer := u.repo.NewObjectWriter(ctx, object.WriterOptions{
 		Description: "STREAMFILE:" + f.Name(),
+		Metadata:    md,
 	})
 	defer writer.Close() //nolint:errcheck
 
-	written, err := u.copyWithProgress(writer, reader, 0, f.Size())
+	parentCheckpointRegistry.addCheckpointCallback(f, func() (*snapshot.DirEntry, error) {
+		// nolint:govet
+		checkpointID, err := writer.Checkpoint()
+		if err != nil {
+			return nil, errors.Wrap(err, "checkpoint error")
+		}
+
+		if checkpointID == "" {
+			return nil, nil
+		}
+
+		return newPartialStreamingFileEntry(f, checkpointID, f.Size(), u.now())
+	})
+
+	defer parentCheckpointRegistry.removeCheckpointCallback(f)
+
+	written, err := u.copyWithProgress(ctx, writer, reader, 0, f.Size())
+	if errors.Is(err, errCanceled) && written > 0 {
+		// preserve the data streamed so far, since it can't be read again.
+		return u.partialStreamingFileResult(writer, md, f, written, err)
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -270,14 +618,19 @@ func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath
 		return nil, errors.Wrap(err, "unable to get result")
 	}
 
+	u.recordObjectMetadata(r, md)
+
 	de, err := newDirEntry(f, r)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create dir entry")
 	}
 
 	de.FileSize = written
+	de.PackedSize = writer.PackedLength()
+	atomic.AddInt64(&u.stats.NewContentBytes, writer.NewPackedLength())
+	atomic.AddInt64(&u.stats.UnknownContentBytes, writer.UnknownPackedLength())
 	streamSize = written
-	de.ModTime = clock.Now()
+	de.ModTime = u.now()
 
 	atomic.AddInt32(&u.stats.TotalFileCount, 1)
 	atomic.AddInt64(&u.stats.TotalFileSize, de.FileSize)
@@ -285,7 +638,56 @@ func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath
 	return de, nil
 }
 
-func (u *Uploader) copyWithProgress(dst io.Writer, src io.Reader, completed, length int64) (int64, error) {
+// partialStreamingFileResult returns the partial entry holding all data written so far when streaming
+// was canceled, along with the cancelation error.
+func (u *Uploader) partialStreamingFileResult(writer object.Writer, md map[string]string, f fs.StreamingFile, written int64, cancelErr error) (*snapshot.DirEntry, error) {
+	r, err := writer.Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get partial result")
+	}
+
+	u.recordObjectMetadata(r, md)
+
+	de, err := newPartialStreamingFileEntry(f, r, written, u.now())
+	if err != nil {
+		return nil, err
+	}
+
+	de.PackedSize = writer.PackedLength()
+	atomic.AddInt64(&u.stats.NewContentBytes, writer.NewPackedLength())
+	atomic.AddInt64(&u.stats.UnknownContentBytes, writer.UnknownPackedLength())
+
+	return de, cancelErr
+}
+
+// newPartialStreamingFileEntry makes DirEntry for a streaming file that was not uploaded completely.
+func newPartialStreamingFileEntry(f fs.StreamingFile, oid object.ID, size int64, modTime time.Time) (*snapshot.DirEntry, error) {
+	de, err := newDirEntry(f, oid)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create dir entry")
+	}
+
+	de.Name += partialStreamingFileSuffix
+	de.FileSize = size
+	de.ModTime = modTime
+
+	return de, nil
+}
+
+// dryRunEntry returns the directory entry for the provided entry without an object ID, reporting its size as hashed.
+func (u *Uploader) dryRunEntry(e fs.Entry, size int64) (*snapshot.DirEntry, error) {
+	de, err := newDirEntry(e, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create dir entry")
+	}
+
+	de.FileSize = size
+	u.Progress.HashedBytes(size)
+
+	return de, nil
+}
+
+func (u *Uploader) copyWithProgress(ctx context.Context, dst io.Writer, src io.Reader, completed, length int64) (int64, error) {
 	uploadBuf := iocopy.GetBuffer()
 	defer iocopy.ReleaseBuffer(uploadBuf)
 
@@ -293,18 +695,25 @@ func (u *Uploader) copyWithProgress(dst io.Writer, src io.Reader, completed, len
 
 	for {
 		if u.IsCanceled() {
-			return 0, errors.Wrap(errCanceled, "canceled when copying data")
+			return written, errors.Wrap(errCanceled, "canceled when copying data")
 		}
 
 		readBytes, readErr := src.Read(uploadBuf)
 
 		// nolint:nestif
 		if readBytes > 0 {
+			if u.uploadThrottle != nil {
+				if err := u.uploadThrottle.wait(u.uploadCtx, int64(readBytes)); err != nil {
+					return written, err
+				}
+			}
+
 			wroteBytes, writeErr := dst.Write(uploadBuf[0:readBytes])
 			if wroteBytes > 0 {
 				written += int64(wroteBytes)
 				completed += int64(wroteBytes)
 				atomic.AddInt64(&u.totalWrittenBytes, int64(wroteBytes))
+				u.checkpointBytesWritten(int64(wroteBytes))
 				u.Progress.HashedBytes(int64(wroteBytes))
 
 				if length < completed {
@@ -327,8 +736,7 @@ func (u *Uploader) copyWithProgress(dst io.Writer, src io.Reader, completed, len
 				break
 			}
 
-			// nolint:wrapcheck
-			return written, readErr
+			return written, fileReadError{readErr}
 		}
 	}
 
@@ -406,7 +814,7 @@ func (u *Uploader) checkpointRoot(ctx context.Context, cp *checkpointRegistry, p
 		return errors.Wrap(err, "running checkpointers")
 	}
 
-	checkpointManifest := dmbCheckpoint.Build(u.repo.Time(), "dummy")
+	checkpointManifest := dmbCheckpoint.Build(u.now(), "dummy", u.maxFailedEntriesPerDir())
 	if len(checkpointManifest.Entries) == 0 {
 		// did not produce a checkpoint, that's ok
 		return nil
@@ -422,9 +830,10 @@ func (u *Uploader) checkpointRoot(ctx context.Context, cp *checkpointRegistry, p
 
 	man := *prototypeManifest
 	man.RootEntry = rootEntry
-	man.EndTime = u.repo.Time()
+	man.EndTime = u.now()
 	man.StartTime = man.EndTime
 	man.IncompleteReason = IncompleteReasonCheckpoint
+	man.ResumeFingerprint = u.resumeFingerprint
 
 	if _, err := snapshot.SaveSnapshot(ctx, u.repo, &man); err != nil {
 		return errors.Wrap(err, "error saving checkpoint snapshot")
@@ -441,9 +850,17 @@ func (u *Uploader) checkpointRoot(ctx context.Context, cp *checkpointRegistry, p
 	return nil
 }
 
-// periodicallyCheckpoint periodically (every CheckpointInterval) invokes checkpointRoot until the
-// returned cancelation function has been called.
+// periodicallyCheckpoint periodically (every CheckpointInterval) and whenever RequestCheckpoint() is called
+// invokes checkpointRoot until the returned cancelation function has been called.
+// All checkpoints are taken by a single goroutine, so they never run concurrently.
 func (u *Uploader) periodicallyCheckpoint(ctx context.Context, cp *checkpointRegistry, prototypeManifest *snapshot.Manifest) (cancelFunc func()) {
+	if u.DryRun {
+		// dry runs never write checkpoints.
+		return func() {}
+	}
+
+	u.resetCheckpointTriggers()
+
 	shutdown := make(chan struct{})
 	ch := u.getTicker(u.CheckpointInterval)
 
@@ -454,17 +871,22 @@ func (u *Uploader) periodicallyCheckpoint(ctx context.Context, cp *checkpointReg
 				return
 
 			case <-ch:
-				if err := u.checkpointRoot(ctx, cp, prototypeManifest); err != nil {
-					uploadLog(ctx).Errorf("error checkpointing: %v", err)
-					u.Cancel()
+			case <-u.checkpointRequests:
+				uploadLog(ctx).Debugf("checkpoint requested")
+			}
 
-					return
-				}
+			u.resetCheckpointTriggers()
 
-				// test action
-				if u.checkpointFinished != nil {
-					u.checkpointFinished <- struct{}{}
-				}
+			if err := u.checkpointRoot(ctx, cp, prototypeManifest); err != nil {
+				uploadLog(ctx).Errorf("error checkpointing: %v", err)
+				u.Cancel()
+
+				return
+			}
+
+			// test action
+			if u.checkpointFinished != nil {
+				u.checkpointFinished <- struct{}{}
 			}
 		}
 	}()
@@ -580,15 +1002,18 @@ func (b *dirManifestBuilder) addEntry(de *snapshot.DirEntry) {
 	case snapshot.EntryTypeFile:
 		b.summary.TotalFileCount++
 		b.summary.TotalFileSize += de.FileSize
+		b.summary.TotalPackedSize += de.PackedSize
 
 	case snapshot.EntryTypeDirectory:
 		if childSummary := de.DirSummary; childSummary != nil {
 			b.summary.TotalFileCount += childSummary.TotalFileCount
 			b.summary.TotalFileSize += childSummary.TotalFileSize
+			b.summary.TotalPackedSize += childSummary.TotalPackedSize
 			b.summary.TotalDirCount += childSummary.TotalDirCount
 			b.summary.FatalErrorCount += childSummary.FatalErrorCount
 			b.summary.IgnoredErrorCount += childSummary.IgnoredErrorCount
 			b.summary.FailedEntries = append(b.summary.FailedEntries, childSummary.FailedEntries...)
+			b.summary.TruncatedFailedEntryCount += childSummary.TruncatedFailedEntryCount
 
 			if childSummary.MaxModTime.After(b.summary.MaxModTime) {
 				b.summary.MaxModTime = childSummary.MaxModTime
@@ -613,7 +1038,8 @@ func (b *dirManifestBuilder) addFailedEntry(relPath string, isIgnoredError bool,
 	})
 }
 
-func (b *dirManifestBuilder) Build(dirModTime time.Time, incompleteReason string) *snapshot.DirManifest {
+// Build returns the directory manifest, whose summary includes up to maxFailedEntries failed entries.
+func (b *dirManifestBuilder) Build(dirModTime time.Time, incompleteReason string, maxFailedEntries int) *snapshot.DirManifest {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -622,13 +1048,19 @@ func (b *dirManifestBuilder) Build(dirModTime time.Time, incompleteReason string
 
 	entries := b.entries
 
+	// unlike other totals, the number of children is not aggregated from subdirectories.
+	s.ImmediateChildCount = int64(len(entries))
+
 	if len(entries) == 0 {
 		s.MaxModTime = dirModTime
 	}
 
 	s.IncompleteReason = incompleteReason
 
-	b.summary.FailedEntries = sortedTopFailures(b.summary.FailedEntries)
+	var truncated int
+
+	s.FailedEntries, truncated = sortedTopFailures(b.summary.FailedEntries, maxFailedEntries)
+	s.TruncatedFailedEntryCount += truncated
 
 	// sort the result, directories first, then non-directories, ordered by name
 	sort.Slice(b.entries, func(i, j int) bool {
@@ -647,16 +1079,18 @@ func (b *dirManifestBuilder) Build(dirModTime time.Time, incompleteReason string
 	}
 }
 
-func sortedTopFailures(entries []*fs.EntryWithError) []*fs.EntryWithError {
+// sortedTopFailures sorts failed entries by path and returns up to maxEntries of them
+// along with the number of entries that were omitted.
+func sortedTopFailures(entries []*fs.EntryWithError, maxEntries int) ([]*fs.EntryWithError, int) {
 	sort.Slice(entries, func(i, j int) bool {
 		return entries[i].EntryPath < entries[j].EntryPath
 	})
 
-	if len(entries) > fs.MaxFailedEntriesPerDirectorySummary {
-		entries = entries[0:fs.MaxFailedEntriesPerDirectorySummary]
+	if len(entries) > maxEntries {
+		return entries[0:maxEntries], len(entries) - maxEntries
 	}
 
-	return entries
+	return entries, 0
 }
 
 func isDir(e *snapshot.DirEntry) bool {
@@ -674,6 +1108,8 @@ func (u *Uploader) processChildren(
 ) error {
 	var wg workshare.AsyncGroup
 
+	entries = u.followSymlinks(ctx, parentDirBuilder, relativePath, entries, policyTree)
+
 	// ignore errCancel because a more serious error may be reported in wg.Wait()
 	// we'll check for cancelation later.
 
@@ -720,6 +1156,13 @@ func (u *Uploader) processSubdirectories(
 			return nil
 		}
 
+		if fd, ok := dir.(*followedDirectory); ok {
+			ctx = withFollowedSymlinkTarget(ctx, fd.Directory)
+			dir = u.wrapIgnorefs(uploadLog(ctx), fd, policyTree.Child(entry.Name()), true /* reportIgnoreStats */)
+		} else {
+			ctx = withUploadingSubdirectory(ctx, dir)
+		}
+
 		var previousDirs []fs.Directory
 		for _, e := range previousEntries {
 			if d, _ := e.FindByName(entry.Name()).(fs.Directory); d != nil {
@@ -729,6 +1172,16 @@ func (u *Uploader) processSubdirectories(
 
 		previousDirs = uniqueDirectories(previousDirs)
 
+		resumed, err := u.maybeResumeDirectory(ctx, entryRelativePath, dir, previousDirs)
+		if err != nil {
+			return errors.Wrapf(err, "unable to resume directory %q", entry.Name())
+		}
+
+		if resumed != nil {
+			u.addEntryWithXattrs(ctx, parentDirBuilder, policyTree, entryRelativePath, entry, resumed)
+			return nil
+		}
+
 		childDirBuilder := &dirManifestBuilder{}
 
 		childLocalDirPathOrEmpty := ""
@@ -750,7 +1203,7 @@ func (u *Uploader) processSubdirectories(
 
 			var dre dirReadError
 			if errors.As(err, &dre) {
-				u.reportErrorAndMaybeCancel(dre.error,
+				u.reportErrorAndMaybeCancel(dre,
 					childTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreDirectoryErrors.OrDefault(false),
 					parentDirBuilder,
 					entryRelativePath)
@@ -758,73 +1211,228 @@ func (u *Uploader) processSubdirectories(
 				return errors.Wrapf(err, "unable to process directory %q", entry.Name())
 			}
 		} else {
-			parentDirBuilder.addEntry(de)
+			u.addEntryWithXattrs(ctx, parentDirBuilder, policyTree, entryRelativePath, entry, de)
 		}
 
 		return nil
 	})
 }
 
-func metadataEquals(e1, e2 fs.Entry) bool {
+// UploadReason describes why a file was hashed and uploaded instead of being reused from a previous snapshot.
+type UploadReason string
+
+// reasons why a file is uploaded instead of being cached.
+const (
+	UploadReasonNew            UploadReason = "new"
+	UploadReasonModTimeChanged UploadReason = "mtime-changed"
+	UploadReasonModeChanged    UploadReason = "mode-changed"
+	UploadReasonSizeChanged    UploadReason = "size-changed"
+	UploadReasonOwnerChanged   UploadReason = "owner-changed"
+	UploadReasonForcedRehash   UploadReason = "forced-rehash"
+	UploadReasonNotCacheable   UploadReason = "not-cacheable"
+	UploadReasonXattrsChanged  UploadReason = "xattrs-changed"
+)
+
+// metadataChangeReason returns the reason why e1 and e2 are considered different or an empty string if they are equal.
+func metadataChangeReason(e1, e2 fs.Entry) UploadReason {
 	if l, r := e1.ModTime(), e2.ModTime(); !l.Equal(r) {
-		return false
+		return UploadReasonModTimeChanged
 	}
 
 	if l, r := e1.Mode(), e2.Mode(); l != r {
-		return false
+		return UploadReasonModeChanged
 	}
 
 	if l, r := e1.Size(), e2.Size(); l != r {
-		return false
+		return UploadReasonSizeChanged
 	}
 
 	if l, r := e1.Owner(), e2.Owner(); l != r {
-		return false
+		return UploadReasonOwnerChanged
 	}
 
-	return true
+	return ""
 }
 
-func findCachedEntry(ctx context.Context, entryRelativePath string, entry fs.Entry, prevEntries []fs.Entries, pol *policy.Tree) fs.Entry {
-	var missedEntry fs.Entry
+// findCachedEntry returns the matching entry from one of previous snapshots or nil and the reason why
+// the cached entry could not be used.
+func findCachedEntry(ctx context.Context, entryRelativePath string, entry fs.Entry, prevEntries []fs.Entries, pol *policy.Tree) (fs.Entry, UploadReason) {
+	var (
+		missedEntry fs.Entry
+		missReason  UploadReason
+	)
 
 	for _, e := range prevEntries {
 		if ent := e.FindByName(entry.Name()); ent != nil {
-			if metadataEquals(entry, ent) {
-				return ent
+			reason := metadataChangeReason(entry, ent)
+			if reason == "" {
+				return ent, ""
 			}
 
 			missedEntry = ent
+			missReason = reason
 		}
 	}
 
-	if missedEntry != nil {
-		if pol.EffectivePolicy().LoggingPolicy.Entries.CacheMiss.OrDefault(policy.LogDetailNone) >= policy.LogDetailNormal {
-			uploadLog(ctx).Debugw(
-				"cache miss",
-				"path", entryRelativePath,
-				"mode", missedEntry.Mode().String(),
-				"size", missedEntry.Size(),
-				"mtime", missedEntry.ModTime())
-		}
+	if missedEntry == nil {
+		return nil, UploadReasonNew
 	}
 
-	return nil
+	if pol.EffectivePolicy().LoggingPolicy.Entries.CacheMiss.OrDefault(policy.LogDetailNone) >= policy.LogDetailNormal {
+		uploadLog(ctx).Debugw(
+			"cache miss",
+			"path", entryRelativePath,
+			"reason", missReason,
+			"mode", missedEntry.Mode().String(),
+			"size", missedEntry.Size(),
+			"mtime", missedEntry.ModTime())
+	}
+
+	return nil, missReason
 }
 
-func (u *Uploader) maybeIgnoreCachedEntry(ctx context.Context, ent fs.Entry) fs.Entry {
+// maybeIgnoreCachedEntry returns the cached entry if it can be used or nil and the reason why it can't.
+// The percentage of cached entries hashed again comes from the policy of the entry, falling back to ForceHashPercentage.
+func (u *Uploader) maybeIgnoreCachedEntry(ctx context.Context, ent fs.Entry, reason UploadReason, policyTree *policy.Tree) (fs.Entry, UploadReason) {
+	if ent == nil {
+		return nil, reason
+	}
+
 	if h, ok := ent.(object.HasObjectID); ok {
-		if 100*rand.Float64() < u.ForceHashPercentage { // nolint:gosec
+		forceHashPercentage := policyTree.EffectivePolicy().UploadPolicy.ForceHashPercentage.OrDefault(u.ForceHashPercentage)
+
+		if 100*rand.Float64() < forceHashPercentage { // nolint:gosec
 			uploadLog(ctx).Debugw("re-hashing cached object", "oid", h.ObjectID())
-			return nil
+			return nil, UploadReasonForcedRehash
 		}
 
-		return ent
+		return ent, ""
+	}
+
+	return nil, UploadReasonNotCacheable
+}
+
+// maybeRecordUploadReason reports the reason why the provided entry is being uploaded, if enabled.
+func (u *Uploader) maybeRecordUploadReason(ctx context.Context, entryRelativePath string, reason UploadReason) {
+	if !u.RecordUploadReasons || reason == "" {
+		return
+	}
+
+	uploadLog(ctx).Debugw("upload reason", "path", entryRelativePath, "reason", reason)
+	u.Progress.UploadReason(entryRelativePath, reason)
+}
+
+// addHardLinkEntry adds an entry for a file that is a hard link to an already uploaded file, reusing its object.
+func (u *Uploader) addHardLinkEntry(ctx context.Context, parentDirBuilder *dirManifestBuilder, policyTree *policy.Tree, entryRelativePath string, f fs.File, oid object.ID, t0 timetrack.Timer) error {
+	de, err := newDirEntry(f, oid)
+	if err != nil {
+		return errors.Wrap(err, "unable to create dir entry")
 	}
 
+	atomic.AddInt32(&u.stats.CollapsedHardLinks, 1)
+	atomic.AddInt32(&u.stats.TotalFileCount, 1)
+	atomic.AddInt64(&u.stats.TotalFileSize, de.FileSize)
+	u.Progress.CachedFile(entryRelativePath, de.FileSize)
+
+	maybeLogEntryProcessed(
+		uploadLog(ctx),
+		u.OverrideEntryLogDetail.OrDefault(policyTree.EffectivePolicy().LoggingPolicy.Entries.CacheHit.OrDefault(policy.LogDetailNone)),
+		"hard link", entryRelativePath, de, nil, t0)
+
+	u.addEntryWithXattrs(ctx, parentDirBuilder, policyTree, entryRelativePath, f, de)
+	u.invokeFileHook(u.OnCachedFile, entryRelativePath, de)
+
 	return nil
 }
 
+// invokeFileHook invokes the provided file hook, if set, ensuring that hooks are never invoked concurrently.
+func (u *Uploader) invokeFileHook(hook func(relativePath string, de *snapshot.DirEntry), relativePath string, de *snapshot.DirEntry) {
+	if hook == nil {
+		return
+	}
+
+	u.fileHookMutex.Lock()
+	defer u.fileHookMutex.Unlock()
+
+	hook(relativePath, de)
+}
+
+// addEntryWithXattrs adds the directory entry to the builder, capturing extended attributes and creation time of the source
+// entry when Uploader.CaptureXattrs and Uploader.CaptureCreationTime are set. Failure to read them is reported as an error,
+// but the entry is still added.
+func (u *Uploader) addEntryWithXattrs(ctx context.Context, parentDirBuilder *dirManifestBuilder, policyTree *policy.Tree, entryRelativePath string, entry fs.Entry, de *snapshot.DirEntry) {
+	if x, ok := entry.(fs.HasXattrs); ok && u.CaptureXattrs {
+		attrs, err := x.Xattrs(ctx)
+		if err != nil {
+			isIgnoredError := policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrors.OrDefault(false)
+
+			u.reportErrorAndMaybeCancel(errors.Wrap(err, "unable to read extended attributes"), isIgnoredError, parentDirBuilder, entryRelativePath)
+		}
+
+		de.Xattrs = attrs
+	}
+
+	if c, ok := entry.(fs.HasCreationTime); ok && u.CaptureCreationTime {
+		t, err := c.CreationTime(ctx)
+		if err != nil {
+			isIgnoredError := policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrors.OrDefault(false)
+
+			u.reportErrorAndMaybeCancel(errors.Wrap(err, "unable to read creation time"), isIgnoredError, parentDirBuilder, entryRelativePath)
+		}
+
+		if !t.IsZero() {
+			de.CreationTime = &t
+		}
+	}
+
+	parentDirBuilder.addEntry(de)
+}
+
+// xattrsEqual returns true if both entries have the same extended attributes.
+func xattrsEqual(ctx context.Context, e1, e2 fs.Entry) bool {
+	return reflect.DeepEqual(readXattrsOrNil(ctx, e1), readXattrsOrNil(ctx, e2))
+}
+
+func readXattrsOrNil(ctx context.Context, e fs.Entry) map[string][]byte {
+	x, ok := e.(fs.HasXattrs)
+	if !ok {
+		return nil
+	}
+
+	attrs, err := x.Xattrs(ctx)
+	if err != nil || len(attrs) == 0 {
+		return nil
+	}
+
+	return attrs
+}
+
+// isSpecialFile returns true if the provided entry is a socket, named pipe or device.
+func isSpecialFile(e fs.Entry) bool {
+	return e.Mode()&(os.ModeSocket|os.ModeNamedPipe|os.ModeDevice|os.ModeCharDevice) != 0
+}
+
+func (u *Uploader) skipSpecialFile(ctx context.Context, policyTree *policy.Tree, entryRelativePath string) {
+	atomic.AddInt32(&u.stats.SkippedSpecialCount, 1)
+	u.Progress.SkippedSpecialFile(entryRelativePath)
+
+	maybeLogEntryProcessed(
+		uploadLog(ctx),
+		u.OverrideEntryLogDetail.OrDefault(policyTree.EffectivePolicy().LoggingPolicy.Entries.Ignored.OrDefault(policy.LogDetailNone)),
+		"skipped special file", entryRelativePath, nil, nil, timetrack.StartTimer())
+}
+
+// excludeLargeFile excludes a file exceeding MaxFileSize and records it in the directory summary as an ignored error.
+func (u *Uploader) excludeLargeFile(ctx context.Context, parentDirBuilder *dirManifestBuilder, entryRelativePath string, f fs.File) {
+	uploadLog(ctx).Debugw("excluding large file", "path", entryRelativePath, "size", f.Size(), "max", u.MaxFileSize)
+
+	u.stats.AddExcluded(f)
+	u.Progress.ExcludedFile(entryRelativePath, f.Size())
+
+	atomic.AddInt32(&u.stats.IgnoredErrorCount, 1)
+	parentDirBuilder.addFailedEntry(entryRelativePath, true, errors.Errorf("file size %v exceeds maximum of %v bytes", f.Size(), u.MaxFileSize))
+}
+
 func (u *Uploader) effectiveParallelFileReads(pol *policy.Policy) int {
 	p := u.ParallelUploads
 	max := pol.UploadPolicy.MaxParallelFileReads.OrDefault(runtime.NumCPU())
@@ -860,6 +1468,10 @@ func (u *Uploader) processNonDirectories(
 		}
 	}
 
+	if u.LargestFirst {
+		entries = sortedLargestFirst(entries)
+	}
+
 	return u.foreachEntryUnlessCanceled(ctx, wg, dirRelativePath, entries, func(ctx context.Context, entry fs.Entry, entryRelativePath string) error {
 		// note this function runs in parallel and updates 'u.stats', which must be done using atomic operations.
 		if _, ok := entry.(fs.Directory); ok {
@@ -867,10 +1479,31 @@ func (u *Uploader) processNonDirectories(
 			return nil
 		}
 
+		if u.SkipSpecialFiles && isSpecialFile(entry) {
+			u.skipSpecialFile(ctx, policyTree, entryRelativePath)
+			return nil
+		}
+
+		if f, ok := entry.(fs.File); ok && u.MaxFileSize > 0 && f.Size() > u.MaxFileSize {
+			u.excludeLargeFile(ctx, parentDirBuilder, entryRelativePath, f)
+			return nil
+		}
+
 		t0 := timetrack.StartTimer()
 
 		// See if we had this name during either of previous passes.
-		if cachedEntry := u.maybeIgnoreCachedEntry(ctx, findCachedEntry(ctx, entryRelativePath, entry, prevEntries, policyTree)); cachedEntry != nil {
+		cachedEntry, uploadReason := findCachedEntry(ctx, entryRelativePath, entry, prevEntries, policyTree)
+		if cachedEntry == nil {
+			if hc := u.findHashCachedEntry(ctx, entry); hc != nil {
+				cachedEntry, uploadReason = hc, ""
+			}
+		}
+
+		if cachedEntry != nil && u.CaptureXattrs && !xattrsEqual(ctx, entry, cachedEntry) {
+			cachedEntry, uploadReason = nil, UploadReasonXattrsChanged
+		}
+
+		if cachedEntry, uploadReason = u.maybeIgnoreCachedEntry(ctx, cachedEntry, uploadReason, policyTree.Child(entry.Name())); cachedEntry != nil {
 			atomic.AddInt32(&u.stats.CachedFiles, 1)
 			atomic.AddInt64(&u.stats.TotalFileSize, entry.Size())
 			u.Progress.CachedFile(filepath.Join(dirRelativePath, entry.Name()), entry.Size())
@@ -881,12 +1514,18 @@ func (u *Uploader) processNonDirectories(
 				return errors.Wrap(err, "unable to create dir entry")
 			}
 
+			if h, ok := cachedEntry.(snapshot.HasDirEntry); ok {
+				cachedDirEntry.PackedSize = h.DirEntry().PackedSize
+			}
+
 			maybeLogEntryProcessed(
 				uploadLog(ctx),
 				u.OverrideEntryLogDetail.OrDefault(policyTree.EffectivePolicy().LoggingPolicy.Entries.CacheHit.OrDefault(policy.LogDetailNone)),
 				"cached", entryRelativePath, cachedDirEntry, nil, t0)
 
-			parentDirBuilder.addEntry(cachedDirEntry)
+			u.updateHashCache(ctx, entry, cachedDirEntry)
+			u.addEntryWithXattrs(ctx, parentDirBuilder, policyTree, entryRelativePath, entry, cachedDirEntry)
+			u.invokeFileHook(u.OnCachedFile, entryRelativePath, cachedDirEntry)
 
 			return nil
 		}
@@ -899,7 +1538,8 @@ func (u *Uploader) processNonDirectories(
 
 				u.reportErrorAndMaybeCancel(err, isIgnoredError, parentDirBuilder, entryRelativePath)
 			} else {
-				parentDirBuilder.addEntry(de)
+				u.addEntryWithXattrs(ctx, parentDirBuilder, policyTree, entryRelativePath, entry, de)
+				u.invokeFileHook(u.OnFileUploaded, entryRelativePath, de)
 			}
 
 			maybeLogEntryProcessed(
@@ -910,7 +1550,12 @@ func (u *Uploader) processNonDirectories(
 			return nil
 
 		case fs.File:
+			if oid := u.hardLinks.find(entry); oid != "" {
+				return u.addHardLinkEntry(ctx, parentDirBuilder, policyTree, entryRelativePath, entry, oid, t0)
+			}
+
 			atomic.AddInt32(&u.stats.NonCachedFiles, 1)
+			u.maybeRecordUploadReason(ctx, entryRelativePath, uploadReason)
 
 			de, err := u.uploadFileInternal(ctx, parentCheckpointRegistry, entryRelativePath, entry, policyTree.Child(entry.Name()).EffectivePolicy(), asyncWritesPerFile)
 			if err != nil {
@@ -918,7 +1563,11 @@ func (u *Uploader) processNonDirectories(
 
 				u.reportErrorAndMaybeCancel(err, isIgnoredError, parentDirBuilder, entryRelativePath)
 			} else {
-				parentDirBuilder.addEntry(de)
+				u.hardLinks.add(entry, de.ObjectID)
+				u.updateHashCache(ctx, entry, de)
+				u.addEntryWithXattrs(ctx, parentDirBuilder, policyTree, entryRelativePath, entry, de)
+				u.invokeFileHook(u.OnFileUploaded, entryRelativePath, de)
+				u.checkpointFileUploaded()
 			}
 
 			maybeLogEntryProcessed(
@@ -947,20 +1596,28 @@ func (u *Uploader) processNonDirectories(
 				u.OverrideEntryLogDetail.OrDefault(policyTree.EffectivePolicy().LoggingPolicy.Entries.Snapshotted.OrDefault(policy.LogDetailNone)),
 				prefix, entryRelativePath, nil, entry.ErrorInfo(), t0)
 
-			u.reportErrorAndMaybeCancel(entry.ErrorInfo(), isIgnoredError, parentDirBuilder, entryRelativePath)
+			u.reportErrorAndMaybeCancel(fileReadError{entry.ErrorInfo()}, isIgnoredError, parentDirBuilder, entryRelativePath)
 
 			return nil
 
 		case fs.StreamingFile:
 			atomic.AddInt32(&u.stats.NonCachedFiles, 1)
+			u.maybeRecordUploadReason(ctx, entryRelativePath, uploadReason)
 
-			de, err := u.uploadStreamingFileInternal(ctx, entryRelativePath, entry)
+			de, err := u.uploadStreamingFileInternal(ctx, parentCheckpointRegistry, entryRelativePath, entry)
 			if err != nil {
+				if de != nil {
+					// partial entry of canceled streaming file.
+					parentDirBuilder.addEntry(de)
+				}
+
 				isIgnoredError := policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrors.OrDefault(false)
 
 				u.reportErrorAndMaybeCancel(err, isIgnoredError, parentDirBuilder, entryRelativePath)
 			} else {
-				parentDirBuilder.addEntry(de)
+				u.addEntryWithXattrs(ctx, parentDirBuilder, policyTree, entryRelativePath, entry, de)
+				u.invokeFileHook(u.OnFileUploaded, entryRelativePath, de)
+				u.checkpointFileUploaded()
 			}
 
 			maybeLogEntryProcessed(
@@ -975,6 +1632,25 @@ func (u *Uploader) processNonDirectories(
 	})
 }
 
+// sortedLargestFirst returns a copy of the provided entries sorted by descending size.
+// Streaming files, whose size is not known in advance, are sorted last.
+func sortedLargestFirst(entries fs.Entries) fs.Entries {
+	result := append(fs.Entries(nil), entries...)
+
+	sort.SliceStable(result, func(i, j int) bool {
+		_, leftStreaming := result[i].(fs.StreamingFile)
+		_, rightStreaming := result[j].(fs.StreamingFile)
+
+		if leftStreaming != rightStreaming {
+			return rightStreaming
+		}
+
+		return result[i].Size() > result[j].Size()
+	})
+
+	return result
+}
+
 func maybeLogEntryProcessed(logger logging.Logger, level policy.LogDetail, msg, relativePath string, de *snapshot.DirEntry, err error, timer timetrack.Timer) {
 	if level <= policy.LogDetailNone && err == nil {
 		return
@@ -1077,6 +1753,9 @@ type dirReadError struct {
 	error
 }
 
+func (e dirReadError) Cause() error  { return e.error }
+func (e dirReadError) Unwrap() error { return e.error }
+
 func uploadShallowDirInternal(ctx context.Context, directory fs.Directory, u *Uploader) (*snapshot.DirEntry, error) {
 	if pf, ok := directory.(snapshot.HasDirEntryOrNil); ok {
 		switch de, err := pf.DirEntryOrNil(ctx); {
@@ -1168,7 +1847,7 @@ func uploadDirInternal(
 			return nil, errors.Wrapf(err, "error checkpointing children")
 		}
 
-		checkpointManifest := thisCheckpointBuilder.Build(directory.ModTime(), IncompleteReasonCheckpoint)
+		checkpointManifest := thisCheckpointBuilder.Build(directory.ModTime(), IncompleteReasonCheckpoint, u.maxFailedEntriesPerDir())
 		oid, err := u.writeDirManifest(ctx, dirRelativePath, checkpointManifest)
 		if err != nil {
 			return nil, errors.Wrap(err, "error writing dir manifest")
@@ -1182,7 +1861,7 @@ func uploadDirInternal(
 		return nil, err
 	}
 
-	dirManifest := thisDirBuilder.Build(directory.ModTime(), u.incompleteReason())
+	dirManifest := thisDirBuilder.Build(directory.ModTime(), u.incompleteReason(), u.maxFailedEntriesPerDir())
 
 	oid, err := u.writeDirManifest(ctx, dirRelativePath, dirManifest)
 	if err != nil {
@@ -1193,9 +1872,16 @@ func uploadDirInternal(
 }
 
 func (u *Uploader) writeDirManifest(ctx context.Context, dirRelativePath string, dirManifest *snapshot.DirManifest) (object.ID, error) {
+	if u.DryRun {
+		return "", nil
+	}
+
+	md := u.objectMetadata(snapshot.EntryTypeDirectory, dirRelativePath)
+
 	writer := u.repo.NewObjectWriter(ctx, object.WriterOptions{
 		Description: "DIR:" + dirRelativePath,
 		Prefix:      objectIDPrefixDirectory,
+		Metadata:    md,
 	})
 
 	defer writer.Close() //nolint:errcheck
@@ -1209,6 +1895,8 @@ func (u *Uploader) writeDirManifest(ctx context.Context, dirRelativePath string,
 		return "", errors.Wrap(err, "unable to write directory")
 	}
 
+	u.recordObjectMetadata(oid, md)
+
 	return oid, nil
 }
 
@@ -1220,8 +1908,9 @@ func (u *Uploader) reportErrorAndMaybeCancel(err error, isIgnored bool, dmb *dir
 
 	if isIgnored {
 		atomic.AddInt32(&u.stats.IgnoredErrorCount, 1)
-	} else {
-		atomic.AddInt32(&u.stats.ErrorCount, 1)
+	} else if ec := atomic.AddInt32(&u.stats.ErrorCount, 1); u.MaxErrors > 0 && int(ec) > u.MaxErrors {
+		atomic.StoreInt32(&u.tooManyErrors, 1)
+		u.Cancel()
 	}
 
 	rc := rootCauseError(err)
@@ -1231,22 +1920,55 @@ func (u *Uploader) reportErrorAndMaybeCancel(err error, isIgnored bool, dmb *dir
 	if u.FailFast && !isIgnored {
 		u.Cancel()
 	}
+
+	if u.AbortOnSourceIOError && isSourceIOError(err) {
+		atomic.StoreInt32(&u.sourceIOError, 1)
+		u.cancelUploadWaits()
+	}
 }
 
 // NewUploader creates new Uploader object for a given repository.
 func NewUploader(r repo.RepositoryWriter) *Uploader {
 	return &Uploader{
-		repo:               r,
-		Progress:           &NullUploadProgress{},
-		EnableActions:      r.ClientOptions().EnableActions,
-		CheckpointInterval: DefaultCheckpointInterval,
-		getTicker:          time.Tick,
+		repo:                          r,
+		Progress:                      &NullUploadProgress{},
+		EnableActions:                 r.ClientOptions().EnableActions,
+		CheckpointInterval:            DefaultCheckpointInterval,
+		MinCheckpointInterval:         DefaultMinCheckpointInterval,
+		FileReadRetryInterval:         DefaultFileReadRetryInterval,
+		ParallelismAdjustmentInterval: DefaultParallelismAdjustmentInterval,
+		getTicker:                     time.Tick,
+		now:                           r.Time,
+		checkpointRequests:            make(chan struct{}, 1),
+	}
+}
+
+// RequestCheckpoint requests a checkpoint of an upload that's in progress to be taken as soon as possible,
+// without affecting the schedule of periodic checkpoints. Multiple requests made before the checkpoint
+// is taken result in a single checkpoint.
+func (u *Uploader) RequestCheckpoint() {
+	select {
+	case u.checkpointRequests <- struct{}{}:
+	default:
+		// checkpoint already requested.
 	}
 }
 
 // Cancel requests cancellation of an upload that's in progress. Will typically result in an incomplete snapshot.
 func (u *Uploader) Cancel() {
 	atomic.StoreInt32(&u.canceled, 1)
+	u.cancelUploadWaits()
+}
+
+// cancelUploadWaits cancels the context of the upload in progress, if any, which stops waits that
+// would otherwise delay the cancellation.
+func (u *Uploader) cancelUploadWaits() {
+	u.cancelMutex.Lock()
+	defer u.cancelMutex.Unlock()
+
+	if u.cancelUploadCtx != nil {
+		u.cancelUploadCtx()
+	}
 }
 
 func (u *Uploader) maybeOpenDirectoryFromManifest(ctx context.Context, man *snapshot.Manifest) fs.Directory {
@@ -1262,11 +1984,20 @@ func (u *Uploader) maybeOpenDirectoryFromManifest(ctx context.Context, man *snap
 		return nil
 	}
 
+	// complete subdirectories of checkpoints created using the same policies and settings are reused
+	// without walking them again.
+	if rd, ok := dir.(*repositoryDirectory); ok && man.IncompleteReason == IncompleteReasonCheckpoint && man.ResumeFingerprint == u.resumeFingerprint {
+		return resumableDirectory{rd, time.Time{}}
+	}
+
 	return dir
 }
 
 // Upload uploads contents of the specified filesystem entry (file or directory) to the repository and returns snapshot.Manifest with statistics.
 // Old snapshot manifest, when provided can be used to speed up uploads by utilizing hash cache.
+// When an old manifest is a checkpoint of an interrupted upload using the same policies and settings, its
+// subdirectories that were fully uploaded and whose modification times are unchanged are reused as-is without
+// reading their contents again.
 func (u *Uploader) Upload(
 	ctx context.Context,
 	source fs.Entry,
@@ -1274,6 +2005,13 @@ func (u *Uploader) Upload(
 	sourceInfo snapshot.SourceInfo,
 	previousManifests ...*snapshot.Manifest,
 ) (*snapshot.Manifest, error) {
+	if u.ProgressUpdateInterval > 0 {
+		p := u.Progress
+		u.Progress = newThrottledUploadProgress(p, u.ProgressUpdateInterval)
+
+		defer func() { u.Progress = p }()
+	}
+
 	u.Progress.UploadStarted()
 	defer u.Progress.UploadFinished()
 
@@ -1283,17 +2021,60 @@ func (u *Uploader) Upload(
 
 	s := &snapshot.Manifest{
 		Source: sourceInfo,
+		DryRun: u.DryRun,
 	}
 
 	u.workerPool = workshare.NewPool(parallel - 1)
 	defer u.workerPool.Close()
 
 	u.stats = &snapshot.Stats{}
+	u.sourceInfo = sourceInfo
+	u.resumeFingerprint = u.computeResumeFingerprint(policyTree)
+	u.uploadThrottle = nil
+
+	uploadCtx, cancelUploadCtx := context.WithCancel(ctx)
+	defer cancelUploadCtx()
+
+	u.uploadCtx = uploadCtx
+
+	u.cancelMutex.Lock()
+	u.cancelUploadCtx = cancelUploadCtx
+	u.cancelMutex.Unlock()
+
+	if u.IsCanceled() {
+		cancelUploadCtx()
+	}
+
+	if u.MaxUploadBytesPerSecond > 0 {
+		th, err := newUploadThrottle(u.MaxUploadBytesPerSecond)
+		if err != nil {
+			return nil, err
+		}
+
+		u.uploadThrottle = th
+	}
+
+	u.hardLinks = newHardLinkTracker()
+	u.extStats = nil
+	u.objectMetadataCollector = nil
+
+	if u.AttachObjectMetadata {
+		u.objectMetadataCollector = &objectMetadataCollector{}
+	}
+
+	if u.RecordExtensionStats {
+		u.extStats = newExtensionStats()
+	}
 	atomic.StoreInt64(&u.totalWrittenBytes, 0)
 
+	if u.AdaptiveParallelism {
+		cancelAdjustments := u.periodicallyAdjustParallelism(ctx, parallel)
+		defer cancelAdjustments()
+	}
+
 	var err error
 
-	s.StartTime = u.repo.Time()
+	s.StartTime = u.now()
 
 	var scanWG sync.WaitGroup
 
@@ -1321,14 +2102,19 @@ func (u *Uploader) Upload(
 			ds, _ := u.scanDirectory(scanctx, wrapped, policyTree)
 
 			u.Progress.EstimatedDataSize(ds.numFiles, ds.totalFileSize)
+			u.Progress.EstimatedDataSizeDetailed(ds.details())
 		}()
 
 		wrapped := u.wrapIgnorefs(uploadLog(ctx), entry, policyTree, true /* reportIgnoreStats */)
 
-		s.RootEntry, err = u.uploadDirWithCheckpointing(ctx, wrapped, policyTree, previousDirs, sourceInfo)
+		s.RootEntry, err = u.uploadDirWithCheckpointing(withUploadRoot(ctx, entry), wrapped, policyTree, previousDirs, sourceInfo)
 
 	case fs.File:
+		fileSizes := makeBuckets()
+		fileSizes.add(entry.Name(), entry.Size(), 1)
+
 		u.Progress.EstimatedDataSize(1, entry.Size())
+		u.Progress.EstimatedDataSizeDetailed(EstimatedDataSizeDetails{FileCount: 1, TotalFileSize: entry.Size(), FileSizes: fileSizes})
 		s.RootEntry, err = u.uploadFileWithCheckpointing(ctx, entry.Name(), entry, policyTree.EffectivePolicy(), sourceInfo)
 
 	default:
@@ -1343,9 +2129,15 @@ func (u *Uploader) Upload(
 	scanWG.Wait()
 
 	s.IncompleteReason = u.incompleteReason()
-	s.EndTime = u.repo.Time()
+	s.EndTime = u.now()
 	s.Stats = *u.stats
 
+	if u.ValidateManifestAfterUpload && !u.DryRun {
+		if err := u.validateUploadedManifest(ctx, s); err != nil {
+			return nil, errors.Wrap(err, "uploaded snapshot failed validation")
+		}
+	}
+
 	return s, nil
 }
 
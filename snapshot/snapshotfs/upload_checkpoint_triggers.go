@@ -0,0 +1,52 @@
+package snapshotfs
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMinCheckpointInterval is the default minimum time between checkpoints triggered by
+// CheckpointEveryBytes or CheckpointEveryFiles.
+const DefaultMinCheckpointInterval = 1 * time.Minute
+
+// resetCheckpointTriggers resets the number of bytes and files written since the last checkpoint,
+// which is assumed to be starting now.
+func (u *Uploader) resetCheckpointTriggers() {
+	atomic.StoreInt64(&u.bytesSinceCheckpoint, 0)
+	atomic.StoreInt32(&u.filesSinceCheckpoint, 0)
+	atomic.StoreInt64(&u.lastCheckpointTime, u.now().UnixNano())
+}
+
+// checkpointBytesWritten records bytes written and requests a checkpoint once CheckpointEveryBytes have been written.
+func (u *Uploader) checkpointBytesWritten(n int64) {
+	if u.CheckpointEveryBytes <= 0 {
+		return
+	}
+
+	if atomic.AddInt64(&u.bytesSinceCheckpoint, n) >= u.CheckpointEveryBytes {
+		u.maybeRequestTriggeredCheckpoint()
+	}
+}
+
+// checkpointFileUploaded records an uploaded file and requests a checkpoint once CheckpointEveryFiles have been uploaded.
+func (u *Uploader) checkpointFileUploaded() {
+	if u.CheckpointEveryFiles <= 0 {
+		return
+	}
+
+	if int(atomic.AddInt32(&u.filesSinceCheckpoint, 1)) >= u.CheckpointEveryFiles {
+		u.maybeRequestTriggeredCheckpoint()
+	}
+}
+
+// maybeRequestTriggeredCheckpoint requests a checkpoint unless the previous one was taken less than
+// MinCheckpointInterval ago. Triggers keep accumulating, so a checkpoint will be requested after
+// the interval passes, which prevents a storm of checkpoints when uploading many small files.
+func (u *Uploader) maybeRequestTriggeredCheckpoint() {
+	last := time.Unix(0, atomic.LoadInt64(&u.lastCheckpointTime))
+	if u.now().Sub(last) < u.MinCheckpointInterval {
+		return
+	}
+
+	u.RequestCheckpoint()
+}
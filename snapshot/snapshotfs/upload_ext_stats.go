@@ -0,0 +1,83 @@
+package snapshotfs
+
+import (
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+// ExtStat contains statistics about uploaded files with a particular extension.
+type ExtStat struct {
+	FileCount int64 `json:"files"`
+	TotalSize int64 `json:"totalSize"`
+
+	// CompressedSize is the number of bytes stored in the repository after compression and encryption,
+	// including contents that were deduplicated against other files.
+	CompressedSize int64 `json:"compressedSize"`
+}
+
+// extensionStats accumulates ExtStat for files uploaded in parallel.
+type extensionStats struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	stats map[string]ExtStat
+}
+
+func (s *extensionStats) add(ext string, size, compressedSize int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.stats[ext]
+	st.FileCount++
+	st.TotalSize += size
+	st.CompressedSize += compressedSize
+	s.stats[ext] = st
+}
+
+func (s *extensionStats) clone() map[string]ExtStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := map[string]ExtStat{}
+
+	for k, v := range s.stats {
+		result[k] = v
+	}
+
+	return result
+}
+
+func newExtensionStats() *extensionStats {
+	return &extensionStats{
+		stats: map[string]ExtStat{},
+	}
+}
+
+// fileExtension returns the lowercased extension of the provided file name, including the leading dot,
+// or an empty string if the file has no extension. Names of hidden files without another dot have no extension.
+func fileExtension(name string) string {
+	ext := path.Ext(name)
+	if ext == name {
+		return ""
+	}
+
+	return strings.ToLower(ext)
+}
+
+// recordExtensionStats adds the uploaded file to extension statistics.
+func (u *Uploader) recordExtensionStats(fname string, de *snapshot.DirEntry) {
+	u.extStats.add(fileExtension(fname), de.FileSize, de.PackedSize)
+}
+
+// ExtensionStats returns statistics of files uploaded by the most recent call to Upload() keyed by
+// lowercased file extension, files with no extension are reported under an empty string.
+// Returns nil unless RecordExtensionStats was set.
+func (u *Uploader) ExtensionStats() map[string]ExtStat {
+	if u.extStats == nil {
+		return nil
+	}
+
+	return u.extStats.clone()
+}
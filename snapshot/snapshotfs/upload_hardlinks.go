@@ -0,0 +1,79 @@
+package snapshotfs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/object"
+)
+
+// hardLinkKey identifies a file on a local filesystem regardless of its name.
+type hardLinkKey struct {
+	dev uint64
+	ino uint64
+}
+
+// hardLinkTarget is the uploaded object of a hard-linked file along with the metadata it was uploaded with.
+type hardLinkTarget struct {
+	oid     object.ID
+	size    int64
+	modTime time.Time
+}
+
+// hardLinkTracker remembers object IDs of uploaded files that have multiple hard links, so that
+// the remaining links can reuse them instead of hashing the same contents again.
+type hardLinkTracker struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	targets map[hardLinkKey]hardLinkTarget
+}
+
+func hardLinkKeyOf(e fs.Entry) (hardLinkKey, bool) {
+	di := e.Device()
+	if di.Ino == 0 || di.Nlink <= 1 {
+		return hardLinkKey{}, false
+	}
+
+	return hardLinkKey{di.Dev, di.Ino}, true
+}
+
+// find returns the object ID of previously uploaded hard link to the provided file or empty ID.
+// Links whose size or modification time differ from the uploaded one are not reused.
+func (t *hardLinkTracker) find(e fs.Entry) object.ID {
+	k, ok := hardLinkKeyOf(e)
+	if !ok {
+		return ""
+	}
+
+	t.mu.Lock()
+	target, ok := t.targets[k]
+	t.mu.Unlock()
+
+	if !ok || target.size != e.Size() || !target.modTime.Equal(e.ModTime()) {
+		return ""
+	}
+
+	return target.oid
+}
+
+// add records the object ID of an uploaded file, if it has multiple hard links.
+func (t *hardLinkTracker) add(e fs.Entry, oid object.ID) {
+	k, ok := hardLinkKeyOf(e)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.targets[k]; !ok {
+		t.targets[k] = hardLinkTarget{oid, e.Size(), e.ModTime()}
+	}
+}
+
+func newHardLinkTracker() *hardLinkTracker {
+	return &hardLinkTracker{
+		targets: map[hardLinkKey]hardLinkTarget{},
+	}
+}
@@ -0,0 +1,219 @@
+package snapshotfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// HashCacheKey identifies a version of a local file by its path and metadata.
+type HashCacheKey struct {
+	Path    string
+	Size    int64
+	ModTime int64 // UnixNano
+	Inode   uint64
+}
+
+// HashCacheValue describes the object of an uploaded file stored in the HashCache.
+type HashCacheValue struct {
+	ObjectID   object.ID
+	PackedSize int64
+}
+
+// HashCache remembers objects of previously uploaded local files, so that files which are not
+// found in any previous snapshot don't need to be hashed again. Implementations must only return
+// values stored with a key that matches the provided one exactly.
+type HashCache interface {
+	Get(ctx context.Context, key HashCacheKey) (HashCacheValue, bool)
+	Put(ctx context.Context, key HashCacheKey, v HashCacheValue)
+}
+
+func hashCacheKeyOf(e fs.Entry) (HashCacheKey, bool) {
+	p := e.LocalFilesystemPath()
+	if p == "" {
+		return HashCacheKey{}, false
+	}
+
+	return HashCacheKey{
+		Path:    p,
+		Size:    e.Size(),
+		ModTime: e.ModTime().UnixNano(),
+		Inode:   e.Device().Ino,
+	}, true
+}
+
+// hashCachedEntry is a file whose object was found in the hash cache.
+type hashCachedEntry struct {
+	fs.Entry
+	v HashCacheValue
+}
+
+func (e *hashCachedEntry) ObjectID() object.ID {
+	return e.v.ObjectID
+}
+
+func (e *hashCachedEntry) DirEntry() *snapshot.DirEntry {
+	return &snapshot.DirEntry{
+		ObjectID:   e.v.ObjectID,
+		PackedSize: e.v.PackedSize,
+	}
+}
+
+// findHashCachedEntry returns the provided file with the object stored in the hash cache or nil if there's none.
+func (u *Uploader) findHashCachedEntry(ctx context.Context, entry fs.Entry) fs.Entry {
+	if u.HashCache == nil {
+		return nil
+	}
+
+	if _, ok := entry.(fs.File); !ok {
+		return nil
+	}
+
+	key, ok := hashCacheKeyOf(entry)
+	if !ok {
+		return nil
+	}
+
+	v, ok := u.HashCache.Get(ctx, key)
+	if !ok {
+		return nil
+	}
+
+	// the object may have been garbage-collected since it was cached.
+	if _, err := u.repo.VerifyObject(ctx, v.ObjectID); err != nil {
+		uploadLog(ctx).Debugw("ignoring hash cache entry", "path", key.Path, "oid", v.ObjectID, "error", err)
+		return nil
+	}
+
+	return &hashCachedEntry{entry, v}
+}
+
+// updateHashCache stores the object of the provided file in the hash cache, unless the file has been
+// modified while it was being uploaded.
+func (u *Uploader) updateHashCache(ctx context.Context, entry fs.Entry, de *snapshot.DirEntry) {
+	if u.HashCache == nil || de.ObjectID == "" {
+		return
+	}
+
+	if _, ok := entry.(snapshot.HasDirEntryOrNil); ok {
+		// placeholders don't have the metadata of the files they represent.
+		return
+	}
+
+	if de.FileSize != entry.Size() || !de.ModTime.Equal(entry.ModTime()) {
+		return
+	}
+
+	if key, ok := hashCacheKeyOf(entry); ok {
+		u.HashCache.Put(ctx, key, HashCacheValue{de.ObjectID, de.PackedSize})
+	}
+}
+
+// LocalHashCache is a HashCache stored in a local file, which must be saved explicitly after
+// the uploaded objects have been flushed to the repository.
+type LocalHashCache struct {
+	filename string
+
+	mu sync.Mutex
+	// +checklocks:mu
+	entries map[string]localHashCacheEntry
+	// +checklocks:mu
+	dirty bool
+}
+
+type localHashCacheEntry struct {
+	Size       int64     `json:"size"`
+	ModTime    int64     `json:"mtime"`
+	Inode      uint64    `json:"ino,omitempty"`
+	ObjectID   object.ID `json:"oid"`
+	PackedSize int64     `json:"packed,omitempty"`
+}
+
+type localHashCacheFile struct {
+	Entries map[string]localHashCacheEntry `json:"entries"`
+}
+
+// Get implements HashCache.
+func (c *LocalHashCache) Get(ctx context.Context, key HashCacheKey) (HashCacheValue, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[key.Path]
+	c.mu.Unlock()
+
+	if !ok || e.Size != key.Size || e.ModTime != key.ModTime || e.Inode != key.Inode {
+		return HashCacheValue{}, false
+	}
+
+	return HashCacheValue{e.ObjectID, e.PackedSize}, true
+}
+
+// Put implements HashCache.
+func (c *LocalHashCache) Put(ctx context.Context, key HashCacheKey, v HashCacheValue) {
+	e := localHashCacheEntry{key.Size, key.ModTime, key.Inode, v.ObjectID, v.PackedSize}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries[key.Path] != e {
+		c.entries[key.Path] = e
+		c.dirty = true
+	}
+}
+
+// Save writes the hash cache to its file if it has changed.
+func (c *LocalHashCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	b, err := json.Marshal(&localHashCacheFile{c.entries})
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal hash cache")
+	}
+
+	if err := atomicfile.Write(c.filename, bytes.NewReader(b)); err != nil {
+		return errors.Wrap(err, "unable to write hash cache")
+	}
+
+	c.dirty = false
+
+	return nil
+}
+
+// OpenLocalHashCache opens the hash cache stored in the provided file, which doesn't need to exist.
+func OpenLocalHashCache(filename string) (*LocalHashCache, error) {
+	var f localHashCacheFile
+
+	b, err := os.ReadFile(filename) // nolint:gosec
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, errors.Wrap(err, "unable to read hash cache")
+	default:
+		if err := json.Unmarshal(b, &f); err != nil {
+			return nil, errors.Wrap(err, "invalid hash cache")
+		}
+	}
+
+	if f.Entries == nil {
+		f.Entries = map[string]localHashCacheEntry{}
+	}
+
+	return &LocalHashCache{
+		filename: filename,
+		entries:  f.Entries,
+	}, nil
+}
+
+var _ HashCache = (*LocalHashCache)(nil)
@@ -0,0 +1,144 @@
+package snapshotfs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// Keys of metadata attached to objects written by the uploader when AttachObjectMetadata is set.
+const (
+	ObjectMetadataSource     = "source"
+	ObjectMetadataPath       = "path"
+	ObjectMetadataEntryType  = "type"
+	ObjectMetadataSnapshotID = "snapshotID"
+)
+
+// ObjectMetadataManifestType is the type of the manifest used to store metadata of objects written by the uploader.
+const ObjectMetadataManifestType = "objectMetadata"
+
+// ObjectMetadataSnapshotIDLabel is the label of the object metadata manifest identifying its snapshot.
+const ObjectMetadataSnapshotIDLabel = "snapshotID"
+
+// ObjectMetadata attributes an object written by the uploader to its origin.
+type ObjectMetadata struct {
+	ObjectID object.ID         `json:"oid"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// objectMetadataRecord is stored as JSON in a manifest with the type label set to ObjectMetadataManifestType.
+type objectMetadataRecord struct {
+	SnapshotID manifest.ID      `json:"snapshotID"`
+	Objects    []ObjectMetadata `json:"objects"`
+}
+
+// objectMetadataCollector accumulates ObjectMetadata for objects written in parallel.
+type objectMetadataCollector struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	objects []ObjectMetadata
+}
+
+func (c *objectMetadataCollector) add(oid object.ID, md map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.objects = append(c.objects, ObjectMetadata{oid, md})
+}
+
+func (c *objectMetadataCollector) clone() []ObjectMetadata {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]ObjectMetadata{}, c.objects...)
+}
+
+// objectMetadata returns the metadata to attach to the object of the provided entry or nil
+// if AttachObjectMetadata is not set.
+func (u *Uploader) objectMetadata(entryType snapshot.EntryType, relativePath string) map[string]string {
+	if !u.AttachObjectMetadata {
+		return nil
+	}
+
+	return map[string]string{
+		ObjectMetadataSource:    u.sourceInfo.String(),
+		ObjectMetadataPath:      relativePath,
+		ObjectMetadataEntryType: string(entryType),
+	}
+}
+
+// recordObjectMetadata remembers the metadata attached to the written object, if any.
+func (u *Uploader) recordObjectMetadata(oid object.ID, md map[string]string) {
+	if md != nil && u.objectMetadataCollector != nil {
+		u.objectMetadataCollector.add(oid, md)
+	}
+}
+
+// WrittenObjectMetadata returns metadata of objects written by the most recent call to Upload(),
+// which can be stored in the repository using SaveObjectMetadata() once the snapshot is saved.
+// Returns nil unless AttachObjectMetadata was set.
+func (u *Uploader) WrittenObjectMetadata() []ObjectMetadata {
+	if u.objectMetadataCollector == nil {
+		return nil
+	}
+
+	return u.objectMetadataCollector.clone()
+}
+
+// SaveObjectMetadata stores metadata of objects written for the provided snapshot in the repository,
+// adding the snapshot ID to the metadata of each object. The manifest is not removed along with the snapshot.
+func SaveObjectMetadata(ctx context.Context, rep repo.RepositoryWriter, snapshotID manifest.ID, objects []ObjectMetadata) (manifest.ID, error) {
+	r := &objectMetadataRecord{SnapshotID: snapshotID}
+
+	for _, o := range objects {
+		md := map[string]string{
+			ObjectMetadataSnapshotID: string(snapshotID),
+		}
+
+		for k, v := range o.Metadata {
+			md[k] = v
+		}
+
+		r.Objects = append(r.Objects, ObjectMetadata{o.ObjectID, md})
+	}
+
+	id, err := rep.PutManifest(ctx, map[string]string{
+		manifest.TypeLabelKey:         ObjectMetadataManifestType,
+		ObjectMetadataSnapshotIDLabel: string(snapshotID),
+	}, r)
+	if err != nil {
+		return "", errors.Wrap(err, "error writing object metadata")
+	}
+
+	return id, nil
+}
+
+// LoadObjectMetadata returns metadata of objects stored in the repository for the provided snapshot.
+func LoadObjectMetadata(ctx context.Context, rep repo.Repository, snapshotID manifest.ID) ([]ObjectMetadata, error) {
+	entries, err := rep.FindManifests(ctx, map[string]string{
+		manifest.TypeLabelKey:         ObjectMetadataManifestType,
+		ObjectMetadataSnapshotIDLabel: string(snapshotID),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing object metadata")
+	}
+
+	var result []ObjectMetadata
+
+	for _, e := range entries {
+		r := &objectMetadataRecord{}
+		if _, err := rep.GetManifest(ctx, e.ID, r); err != nil {
+			return nil, errors.Wrapf(err, "error loading object metadata %v", e.ID)
+		}
+
+		result = append(result, r.Objects...)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,122 @@
+package snapshotfs
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/kopia/kopia/internal/clock"
+)
+
+// DefaultParallelismAdjustmentInterval is the default interval between adjustments of the number
+// of parallel uploads when Uploader.AdaptiveParallelism is enabled.
+const DefaultParallelismAdjustmentInterval = 5 * time.Second
+
+// parallelismThroughputTolerance is the relative change of throughput between samples
+// below which the throughput is considered to have plateaued.
+const parallelismThroughputTolerance = 0.1
+
+// parallelismController adjusts the number of parallel uploads between the minimum and maximum
+// based on the observed throughput. It grows the parallelism as long as that increases throughput
+// and backs off when throughput drops or stops improving after growing.
+type parallelismController struct {
+	min, max int
+	current  int
+
+	lastTime       time.Time
+	lastBytes      int64
+	lastThroughput float64
+	lastChange     int
+}
+
+// sample records the total number of bytes written as of the provided time and returns
+// the adjusted parallelism.
+func (c *parallelismController) sample(now time.Time, totalBytes int64) int {
+	if c.lastTime.IsZero() || !now.After(c.lastTime) {
+		c.lastTime = now
+		c.lastBytes = totalBytes
+
+		return c.current
+	}
+
+	throughput := float64(totalBytes-c.lastBytes) / now.Sub(c.lastTime).Seconds()
+
+	c.lastTime = now
+	c.lastBytes = totalBytes
+
+	change := 0
+
+	switch {
+	case c.lastThroughput == 0 || throughput > c.lastThroughput*(1+parallelismThroughputTolerance):
+		// throughput is improving, try adding more workers.
+		change = 1
+
+	case throughput < c.lastThroughput*(1-parallelismThroughputTolerance):
+		change = -1
+
+	case c.lastChange > 0:
+		// the last worker we added did not help.
+		change = -1
+	}
+
+	c.lastThroughput = throughput
+	c.lastChange = 0
+
+	if n := c.current + change; n >= c.min && n <= c.max {
+		c.current = n
+		c.lastChange = change
+	}
+
+	return c.current
+}
+
+func newParallelismController(min, max int) *parallelismController {
+	if min < 1 {
+		min = 1
+	}
+
+	if min > max {
+		min = max
+	}
+
+	return &parallelismController{
+		min:     min,
+		max:     max,
+		current: min,
+	}
+}
+
+// periodicallyAdjustParallelism limits the number of active upload workers starting with MinParallelUploads
+// and adjusts it up to maxParallel every ParallelismAdjustmentInterval until the returned cancelation function
+// has been called.
+func (u *Uploader) periodicallyAdjustParallelism(ctx context.Context, maxParallel int) (cancelFunc func()) {
+	c := newParallelismController(u.MinParallelUploads, maxParallel)
+
+	// the goroutine calling Upload() is an upload worker too.
+	u.workerPool.SetMaxActiveWorkers(c.current - 1)
+	c.sample(clock.Now(), atomic.LoadInt64(&u.totalWrittenBytes))
+
+	shutdown := make(chan struct{})
+	ch := u.getTicker(u.ParallelismAdjustmentInterval)
+
+	go func() {
+		for {
+			select {
+			case <-shutdown:
+				return
+
+			case <-ch:
+				prev := c.current
+
+				if n := c.sample(clock.Now(), atomic.LoadInt64(&u.totalWrittenBytes)); n != prev {
+					uploadLog(ctx).Debugf("adjusting parallel uploads from %v to %v", prev, n)
+					u.workerPool.SetMaxActiveWorkers(n - 1)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(shutdown)
+	}
+}
@@ -0,0 +1,57 @@
+package snapshotfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallelismController(t *testing.T) {
+	c := newParallelismController(2, 5)
+	require.Equal(t, 2, c.current)
+
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var totalBytes int64
+
+	// each step advances time by one second and reports throughput in bytes/sec.
+	step := func(throughput int64) int {
+		t0 = t0.Add(time.Second)
+		totalBytes += throughput
+
+		return c.sample(t0, totalBytes)
+	}
+
+	// first sample only establishes the baseline.
+	require.Equal(t, 2, c.sample(t0, totalBytes))
+
+	// throughput keeps improving, parallelism grows up to the maximum.
+	require.Equal(t, 3, step(100))
+	require.Equal(t, 4, step(200))
+	require.Equal(t, 5, step(300))
+	require.Equal(t, 5, step(400))
+
+	// throughput has plateaued, but the last sample did not add a worker.
+	require.Equal(t, 5, step(410))
+
+	// throughput drops, back off down to the minimum.
+	require.Equal(t, 4, step(300))
+	require.Equal(t, 3, step(200))
+	require.Equal(t, 2, step(100))
+	require.Equal(t, 2, step(50))
+
+	// throughput improves after adding a worker, but plateaus after adding another one.
+	require.Equal(t, 3, step(100))
+	require.Equal(t, 4, step(200))
+	require.Equal(t, 3, step(205))
+	require.Equal(t, 3, step(205))
+
+	// samples at the same time are ignored.
+	require.Equal(t, 3, c.sample(t0, totalBytes+1000))
+}
+
+func TestParallelismControllerLimits(t *testing.T) {
+	require.Equal(t, 1, newParallelismController(0, 5).current)
+	require.Equal(t, 3, newParallelismController(10, 3).current)
+}
@@ -27,10 +27,15 @@ type UploadProgress interface {
 	// ExcludedDir is emitted when a directory is excluded.
 	ExcludedDir(dirname string)
 
+	// SkippedSpecialFile is emitted when a socket, named pipe or device is skipped.
+	// Only emitted when Uploader.SkipSpecialFiles is set.
+	SkippedSpecialFile(fname string)
+
 	// FinishedHashingFile is emitted at the end of hashing of a given file.
 	FinishedHashingFile(fname string, numBytes int64)
 
-	// HashedBytes is emitted while hashing any blocks of bytes.
+	// HashedBytes is emitted while hashing any blocks of bytes. The number of bytes is negative when bytes
+	// reported earlier are discarded, such as when reading a file is retried after a transient error.
 	HashedBytes(numBytes int64)
 
 	// Error is emitted when an error is encountered.
@@ -47,6 +52,23 @@ type UploadProgress interface {
 
 	// EstimatedDataSize is emitted whenever the size of upload is estimated.
 	EstimatedDataSize(fileCount int, totalBytes int64)
+
+	// EstimatedDataSizeDetailed is emitted after EstimatedDataSize with the breakdown of the estimate.
+	EstimatedDataSizeDetailed(details EstimatedDataSizeDetails)
+
+	// UploadReason is emitted before a file is hashed with the reason why a cached entry could not be used.
+	// Only emitted when Uploader.RecordUploadReasons is set.
+	UploadReason(path string, reason UploadReason)
+}
+
+// EstimatedDataSizeDetails describes the estimated size of upload.
+type EstimatedDataSizeDetails struct {
+	FileCount      int   `json:"files"`
+	TotalFileSize  int64 `json:"bytes"`
+	DirectoryCount int   `json:"dirs"`
+
+	// FileSizes is the histogram of sizes of files to be uploaded, sorted by descending minimum size.
+	FileSizes SampleBuckets `json:"fileSizes,omitempty"`
 }
 
 // NullUploadProgress is an implementation of UploadProgress that does not produce any output.
@@ -58,6 +80,9 @@ func (p *NullUploadProgress) UploadStarted() {}
 // EstimatedDataSize implements UploadProgress.
 func (p *NullUploadProgress) EstimatedDataSize(fileCount int, totalBytes int64) {}
 
+// EstimatedDataSizeDetailed implements UploadProgress.
+func (p *NullUploadProgress) EstimatedDataSizeDetailed(details EstimatedDataSizeDetails) {}
+
 // UploadFinished implements UploadProgress.
 func (p *NullUploadProgress) UploadFinished() {}
 
@@ -70,9 +95,15 @@ func (p *NullUploadProgress) ExcludedFile(fname string, numBytes int64) {}
 // ExcludedDir implements UploadProgress.
 func (p *NullUploadProgress) ExcludedDir(dirname string) {}
 
+// SkippedSpecialFile implements UploadProgress.
+func (p *NullUploadProgress) SkippedSpecialFile(fname string) {}
+
 // CachedFile implements UploadProgress.
 func (p *NullUploadProgress) CachedFile(fname string, numBytes int64) {}
 
+// CachedFiles implements CoalescedCachedFileProgress.
+func (p *NullUploadProgress) CachedFiles(numFiles int32, numBytes int64) {}
+
 // UploadedBytes implements UploadProgress.
 func (p *NullUploadProgress) UploadedBytes(numBytes int64) {}
 
@@ -91,8 +122,13 @@ func (p *NullUploadProgress) FinishedDirectory(dirname string) {}
 // Error implements UploadProgress.
 func (p *NullUploadProgress) Error(path string, err error, isIgnored bool) {}
 
+// UploadReason implements UploadProgress.
+func (p *NullUploadProgress) UploadReason(path string, reason UploadReason) {}
+
 var _ UploadProgress = (*NullUploadProgress)(nil)
 
+var _ CoalescedCachedFileProgress = (*NullUploadProgress)(nil)
+
 // UploadCounters represents a snapshot of upload counters.
 type UploadCounters struct {
 	// +checkatomic
@@ -114,6 +150,8 @@ type UploadCounters struct {
 	TotalExcludedFiles int32 `json:"excludedFiles"`
 	// +checkatomic
 	TotalExcludedDirs int32 `json:"excludedDirs"`
+	// +checkatomic
+	TotalSkippedSpecialFiles int32 `json:"skippedSpecialFiles"`
 
 	// +checkatomic
 	FatalErrorCount int32 `json:"errors"`
@@ -121,6 +159,10 @@ type UploadCounters struct {
 	IgnoredErrorCount int32 `json:"ignoredErrors"`
 	// +checkatomic
 	EstimatedFiles int32 `json:"estimatedFiles"`
+	// +checkatomic
+	EstimatedDirs int32 `json:"estimatedDirs"`
+
+	EstimatedFileSizes SampleBuckets `json:"estimatedFileSizes,omitempty"`
 
 	CurrentDirectory string `json:"directory"`
 
@@ -154,6 +196,15 @@ func (p *CountingUploadProgress) EstimatedDataSize(numFiles int, numBytes int64)
 	atomic.StoreInt32(&p.counters.EstimatedFiles, int32(numFiles))
 }
 
+// EstimatedDataSizeDetailed implements UploadProgress.
+func (p *CountingUploadProgress) EstimatedDataSizeDetailed(details EstimatedDataSizeDetails) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	atomic.StoreInt32(&p.counters.EstimatedDirs, int32(details.DirectoryCount))
+	p.counters.EstimatedFileSizes = details.FileSizes
+}
+
 // HashedBytes implements UploadProgress.
 func (p *CountingUploadProgress) HashedBytes(numBytes int64) {
 	atomic.AddInt64(&p.counters.TotalHashedBytes, numBytes)
@@ -161,7 +212,12 @@ func (p *CountingUploadProgress) HashedBytes(numBytes int64) {
 
 // CachedFile implements UploadProgress.
 func (p *CountingUploadProgress) CachedFile(fname string, numBytes int64) {
-	atomic.AddInt32(&p.counters.TotalCachedFiles, 1)
+	p.CachedFiles(1, numBytes)
+}
+
+// CachedFiles implements CoalescedCachedFileProgress.
+func (p *CountingUploadProgress) CachedFiles(numFiles int32, numBytes int64) {
+	atomic.AddInt32(&p.counters.TotalCachedFiles, numFiles)
 	atomic.AddInt64(&p.counters.TotalCachedBytes, numBytes)
 }
 
@@ -180,6 +236,11 @@ func (p *CountingUploadProgress) ExcludedFile(fname string, numBytes int64) {
 	atomic.AddInt32(&p.counters.TotalExcludedFiles, 1)
 }
 
+// SkippedSpecialFile implements UploadProgress.
+func (p *CountingUploadProgress) SkippedSpecialFile(fname string) {
+	atomic.AddInt32(&p.counters.TotalSkippedSpecialFiles, 1)
+}
+
 // Error implements UploadProgress.
 func (p *CountingUploadProgress) Error(path string, err error, isIgnored bool) {
 	p.mu.Lock()
@@ -209,15 +270,17 @@ func (p *CountingUploadProgress) Snapshot() UploadCounters {
 	defer p.mu.Unlock()
 
 	return UploadCounters{
-		TotalCachedFiles: atomic.LoadInt32(&p.counters.TotalCachedFiles),
-		TotalHashedFiles: atomic.LoadInt32(&p.counters.TotalHashedFiles),
-		TotalCachedBytes: atomic.LoadInt64(&p.counters.TotalCachedBytes),
-		TotalHashedBytes: atomic.LoadInt64(&p.counters.TotalHashedBytes),
-		EstimatedBytes:   atomic.LoadInt64(&p.counters.EstimatedBytes),
-		EstimatedFiles:   atomic.LoadInt32(&p.counters.EstimatedFiles),
-		CurrentDirectory: p.counters.CurrentDirectory,
-		LastErrorPath:    p.counters.LastErrorPath,
-		LastError:        p.counters.LastError,
+		TotalCachedFiles:   atomic.LoadInt32(&p.counters.TotalCachedFiles),
+		TotalHashedFiles:   atomic.LoadInt32(&p.counters.TotalHashedFiles),
+		TotalCachedBytes:   atomic.LoadInt64(&p.counters.TotalCachedBytes),
+		TotalHashedBytes:   atomic.LoadInt64(&p.counters.TotalHashedBytes),
+		EstimatedBytes:     atomic.LoadInt64(&p.counters.EstimatedBytes),
+		EstimatedFiles:     atomic.LoadInt32(&p.counters.EstimatedFiles),
+		EstimatedDirs:      atomic.LoadInt32(&p.counters.EstimatedDirs),
+		EstimatedFileSizes: p.counters.EstimatedFileSizes,
+		CurrentDirectory:   p.counters.CurrentDirectory,
+		LastErrorPath:      p.counters.LastErrorPath,
+		LastError:          p.counters.LastError,
 	}
 }
 
@@ -244,15 +307,20 @@ func (p *CountingUploadProgress) UITaskCounters(final bool) map[string]uitask.Co
 		"Excluded Files":       uitask.SimpleCounter(int64(atomic.LoadInt32(&p.counters.TotalExcludedFiles))),
 		"Excluded Directories": uitask.SimpleCounter(int64(atomic.LoadInt32(&p.counters.TotalExcludedDirs))),
 
+		"Skipped Special Files": uitask.SimpleCounter(int64(atomic.LoadInt32(&p.counters.TotalSkippedSpecialFiles))),
+
 		"Errors": uitask.ErrorCounter(int64(atomic.LoadInt32(&p.counters.IgnoredErrorCount))),
 	}
 
 	if !final {
 		m["Estimated Files"] = uitask.SimpleCounter(int64(atomic.LoadInt32(&p.counters.EstimatedFiles)))
 		m["Estimated Bytes"] = uitask.BytesCounter(atomic.LoadInt64(&p.counters.EstimatedBytes))
+		m["Estimated Directories"] = uitask.SimpleCounter(int64(atomic.LoadInt32(&p.counters.EstimatedDirs)))
 	}
 
 	return m
 }
 
 var _ UploadProgress = (*CountingUploadProgress)(nil)
+
+var _ CoalescedCachedFileProgress = (*CountingUploadProgress)(nil)
@@ -0,0 +1,131 @@
+package snapshotfs
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+)
+
+// JSONProgressVersion is the version of the schema of events emitted by JSONProgress.
+const JSONProgressVersion = 1
+
+// Types of events emitted by JSONProgress.
+const (
+	JSONProgressEventHashingFile   = "hashing-file"
+	JSONProgressEventCachedFile    = "cached-file"
+	JSONProgressEventFinishedFile  = "finished-file"
+	JSONProgressEventExcluded      = "excluded"
+	JSONProgressEventSkipped       = "skipped-special"
+	JSONProgressEventError         = "error"
+	JSONProgressEventEstimatedSize = "estimated-size"
+)
+
+// JSONProgressEvent is a single event written by JSONProgress.
+type JSONProgressEvent struct {
+	Version   int       `json:"v"`
+	Sequence  int64     `json:"seq"`
+	Timestamp time.Time `json:"time"`
+	Type      string    `json:"type"`
+	Path      string    `json:"path,omitempty"`
+	Directory bool      `json:"dir,omitempty"`
+	Bytes     int64     `json:"bytes,omitempty"`
+	Files     int       `json:"files,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Ignored   bool      `json:"ignored,omitempty"`
+}
+
+// JSONProgress is an implementation of UploadProgress that writes newline-delimited JSON events
+// to the provided writer. It is safe for concurrent use by parallel uploads.
+type JSONProgress struct {
+	NullUploadProgress
+
+	mu sync.Mutex
+	// +checklocks:mu
+	enc *json.Encoder
+	// +checklocks:mu
+	nextSequence int64
+	// +checklocks:mu
+	err error
+}
+
+func (p *JSONProgress) emit(ev JSONProgressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return
+	}
+
+	p.nextSequence++
+
+	ev.Version = JSONProgressVersion
+	ev.Sequence = p.nextSequence
+	ev.Timestamp = clock.Now()
+
+	if err := p.enc.Encode(ev); err != nil {
+		p.err = errors.Wrap(err, "error writing progress event")
+	}
+}
+
+// Err returns the error encountered when writing events, if any. No more events are written after the first error.
+func (p *JSONProgress) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
+
+// HashingFile implements UploadProgress.
+func (p *JSONProgress) HashingFile(fname string) {
+	p.emit(JSONProgressEvent{Type: JSONProgressEventHashingFile, Path: fname})
+}
+
+// FinishedHashingFile implements UploadProgress.
+func (p *JSONProgress) FinishedHashingFile(fname string, numBytes int64) {
+	p.emit(JSONProgressEvent{Type: JSONProgressEventFinishedFile, Path: fname, Bytes: numBytes})
+}
+
+// CachedFile implements UploadProgress.
+func (p *JSONProgress) CachedFile(fname string, numBytes int64) {
+	p.emit(JSONProgressEvent{Type: JSONProgressEventCachedFile, Path: fname, Bytes: numBytes})
+}
+
+// ExcludedFile implements UploadProgress.
+func (p *JSONProgress) ExcludedFile(fname string, numBytes int64) {
+	p.emit(JSONProgressEvent{Type: JSONProgressEventExcluded, Path: fname, Bytes: numBytes})
+}
+
+// ExcludedDir implements UploadProgress.
+func (p *JSONProgress) ExcludedDir(dirname string) {
+	p.emit(JSONProgressEvent{Type: JSONProgressEventExcluded, Path: dirname, Directory: true})
+}
+
+// SkippedSpecialFile implements UploadProgress.
+func (p *JSONProgress) SkippedSpecialFile(fname string) {
+	p.emit(JSONProgressEvent{Type: JSONProgressEventSkipped, Path: fname})
+}
+
+// Error implements UploadProgress.
+func (p *JSONProgress) Error(path string, err error, isIgnored bool) {
+	p.emit(JSONProgressEvent{Type: JSONProgressEventError, Path: path, Error: err.Error(), Ignored: isIgnored})
+}
+
+// EstimatedDataSize implements UploadProgress.
+func (p *JSONProgress) EstimatedDataSize(fileCount int, totalBytes int64) {
+	p.emit(JSONProgressEvent{Type: JSONProgressEventEstimatedSize, Files: fileCount, Bytes: totalBytes})
+}
+
+var _ UploadProgress = (*JSONProgress)(nil)
+
+// NewJSONProgress returns UploadProgress that writes newline-delimited JSON events to the provided writer.
+// To use it, assign it to Uploader.Progress after calling NewUploader().
+func NewJSONProgress(w io.Writer) *JSONProgress {
+	return &JSONProgress{
+		enc: json.NewEncoder(w),
+	}
+}
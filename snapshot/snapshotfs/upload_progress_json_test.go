@@ -0,0 +1,79 @@
+package snapshotfs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONProgress(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewJSONProgress(&buf)
+
+	const numWorkers = 10
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			p.HashingFile("f1")
+			p.FinishedHashingFile("f1", 100)
+			p.CachedFile("f2", 200)
+			p.ExcludedFile("f3", 300)
+			p.ExcludedDir("d1")
+			p.Error("f4", errors.New("some error"), true)
+			p.EstimatedDataSize(5, 600)
+		}()
+	}
+
+	wg.Wait()
+
+	require.NoError(t, p.Err())
+
+	counts := map[string]int{}
+
+	var lastSeq int64
+
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		var ev JSONProgressEvent
+
+		require.NoError(t, json.Unmarshal(s.Bytes(), &ev))
+		require.Equal(t, JSONProgressVersion, ev.Version)
+		require.Equal(t, lastSeq+1, ev.Sequence)
+		require.False(t, ev.Timestamp.IsZero())
+
+		lastSeq = ev.Sequence
+		counts[ev.Type]++
+
+		switch ev.Type {
+		case JSONProgressEventError:
+			require.Equal(t, "f4", ev.Path)
+			require.Equal(t, "some error", ev.Error)
+			require.True(t, ev.Ignored)
+		case JSONProgressEventEstimatedSize:
+			require.Equal(t, 5, ev.Files)
+			require.Equal(t, int64(600), ev.Bytes)
+		}
+	}
+
+	require.NoError(t, s.Err())
+	require.Equal(t, map[string]int{
+		JSONProgressEventHashingFile:   numWorkers,
+		JSONProgressEventFinishedFile:  numWorkers,
+		JSONProgressEventCachedFile:    numWorkers,
+		JSONProgressEventExcluded:      2 * numWorkers,
+		JSONProgressEventError:         numWorkers,
+		JSONProgressEventEstimatedSize: numWorkers,
+	}, counts)
+}
@@ -0,0 +1,139 @@
+package snapshotfs
+
+import (
+	"sync"
+	"time"
+)
+
+// CoalescedCachedFileProgress is optionally implemented by UploadProgress that can receive notifications
+// about multiple cached files at once, which allows Uploader.ProgressUpdateInterval to coalesce them.
+// Progress that doesn't implement it receives a CachedFile notification for each cached file, except
+// for files of directories reused from a checkpoint, which are reported with a single notification
+// for the directory.
+type CoalescedCachedFileProgress interface {
+	CachedFiles(numFiles int32, numBytes int64)
+}
+
+// reportCachedFiles reports multiple cached files under the provided path at once.
+func reportCachedFiles(p UploadProgress, path string, numFiles int32, numBytes int64) {
+	if numFiles == 0 {
+		return
+	}
+
+	if tp, ok := p.(*throttledUploadProgress); ok && tp.cached != nil {
+		tp.addCachedFiles(numFiles, numBytes)
+		return
+	}
+
+	if cp, ok := p.(CoalescedCachedFileProgress); ok {
+		cp.CachedFiles(numFiles, numBytes)
+		return
+	}
+
+	p.CachedFile(path, numBytes)
+}
+
+// throttledUploadProgress coalesces HashedBytes and CachedFile notifications, which are delivered to the
+// underlying UploadProgress at most once per interval. All other notifications are passed through.
+type throttledUploadProgress struct {
+	UploadProgress
+
+	interval time.Duration
+	cached   CoalescedCachedFileProgress
+
+	mu sync.Mutex
+	// +checklocks:mu
+	hashedBytes int64
+	// +checklocks:mu
+	cachedFiles int32
+	// +checklocks:mu
+	cachedBytes int64
+	// +checklocks:mu
+	timer *time.Timer
+}
+
+func newThrottledUploadProgress(p UploadProgress, interval time.Duration) *throttledUploadProgress {
+	cached, _ := p.(CoalescedCachedFileProgress)
+
+	return &throttledUploadProgress{
+		UploadProgress: p,
+		interval:       interval,
+		cached:         cached,
+	}
+}
+
+// HashedBytes implements UploadProgress.
+func (p *throttledUploadProgress) HashedBytes(numBytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.hashedBytes += numBytes
+	p.scheduleFlushLocked()
+}
+
+// CachedFile implements UploadProgress.
+func (p *throttledUploadProgress) CachedFile(fname string, numBytes int64) {
+	if p.cached == nil {
+		p.UploadProgress.CachedFile(fname, numBytes)
+		return
+	}
+
+	p.addCachedFiles(1, numBytes)
+}
+
+func (p *throttledUploadProgress) addCachedFiles(numFiles int32, numBytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cachedFiles += numFiles
+	p.cachedBytes += numBytes
+	p.scheduleFlushLocked()
+}
+
+// UploadFinished implements UploadProgress.
+func (p *throttledUploadProgress) UploadFinished() {
+	p.mu.Lock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+
+	// deliver pending notifications so that the totals are exact.
+	p.flushLocked()
+	p.mu.Unlock()
+
+	p.UploadProgress.UploadFinished()
+}
+
+// +checklocks:p.mu
+func (p *throttledUploadProgress) scheduleFlushLocked() {
+	if p.timer != nil {
+		return
+	}
+
+	p.timer = time.AfterFunc(p.interval, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.timer = nil
+		p.flushLocked()
+	})
+}
+
+// flushLocked delivers accumulated notifications, it is invoked while holding the lock to ensure that
+// they are not delivered after UploadFinished.
+// +checklocks:p.mu
+func (p *throttledUploadProgress) flushLocked() {
+	if p.hashedBytes != 0 {
+		p.UploadProgress.HashedBytes(p.hashedBytes)
+		p.hashedBytes = 0
+	}
+
+	if p.cachedFiles != 0 {
+		p.cached.CachedFiles(p.cachedFiles, p.cachedBytes)
+		p.cachedFiles, p.cachedBytes = 0, 0
+	}
+}
+
+var _ UploadProgress = (*throttledUploadProgress)(nil)
@@ -0,0 +1,117 @@
+package snapshotfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// resumableDirectory is a directory from a checkpoint of an interrupted snapshot. Subdirectories
+// of resumable directories that were fully uploaded before the snapshot was interrupted and were not
+// modified since then are reused by the uploader without reading their contents again.
+type resumableDirectory struct {
+	*repositoryDirectory
+
+	// modification time of the source directory when it was uploaded, zero for the root. ModTime() of
+	// repository directories returns the latest modification time of their contents instead.
+	sourceModTime time.Time
+}
+
+func (d resumableDirectory) Readdir(ctx context.Context) (fs.Entries, error) {
+	r, err := d.repo.OpenObject(ctx, d.metadata.ObjectID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open object: %v", d.metadata.ObjectID)
+	}
+	defer r.Close() //nolint:errcheck
+
+	metadata, _, err := readDirEntries(r)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(fs.Entries, len(metadata))
+
+	for i, m := range metadata {
+		sourceModTime := m.ModTime
+
+		entries[i] = EntryFromDirEntry(d.repo, m)
+		if rd, ok := entries[i].(*repositoryDirectory); ok {
+			entries[i] = resumableDirectory{rd, sourceModTime}
+		}
+	}
+
+	entries.Sort()
+
+	return entries, nil
+}
+
+// completeDirEntry returns the directory entry if the directory manifest it points to is complete or nil otherwise.
+// Directory manifests written as part of a checkpoint carry IncompleteReason and must be walked again.
+func (d resumableDirectory) completeDirEntry() *snapshot.DirEntry {
+	de := d.DirEntry()
+
+	if de.ObjectID == "" || de.DirSummary == nil {
+		return nil
+	}
+
+	if de.DirSummary.IncompleteReason != "" || de.DirSummary.FatalErrorCount > 0 {
+		return nil
+	}
+
+	return de
+}
+
+// maybeResumeDirectory returns the entry for the provided directory reusing a complete directory manifest
+// from a previous checkpoint, or nil if none of previous directories can be reused. Directories whose
+// modification time has changed since the checkpoint, because entries were added, removed or renamed,
+// are not reused.
+func (u *Uploader) maybeResumeDirectory(ctx context.Context, dirRelativePath string, dir fs.Directory, previousDirs []fs.Directory) (*snapshot.DirEntry, error) {
+	for _, pd := range previousDirs {
+		rd, ok := pd.(resumableDirectory)
+		if !ok {
+			continue
+		}
+
+		prev := rd.completeDirEntry()
+		if prev == nil || !rd.sourceModTime.Equal(dir.ModTime()) {
+			continue
+		}
+
+		de, err := newDirEntryWithSummary(dir, prev.ObjectID, prev.DirSummary)
+		if err != nil {
+			return nil, err
+		}
+
+		uploadLog(ctx).Debugw("resumed directory", "path", dirRelativePath, "oid", prev.ObjectID)
+
+		atomic.AddInt32(&u.stats.TotalDirectoryCount, int32(prev.DirSummary.TotalDirCount))
+		atomic.AddInt32(&u.stats.CachedFiles, int32(prev.DirSummary.TotalFileCount))
+		atomic.AddInt64(&u.stats.TotalFileSize, prev.DirSummary.TotalFileSize)
+
+		u.Progress.StartedDirectory(dirRelativePath)
+		reportCachedFiles(u.Progress, dirRelativePath, int32(prev.DirSummary.TotalFileCount), prev.DirSummary.TotalFileSize)
+		u.Progress.FinishedDirectory(dirRelativePath)
+
+		return de, nil
+	}
+
+	return nil, nil
+}
+
+// computeResumeFingerprint returns the fingerprint of the policy tree and uploader settings which determine
+// contents of uploaded directories.
+func (u *Uploader) computeResumeFingerprint(policyTree *policy.Tree) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%v:%v:%v", policyTree.Fingerprint(), u.MaxFileSize, u.DisableIgnoreRules)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -12,6 +12,17 @@
 type scanResults struct {
 	numFiles      int
 	totalFileSize int64
+	numDirs       int
+	fileSizes     SampleBuckets
+}
+
+func (e *scanResults) details() EstimatedDataSizeDetails {
+	return EstimatedDataSizeDetails{
+		FileCount:      e.numFiles,
+		TotalFileSize:  e.totalFileSize,
+		DirectoryCount: e.numDirs,
+		FileSizes:      e.fileSizes,
+	}
 }
 
 func (e *scanResults) Error(ctx context.Context, filename string, err error, isIgnored bool) {}
@@ -22,13 +33,15 @@ func (e *scanResults) Stats(ctx context.Context, s *snapshot.Stats, includedFile
 	if final {
 		e.numFiles = int(atomic.LoadInt32(&s.TotalFileCount))
 		e.totalFileSize = atomic.LoadInt64(&s.TotalFileSize)
+		e.numDirs = int(atomic.LoadInt32(&s.TotalDirectoryCount))
+		e.fileSizes = includedFiles
 	}
 }
 
 var _ EstimateProgress = (*scanResults)(nil)
 
-// scanDirectory computes the number of files and their total size in a given directory recursively descending
-// into subdirectories. The scan teminates early as soon as the provided context is canceled.
+// scanDirectory computes the number of files and directories and the histogram of file sizes in a given directory
+// recursively descending into subdirectories. The scan teminates early as soon as the provided context is canceled.
 func (u *Uploader) scanDirectory(ctx context.Context, dir fs.Directory, policyTree *policy.Tree) (scanResults, error) {
 	var res scanResults
 
@@ -0,0 +1,199 @@
+package snapshotfs
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// followedDirectory is a directory pointed to by a followed symbolic link, stored under the name of the link.
+type followedDirectory struct {
+	fs.Directory
+	name string
+}
+
+func (d *followedDirectory) Name() string {
+	return d.name
+}
+
+// followedFile is a file pointed to by a followed symbolic link, stored under the name of the link.
+type followedFile struct {
+	fs.File
+	name string
+}
+
+func (f *followedFile) Name() string {
+	return f.name
+}
+
+func (f *followedFile) Open(ctx context.Context) (fs.Reader, error) {
+	r, err := f.File.Open(ctx)
+	if err != nil {
+		// nolint:wrapcheck
+		return nil, err
+	}
+
+	return &followedFileReader{r, f.name}, nil
+}
+
+// followedFileReader ensures that the up-to-date entry of a followed file has the name of the link.
+type followedFileReader struct {
+	fs.Reader
+	name string
+}
+
+func (r *followedFileReader) Entry() (fs.Entry, error) {
+	e, err := r.Reader.Entry()
+	if f, ok := e.(fs.File); ok && err == nil {
+		return &followedFile{f, r.name}, nil
+	}
+
+	// nolint:wrapcheck
+	return e, err
+}
+
+// uploadingDirectory is an element of the list of directories being uploaded, from the current directory up to
+// the root of the upload, which is stored in the context.
+type uploadingDirectory struct {
+	dir fs.Directory
+	// real path of the local directory with all symbolic links resolved, empty for non-local directories.
+	realPath string
+	parent   *uploadingDirectory
+}
+
+type uploadingDirectoryKey struct{}
+
+func currentUploadingDirectory(ctx context.Context) *uploadingDirectory {
+	d, _ := ctx.Value(uploadingDirectoryKey{}).(*uploadingDirectory)
+	return d
+}
+
+// withUploadRoot returns the context for uploading the provided root directory, whose real path is determined
+// by resolving symbolic links in its local path.
+func withUploadRoot(ctx context.Context, dir fs.Directory) context.Context {
+	realPath := dir.LocalFilesystemPath()
+	if realPath != "" {
+		if p, err := filepath.EvalSymlinks(realPath); err == nil {
+			realPath = p
+		}
+	}
+
+	return context.WithValue(ctx, uploadingDirectoryKey{}, &uploadingDirectory{dir, realPath, nil})
+}
+
+// withUploadingSubdirectory returns the context for uploading the provided subdirectory of the current directory.
+func withUploadingSubdirectory(ctx context.Context, dir fs.Directory) context.Context {
+	parent := currentUploadingDirectory(ctx)
+
+	var realPath string
+
+	switch {
+	case dir.LocalFilesystemPath() == "":
+	case parent != nil && parent.realPath != "":
+		realPath = filepath.Join(parent.realPath, dir.Name())
+	default:
+		realPath = dir.LocalFilesystemPath()
+	}
+
+	return context.WithValue(ctx, uploadingDirectoryKey{}, &uploadingDirectory{dir, realPath, parent})
+}
+
+// withFollowedSymlinkTarget returns the context for uploading the provided target of a followed symbolic link,
+// whose local path has all symbolic links already resolved.
+func withFollowedSymlinkTarget(ctx context.Context, dir fs.Directory) context.Context {
+	return context.WithValue(ctx, uploadingDirectoryKey{}, &uploadingDirectory{dir, dir.LocalFilesystemPath(), currentUploadingDirectory(ctx)})
+}
+
+// isUploadingDirectory returns true if the target of a symbolic link is the current directory or any of its
+// parents up to the root of the upload, in which case following it would never terminate.
+func isUploadingDirectory(ctx context.Context, target fs.Directory) bool {
+	targetPath := target.LocalFilesystemPath()
+
+	for d := currentUploadingDirectory(ctx); d != nil; d = d.parent {
+		if targetPath != "" || d.realPath != "" {
+			if targetPath == d.realPath {
+				return true
+			}
+
+			continue
+		}
+
+		if d.dir == target {
+			return true
+		}
+	}
+
+	return false
+}
+
+// followSymlinks replaces symbolic links that the policy says to follow with the files or directories they
+// point to, keeping the names of the links. Links whose targets don't exist or are neither files nor directories
+// are stored as links. Links that can't be resolved are reported as errors and skipped, links pointing to
+// the directory containing them or its parents are reported as ignored errors.
+func (u *Uploader) followSymlinks(ctx context.Context, parentDirBuilder *dirManifestBuilder, relativePath string, entries fs.Entries, policyTree *policy.Tree) fs.Entries {
+	var result fs.Entries
+
+	for i, entry := range entries {
+		sl, ok := entry.(fs.ResolvableSymlink)
+		if !ok || policyTree.Child(entry.Name()).EffectivePolicy().UploadPolicy.Symlinks != policy.SymlinksFollow {
+			if result != nil {
+				result = append(result, entry)
+			}
+
+			continue
+		}
+
+		if result == nil {
+			result = append(fs.Entries{}, entries[:i]...)
+		}
+
+		target, err := resolveSymlink(ctx, sl)
+		if err != nil {
+			isIgnoredError := errors.Is(err, fs.ErrSymlinkLoop) || policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrors.OrDefault(false)
+
+			u.reportErrorAndMaybeCancel(err, isIgnoredError, parentDirBuilder, path.Join(relativePath, entry.Name()))
+
+			continue
+		}
+
+		result = append(result, target)
+	}
+
+	if result == nil {
+		return entries
+	}
+
+	return result
+}
+
+func resolveSymlink(ctx context.Context, sl fs.ResolvableSymlink) (fs.Entry, error) {
+	target, err := sl.Resolve(ctx)
+	if errors.Is(err, os.ErrNotExist) {
+		return sl, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to follow symlink")
+	}
+
+	switch target := target.(type) {
+	case fs.Directory:
+		if isUploadingDirectory(ctx, target) {
+			return nil, errors.Wrap(fs.ErrSymlinkLoop, "symlink points to a directory which contains it")
+		}
+
+		return &followedDirectory{target, sl.Name()}, nil
+
+	case fs.File:
+		return &followedFile{target, sl.Name()}, nil
+
+	default:
+		return sl, nil
+	}
+}
@@ -1,15 +1,22 @@
 package snapshotfs
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/kylelemons/godebug/pretty"
@@ -273,6 +280,1181 @@ func TestUploadDoesNotReportProgressForIgnoredFilesTwice(t *testing.T) {
 	require.EqualValues(t, 1, cup.counters.TotalExcludedDirs)
 }
 
+type progressCallCounter struct {
+	CountingUploadProgress
+
+	hashedBytesCalls int32
+	cachedFilesCalls int32
+}
+
+func (p *progressCallCounter) HashedBytes(numBytes int64) {
+	atomic.AddInt32(&p.hashedBytesCalls, 1)
+	p.CountingUploadProgress.HashedBytes(numBytes)
+}
+
+func (p *progressCallCounter) CachedFile(fname string, numBytes int64) {
+	p.CachedFiles(1, numBytes)
+}
+
+func (p *progressCallCounter) CachedFiles(numFiles int32, numBytes int64) {
+	atomic.AddInt32(&p.cachedFilesCalls, 1)
+	p.CountingUploadProgress.CachedFiles(numFiles, numBytes)
+}
+
+func TestUploadProgressUpdateInterval(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	// without the interval, every event is delivered.
+	p1 := &progressCallCounter{}
+	u.Progress = p1
+
+	man1, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Greater(t, p1.hashedBytesCalls, int32(1))
+
+	// with a long interval, events are only delivered when the upload finishes, but totals are exact.
+	p2 := &progressCallCounter{}
+	u.Progress = p2
+	u.ProgressUpdateInterval = time.Hour
+
+	_, err = u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), p2.hashedBytesCalls)
+	require.Equal(t, p1.Snapshot().TotalHashedBytes, p2.Snapshot().TotalHashedBytes)
+	require.Equal(t, u.Progress, p2)
+
+	p3 := &progressCallCounter{}
+	u.Progress = p3
+
+	man3, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), p3.cachedFilesCalls)
+	require.Equal(t, man3.Stats.CachedFiles, p3.Snapshot().TotalCachedFiles)
+	require.Greater(t, p3.Snapshot().TotalCachedFiles, int32(1))
+}
+
+type uploadReasonRecorder struct {
+	NullUploadProgress
+
+	mu      sync.Mutex
+	reasons map[string]UploadReason
+}
+
+func (p *uploadReasonRecorder) UploadReason(path string, reason UploadReason) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reasons[path] = reason
+}
+
+func TestUploadRecordsUploadReasons(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	s1, err := NewUploader(th.repo).Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	th.sourceDir.Remove("f1")
+	th.sourceDir.AddFile("f1", []byte{1, 2, 3, 4, 5, 6}, defaultPermissions)
+	th.sourceDir.AddFile("f4", []byte{1}, defaultPermissions)
+
+	rec := &uploadReasonRecorder{reasons: map[string]UploadReason{}}
+
+	u := NewUploader(th.repo)
+	u.RecordUploadReasons = true
+	u.Progress = rec
+
+	_, err = u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, s1)
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]UploadReason{
+		"f1": UploadReasonSizeChanged,
+		"f4": UploadReasonNew,
+	}, rec.reasons)
+
+	// when all cached entries are ignored, every previously-seen file is reported as re-hashed.
+	rec = &uploadReasonRecorder{reasons: map[string]UploadReason{}}
+
+	u = NewUploader(th.repo)
+	u.RecordUploadReasons = true
+	u.ForceHashPercentage = 100
+	u.Progress = rec
+
+	_, err = u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, s1)
+	require.NoError(t, err)
+
+	require.Equal(t, UploadReasonSizeChanged, rec.reasons["f1"])
+	require.Equal(t, UploadReasonForcedRehash, rec.reasons["f2"])
+	require.Equal(t, UploadReasonForcedRehash, rec.reasons["d1/d1/f1"])
+	require.Equal(t, UploadReasonNew, rec.reasons["f4"])
+}
+
+func TestUploadValidateManifestAfterUpload(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	u.ValidateManifestAfterUpload = true
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.NoError(t, u.validateUploadedManifest(ctx, man))
+
+	// file snapshots are validated too
+	_, err = u.Upload(ctx, th.sourceDir.AddFile("f9", []byte{1, 2, 3}, defaultPermissions), policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	bad := *man
+	bad.RootEntry = nil
+	require.Error(t, u.validateUploadedManifest(ctx, &bad))
+}
+
+func TestVerifyDirSummaryConsistency(t *testing.T) {
+	entries := []*snapshot.DirEntry{
+		{Name: "f1", Type: snapshot.EntryTypeFile, FileSize: 10},
+		{Name: "s1", Type: snapshot.EntryTypeSymlink, FileSize: 3},
+		{Name: "d1", Type: snapshot.EntryTypeDirectory, DirSummary: &fs.DirectorySummary{
+			TotalFileCount: 2,
+			TotalFileSize:  20,
+			TotalDirCount:  3,
+		}},
+	}
+
+	good := &fs.DirectorySummary{TotalFileCount: 3, TotalFileSize: 30, TotalDirCount: 4}
+
+	require.NoError(t, verifyDirSummaryConsistency(nil, entries, good))
+	require.NoError(t, verifyDirSummaryConsistency(good, entries, good))
+
+	require.Error(t, verifyDirSummaryConsistency(nil, entries, nil))
+	require.Error(t, verifyDirSummaryConsistency(nil, entries, &fs.DirectorySummary{TotalFileCount: 2, TotalFileSize: 30, TotalDirCount: 4}))
+	require.Error(t, verifyDirSummaryConsistency(nil, entries, &fs.DirectorySummary{TotalFileCount: 3, TotalFileSize: 31, TotalDirCount: 4}))
+	require.Error(t, verifyDirSummaryConsistency(nil, entries, &fs.DirectorySummary{TotalFileCount: 3, TotalFileSize: 30, TotalDirCount: 3}))
+	require.Error(t, verifyDirSummaryConsistency(&fs.DirectorySummary{TotalFileCount: 1}, entries, good))
+
+	// number of children is optional, but must match if present.
+	require.NoError(t, verifyDirSummaryConsistency(nil, entries, &fs.DirectorySummary{TotalFileCount: 3, TotalFileSize: 30, TotalDirCount: 4, ImmediateChildCount: 3}))
+	require.Error(t, verifyDirSummaryConsistency(nil, entries, &fs.DirectorySummary{TotalFileCount: 3, TotalFileSize: 30, TotalDirCount: 4, ImmediateChildCount: 5}))
+}
+
+func TestUploadImmediateChildCount(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+
+	man, err := u.Upload(ctx, th.sourceDir, policy.BuildTree(nil, policy.DefaultPolicy), snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	// f1, f2, f3, d1 and d2, the totals include entries of subdirectories.
+	require.Equal(t, int64(5), man.RootEntry.DirSummary.ImmediateChildCount)
+	require.Equal(t, int64(10), man.RootEntry.DirSummary.TotalFileCount)
+
+	root := EntryFromDirEntry(th.repo, man.RootEntry).(fs.Directory)
+
+	d1, err := root.Child(ctx, "d1")
+	require.NoError(t, err)
+
+	// d1, d2 and f2.
+	s, err := d1.(fs.DirectoryWithSummary).Summary(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), s.ImmediateChildCount)
+}
+
+func TestUploadThrottle(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	th, err := newUploadThrottle(10000)
+	require.NoError(t, err)
+
+	// initial burst of up to one second worth of data is allowed without waiting.
+	t0 := clock.Now()
+	require.NoError(t, th.wait(ctx, 10000))
+	require.Less(t, clock.Now().Sub(t0), 100*time.Millisecond)
+
+	// subsequent writes must wait for tokens to refill.
+	t0 = clock.Now()
+	require.NoError(t, th.wait(ctx, 3000))
+	require.GreaterOrEqual(t, clock.Now().Sub(t0), 200*time.Millisecond)
+
+	// a canceled writer stops waiting immediately.
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	t0 = clock.Now()
+	require.ErrorIs(t, th.wait(canceledCtx, 1000000), errCanceled)
+	require.Less(t, clock.Now().Sub(t0), 100*time.Millisecond)
+
+	// a writer canceled while waiting for a large amount of data stops waiting shortly after.
+	canceledCtx, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	t0 = clock.Now()
+	require.ErrorIs(t, th.wait(canceledCtx, 1000000), errCanceled)
+	require.Less(t, clock.Now().Sub(t0), 2*time.Second)
+}
+
+func TestUploadThrottleCancel(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	th.sourceDir.AddFile("large", make([]byte, 10<<20), defaultPermissions)
+
+	u := NewUploader(th.repo)
+	u.MaxUploadBytesPerSecond = 1 << 10
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	time.AfterFunc(500*time.Millisecond, u.Cancel)
+
+	t0 := clock.Now()
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, IncompleteReasonCanceled, man.IncompleteReason)
+	require.Less(t, clock.Now().Sub(t0), 10*time.Second)
+}
+
+func TestUploadResumesFromIncompleteManifest(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	u.disableEstimation = true
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man1, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	// makeCheckpoint rewrites the root directory to simulate a checkpoint in which 'd1' was complete
+	// and 'd2' was still being uploaded.
+	makeCheckpoint := func(incompleteReason string, d1ModTime time.Time) *snapshot.Manifest {
+		r, err := th.repo.OpenObject(ctx, man1.RootObjectID())
+		require.NoError(t, err)
+
+		entries, summ, err := readDirEntries(r)
+		require.NoError(t, err)
+		r.Close()
+
+		for _, e := range entries {
+			switch e.Name {
+			case "d1":
+				e.ModTime = d1ModTime
+
+			case "d2":
+				partial := *e.DirSummary
+				partial.IncompleteReason = IncompleteReasonCheckpoint
+				e.DirSummary = &partial
+			}
+		}
+
+		checkpointRootOID, err := u.writeDirManifest(ctx, ".", &snapshot.DirManifest{
+			StreamType: directoryStreamType,
+			Entries:    entries,
+			Summary:    summ,
+		})
+		require.NoError(t, err)
+
+		checkpointRoot := *man1.RootEntry
+		checkpointRoot.ObjectID = checkpointRootOID
+
+		checkpoint := *man1
+		checkpoint.RootEntry = &checkpointRoot
+		checkpoint.IncompleteReason = incompleteReason
+		checkpoint.ResumeFingerprint = u.resumeFingerprint
+
+		return &checkpoint
+	}
+
+	var d1Reads, d2Reads int
+
+	th.sourceDir.Subdir("d1").OnReaddir(func() { d1Reads++ })
+	th.sourceDir.Subdir("d2").OnReaddir(func() { d2Reads++ })
+
+	checkpoint := makeCheckpoint(IncompleteReasonCheckpoint, mockfs.DefaultModTime)
+
+	progress := &CountingUploadProgress{}
+	u.Progress = progress
+
+	man2, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, checkpoint)
+	require.NoError(t, err)
+
+	require.Equal(t, 0, d1Reads, "complete directory must not be walked again")
+	require.Equal(t, 1, d2Reads, "incomplete directory must be walked again")
+	require.Equal(t, man1.RootObjectID(), man2.RootObjectID())
+
+	// files of the resumed directory are reported as cached.
+	require.Equal(t, man2.Stats.CachedFiles, progress.Snapshot().TotalCachedFiles)
+	require.Equal(t, int32(man1.RootEntry.DirSummary.TotalFileCount), man2.Stats.CachedFiles)
+
+	u.Progress = &NullUploadProgress{}
+
+	for _, tc := range []struct {
+		desc     string
+		previous *snapshot.Manifest
+		tree     *policy.Tree
+	}{
+		{"complete manifest", man1, policyTree},
+		{"canceled manifest", makeCheckpoint(IncompleteReasonCanceled, mockfs.DefaultModTime), policyTree},
+		{"directory modified since checkpoint", makeCheckpoint(IncompleteReasonCheckpoint, mockfs.DefaultModTime.Add(-time.Hour)), policyTree},
+		{"policy changed since checkpoint", checkpoint, policy.BuildTree(map[string]*policy.Policy{
+			"./d1": {FilesPolicy: policy.FilesPolicy{IgnoreRules: []string{"*.tmp"}}},
+		}, policy.DefaultPolicy)},
+	} {
+		d1Reads, d2Reads = 0, 0
+
+		man3, err := u.Upload(ctx, th.sourceDir, tc.tree, snapshot.SourceInfo{}, tc.previous)
+		require.NoError(t, err)
+
+		require.Equal(t, 1, d1Reads, tc.desc)
+		require.Equal(t, 1, d2Reads, tc.desc)
+		require.Equal(t, man1.RootObjectID(), man3.RootObjectID(), tc.desc)
+	}
+}
+
+// flakyFile is a file that fails the provided number of reads after reading half of its contents.
+type flakyFile struct {
+	*mockfs.File
+
+	content      []byte
+	openErr      error
+	failuresLeft int
+	opens        int
+}
+
+type flakyFileReader struct {
+	io.Reader
+	f fs.Entry
+}
+
+func (r flakyFileReader) Close() error                                 { return nil }
+func (r flakyFileReader) Seek(offset int64, whence int) (int64, error) { return 0, errTest }
+func (r flakyFileReader) Entry() (fs.Entry, error)                     { return r.f, nil }
+
+func (f *flakyFile) Open(ctx context.Context) (fs.Reader, error) {
+	f.opens++
+
+	if f.openErr != nil {
+		return nil, f.openErr
+	}
+
+	if f.failuresLeft > 0 {
+		f.failuresLeft--
+
+		return flakyFileReader{io.MultiReader(bytes.NewReader(f.content[0:len(f.content)/2]), iotest.ErrReader(errTest)), f}, nil
+	}
+
+	return flakyFileReader{bytes.NewReader(f.content), f}, nil
+}
+
+func TestUploadRetriesFileReadErrors(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	content := bytes.Repeat([]byte{1, 2, 3, 4, 5}, 100000)
+
+	u := NewUploader(th.repo)
+	u.FileReadRetryInterval = time.Millisecond
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	f := &flakyFile{
+		File:         th.sourceDir.AddFile("flaky", content, defaultPermissions),
+		content:      content,
+		failuresLeft: 2,
+	}
+
+	// not enough retries
+	u.FileReadRetries = 1
+	_, err := u.Upload(ctx, f, policyTree, snapshot.SourceInfo{})
+	require.ErrorIs(t, err, errTest)
+	require.Equal(t, 2, f.opens)
+
+	f.failuresLeft = 2
+	f.opens = 0
+	u.FileReadRetries = 2
+
+	cup := &CountingUploadProgress{}
+	u.Progress = cup
+
+	man, err := u.Upload(ctx, f, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, 3, f.opens)
+	require.Equal(t, int64(len(content)), man.RootEntry.FileSize)
+
+	// bytes written by failed attempts are not counted.
+	require.Equal(t, int64(len(content)), atomic.LoadInt64(&u.totalWrittenBytes))
+	require.Equal(t, int64(len(content)), cup.Snapshot().TotalHashedBytes)
+
+	r, err := th.repo.OpenObject(ctx, man.RootObjectID())
+	require.NoError(t, err)
+
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	r.Close()
+
+	require.Equal(t, content, got)
+
+	// permanent errors are not retried.
+	f.opens = 0
+	f.openErr = os.ErrNotExist
+	_, err = u.Upload(ctx, f, policyTree, snapshot.SourceInfo{})
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.Equal(t, 1, f.opens)
+
+	// zero interval retries after the default interval instead of immediately.
+	f.opens = 0
+	f.openErr = nil
+	f.failuresLeft = 1
+	u.FileReadRetries = 1
+	u.FileReadRetryInterval = 0
+
+	t0 := clock.Now()
+	_, err = u.Upload(ctx, f, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, 2, f.opens)
+	require.GreaterOrEqual(t, clock.Now().Sub(t0), DefaultFileReadRetryInterval)
+}
+
+func TestUploadMaxFileSize(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	u.MaxFileSize = 4
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	// f3 is the only top-level file larger than 4 bytes.
+	require.Equal(t, int32(1), man.Stats.ExcludedFileCount)
+	require.Equal(t, int64(5), man.Stats.ExcludedTotalFileSize)
+	require.Equal(t, int32(0), man.Stats.ErrorCount)
+	require.Equal(t, int32(1), man.Stats.IgnoredErrorCount)
+	require.Equal(t, 1, man.RootEntry.DirSummary.IgnoredErrorCount)
+	require.Equal(t, 0, man.RootEntry.DirSummary.FatalErrorCount)
+	require.Len(t, man.RootEntry.DirSummary.FailedEntries, 1)
+	require.Equal(t, "f3", man.RootEntry.DirSummary.FailedEntries[0].EntryPath)
+
+	// streaming files of unknown size are not subject to the limit.
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("streaming file content"))
+	require.NoError(t, err)
+	w.Close()
+
+	staticRoot := virtualfs.NewStaticDirectory("rootdir", fs.Entries{
+		virtualfs.StreamingFileFromReader("stream-file", r),
+	})
+
+	man, err = u.Upload(ctx, staticRoot, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), man.Stats.ExcludedFileCount)
+	require.Equal(t, int32(1), man.Stats.NonCachedFiles)
+}
+
+func TestUploadCollapsesHardLinks(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	content := bytes.Repeat([]byte{1, 2, 3}, 1000)
+	linked := fs.DeviceInfo{Dev: 1, Ino: 100, Nlink: 2}
+
+	root := mockfs.NewDirectory()
+	root.AddDir("a", defaultPermissions)
+	root.AddDir("b", defaultPermissions)
+	root.AddFileDevice("a/link1", content, defaultPermissions, linked)
+	root.AddFileDevice("b/link2", content, defaultPermissions, linked)
+	// same inode number on a different device is not a hard link.
+	root.AddFileDevice("b/other", content, defaultPermissions, fs.DeviceInfo{Dev: 2, Ino: 100, Nlink: 2})
+
+	u := NewUploader(th.repo)
+	u.ParallelUploads = 1
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man, err := u.Upload(ctx, root, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	require.Equal(t, int32(1), man.Stats.CollapsedHardLinks)
+	require.Equal(t, int32(2), man.Stats.NonCachedFiles)
+	require.Equal(t, int32(3), man.Stats.TotalFileCount)
+	require.Equal(t, int64(3*len(content)), man.Stats.TotalFileSize)
+
+	rootDir := EntryFromDirEntry(th.repo, man.RootEntry).(fs.Directory)
+
+	link1, err := mustChildDir(ctx, t, rootDir, "a").Readdir(ctx)
+	require.NoError(t, err)
+
+	link2, err := mustChildDir(ctx, t, rootDir, "b").Readdir(ctx)
+	require.NoError(t, err)
+
+	require.Equal(t, link1[0].(object.HasObjectID).ObjectID(), link2[0].(object.HasObjectID).ObjectID())
+	require.Equal(t, "link2", link2[0].Name())
+}
+
+func mustChildDir(ctx context.Context, t *testing.T, d fs.Directory, name string) fs.Directory {
+	t.Helper()
+
+	e, err := d.Child(ctx, name)
+	require.NoError(t, err)
+
+	return e.(fs.Directory)
+}
+
+type xattrFile struct {
+	*mockfs.File
+
+	attrs map[string][]byte
+}
+
+func (f *xattrFile) Xattrs(ctx context.Context) (map[string][]byte, error) {
+	return f.attrs, nil
+}
+
+func TestUploadCaptureXattrs(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	f := &xattrFile{
+		File:  th.sourceDir.AddFile("f1", []byte{1, 2, 3}, defaultPermissions),
+		attrs: map[string][]byte{"user.foo": []byte("bar")},
+	}
+
+	root := virtualfs.NewStaticDirectory("root", fs.Entries{f})
+
+	u := NewUploader(th.repo)
+	u.CaptureXattrs = true
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man1, err := u.Upload(ctx, root, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	readRootEntries := func(man *snapshot.Manifest) []*snapshot.DirEntry {
+		r, err := th.repo.OpenObject(ctx, man.RootObjectID())
+		require.NoError(t, err)
+
+		defer r.Close()
+
+		entries, _, err := readDirEntries(r)
+		require.NoError(t, err)
+
+		return entries
+	}
+
+	require.Equal(t, f.attrs, readRootEntries(man1)[0].Xattrs)
+
+	// unchanged attributes allow the file to be cached.
+	man2, err := u.Upload(ctx, root, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), man2.Stats.CachedFiles)
+	require.Equal(t, f.attrs, readRootEntries(man2)[0].Xattrs)
+
+	// changed attributes invalidate the cached entry.
+	f.attrs = map[string][]byte{"user.foo": []byte("baz")}
+
+	man3, err := u.Upload(ctx, root, policyTree, snapshot.SourceInfo{}, man2)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), man3.Stats.CachedFiles)
+	require.Equal(t, int32(1), man3.Stats.NonCachedFiles)
+	require.Equal(t, f.attrs, readRootEntries(man3)[0].Xattrs)
+
+	// attributes are not captured unless requested.
+	u.CaptureXattrs = false
+
+	man4, err := u.Upload(ctx, root, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Nil(t, readRootEntries(man4)[0].Xattrs)
+}
+
+type creationTimeFile struct {
+	*mockfs.File
+
+	btime time.Time
+}
+
+func (f *creationTimeFile) CreationTime(ctx context.Context) (time.Time, error) {
+	return f.btime, nil
+}
+
+func TestUploadCaptureCreationTime(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	f := &creationTimeFile{
+		File:  th.sourceDir.AddFile("f1", []byte{1, 2, 3}, defaultPermissions),
+		btime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	root := virtualfs.NewStaticDirectory("root", fs.Entries{f})
+
+	u := NewUploader(th.repo)
+	u.CaptureCreationTime = true
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	readRootEntries := func(man *snapshot.Manifest) []*snapshot.DirEntry {
+		r, err := th.repo.OpenObject(ctx, man.RootObjectID())
+		require.NoError(t, err)
+
+		defer r.Close()
+
+		entries, _, err := readDirEntries(r)
+		require.NoError(t, err)
+
+		return entries
+	}
+
+	man1, err := u.Upload(ctx, root, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.NotNil(t, readRootEntries(man1)[0].CreationTime)
+	require.True(t, f.btime.Equal(*readRootEntries(man1)[0].CreationTime))
+
+	// creation time does not affect caching, but is still recorded.
+	f.btime = f.btime.Add(time.Hour)
+
+	man2, err := u.Upload(ctx, root, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), man2.Stats.CachedFiles)
+	require.True(t, f.btime.Equal(*readRootEntries(man2)[0].CreationTime))
+
+	// creation time is not captured unless requested.
+	u.CaptureCreationTime = false
+
+	man3, err := u.Upload(ctx, root, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Nil(t, readRootEntries(man3)[0].CreationTime)
+}
+
+func TestUploadFileHooks(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	th.sourceDir.AddSymlink("s1", "f1", defaultPermissions)
+
+	u := NewUploader(th.repo)
+
+	uploaded := map[string]*snapshot.DirEntry{}
+
+	u.OnFileUploaded = func(relativePath string, de *snapshot.DirEntry) {
+		uploaded[relativePath] = de
+	}
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man1, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	// 10 files and one symlink.
+	require.Len(t, uploaded, 11)
+	require.Equal(t, int64(5), uploaded["f3"].FileSize)
+	require.NotEmpty(t, uploaded["f3"].ObjectID)
+	require.Equal(t, snapshot.EntryTypeSymlink, uploaded["s1"].Type)
+
+	// cached files are not reported as uploaded unless OnCachedFile is set.
+	uploaded = map[string]*snapshot.DirEntry{}
+
+	_, err = u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Empty(t, uploaded)
+
+	cached := map[string]*snapshot.DirEntry{}
+
+	u.OnCachedFile = func(relativePath string, de *snapshot.DirEntry) {
+		cached[relativePath] = de
+	}
+
+	_, err = u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Empty(t, uploaded)
+	require.Len(t, cached, 11)
+}
+
+func TestUploadLargestFirst(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	root := mockfs.NewDirectory()
+	root.AddFile("a", make([]byte, 10), defaultPermissions)
+	root.AddFile("b", make([]byte, 3000), defaultPermissions)
+	root.AddFile("c", make([]byte, 200), defaultPermissions)
+	root.AddFile("d", make([]byte, 40000), defaultPermissions)
+
+	u := NewUploader(th.repo)
+	u.ParallelUploads = 1
+	u.LargestFirst = true
+
+	var order []string
+
+	u.OnFileUploaded = func(relativePath string, de *snapshot.DirEntry) {
+		order = append(order, relativePath)
+	}
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man, err := u.Upload(ctx, root, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"d", "b", "c", "a"}, order)
+
+	// directory manifest is still sorted by name.
+	entries, err := EntryFromDirEntry(th.repo, man.RootEntry).(fs.Directory).Readdir(ctx)
+	require.NoError(t, err)
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	require.Equal(t, []string{"a", "b", "c", "d"}, names)
+}
+
+func TestSortedLargestFirst(t *testing.T) {
+	root := mockfs.NewDirectory()
+	small := root.AddFile("small", make([]byte, 1), defaultPermissions)
+	large := root.AddFile("large", make([]byte, 100), defaultPermissions)
+	stream := virtualfs.StreamingFileFromReader("stream", bytes.NewReader(nil))
+
+	entries := fs.Entries{stream, small, large}
+	sorted := sortedLargestFirst(entries)
+
+	require.Equal(t, fs.Entries{large, small, stream}, sorted)
+
+	// original slice is not modified.
+	require.Equal(t, fs.Entries{stream, small, large}, entries)
+}
+
+func TestUploadDryRun(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	th.sourceDir.AddSymlink("s1", "f1", defaultPermissions)
+
+	u := NewUploader(th.repo)
+	u.DryRun = true
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.True(t, man.DryRun)
+	require.Empty(t, man.RootEntry.ObjectID)
+	require.Zero(t, atomic.LoadInt64(&u.totalWrittenBytes))
+
+	require.Equal(t, int32(10), man.Stats.TotalFileCount)
+	require.Equal(t, int64(37), man.Stats.TotalFileSize)
+	require.Equal(t, int64(10), man.RootEntry.DirSummary.TotalFileCount)
+	require.Equal(t, int64(37), man.RootEntry.DirSummary.TotalFileSize)
+	require.Equal(t, int64(1), man.RootEntry.DirSummary.TotalSymlinkCount)
+
+	_, err = snapshot.SaveSnapshot(ctx, th.repo, man)
+	require.Error(t, err)
+
+	// regular upload of the same source produces the same statistics.
+	u = NewUploader(th.repo)
+
+	man2, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.False(t, man2.DryRun)
+	require.Equal(t, man.Stats.TotalFileCount, man2.Stats.TotalFileCount)
+	require.Equal(t, man.Stats.TotalFileSize, man2.Stats.TotalFileSize)
+	require.Equal(t, man.RootEntry.DirSummary.TotalDirCount, man2.RootEntry.DirSummary.TotalDirCount)
+}
+
+func TestUploadAdaptiveParallelism(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	u.ParallelUploads = 4
+	u.AdaptiveParallelism = true
+	u.MinParallelUploads = 1
+
+	ticker := make(chan time.Time)
+	u.getTicker = func(time.Duration) <-chan time.Time {
+		return ticker
+	}
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, int32(10), man.Stats.TotalFileCount)
+
+	// the upload started with a single worker and adjustments never happened.
+	require.Equal(t, 0, u.workerPool.MaxActiveWorkers())
+}
+
+func TestUploadForceHashPercentagePolicy(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	forceHash := func(pct float64) *policy.Policy {
+		p := *policy.DefaultPolicy
+		v := policy.OptionalFloat64(pct)
+		p.UploadPolicy.ForceHashPercentage = &v
+
+		return &p
+	}
+
+	u := NewUploader(th.repo)
+
+	man1, err := u.Upload(ctx, th.sourceDir, policy.BuildTree(nil, policy.DefaultPolicy), snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, int32(10), man1.Stats.NonCachedFiles)
+
+	// all 5 files under d1 are hashed again, remaining 5 files are cached.
+	man2, err := u.Upload(ctx, th.sourceDir, policy.BuildTree(map[string]*policy.Policy{
+		"./d1": forceHash(100),
+	}, policy.DefaultPolicy), snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Equal(t, int32(5), man2.Stats.NonCachedFiles)
+	require.Equal(t, int32(5), man2.Stats.CachedFiles)
+
+	// policy overrides the percentage specified on the uploader, 2 files under d2 remain cached.
+	u.ForceHashPercentage = 100
+
+	man3, err := u.Upload(ctx, th.sourceDir, policy.BuildTree(map[string]*policy.Policy{
+		"./d2": forceHash(0),
+	}, policy.DefaultPolicy), snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Equal(t, int32(8), man3.Stats.NonCachedFiles)
+	require.Equal(t, int32(2), man3.Stats.CachedFiles)
+}
+
+func TestUploadHashCache(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	td := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(td, "f1"), []byte{1, 2, 3}, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(td, "f2"), []byte{4, 5, 6}, 0o600))
+
+	srcdir, err := localfs.Directory(td)
+	require.NoError(t, err)
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+	cacheFile := filepath.Join(testutil.TempDirectory(t), "hash-cache.json")
+
+	upload := func(forceHash float64) *snapshot.Manifest {
+		t.Helper()
+
+		hc, herr := OpenLocalHashCache(cacheFile)
+		require.NoError(t, herr)
+
+		u := NewUploader(th.repo)
+		u.HashCache = hc
+		u.ForceHashPercentage = forceHash
+
+		// no previous manifests, only the hash cache can be used.
+		man, uerr := u.Upload(ctx, srcdir, policyTree, snapshot.SourceInfo{})
+		require.NoError(t, uerr)
+		require.NoError(t, hc.Save())
+
+		return man
+	}
+
+	man1 := upload(0)
+	require.Equal(t, int32(2), man1.Stats.NonCachedFiles)
+	require.FileExists(t, cacheFile)
+
+	man2 := upload(0)
+	require.Equal(t, int32(0), man2.Stats.NonCachedFiles)
+	require.Equal(t, int32(2), man2.Stats.CachedFiles)
+	require.Equal(t, man1.RootObjectID(), man2.RootObjectID())
+
+	// any metadata change causes a miss.
+	require.NoError(t, os.WriteFile(filepath.Join(td, "f1"), []byte{1, 2, 3, 4}, 0o600))
+	require.NoError(t, os.Chtimes(filepath.Join(td, "f2"), time.Now(), time.Unix(1000000, 0)))
+
+	man3 := upload(0)
+	require.Equal(t, int32(2), man3.Stats.NonCachedFiles)
+
+	man4 := upload(0)
+	require.Equal(t, int32(2), man4.Stats.CachedFiles)
+	require.Equal(t, man3.RootObjectID(), man4.RootObjectID())
+
+	// cached entries are still hashed again when forced.
+	man5 := upload(100)
+	require.Equal(t, int32(2), man5.Stats.NonCachedFiles)
+	require.Equal(t, man3.RootObjectID(), man5.RootObjectID())
+}
+
+func TestUploadNewContentBytes(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	u := NewUploader(th.repo)
+
+	man1, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Positive(t, man1.Stats.NewContentBytes)
+
+	// direct repositories always know whether contents were new.
+	require.Zero(t, man1.Stats.UnknownContentBytes)
+
+	// cached files don't write anything.
+	man2, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Zero(t, man2.Stats.NewContentBytes)
+
+	// files hashed again are fully deduplicated.
+	u.ForceHashPercentage = 100
+
+	man3, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Equal(t, int32(10), man3.Stats.NonCachedFiles)
+	require.Zero(t, man3.Stats.NewContentBytes)
+
+	th.sourceDir.AddFile("f5", []byte("contents not found in any other file"), defaultPermissions)
+
+	man4, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Positive(t, man4.Stats.NewContentBytes)
+}
+
+func TestUploadExtensionStats(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	root := mockfs.NewDirectory()
+	root.AddFile("a.TXT", []byte{1, 2, 3}, defaultPermissions)
+	root.AddFile("b.txt", []byte{1, 2, 3, 4}, defaultPermissions)
+	root.AddFile("c", []byte{1, 2, 3, 4, 5}, defaultPermissions)
+	root.AddFile(".hidden", []byte{1, 2}, defaultPermissions)
+	root.AddDir("d", defaultPermissions)
+	root.AddFile("d/e.jpg", []byte{1}, defaultPermissions)
+
+	u := NewUploader(th.repo)
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	_, err := u.Upload(ctx, root, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Nil(t, u.ExtensionStats())
+
+	u.RecordExtensionStats = true
+
+	_, err = u.Upload(ctx, root, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	stats := u.ExtensionStats()
+	require.Len(t, stats, 3)
+
+	require.Equal(t, int64(2), stats[".txt"].FileCount)
+	require.Equal(t, int64(7), stats[".txt"].TotalSize)
+	require.Positive(t, stats[".txt"].CompressedSize)
+
+	require.Equal(t, int64(2), stats[""].FileCount)
+	require.Equal(t, int64(7), stats[""].TotalSize)
+
+	require.Equal(t, int64(1), stats[".jpg"].FileCount)
+	require.Equal(t, int64(1), stats[".jpg"].TotalSize)
+}
+
+func TestUploadSkipSpecialFiles(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	root := mockfs.NewDirectory()
+	root.AddFile("f1", []byte{1, 2, 3}, defaultPermissions)
+	root.AddFile("fifo", nil, os.ModeNamedPipe|defaultPermissions)
+	root.AddFile("dev", nil, os.ModeDevice|os.ModeCharDevice|defaultPermissions)
+	root.AddErrorEntry("sock", os.ModeSocket, fs.ErrUnknown)
+
+	u := NewUploader(th.repo)
+	u.SkipSpecialFiles = true
+
+	cup := &CountingUploadProgress{}
+	u.Progress = cup
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man, err := u.Upload(ctx, root, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, int32(3), man.Stats.SkippedSpecialCount)
+	require.Equal(t, int32(3), atomic.LoadInt32(&cup.counters.TotalSkippedSpecialFiles))
+	require.Zero(t, man.Stats.ErrorCount)
+	require.Zero(t, man.Stats.IgnoredErrorCount)
+	require.Empty(t, man.RootEntry.DirSummary.FailedEntries)
+
+	entries, err := EntryFromDirEntry(th.repo, man.RootEntry).(fs.Directory).Readdir(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "f1", entries[0].Name())
+}
+
+func TestUploadPackedSize(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man1, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	summ := man1.RootEntry.DirSummary
+	require.Positive(t, summ.TotalPackedSize)
+
+	var totalPacked int64
+
+	entries, err := EntryFromDirEntry(th.repo, man1.RootEntry).(fs.Directory).Readdir(ctx)
+	require.NoError(t, err)
+
+	for _, e := range entries {
+		de := e.(snapshot.HasDirEntry).DirEntry()
+
+		if de.Type == snapshot.EntryTypeFile {
+			require.Positive(t, de.PackedSize, de.Name)
+			totalPacked += de.PackedSize
+		} else {
+			totalPacked += de.DirSummary.TotalPackedSize
+		}
+	}
+
+	require.Equal(t, summ.TotalPackedSize, totalPacked)
+
+	// cached files carry packed size over from the previous snapshot.
+	man2, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Equal(t, int32(10), man2.Stats.CachedFiles)
+	require.Equal(t, summ.TotalPackedSize, man2.RootEntry.DirSummary.TotalPackedSize)
+}
+
+func TestUploadCheckpointTriggers(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	cases := []struct {
+		desc           string
+		everyBytes     int64
+		everyFiles     int
+		minInterval    time.Duration
+		wantCheckpoint bool
+	}{
+		{"disabled", 0, 0, 0, false},
+		{"every file", 0, 1, 0, true},
+		{"every byte", 1, 0, 0, true},
+		{"every file with min interval", 0, 1, time.Hour, false},
+		{"every byte with min interval", 1, 0, time.Hour, false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.desc, func(t *testing.T) {
+			th := newUploadTestHarness(ctx, t)
+			defer th.cleanup()
+
+			si := snapshot.SourceInfo{
+				UserName: "user",
+				Host:     "host",
+				Path:     "path",
+			}
+
+			policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+			man1, err := NewUploader(th.repo).Upload(ctx, th.sourceDir, policyTree, si)
+			require.NoError(t, err)
+
+			// the only file that's not cached is in d1, which is processed before d2.
+			th.sourceDir.AddFile("d1/f3", []byte{1, 2, 3, 4, 5, 6}, defaultPermissions)
+
+			u := NewUploader(th.repo)
+
+			// periodic checkpoints never fire.
+			u.getTicker = func(d time.Duration) <-chan time.Time {
+				return nil
+			}
+
+			u.checkpointFinished = make(chan struct{})
+			u.disableEstimation = true
+			u.ParallelUploads = 1
+			u.CheckpointEveryBytes = tc.everyBytes
+			u.CheckpointEveryFiles = tc.everyFiles
+			u.MinCheckpointInterval = tc.minInterval
+
+			if tc.wantCheckpoint {
+				th.sourceDir.Subdir("d2").OnReaddir(func() {
+					<-u.checkpointFinished
+				})
+			}
+
+			man2, err := u.Upload(ctx, th.sourceDir, policyTree, si, man1)
+			require.NoError(t, err)
+			require.Equal(t, int32(1), man2.Stats.NonCachedFiles)
+
+			snapshots, err := snapshot.ListSnapshots(ctx, th.repo, si)
+			require.NoError(t, err)
+
+			if tc.wantCheckpoint {
+				require.Len(t, snapshots, 1)
+				require.Equal(t, IncompleteReasonCheckpoint, snapshots[0].IncompleteReason)
+			} else {
+				require.Empty(t, snapshots)
+			}
+		})
+	}
+}
+
 func TestUpload_SubDirectoryReadFailureFailFast(t *testing.T) {
 	ctx := testlogging.Context(t)
 	th := newUploadTestHarness(ctx, t)
@@ -305,6 +1487,97 @@ func TestUpload_SubDirectoryReadFailureFailFast(t *testing.T) {
 	)
 }
 
+func TestUpload_SourceIOErrorAbort(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	errNoSpace := &os.PathError{Op: "readdir", Path: "d1", Err: syscall.ENOSPC}
+
+	cases := []struct {
+		desc                 string
+		readErr              error
+		abort                bool
+		wantIncompleteReason string
+		wantFatalErrors      int
+	}{
+		{"no space, abort", errNoSpace, true, IncompleteReasonSourceError, 1},
+		{"no space, no abort", errNoSpace, false, "", 2},
+		{"I/O error, abort", errors.Wrap(syscall.EIO, "some error"), true, IncompleteReasonSourceError, 1},
+		{"other error, abort", errTest, true, "", 2},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.desc, func(t *testing.T) {
+			th := newUploadTestHarness(ctx, t)
+			defer th.cleanup()
+
+			th.sourceDir.Subdir("d1").FailReaddir(tc.readErr)
+			th.sourceDir.Subdir("d2").Subdir("d1").FailReaddir(tc.readErr)
+
+			u := NewUploader(th.repo)
+			u.ParallelUploads = 1
+			u.AbortOnSourceIOError = tc.abort
+
+			policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+			man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+			require.NoError(t, err)
+			require.Equal(t, tc.wantIncompleteReason, man.IncompleteReason)
+			require.Equal(t, tc.wantFatalErrors, man.RootEntry.DirSummary.FatalErrorCount)
+		})
+	}
+}
+
+func TestUpload_MaxErrors(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	cases := []struct {
+		maxErrors            int
+		wantIncompleteReason string
+		wantFatalErrors      int
+	}{
+		{0, "", 2},
+		{2, "", 2},
+		{1, IncompleteReasonTooManyErrors, 2},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(fmt.Sprintf("max-%v", tc.maxErrors), func(t *testing.T) {
+			th := newUploadTestHarness(ctx, t)
+			defer th.cleanup()
+
+			th.sourceDir.Subdir("d1").FailReaddir(errTest)
+			th.sourceDir.Subdir("d2").Subdir("d1").FailReaddir(errTest)
+			th.sourceDir.AddDir("d0", defaultPermissions)
+			th.sourceDir.Subdir("d0").FailReaddir(errTest)
+
+			u := NewUploader(th.repo)
+			u.ParallelUploads = 1
+			u.MaxErrors = tc.maxErrors
+
+			trueValue := policy.OptionalBool(true)
+
+			// ignored errors don't count towards the limit.
+			policyTree := policy.BuildTree(map[string]*policy.Policy{
+				"./d0": {
+					ErrorHandlingPolicy: policy.ErrorHandlingPolicy{
+						IgnoreDirectoryErrors: &trueValue,
+					},
+				},
+			}, policy.DefaultPolicy)
+
+			man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+			require.NoError(t, err)
+			require.Equal(t, tc.wantIncompleteReason, man.IncompleteReason)
+			require.Equal(t, tc.wantFatalErrors, man.RootEntry.DirSummary.FatalErrorCount)
+			require.Equal(t, 1, man.RootEntry.DirSummary.IgnoredErrorCount)
+		})
+	}
+}
+
 func objectIDsEqual(o1, o2 object.ID) bool {
 	return reflect.DeepEqual(o1, o2)
 }
@@ -439,6 +1712,52 @@ func TestUpload_ErrorEntries(t *testing.T) {
 	}
 }
 
+func TestUpload_MaxFailedEntriesPerDir(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	root := mockfs.NewDirectory()
+	d1 := root.AddDir("d1", defaultPermissions)
+	d2 := root.AddDir("d2", defaultPermissions)
+
+	for i := 0; i < 12; i++ {
+		d1.AddErrorEntry(fmt.Sprintf("failed-%02v", i), 0, errors.Errorf("some-error"))
+	}
+
+	for i := 0; i < 3; i++ {
+		d2.AddErrorEntry(fmt.Sprintf("failed-%02v", i), 0, errors.Errorf("some-error"))
+	}
+
+	policyTree := policy.BuildTree(nil, &policy.Policy{})
+
+	cases := []struct {
+		maxFailedEntries int
+		wantEntries      int
+		wantTruncated    int
+	}{
+		// d1 records 10 of its 12 failures, root records 10 of 13 failures reported by d1 and d2.
+		{0, fs.MaxFailedEntriesPerDirectorySummary, 5},
+		{3, 3, 12},
+		{100, 15, 0},
+	}
+
+	for _, tc := range cases {
+		u := NewUploader(th.repo)
+		u.MaxFailedEntriesPerDir = tc.maxFailedEntries
+
+		man, err := u.Upload(ctx, root, policyTree, snapshot.SourceInfo{})
+		require.NoError(t, err)
+
+		ds := man.RootEntry.DirSummary
+		require.Equal(t, 15, ds.FatalErrorCount)
+		require.Len(t, ds.FailedEntries, tc.wantEntries)
+		require.Equal(t, tc.wantTruncated, ds.TruncatedFailedEntryCount)
+		require.Equal(t, "d1/failed-00", ds.FailedEntries[0].EntryPath)
+	}
+}
+
 func TestUpload_SubDirectoryReadFailureNoFailFast(t *testing.T) {
 	ctx := testlogging.Context(t)
 	th := newUploadTestHarness(ctx, t)
@@ -493,45 +1812,177 @@ func TestUpload_SubDirectoryReadFailureSomeIgnoredNoFailFast(t *testing.T) {
 
 	defer th.cleanup()
 
-	th.sourceDir.Subdir("d1").FailReaddir(errTest)
-	th.sourceDir.Subdir("d2").Subdir("d1").FailReaddir(errTest)
-	th.sourceDir.AddDir("d3", defaultPermissions)
-	th.sourceDir.Subdir("d3").FailReaddir(errTest)
+	th.sourceDir.Subdir("d1").FailReaddir(errTest)
+	th.sourceDir.Subdir("d2").Subdir("d1").FailReaddir(errTest)
+	th.sourceDir.AddDir("d3", defaultPermissions)
+	th.sourceDir.Subdir("d3").FailReaddir(errTest)
+
+	u := NewUploader(th.repo)
+
+	trueValue := policy.OptionalBool(true)
+
+	// set up a policy tree where errors from d3 are ignored.
+	policyTree := policy.BuildTree(map[string]*policy.Policy{
+		"./d3": {
+			ErrorHandlingPolicy: policy.ErrorHandlingPolicy{
+				IgnoreFileErrors:      &trueValue,
+				IgnoreDirectoryErrors: &trueValue,
+			},
+		},
+	}, policy.DefaultPolicy)
+
+	if got, want := policyTree.Child("d3").EffectivePolicy().ErrorHandlingPolicy.IgnoreDirectoryErrors.OrDefault(false), true; got != want {
+		t.Fatalf("policy not effective")
+	}
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	verifyErrors(t, man,
+		2, 1,
+		[]*fs.EntryWithError{
+			{EntryPath: "d1", Error: errTest.Error()},
+			{EntryPath: "d2/d1", Error: errTest.Error()},
+			{EntryPath: "d3", Error: errTest.Error()},
+		},
+	)
+}
+
+func TestUploadWithCheckpointing(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+
+	fakeTicker := make(chan time.Time)
+
+	// inject fake ticker that we can control externally instead of through time passage.
+	u.getTicker = func(d time.Duration) <-chan time.Time {
+		return fakeTicker
+	}
+
+	// create a channel that will be sent to whenever checkpoint completes.
+	u.checkpointFinished = make(chan struct{})
+	u.disableEstimation = true
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	si := snapshot.SourceInfo{
+		UserName: "user",
+		Host:     "host",
+		Path:     "path",
+	}
+
+	// inject a action into mock filesystem to trigger and wait for checkpoints at few places.
+	// the places are not important, what's important that those are 3 separate points in time.
+	dirsToCheckpointAt := []*mockfs.Directory{
+		th.sourceDir.Subdir("d1"),
+		th.sourceDir.Subdir("d2"),
+		th.sourceDir.Subdir("d1").Subdir("d2"),
+	}
+
+	for _, d := range dirsToCheckpointAt {
+		d.OnReaddir(func() {
+			// trigger checkpoint
+			fakeTicker <- clock.Now()
+			// wait for checkpoint
+			<-u.checkpointFinished
+		})
+	}
+
+	if _, err := u.Upload(ctx, th.sourceDir, policyTree, si); err != nil {
+		t.Errorf("Upload error: %v", err)
+	}
+
+	snapshots, err := snapshot.ListSnapshots(ctx, th.repo, si)
+	if err != nil {
+		t.Fatalf("error listing snapshots: %v", err)
+	}
+
+	if got, want := len(snapshots), len(dirsToCheckpointAt); got != want {
+		t.Fatalf("unexpected number of snapshots: %v, want %v", got, want)
+	}
+
+	for _, sn := range snapshots {
+		if got, want := sn.IncompleteReason, IncompleteReasonCheckpoint; got != want {
+			t.Errorf("unexpected incompleteReason %q, want %q", got, want)
+		}
+	}
+}
+
+func TestUploadCheckpointTimestamps(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+
+	t0 := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	ft := faketime.NewTimeAdvance(t0, 0)
+	u.now = ft.NowFunc()
+
+	fakeTicker := make(chan time.Time)
+
+	u.getTicker = func(d time.Duration) <-chan time.Time {
+		return fakeTicker
+	}
+
+	u.checkpointFinished = make(chan struct{})
+	u.disableEstimation = true
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	si := snapshot.SourceInfo{
+		UserName: "user",
+		Host:     "host",
+		Path:     "path",
+	}
+
+	var wantCheckpointTimes []time.Time
+
+	for _, d := range []*mockfs.Directory{
+		th.sourceDir.Subdir("d1"),
+		th.sourceDir.Subdir("d2"),
+	} {
+		d.OnReaddir(func() {
+			wantCheckpointTimes = append(wantCheckpointTimes, ft.Advance(time.Minute))
+
+			fakeTicker <- clock.Now()
+			<-u.checkpointFinished
+		})
+	}
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, si)
+	require.NoError(t, err)
+
+	require.Equal(t, t0, man.StartTime)
+	require.Equal(t, t0.Add(2*time.Minute), man.EndTime)
 
-	u := NewUploader(th.repo)
+	snapshots, err := snapshot.ListSnapshots(ctx, th.repo, si)
+	require.NoError(t, err)
 
-	trueValue := policy.OptionalBool(true)
+	var gotCheckpointTimes []time.Time
 
-	// set up a policy tree where errors from d3 are ignored.
-	policyTree := policy.BuildTree(map[string]*policy.Policy{
-		"./d3": {
-			ErrorHandlingPolicy: policy.ErrorHandlingPolicy{
-				IgnoreFileErrors:      &trueValue,
-				IgnoreDirectoryErrors: &trueValue,
-			},
-		},
-	}, policy.DefaultPolicy)
+	for _, sn := range snapshots {
+		require.Equal(t, IncompleteReasonCheckpoint, sn.IncompleteReason)
+		require.Equal(t, sn.EndTime, sn.StartTime)
 
-	if got, want := policyTree.Child("d3").EffectivePolicy().ErrorHandlingPolicy.IgnoreDirectoryErrors.OrDefault(false), true; got != want {
-		t.Fatalf("policy not effective")
+		gotCheckpointTimes = append(gotCheckpointTimes, sn.EndTime.UTC())
 	}
 
-	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
-	if err != nil {
-		t.Errorf("unexpected error: %v", err)
-	}
+	sort.Slice(gotCheckpointTimes, func(i, j int) bool {
+		return gotCheckpointTimes[i].Before(gotCheckpointTimes[j])
+	})
 
-	verifyErrors(t, man,
-		2, 1,
-		[]*fs.EntryWithError{
-			{EntryPath: "d1", Error: errTest.Error()},
-			{EntryPath: "d2/d1", Error: errTest.Error()},
-			{EntryPath: "d3", Error: errTest.Error()},
-		},
-	)
+	require.Equal(t, wantCheckpointTimes, gotCheckpointTimes)
 }
 
-func TestUploadWithCheckpointing(t *testing.T) {
+func TestUploadWithRequestedCheckpoints(t *testing.T) {
 	ctx := testlogging.Context(t)
 	th := newUploadTestHarness(ctx, t)
 
@@ -539,17 +1990,23 @@ func TestUploadWithCheckpointing(t *testing.T) {
 
 	u := NewUploader(th.repo)
 
-	fakeTicker := make(chan time.Time)
+	// multiple requests are coalesced into one.
+	u.RequestCheckpoint()
+	u.RequestCheckpoint()
+	require.Len(t, u.checkpointRequests, 1)
+	<-u.checkpointRequests
 
-	// inject fake ticker that we can control externally instead of through time passage.
+	// periodic checkpoints never fire.
 	u.getTicker = func(d time.Duration) <-chan time.Time {
-		return fakeTicker
+		return nil
 	}
 
-	// create a channel that will be sent to whenever checkpoint completes.
 	u.checkpointFinished = make(chan struct{})
 	u.disableEstimation = true
 
+	// process directories one at a time, so that requests made by them are not coalesced.
+	u.ParallelUploads = 1
+
 	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
 
 	si := snapshot.SourceInfo{
@@ -558,40 +2015,27 @@ func TestUploadWithCheckpointing(t *testing.T) {
 		Path:     "path",
 	}
 
-	// inject a action into mock filesystem to trigger and wait for checkpoints at few places.
-	// the places are not important, what's important that those are 3 separate points in time.
 	dirsToCheckpointAt := []*mockfs.Directory{
 		th.sourceDir.Subdir("d1"),
 		th.sourceDir.Subdir("d2"),
-		th.sourceDir.Subdir("d1").Subdir("d2"),
 	}
 
 	for _, d := range dirsToCheckpointAt {
 		d.OnReaddir(func() {
-			// trigger checkpoint
-			fakeTicker <- clock.Now()
-			// wait for checkpoint
+			u.RequestCheckpoint()
 			<-u.checkpointFinished
 		})
 	}
 
-	if _, err := u.Upload(ctx, th.sourceDir, policyTree, si); err != nil {
-		t.Errorf("Upload error: %v", err)
-	}
+	_, err := u.Upload(ctx, th.sourceDir, policyTree, si)
+	require.NoError(t, err)
 
 	snapshots, err := snapshot.ListSnapshots(ctx, th.repo, si)
-	if err != nil {
-		t.Fatalf("error listing snapshots: %v", err)
-	}
-
-	if got, want := len(snapshots), len(dirsToCheckpointAt); got != want {
-		t.Fatalf("unexpected number of snapshots: %v, want %v", got, want)
-	}
+	require.NoError(t, err)
+	require.Len(t, snapshots, len(dirsToCheckpointAt))
 
 	for _, sn := range snapshots {
-		if got, want := sn.IncompleteReason, IncompleteReasonCheckpoint; got != want {
-			t.Errorf("unexpected incompleteReason %q, want %q", got, want)
-		}
+		require.Equal(t, IncompleteReasonCheckpoint, sn.IncompleteReason)
 	}
 }
 
@@ -660,6 +2104,188 @@ func TestUploadScanIgnoresFiles(t *testing.T) {
 	}
 }
 
+func TestUploadScanDetails(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+
+	sourceDir := mockfs.NewDirectory()
+	sourceDir.AddFile("f1", []byte{1, 2, 3}, defaultPermissions)
+	sourceDir.AddDir("d1", defaultPermissions)
+	sourceDir.AddFile("d1/f2", []byte{1, 2, 3, 4}, defaultPermissions)
+	sourceDir.AddFile("d1/big", make([]byte, 20000), defaultPermissions)
+
+	result, err := u.scanDirectory(ctx, sourceDir, nil)
+	require.NoError(t, err)
+
+	details := result.details()
+	require.Equal(t, 3, details.FileCount)
+	require.Equal(t, int64(20007), details.TotalFileSize)
+	require.Equal(t, 2, details.DirectoryCount)
+
+	histogram := map[int64]int{}
+
+	for _, b := range details.FileSizes {
+		if b.Count > 0 {
+			histogram[b.MinSize] = b.Count
+		}
+	}
+
+	require.Equal(t, map[int64]int{0: 2, 1e4: 1}, histogram)
+}
+
+func TestUpload_FollowSymlinks(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	shared := mockfs.NewDirectory()
+	sharedFile := shared.AddFile("data", []byte{1, 2, 3}, defaultPermissions)
+	sharedDir := shared.AddDir("dir", defaultPermissions)
+	sharedDir.AddFile("nested", []byte{4, 5, 6, 7}, defaultPermissions)
+
+	// symlink inside followed directory pointing back to it.
+	sharedDir.AddSymlink("loop", ".", defaultPermissions).SetResolvedEntry(sharedDir)
+
+	sourceDir := mockfs.NewDirectory()
+	sourceDir.AddSymlink("link-file", "/shared/data", defaultPermissions).SetResolvedEntry(sharedFile)
+	sourceDir.AddSymlink("link-dir", "/shared/dir", defaultPermissions).SetResolvedEntry(sharedDir)
+	sourceDir.AddSymlink("dangling", "/no-such-file", defaultPermissions)
+
+	cases := []struct {
+		mode        string
+		wantTypes   map[string]snapshot.EntryType
+		wantIgnored int
+	}{
+		{
+			mode: "",
+			wantTypes: map[string]snapshot.EntryType{
+				"link-file": snapshot.EntryTypeSymlink,
+				"link-dir":  snapshot.EntryTypeSymlink,
+				"dangling":  snapshot.EntryTypeSymlink,
+			},
+		},
+		{
+			mode: policy.SymlinksFollow,
+			wantTypes: map[string]snapshot.EntryType{
+				"link-file": snapshot.EntryTypeFile,
+				"link-dir":  snapshot.EntryTypeDirectory,
+				"dangling":  snapshot.EntryTypeSymlink,
+			},
+			wantIgnored: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		policyTree := policy.BuildTree(map[string]*policy.Policy{
+			".": {UploadPolicy: policy.UploadPolicy{Symlinks: tc.mode}},
+		}, policy.DefaultPolicy)
+
+		man, err := NewUploader(th.repo).Upload(ctx, sourceDir, policyTree, snapshot.SourceInfo{})
+		require.NoError(t, err)
+
+		entries, err := EntryFromDirEntry(th.repo, man.RootEntry).(fs.Directory).Readdir(ctx)
+		require.NoError(t, err)
+
+		gotTypes := map[string]snapshot.EntryType{}
+
+		for _, e := range entries {
+			gotTypes[e.Name()] = e.(snapshot.HasDirEntry).DirEntry().Type
+		}
+
+		require.Equal(t, tc.wantTypes, gotTypes, tc.mode)
+		require.Equal(t, tc.wantIgnored, man.RootEntry.DirSummary.IgnoredErrorCount, tc.mode)
+
+		if tc.mode != policy.SymlinksFollow {
+			continue
+		}
+
+		require.Equal(t, "link-dir/loop", man.RootEntry.DirSummary.FailedEntries[0].EntryPath)
+		require.Contains(t, man.RootEntry.DirSummary.FailedEntries[0].Error, fs.ErrSymlinkLoop.Error())
+
+		linkDir := entries.FindByName("link-dir").(fs.Directory)
+
+		nested, err := linkDir.Readdir(ctx)
+		require.NoError(t, err)
+		require.Len(t, nested, 1)
+		require.Equal(t, "nested", nested[0].Name())
+		require.Equal(t, int64(4), nested[0].Size())
+	}
+}
+
+func TestUpload_FollowSymlinksToAncestors(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	policyTree := policy.BuildTree(map[string]*policy.Policy{
+		".": {UploadPolicy: policy.UploadPolicy{Symlinks: policy.SymlinksFollow}},
+	}, policy.DefaultPolicy)
+
+	// symlinks pointing to the root and to a parent of the directory containing them.
+	sourceDir := mockfs.NewDirectory()
+	sub := sourceDir.AddDir("sub", defaultPermissions)
+	sub.AddFile("data", []byte{1, 2, 3}, defaultPermissions)
+	sub.AddSymlink("up", "..", defaultPermissions).SetResolvedEntry(sourceDir)
+	sub.AddDir("nested", defaultPermissions).AddSymlink("up2", "../..", defaultPermissions).SetResolvedEntry(sourceDir)
+
+	man, err := NewUploader(th.repo).Upload(ctx, sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	// the tree is uploaded once, links to ancestors are reported as ignored errors.
+	require.Equal(t, 2, man.RootEntry.DirSummary.IgnoredErrorCount)
+	require.EqualValues(t, 1, man.RootEntry.DirSummary.TotalFileCount)
+
+	var failed []string
+
+	for _, fe := range man.RootEntry.DirSummary.FailedEntries {
+		require.Contains(t, fe.Error, fs.ErrSymlinkLoop.Error())
+		failed = append(failed, fe.EntryPath)
+	}
+
+	require.ElementsMatch(t, []string{"sub/up", "sub/nested/up2"}, failed)
+}
+
+func TestUpload_FollowSymlinksToAncestorsOfLocalRootWithSymlinkInPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires elevated privileges on Windows")
+	}
+
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	td := testutil.TempDirectory(t)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(td, "real", "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(td, "real", "sub", "data"), []byte{1, 2, 3}, 0o600))
+	require.NoError(t, os.Symlink("..", filepath.Join(td, "real", "sub", "up")))
+
+	// the root of the upload is reached through a symbolic link, so its path is different from the
+	// resolved target of sub/up.
+	require.NoError(t, os.Symlink(filepath.Join(td, "real"), filepath.Join(td, "alias")))
+
+	srcdir, err := localfs.Directory(filepath.Join(td, "alias"))
+	require.NoError(t, err)
+
+	policyTree := policy.BuildTree(map[string]*policy.Policy{
+		".": {UploadPolicy: policy.UploadPolicy{Symlinks: policy.SymlinksFollow}},
+	}, policy.DefaultPolicy)
+
+	man, err := NewUploader(th.repo).Upload(ctx, srcdir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	require.Equal(t, 1, man.RootEntry.DirSummary.IgnoredErrorCount)
+	require.EqualValues(t, 1, man.RootEntry.DirSummary.TotalFileCount)
+	require.Equal(t, "sub/up", man.RootEntry.DirSummary.FailedEntries[0].EntryPath)
+}
+
 func TestUpload_VirtualDirectoryWithStreamingFile(t *testing.T) {
 	ctx := testlogging.Context(t)
 	th := newUploadTestHarness(ctx, t)
@@ -715,6 +2341,122 @@ func TestUpload_VirtualDirectoryWithStreamingFile(t *testing.T) {
 	}
 }
 
+// stagedReader returns data of each stage followed by a read of zero bytes that invokes the stage's callback.
+type stagedReader struct {
+	stages []stagedReaderStage
+}
+
+type stagedReaderStage struct {
+	data  []byte
+	after func()
+}
+
+func (r *stagedReader) Read(p []byte) (int, error) {
+	if len(r.stages) == 0 {
+		return 0, io.EOF
+	}
+
+	st := &r.stages[0]
+	if len(st.data) > 0 {
+		n := copy(p, st.data)
+		st.data = st.data[n:]
+
+		return n, nil
+	}
+
+	r.stages = r.stages[1:]
+
+	if st.after != nil {
+		st.after()
+	}
+
+	return 0, nil
+}
+
+func TestUpload_StreamingFileCheckpointAndCancel(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+
+	fakeTicker := make(chan time.Time)
+
+	u.getTicker = func(d time.Duration) <-chan time.Time {
+		return fakeTicker
+	}
+
+	u.checkpointFinished = make(chan struct{})
+	u.disableEstimation = true
+
+	// large enough to be split into multiple contents before the checkpoint.
+	data := make([]byte, 24<<20)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	const checkpointAt = 20 << 20
+
+	staticRoot := virtualfs.NewStaticDirectory("rootdir", fs.Entries{
+		virtualfs.StreamingFileFromReader("stream-file", &stagedReader{
+			stages: []stagedReaderStage{
+				{data: data[0:checkpointAt], after: func() {
+					fakeTicker <- clock.Now()
+					<-u.checkpointFinished
+				}},
+				{data: data[checkpointAt:], after: u.Cancel},
+			},
+		}),
+	})
+
+	si := snapshot.SourceInfo{
+		UserName: "user",
+		Host:     "host",
+		Path:     "path",
+	}
+
+	man, err := u.Upload(ctx, staticRoot, policy.BuildTree(nil, policy.DefaultPolicy), si)
+	require.NoError(t, err)
+	require.Equal(t, IncompleteReasonCanceled, man.IncompleteReason)
+
+	// all data streamed before cancelation is preserved in the partial entry.
+	entries, err := EntryFromDirEntry(th.repo, man.RootEntry).(fs.Directory).Readdir(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "stream-file"+partialStreamingFileSuffix, entries[0].Name())
+	require.Equal(t, int64(len(data)), entries[0].Size())
+	require.Equal(t, data, mustReadFileEntry(ctx, t, entries[0]))
+
+	// checkpoint includes the data written so far in the partial entry.
+	snapshots, err := snapshot.ListSnapshots(ctx, th.repo, si)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	require.Equal(t, IncompleteReasonCheckpoint, snapshots[0].IncompleteReason)
+
+	entries, err = EntryFromDirEntry(th.repo, snapshots[0].RootEntry).(fs.Directory).Readdir(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.True(t, strings.HasPrefix(entries[0].Name(), ".checkpointed.stream-file"+partialStreamingFileSuffix+"."), entries[0].Name())
+
+	checkpointed := mustReadFileEntry(ctx, t, entries[0])
+	require.NotEmpty(t, checkpointed)
+	require.Equal(t, data[0:len(checkpointed)], checkpointed)
+}
+
+func mustReadFileEntry(ctx context.Context, t *testing.T, e fs.Entry) []byte {
+	t.Helper()
+
+	r, err := e.(fs.File).Open(ctx)
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return b
+}
+
 type mockLogger struct {
 	logging.Logger
 
@@ -736,6 +2478,82 @@ func (l *mockLogger) Debugw(msg string, keysAndValues ...interface{}) {
 	l.logged = append(l.logged, loggedAction{msg, m})
 }
 
+func TestUploadAttachObjectMetadata(t *testing.T) {
+	ml := &mockLogger{
+		Logger: logging.NullLogger(),
+	}
+
+	ctx := logging.WithLogger(testlogging.Context(t), func(module string) logging.Logger {
+		if module == "object" {
+			return ml
+		}
+
+		return logging.NullLogger()
+	})
+
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	sourceInfo := snapshot.SourceInfo{Host: "somehost", UserName: "someuser", Path: "/somepath"}
+
+	u := NewUploader(th.repo)
+	u.ParallelUploads = 1
+
+	man1, err := u.Upload(ctx, th.sourceDir, policy.BuildTree(nil, policy.DefaultPolicy), sourceInfo)
+	require.NoError(t, err)
+	require.Empty(t, ml.logged)
+	require.Nil(t, u.WrittenObjectMetadata())
+
+	u.AttachObjectMetadata = true
+
+	man2, err := u.Upload(ctx, th.sourceDir, policy.BuildTree(nil, policy.DefaultPolicy), sourceInfo)
+	require.NoError(t, err)
+
+	// metadata does not affect object IDs.
+	require.Equal(t, man1.RootObjectID(), man2.RootObjectID())
+
+	got := map[string]string{}
+
+	for _, l := range ml.logged {
+		require.Equal(t, "wrote object", l.msg)
+		require.Equal(t, sourceInfo.String(), l.keysAndValues[ObjectMetadataSource])
+
+		got[l.keysAndValues[ObjectMetadataPath].(string)] = l.keysAndValues[ObjectMetadataEntryType].(string)
+	}
+
+	require.Equal(t, "d", got["."])
+	require.Equal(t, "d", got["d1/d2"])
+	require.Equal(t, "f", got["d1/d2/f1"])
+	require.Equal(t, "f", got["f3"])
+
+	// metadata is stored in the repository along with the snapshot ID.
+	written := u.WrittenObjectMetadata()
+	require.Len(t, written, len(ml.logged))
+
+	snapID, err := snapshot.SaveSnapshot(ctx, th.repo, man2)
+	require.NoError(t, err)
+
+	_, err = SaveObjectMetadata(ctx, th.repo, snapID, written)
+	require.NoError(t, err)
+
+	loaded, err := LoadObjectMetadata(ctx, th.repo, snapID)
+	require.NoError(t, err)
+	require.Len(t, loaded, len(written))
+
+	for _, o := range loaded {
+		require.Equal(t, string(snapID), o.Metadata[ObjectMetadataSnapshotID])
+
+		if o.Metadata[ObjectMetadataPath] == "." {
+			require.Equal(t, man2.RootObjectID(), o.ObjectID)
+		}
+	}
+
+	loaded, err = LoadObjectMetadata(ctx, th.repo, "no-such-snapshot")
+	require.NoError(t, err)
+	require.Empty(t, loaded)
+}
+
 func TestParallelUploadDedup(t *testing.T) {
 	ctx := testlogging.Context(t)
 	th := newUploadTestHarness(ctx, t)
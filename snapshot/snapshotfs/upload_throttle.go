@@ -0,0 +1,45 @@
+package snapshotfs
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob/throttling"
+)
+
+// uploadThrottlingWindow is the duration window during which the upload token bucket fully replenishes,
+// which allows short bursts of up to one window worth of data above the limit.
+const uploadThrottlingWindow = time.Second
+
+// uploadThrottle limits the number of bytes per second written by the uploader using a throttler shared
+// by all parallel uploads.
+type uploadThrottle struct {
+	throttler throttling.Throttler
+}
+
+// wait blocks until n bytes can be written, returning errCanceled if the context is canceled while waiting,
+// which happens when the uploader is canceled, regardless of how long the wait is due to tokens taken by
+// parallel uploads.
+func (t *uploadThrottle) wait(ctx context.Context, n int64) error {
+	t.throttler.BeforeUpload(ctx, n)
+
+	if ctx.Err() != nil {
+		return errors.Wrap(errCanceled, "canceled while throttling upload")
+	}
+
+	return nil
+}
+
+// newUploadThrottle returns a throttle limiting uploads to the provided positive number of bytes per second.
+func newUploadThrottle(bytesPerSecond int64) (*uploadThrottle, error) {
+	th, err := throttling.NewThrottler(throttling.Limits{
+		UploadBytesPerSecond: float64(bytesPerSecond),
+	}, uploadThrottlingWindow, 1)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create upload throttler")
+	}
+
+	return &uploadThrottle{th}, nil
+}
@@ -0,0 +1,96 @@
+package snapshotfs
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// validateUploadedManifest reads back the root object of the provided snapshot manifest and verifies
+// that it can be decoded and that its directory summary is consistent with its entries.
+func (u *Uploader) validateUploadedManifest(ctx context.Context, man *snapshot.Manifest) error {
+	root := man.RootEntry
+	if root == nil {
+		return errors.Errorf("snapshot has no root entry")
+	}
+
+	if _, err := u.repo.VerifyObject(ctx, root.ObjectID); err != nil {
+		return errors.Wrapf(err, "invalid root object %v", root.ObjectID)
+	}
+
+	if root.Type != snapshot.EntryTypeDirectory {
+		return nil
+	}
+
+	r, err := u.repo.OpenObject(ctx, root.ObjectID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open root directory %v", root.ObjectID)
+	}
+	defer r.Close() //nolint:errcheck
+
+	entries, summ, err := readDirEntries(r)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read root directory %v", root.ObjectID)
+	}
+
+	return verifyDirSummaryConsistency(root.DirSummary, entries, summ)
+}
+
+// verifyDirSummaryConsistency verifies that the directory summary stored in the directory manifest
+// matches the summary in its parent entry and the totals computed from its entries.
+func verifyDirSummaryConsistency(parentSumm *fs.DirectorySummary, entries []*snapshot.DirEntry, summ *fs.DirectorySummary) error {
+	if summ == nil {
+		return errors.Errorf("directory manifest has no summary")
+	}
+
+	if parentSumm != nil {
+		if parentSumm.TotalFileCount != summ.TotalFileCount ||
+			parentSumm.TotalFileSize != summ.TotalFileSize ||
+			parentSumm.TotalDirCount != summ.TotalDirCount {
+			return errors.Errorf("directory summary %+v does not match summary in parent entry %+v", *summ, *parentSumm)
+		}
+	}
+
+	// the directory itself is included in the total directory count.
+	computed := fs.DirectorySummary{
+		TotalDirCount: 1,
+	}
+
+	for _, e := range entries {
+		// nolint:exhaustive
+		switch e.Type {
+		case snapshot.EntryTypeFile:
+			computed.TotalFileCount++
+			computed.TotalFileSize += e.FileSize
+
+		case snapshot.EntryTypeDirectory:
+			if cs := e.DirSummary; cs != nil {
+				computed.TotalFileCount += cs.TotalFileCount
+				computed.TotalFileSize += cs.TotalFileSize
+				computed.TotalDirCount += cs.TotalDirCount
+			}
+		}
+	}
+
+	if computed.TotalFileCount != summ.TotalFileCount {
+		return errors.Errorf("directory summary has %v files, entries have %v", summ.TotalFileCount, computed.TotalFileCount)
+	}
+
+	if computed.TotalFileSize != summ.TotalFileSize {
+		return errors.Errorf("directory summary has %v bytes, entries have %v", summ.TotalFileSize, computed.TotalFileSize)
+	}
+
+	if computed.TotalDirCount != summ.TotalDirCount {
+		return errors.Errorf("directory summary has %v directories, entries have %v", summ.TotalDirCount, computed.TotalDirCount)
+	}
+
+	// summaries written by older versions don't have the number of children.
+	if summ.ImmediateChildCount != 0 && summ.ImmediateChildCount != int64(len(entries)) {
+		return errors.Errorf("directory summary has %v children, directory has %v entries", summ.ImmediateChildCount, len(entries))
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package snapshotgc
+
+import (
+	"context"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+// excludeSources returns the manifests that don't belong to any of the excluded sources.
+func excludeSources(ctx context.Context, manifests []*snapshot.Manifest, excluded []snapshot.SourceInfo) []*snapshot.Manifest {
+	if len(excluded) == 0 {
+		return manifests
+	}
+
+	var result []*snapshot.Manifest
+
+	excludedCount := map[snapshot.SourceInfo]int{}
+
+	for _, m := range manifests {
+		if ex, ok := matchingSource(m.Source, excluded); ok {
+			excludedCount[ex]++
+			continue
+		}
+
+		result = append(result, m)
+	}
+
+	for _, ex := range excluded {
+		log(ctx).Warnf("WARNING: ignoring %v snapshots of excluded source %v, contents only used by them will be garbage-collected.", excludedCount[ex], ex)
+	}
+
+	return result
+}
+
+func matchingSource(si snapshot.SourceInfo, excluded []snapshot.SourceInfo) (snapshot.SourceInfo, bool) {
+	for _, ex := range excluded {
+		if ex.Host != si.Host || ex.UserName != si.UserName {
+			continue
+		}
+
+		if ex.Path == "" || ex.Path == si.Path {
+			return ex, true
+		}
+	}
+
+	return snapshot.SourceInfo{}, false
+}
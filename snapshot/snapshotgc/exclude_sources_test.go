@@ -0,0 +1,41 @@
+package snapshotgc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestExcludeSources(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var (
+		a1 = &snapshot.Manifest{Source: snapshot.SourceInfo{UserName: "u", Host: "h", Path: "/a"}}
+		a2 = &snapshot.Manifest{Source: snapshot.SourceInfo{UserName: "u", Host: "h", Path: "/a"}}
+		b  = &snapshot.Manifest{Source: snapshot.SourceInfo{UserName: "u", Host: "h", Path: "/b"}}
+		c  = &snapshot.Manifest{Source: snapshot.SourceInfo{UserName: "u", Host: "h2", Path: "/a"}}
+		d  = &snapshot.Manifest{Source: snapshot.SourceInfo{UserName: "u2", Host: "h", Path: "/a"}}
+	)
+
+	all := []*snapshot.Manifest{a1, b, a2, c, d}
+
+	require.Equal(t, all, excludeSources(ctx, all, nil))
+
+	require.Equal(t, []*snapshot.Manifest{b, c, d}, excludeSources(ctx, all, []snapshot.SourceInfo{
+		{UserName: "u", Host: "h", Path: "/a"},
+	}))
+
+	// source without path excludes all paths of the user and host.
+	require.Equal(t, []*snapshot.Manifest{c, d}, excludeSources(ctx, all, []snapshot.SourceInfo{
+		{UserName: "u", Host: "h"},
+	}))
+
+	require.Equal(t, []*snapshot.Manifest{b}, excludeSources(ctx, all, []snapshot.SourceInfo{
+		{UserName: "u", Host: "h", Path: "/a"},
+		{UserName: "u", Host: "h2"},
+		{UserName: "u2", Host: "h"},
+	}))
+}
@@ -0,0 +1,64 @@
+package snapshotgc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/stats"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// exportInUse runs the mark phase only and writes IDs of in-use contents to opt.ExportInUse, one per line,
+// sorted. The IDs are streamed by iterating the index, which is already sorted, and checking each content
+// against the in-use set, so the set is never materialized as a list.
+func exportInUse(ctx context.Context, rep repo.DirectRepository, opt Options, st *Stats) error {
+	if opt.BloomFilterOnly {
+		return errors.Errorf("in-use contents can't be exported when only using bloom filter")
+	}
+
+	used, err := newContentIDSet(opt)
+	if err != nil {
+		return errors.Wrap(err, "unable to create in-use content set")
+	}
+
+	defer used.Close() //nolint:errcheck
+
+	if err := findInUseContentIDs(ctx, rep, used, opt); err != nil {
+		return errors.Wrap(err, "unable to find in-use content ID")
+	}
+
+	log(ctx).Infof("Exporting in-use contents...")
+
+	var inUse stats.CountSum
+
+	w := bufio.NewWriter(opt.ExportInUse)
+
+	if err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{IncludeDeleted: true}, func(ci content.Info) error {
+		isUsed, err := used.Contains(ci.GetContentID())
+		if err != nil {
+			return errors.Wrap(err, "error checking in-use content")
+		}
+
+		if !isUsed {
+			return nil
+		}
+
+		inUse.Add(int64(ci.GetPackedLength()))
+
+		if _, err := fmt.Fprintln(w, ci.GetContentID()); err != nil {
+			return errors.Wrap(err, "error writing in-use content")
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "error iterating contents")
+	}
+
+	st.InUseCount, st.InUseBytes = inUse.Approximate()
+
+	return errors.Wrap(w.Flush(), "error writing in-use contents")
+}
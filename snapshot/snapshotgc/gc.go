@@ -3,9 +3,12 @@
 
 import (
 	"context"
-	"sync"
+	"io"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/internal/stats"
@@ -22,7 +25,98 @@
 
 var log = logging.Module("snapshotgc")
 
-func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *sync.Map) error {
+// ErrSnapshotsCreatedDuringGC is returned when snapshots have been created while looking for in-use contents,
+// in which case contents referenced only by them could be deleted.
+var ErrSnapshotsCreatedDuringGC = errors.New("snapshots have been created during garbage collection")
+
+// Options provides optional settings for snapshot garbage collection.
+//
+// By default the set of in-use content IDs is kept entirely in memory, which on repositories with
+// hundreds of millions of contents may exhaust RAM. Setting InUseSetDirectory bounds memory usage
+// by spilling content IDs to sorted files on disk at the cost of additional disk I/O during the
+// sweep phase, which can be reduced by also setting BloomFilterBytes.
+//
+// BloomFilterOnly keeps only the bloom filter in memory and no exact set. This is the fastest and
+// smallest mode, but the filter has false positives (roughly 1% with 10 bits per in-use content
+// and growing quickly as the filter gets more crowded), so some unreferenced contents will be
+// treated as in-use and not collected until a subsequent GC. In-use contents are never deleted
+// because the filter never produces false negatives. Since a false positive can't be told apart from
+// a referenced content, deleted contents matching the filter are collected during the sweep and
+// confirmed by walking snapshots again looking only for them, before being undeleted.
+type Options struct {
+	// InUseSetDirectory, when not empty, causes in-use content IDs to be stored in a temporary
+	// subdirectory of the provided directory.
+	InUseSetDirectory string
+
+	// InUseSetMaxBufferedIDs is the maximum number of content IDs buffered in memory before being
+	// written to disk, defaults to DefaultInUseSetMaxBufferedIDs.
+	InUseSetMaxBufferedIDs int
+
+	// BloomFilterBytes is the size of the in-memory bloom filter used to avoid disk lookups
+	// of unreferenced contents, 0 disables the bloom filter.
+	BloomFilterBytes int
+
+	// BloomFilterOnly causes only the bloom filter to be used to determine in-use contents.
+	BloomFilterOnly bool
+
+	// SnapshotParallelism is the number of snapshots whose trees are walked concurrently,
+	// defaults to DefaultSnapshotParallelism.
+	SnapshotParallelism int
+
+	// MaxTreeDepth and MaxTreeObjects limit the depth of snapshot trees and the total number of unique objects
+	// walked when looking for in-use contents, so that GC fails on malformed snapshots, zero means unlimited.
+	MaxTreeDepth   int
+	MaxTreeObjects int
+
+	// VerifyContents causes all files in snapshots to be read entirely while looking for in-use contents,
+	// so that GC fails when data referenced by snapshots can't be retrieved. This is expensive.
+	VerifyContents bool
+
+	// Progress, when set, is invoked to report progress of each phase.
+	Progress ProgressFunc
+
+	// FindOrphanedBlobs enables an additional pass after the sweep, which finds pack blobs containing
+	// only deleted contents.
+	FindOrphanedBlobs bool
+
+	// DeleteOrphanedBlobs causes orphaned blobs whose contents were deleted before the safe drop time
+	// to be deleted when contents are deleted.
+	DeleteOrphanedBlobs bool
+
+	// ExcludeSources is the list of snapshot sources whose snapshots are ignored when looking for in-use contents,
+	// making contents reachable only from them subject to garbage collection. Sources without a path match all
+	// paths of a given user and host. This is dangerous and only meant for sources about to be deleted.
+	ExcludeSources []snapshot.SourceInfo
+
+	// ExportInUse, when set, causes GC to only run the mark phase and write sorted IDs of in-use contents
+	// to the writer, one per line. Nothing is deleted and the run is not recorded as snapshot GC.
+	ExportInUse io.Writer
+
+	// Report, when set, receives a ReportEntry for each content not referenced by any snapshot.
+	// When the report is written, GC without deletion succeeds even if unused contents were found.
+	Report io.Writer
+
+	// MaxDeleteCount and MaxDeleteBytes limit the number and total packed size of contents deleted by
+	// a single run, so that a large cleanup can be spread over multiple runs, zero means unlimited.
+	// Unused contents over the limit are left for subsequent runs and reported as remaining.
+	MaxDeleteCount int64
+	MaxDeleteBytes int64
+}
+
+// deleteLimitReached returns true if deleting the content bringing the number and total size of contents
+// deleted by the current run to the provided values would exceed the delete limit.
+func (o *Options) deleteLimitReached(cnt uint32, totalSize int64) bool {
+	if o.MaxDeleteCount > 0 && int64(cnt) > o.MaxDeleteCount {
+		return true
+	}
+
+	return o.MaxDeleteBytes > 0 && totalSize > o.MaxDeleteBytes
+}
+
+// DefaultSnapshotParallelism is the default number of snapshots walked concurrently.
+const DefaultSnapshotParallelism = 1
+
+func findInUseContentIDs(ctx context.Context, rep repo.Repository, used contentIDSet, opt Options) error {
 	ids, err := snapshot.ListSnapshotManifests(ctx, rep, nil, nil)
 	if err != nil {
 		return errors.Wrap(err, "unable to list snapshot manifest IDs")
@@ -33,6 +127,8 @@ func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *sync.Ma
 		return errors.Wrap(err, "unable to load manifest IDs")
 	}
 
+	manifests = excludeSources(ctx, manifests, opt.ExcludeSources)
+
 	w, twerr := snapshotfs.NewTreeWalker(snapshotfs.TreeWalkerOptions{
 		EntryCallback: func(ctx context.Context, entry fs.Entry, oid object.ID, entryPath string) error {
 			contentIDs, err := rep.VerifyObject(ctx, oid)
@@ -41,11 +137,16 @@ func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *sync.Ma
 			}
 
 			for _, cid := range contentIDs {
-				used.Store(cid, nil)
+				if err := used.Add(cid); err != nil {
+					return errors.Wrap(err, "error adding in-use content")
+				}
 			}
 
 			return nil
 		},
+		MaxDepth:       opt.MaxTreeDepth,
+		MaxVisited:     opt.MaxTreeObjects,
+		VerifyContents: opt.VerifyContents,
 	})
 	if twerr != nil {
 		return errors.Wrap(twerr, "unable to initialize tree walker")
@@ -55,15 +156,125 @@ func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *sync.Ma
 
 	log(ctx).Infof("Looking for active contents...")
 
+	parallelism := opt.SnapshotParallelism
+	if parallelism <= 0 {
+		parallelism = DefaultSnapshotParallelism
+	}
+
+	work := make(chan *snapshot.Manifest, len(manifests))
 	for _, m := range manifests {
-		root, err := snapshotfs.SnapshotRoot(rep, m)
+		work <- m
+	}
+
+	close(work)
+
+	// all workers share the tree walker, so objects shared between snapshots are only walked once.
+	eg, egctx := errgroup.WithContext(ctx)
+
+	var walked int64
+
+	opt.reportProgress(PhaseMark, 0, int64(len(manifests)))
+
+	for i := 0; i < parallelism; i++ {
+		eg.Go(func() error {
+			for m := range work {
+				if egctx.Err() != nil {
+					return nil
+				}
+
+				root, err := snapshotfs.SnapshotRoot(rep, m)
+				if err != nil {
+					return errors.Wrap(err, "unable to get snapshot root")
+				}
+
+				if err := w.Process(egctx, root, ""); err != nil {
+					return errors.Wrap(err, "error processing snapshot root")
+				}
+
+				opt.reportProgress(PhaseMark, atomic.AddInt64(&walked, 1), int64(len(manifests)))
+			}
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return errors.Wrap(err, "error walking snapshots")
+	}
+
+	return errors.Wrap(used.Seal(), "error sealing in-use content set")
+}
+
+// undeleteConfirmedContents walks all snapshots again looking only for the provided deleted contents,
+// which were found by an inexact in-use set, and undeletes the ones that are actually referenced.
+func undeleteConfirmedContents(ctx context.Context, rep repo.DirectRepositoryWriter, candidates map[content.ID]int64, opt Options, inUse, undeleted *stats.CountSum) error {
+	log(ctx).Infof("Confirming references to %v deleted contents...", len(candidates))
+
+	confirmed := &candidateContentIDSet{candidates: candidates}
+
+	exactOpt := opt
+	exactOpt.Progress = nil
+
+	if err := findInUseContentIDs(ctx, rep, confirmed, exactOpt); err != nil {
+		return errors.Wrap(err, "unable to confirm in-use contents")
+	}
+
+	for cid, packedLength := range candidates {
+		isUsed, err := confirmed.Contains(cid)
 		if err != nil {
-			return errors.Wrap(err, "unable to get snapshot root")
+			return errors.Wrap(err, "error checking in-use content")
 		}
 
-		if err := w.Process(ctx, root, ""); err != nil {
-			return errors.Wrap(err, "error processing snapshot root")
+		if !isUsed {
+			log(ctx).Debugf("not undeleting unreferenced content %v", cid)
+			continue
 		}
+
+		if err := rep.ContentManager().UndeleteContent(ctx, cid); err != nil {
+			return errors.Wrapf(err, "Could not undelete referenced content: %v", cid)
+		}
+
+		undeleted.Add(packedLength)
+		inUse.Add(packedLength)
+	}
+
+	return nil
+}
+
+// latestSnapshotManifestTime returns the latest modification time of snapshot manifests in the repository.
+func latestSnapshotManifestTime(ctx context.Context, rep repo.Repository) (time.Time, error) {
+	entries, err := rep.FindManifests(ctx, map[string]string{
+		manifest.TypeLabelKey: snapshot.ManifestType,
+	})
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "unable to find snapshot manifests")
+	}
+
+	var latest time.Time
+
+	for _, e := range entries {
+		if e.ModTime.After(latest) {
+			latest = e.ModTime
+		}
+	}
+
+	return latest, nil
+}
+
+// verifyNoSnapshotsCreatedSince returns ErrSnapshotsCreatedDuringGC if any snapshot manifest
+// visible after refreshing the repository is newer than the provided time.
+func verifyNoSnapshotsCreatedSince(ctx context.Context, rep repo.DirectRepositoryWriter, markStart time.Time) error {
+	if err := rep.Refresh(ctx); err != nil {
+		return errors.Wrap(err, "error refreshing repository")
+	}
+
+	latest, err := latestSnapshotManifestTime(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	if latest.After(markStart) {
+		return errors.Wrapf(ErrSnapshotsCreatedDuringGC, "snapshot created at %v after marking started", latest)
 	}
 
 	return nil
@@ -71,37 +282,98 @@ func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *sync.Ma
 
 // Run performs garbage collection on all the snapshots in the repository.
 func Run(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete bool, safety maintenance.SafetyParameters) (Stats, error) {
+	return RunWithOptions(ctx, rep, gcDelete, safety, Options{})
+}
+
+// RunWithOptions performs garbage collection on all the snapshots in the repository using the provided options.
+func RunWithOptions(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete bool, safety maintenance.SafetyParameters, opt Options) (Stats, error) {
 	var st Stats
 
+	if opt.ExportInUse != nil {
+		// mark-only run must not count as a GC cycle for the purpose of safety.
+		err := exportInUse(ctx, rep, opt, &st)
+
+		return st, errors.Wrap(err, "error exporting in-use contents")
+	}
+
 	err := maintenance.ReportRun(ctx, rep, maintenance.TaskSnapshotGarbageCollection, nil, func() error {
-		return runInternal(ctx, rep, gcDelete, safety, &st)
+		return runInternal(ctx, rep, gcDelete, safety, opt, &st)
 	})
 
 	return st, errors.Wrap(err, "error running snapshot gc")
 }
 
-func runInternal(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete bool, safety maintenance.SafetyParameters, st *Stats) error {
-	var (
-		used sync.Map
+func runInternal(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete bool, safety maintenance.SafetyParameters, opt Options, st *Stats) error {
+	var unused, inUse, system, tooRecent, undeleted, deleted, remaining stats.CountSum
+
+	used, err := newContentIDSet(opt)
+	if err != nil {
+		return errors.Wrap(err, "unable to create in-use content set")
+	}
 
-		unused, inUse, system, tooRecent, undeleted stats.CountSum
-	)
+	defer used.Close() //nolint:errcheck
 
-	if err := findInUseContentIDs(ctx, rep, &used); err != nil {
+	markStart, err := latestSnapshotManifestTime(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	if err := findInUseContentIDs(ctx, rep, used, opt); err != nil {
 		return errors.Wrap(err, "unable to find in-use content ID")
 	}
 
+	// snapshots created after the mark phase started may reference new contents which were not marked
+	// as in-use, so instead of relying only on MinContentAgeSubjectToGC, don't delete anything in such case.
+	if gcDelete {
+		if err := verifyNoSnapshotsCreatedSince(ctx, rep, markStart); err != nil {
+			return err
+		}
+	}
+
 	log(ctx).Infof("Looking for unreferenced contents...")
 
+	var scanned, totalContents int64
+
+	report := newReportWriter(opt.Report)
+
+	if opt.Progress != nil {
+		if totalContents, err = countContents(ctx, rep); err != nil {
+			return err
+		}
+
+		opt.reportProgress(PhaseSweep, 0, totalContents)
+	}
+
+	// deleted contents which may be referenced according to an inexact set, keyed by content ID with packed length.
+	undeleteCandidates := map[content.ID]int64{}
+
 	// Ensure that the iteration includes deleted contents, so those can be
 	// undeleted (recovered).
-	err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{IncludeDeleted: true}, func(ci content.Info) error {
+	err = rep.ContentReader().IterateContents(ctx, content.IterateOptions{IncludeDeleted: true}, func(ci content.Info) error {
+		scanned++
+
+		if scanned%sweepProgressInterval == 0 {
+			opt.reportProgress(PhaseSweep, scanned, totalContents)
+		}
+
 		if manifest.ContentPrefix == ci.GetContentID().Prefix() {
 			system.Add(int64(ci.GetPackedLength()))
-			return nil
+			return report.write(ci, ContentStatusSystem)
 		}
 
-		if _, ok := used.Load(ci.GetContentID()); ok {
+		isUsed, err := used.Contains(ci.GetContentID())
+		if err != nil {
+			return errors.Wrap(err, "error checking in-use content")
+		}
+
+		if isUsed {
+			if ci.GetDeleted() && !used.Exact() {
+				// the content may be a false positive, in which case undeleting it would resurrect garbage,
+				// so it's only undeleted after the reference has been confirmed.
+				undeleteCandidates[ci.GetContentID()] = int64(ci.GetPackedLength())
+				return nil
+			}
+
 			if ci.GetDeleted() {
 				if err := rep.ContentManager().UndeleteContent(ctx, ci.GetContentID()); err != nil {
 					return errors.Wrapf(err, "Could not undelete referenced content: %v", ci)
@@ -116,13 +388,30 @@ func runInternal(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete
 		if rep.Time().Sub(ci.Timestamp()) < safety.MinContentAgeSubjectToGC {
 			log(ctx).Debugf("recent unreferenced content %v (%v bytes, modified %v)", ci.GetContentID(), ci.GetPackedLength(), ci.Timestamp())
 			tooRecent.Add(int64(ci.GetPackedLength()))
-			return nil
+			return report.write(ci, ContentStatusTooRecent)
 		}
 
 		log(ctx).Debugf("unreferenced %v (%v bytes, modified %v)", ci.GetContentID(), ci.GetPackedLength(), ci.Timestamp())
 		cnt, totalSize := unused.Add(int64(ci.GetPackedLength()))
 
-		if gcDelete {
+		if err := report.write(ci, ContentStatusUnused); err != nil {
+			return err
+		}
+
+		// contents deleted by an earlier run remain in the index until compaction, deleting them again
+		// is a no-op so they don't count towards the delete limit.
+		if gcDelete && !ci.GetDeleted() {
+			// unused contents are visited in a stable order and the running totals only grow, so once
+			// the limit is reached all remaining unused contents are left for the next run.
+			if dcnt, dsize := deleted.Add(int64(ci.GetPackedLength())); opt.deleteLimitReached(dcnt, dsize) {
+				if rcnt, _ := remaining.Add(int64(ci.GetPackedLength())); rcnt == 1 {
+					log(ctx).Infof("Reached delete limit, not deleting unused contents starting at %v", ci.GetContentID())
+					st.DeleteLimitStoppedAt = ci.GetContentID()
+				}
+
+				return nil
+			}
+
 			if err := rep.ContentManager().DeleteContent(ctx, ci.GetContentID()); err != nil {
 				return errors.Wrap(err, "error deleting content")
 			}
@@ -139,20 +428,36 @@ func runInternal(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete
 
 		return nil
 	})
+	if err != nil {
+		err = errors.Wrap(err, "error iterating contents")
+	} else if len(undeleteCandidates) > 0 {
+		err = undeleteConfirmedContents(ctx, rep, undeleteCandidates, opt, &inUse, &undeleted)
+	}
+
+	opt.reportProgress(PhaseSweep, scanned, totalContents)
 
 	st.UnusedCount, st.UnusedBytes = unused.Approximate()
 	st.InUseCount, st.InUseBytes = inUse.Approximate()
 	st.SystemCount, st.SystemBytes = system.Approximate()
 	st.TooRecentCount, st.TooRecentBytes = tooRecent.Approximate()
 	st.UndeletedCount, st.UndeletedBytes = undeleted.Approximate()
+	st.RemainingCount, st.RemainingBytes = remaining.Approximate()
 
 	if err != nil {
-		return errors.Wrap(err, "error iterating contents")
+		return err
 	}
 
-	if st.UnusedCount > 0 && !gcDelete {
+	if st.UnusedCount > 0 && !gcDelete && opt.Report == nil {
 		return errors.Errorf("Not deleting because '--delete' flag was not set")
 	}
 
-	return errors.Wrap(rep.Flush(ctx), "flush error")
+	if err := rep.Flush(ctx); err != nil {
+		return errors.Wrap(err, "flush error")
+	}
+
+	if opt.FindOrphanedBlobs {
+		return findOrphanedBlobs(ctx, rep, gcDelete && opt.DeleteOrphanedBlobs, safety, st)
+	}
+
+	return nil
 }
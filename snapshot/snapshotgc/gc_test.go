@@ -0,0 +1,236 @@
+package snapshotgc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/content/index"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+func TestSnapshotsCreatedDuringGC(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	_, err := RunWithOptions(ctx, env.RepositoryWriter, true, maintenance.SafetyNone, Options{})
+	require.NoError(t, err)
+
+	var created bool
+
+	_, err = RunWithOptions(ctx, env.RepositoryWriter, true, maintenance.SafetyNone, Options{
+		Progress: func(phase Phase, completed, total int64) {
+			if phase != PhaseMark || created {
+				return
+			}
+
+			created = true
+
+			// another session creates a snapshot after the mark phase has started.
+			w := env.MustOpenAnother(t)
+
+			_, err := snapshot.SaveSnapshot(ctx, w, &snapshot.Manifest{
+				Source: snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"},
+			})
+			require.NoError(t, err)
+			require.NoError(t, w.Flush(ctx))
+		},
+	})
+	require.ErrorIs(t, err, ErrSnapshotsCreatedDuringGC)
+	require.True(t, created)
+}
+
+func TestBloomFilterOnlyDoesNotUndelete(t *testing.T) {
+	// deletion must be newer than the content it deletes.
+	ft := faketime.NewTimeAdvance(time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC), time.Second)
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant, repotesting.Options{
+		OpenOptions: func(o *repo.Options) {
+			o.TimeNowFunc = ft.NowFunc()
+		},
+	})
+
+	// snapshot with enough contents to saturate a tiny bloom filter.
+	sourceRoot := mockfs.NewDirectory()
+	for i := 0; i < 200; i++ {
+		sourceRoot.AddFile(fmt.Sprintf("f%v", i), []byte(fmt.Sprintf("data-%v", i)), 0o644)
+	}
+
+	man, err := snapshotfs.NewUploader(env.RepositoryWriter).Upload(ctx, sourceRoot, nil, snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"})
+	require.NoError(t, err)
+
+	_, err = snapshot.SaveSnapshot(ctx, env.RepositoryWriter, man)
+	require.NoError(t, err)
+
+	cid, err := env.RepositoryWriter.ContentManager().WriteContent(ctx, gather.FromSlice([]byte("garbage")), "", content.NoCompression)
+	require.NoError(t, err)
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+	require.NoError(t, env.RepositoryWriter.ContentManager().DeleteContent(ctx, cid))
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	opt := Options{BloomFilterOnly: true, BloomFilterBytes: 1}
+
+	// the deleted content is a false positive of the bloom filter.
+	used, err := newContentIDSet(opt)
+	require.NoError(t, err)
+
+	defer used.Close()
+
+	require.NoError(t, findInUseContentIDs(ctx, env.RepositoryWriter, used, opt))
+
+	isUsed, err := used.Contains(cid)
+	require.NoError(t, err)
+	require.True(t, isUsed)
+
+	st, err := RunWithOptions(ctx, env.RepositoryWriter, true, maintenance.SafetyNone, opt)
+	require.NoError(t, err)
+	require.Zero(t, st.UndeletedCount)
+
+	ci, err := env.RepositoryWriter.ContentInfo(ctx, cid)
+	require.NoError(t, err)
+	require.True(t, ci.GetDeleted())
+}
+
+func TestBloomFilterOnlyUndeletesConfirmedContents(t *testing.T) {
+	// deletion must be newer than the content it deletes.
+	ft := faketime.NewTimeAdvance(time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC), time.Second)
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant, repotesting.Options{
+		OpenOptions: func(o *repo.Options) {
+			o.TimeNowFunc = ft.NowFunc()
+		},
+	})
+
+	sourceRoot := mockfs.NewDirectory()
+	sourceRoot.AddFile("f1", []byte("referenced-data"), 0o644)
+
+	man, err := snapshotfs.NewUploader(env.RepositoryWriter).Upload(ctx, sourceRoot, nil, snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"})
+	require.NoError(t, err)
+
+	_, err = snapshot.SaveSnapshot(ctx, env.RepositoryWriter, man)
+	require.NoError(t, err)
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	// soft-delete the content of the file, which is still referenced by the snapshot.
+	var fileContentIDs []content.ID
+
+	require.NoError(t, env.RepositoryWriter.ContentReader().IterateContents(ctx, content.IterateOptions{Range: index.AllNonPrefixedIDs}, func(ci content.Info) error {
+		fileContentIDs = append(fileContentIDs, ci.GetContentID())
+		return nil
+	}))
+	require.Len(t, fileContentIDs, 1)
+
+	require.NoError(t, env.RepositoryWriter.ContentManager().DeleteContent(ctx, fileContentIDs[0]))
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	st, err := RunWithOptions(ctx, env.RepositoryWriter, true, maintenance.SafetyNone, Options{BloomFilterOnly: true, BloomFilterBytes: 1024})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, st.UndeletedCount)
+
+	ci, err := env.RepositoryWriter.ContentInfo(ctx, fileContentIDs[0])
+	require.NoError(t, err)
+	require.False(t, ci.GetDeleted())
+}
+
+func TestOrphanedBlobsRequireSafeDropTime(t *testing.T) {
+	ft := faketime.NewClockTimeWithOffset(0)
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant, repotesting.Options{
+		OpenOptions: func(o *repo.Options) {
+			o.TimeNowFunc = ft.NowFunc()
+		},
+	})
+
+	// write a content in its own pack blob and delete it, which leaves the pack blob orphaned.
+	cid, err := env.RepositoryWriter.ContentManager().WriteContent(ctx, gather.FromSlice([]byte("orphaned")), "", content.NoCompression)
+	require.NoError(t, err)
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	ft.Advance(time.Second)
+	require.NoError(t, env.RepositoryWriter.ContentManager().DeleteContent(ctx, cid))
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	ft.Advance(48 * time.Hour)
+
+	opt := Options{FindOrphanedBlobs: true, DeleteOrphanedBlobs: true}
+
+	// without history of snapshot GC runs the deletion is not known to be safe, regardless of its age.
+	st, err := RunWithOptions(ctx, env.RepositoryWriter, true, maintenance.SafetyFull, opt)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, st.OrphanedBlobCount)
+	require.Zero(t, st.DeletedOrphanedBlobCount)
+
+	// two successful snapshot GC runs after the deletion, far enough apart.
+	now := ft.NowFunc()()
+
+	s, err := maintenance.GetSchedule(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+
+	s.ReportRun(maintenance.TaskSnapshotGarbageCollection, maintenance.RunInfo{Start: now.Add(-20 * time.Hour), End: now.Add(-19 * time.Hour), Success: true})
+	s.ReportRun(maintenance.TaskSnapshotGarbageCollection, maintenance.RunInfo{Start: now.Add(-2 * time.Hour), End: now.Add(-1 * time.Hour), Success: true})
+	require.NoError(t, maintenance.SetSchedule(ctx, env.RepositoryWriter, s))
+
+	st, err = RunWithOptions(ctx, env.RepositoryWriter, true, maintenance.SafetyFull, opt)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, st.OrphanedBlobCount)
+	require.EqualValues(t, 1, st.DeletedOrphanedBlobCount)
+}
+
+func TestDeleteLimitSkipsAlreadyDeletedContents(t *testing.T) {
+	// deletion must be newer than the content it deletes.
+	ft := faketime.NewTimeAdvance(time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC), time.Second)
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant, repotesting.Options{
+		OpenOptions: func(o *repo.Options) {
+			o.TimeNowFunc = ft.NowFunc()
+		},
+	})
+
+	var cids []content.ID
+
+	for i := 0; i < 5; i++ {
+		cid, err := env.RepositoryWriter.ContentManager().WriteContent(ctx, gather.FromSlice([]byte(fmt.Sprintf("garbage-%v", i))), "", content.NoCompression)
+		require.NoError(t, err)
+
+		cids = append(cids, cid)
+	}
+
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	countDeleted := func() int {
+		n := 0
+
+		for _, cid := range cids {
+			ci, err := env.RepositoryWriter.ContentInfo(ctx, cid)
+			require.NoError(t, err)
+
+			if ci.GetDeleted() {
+				n++
+			}
+		}
+
+		return n
+	}
+
+	opt := Options{MaxDeleteCount: 2}
+
+	st, err := RunWithOptions(ctx, env.RepositoryWriter, true, maintenance.SafetyNone, opt)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, st.RemainingCount)
+	require.Equal(t, 2, countDeleted())
+
+	// contents deleted by the first run don't use up the budget of the second one.
+	st, err = RunWithOptions(ctx, env.RepositoryWriter, true, maintenance.SafetyNone, opt)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, st.RemainingCount)
+	require.Equal(t, 4, countDeleted())
+}
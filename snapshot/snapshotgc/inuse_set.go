@@ -0,0 +1,603 @@
+package snapshotgc
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"hash/maphash"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/content"
+)
+
+const (
+	// DefaultInUseSetMaxBufferedIDs is the default number of content IDs buffered in memory
+	// before they are spilled to disk.
+	DefaultInUseSetMaxBufferedIDs = 1000000
+
+	// number of records in each block of the merged in-use set file, each block is referenced
+	// from in-memory sparse index.
+	inUseSetBlockSize = 256
+
+	// number of hash functions used by the bloom filter, which is optimal for ~10 bits per element
+	// and results in ~1% false positive rate.
+	bloomFilterHashCount = 7
+)
+
+// contentIDSet is a set of content IDs that is populated during the mark phase of GC and
+// queried during the sweep phase.
+type contentIDSet interface {
+	// Add adds the provided content ID to the set, safe for concurrent use.
+	Add(cid content.ID) error
+
+	// Seal is invoked once after all content IDs have been added and before Contains is called.
+	Seal() error
+
+	// Contains determines whether the set contains the provided content ID.
+	Contains(cid content.ID) (bool, error)
+
+	// Exact returns true if Contains never returns false positives.
+	Exact() bool
+
+	// Close releases all resources associated with the set.
+	Close() error
+}
+
+// newContentIDSet returns contentIDSet implementation for the provided options.
+func newContentIDSet(opt Options) (contentIDSet, error) {
+	switch {
+	case opt.BloomFilterOnly:
+		if opt.BloomFilterBytes <= 0 {
+			return nil, errors.Errorf("bloom filter size must be specified")
+		}
+
+		return &bloomContentIDSet{newBloomFilter(opt.BloomFilterBytes)}, nil
+
+	case opt.InUseSetDirectory != "":
+		return newDiskContentIDSet(opt)
+
+	default:
+		return &memoryContentIDSet{}, nil
+	}
+}
+
+// memoryContentIDSet keeps all content IDs in memory.
+type memoryContentIDSet struct {
+	m sync.Map
+}
+
+func (s *memoryContentIDSet) Add(cid content.ID) error {
+	s.m.Store(cid, nil)
+	return nil
+}
+
+func (s *memoryContentIDSet) Seal() error {
+	return nil
+}
+
+func (s *memoryContentIDSet) Contains(cid content.ID) (bool, error) {
+	_, ok := s.m.Load(cid)
+	return ok, nil
+}
+
+func (s *memoryContentIDSet) Exact() bool {
+	return true
+}
+
+func (s *memoryContentIDSet) Close() error {
+	return nil
+}
+
+// bloomContentIDSet only keeps a bloom filter of content IDs, so Contains() may return false positives.
+type bloomContentIDSet struct {
+	bf *bloomFilter
+}
+
+func (s *bloomContentIDSet) Add(cid content.ID) error {
+	s.bf.add(cid)
+	return nil
+}
+
+func (s *bloomContentIDSet) Seal() error {
+	return nil
+}
+
+func (s *bloomContentIDSet) Contains(cid content.ID) (bool, error) {
+	return s.bf.mayContain(cid), nil
+}
+
+func (s *bloomContentIDSet) Exact() bool {
+	return false
+}
+
+func (s *bloomContentIDSet) Close() error {
+	return nil
+}
+
+// candidateContentIDSet is an exact set which only records the content IDs from a fixed set of candidates,
+// which is used to confirm matches of an inexact set without keeping all in-use content IDs in memory.
+type candidateContentIDSet struct {
+	candidates map[content.ID]int64 // read-only
+	found      sync.Map
+}
+
+func (s *candidateContentIDSet) Add(cid content.ID) error {
+	if _, ok := s.candidates[cid]; ok {
+		s.found.Store(cid, nil)
+	}
+
+	return nil
+}
+
+func (s *candidateContentIDSet) Seal() error {
+	return nil
+}
+
+func (s *candidateContentIDSet) Contains(cid content.ID) (bool, error) {
+	_, ok := s.found.Load(cid)
+	return ok, nil
+}
+
+func (s *candidateContentIDSet) Exact() bool {
+	return true
+}
+
+func (s *candidateContentIDSet) Close() error {
+	return nil
+}
+
+// bloomFilter is a fixed-size bloom filter of content IDs safe for concurrent use.
+type bloomFilter struct {
+	seed1, seed2 maphash.Seed
+	bits         []uint64
+}
+
+func newBloomFilter(sizeBytes int) *bloomFilter {
+	const bytesPerWord = 8
+
+	numWords := (sizeBytes + bytesPerWord - 1) / bytesPerWord
+
+	return &bloomFilter{
+		seed1: maphash.MakeSeed(),
+		seed2: maphash.MakeSeed(),
+		bits:  make([]uint64, numWords),
+	}
+}
+
+func (f *bloomFilter) hashes(cid content.ID) (h1, h2 uint64) {
+	var h maphash.Hash
+
+	h.SetSeed(f.seed1)
+	h.WriteString(string(cid)) // nolint:errcheck
+	h1 = h.Sum64()
+
+	h.SetSeed(f.seed2)
+	h.WriteString(string(cid)) // nolint:errcheck
+	h2 = h.Sum64()
+
+	return h1, h2
+}
+
+func (f *bloomFilter) bitPositions(cid content.ID) [bloomFilterHashCount]uint64 {
+	var result [bloomFilterHashCount]uint64
+
+	h1, h2 := f.hashes(cid)
+	numBits := uint64(len(f.bits)) * 64 // nolint:gomnd
+
+	for i := range result {
+		result[i] = (h1 + uint64(i)*h2) % numBits
+	}
+
+	return result
+}
+
+func (f *bloomFilter) add(cid content.ID) {
+	for _, b := range f.bitPositions(cid) {
+		word := &f.bits[b/64]
+		mask := uint64(1) << (b % 64)
+
+		for {
+			old := atomic.LoadUint64(word)
+			if old&mask != 0 || atomic.CompareAndSwapUint64(word, old, old|mask) {
+				break
+			}
+		}
+	}
+}
+
+func (f *bloomFilter) mayContain(cid content.ID) bool {
+	for _, b := range f.bitPositions(cid) {
+		if atomic.LoadUint64(&f.bits[b/64])&(uint64(1)<<(b%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+type sparseIndexEntry struct {
+	firstID content.ID
+	offset  int64
+}
+
+// diskContentIDSet keeps a bounded number of content IDs in memory and spills the remaining ones
+// to sorted run files on disk, which are merged into a single sorted file when the set is sealed.
+// Lookups use an in-memory sparse index to read a single block of the merged file.
+type diskContentIDSet struct {
+	dir         string
+	maxBuffered int
+	bloom       *bloomFilter // optional
+
+	mu sync.Mutex
+	// +checklocks:mu
+	buffered []content.ID
+	// +checklocks:mu
+	runFiles []string
+	// +checklocks:mu
+	merged *os.File
+	// +checklocks:mu
+	mergedSize int64
+	// +checklocks:mu
+	index []sparseIndexEntry
+	// +checklocks:mu
+	cachedBlock int
+	// +checklocks:mu
+	cachedBlockIDs []content.ID
+}
+
+func newDiskContentIDSet(opt Options) (*diskContentIDSet, error) {
+	dir, err := os.MkdirTemp(opt.InUseSetDirectory, "kopia-gc-")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create in-use set directory")
+	}
+
+	s := &diskContentIDSet{
+		dir:         dir,
+		maxBuffered: opt.InUseSetMaxBufferedIDs,
+		cachedBlock: -1,
+	}
+
+	if s.maxBuffered <= 0 {
+		s.maxBuffered = DefaultInUseSetMaxBufferedIDs
+	}
+
+	if opt.BloomFilterBytes > 0 {
+		s.bloom = newBloomFilter(opt.BloomFilterBytes)
+	}
+
+	return s, nil
+}
+
+func (s *diskContentIDSet) Add(cid content.ID) error {
+	if s.bloom != nil {
+		s.bloom.add(cid)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buffered = append(s.buffered, cid)
+
+	if len(s.buffered) >= s.maxBuffered {
+		return s.spillLocked()
+	}
+
+	return nil
+}
+
+// +checklocks:s.mu
+func (s *diskContentIDSet) spillLocked() error {
+	sort.Slice(s.buffered, func(i, j int) bool {
+		return s.buffered[i] < s.buffered[j]
+	})
+
+	f, err := os.CreateTemp(s.dir, "run-")
+	if err != nil {
+		return errors.Wrap(err, "unable to create run file")
+	}
+
+	s.runFiles = append(s.runFiles, f.Name())
+
+	bw := bufio.NewWriter(f)
+
+	var last content.ID
+
+	for i, cid := range s.buffered {
+		if i > 0 && cid == last {
+			continue
+		}
+
+		if _, err := writeIDRecord(bw, cid); err != nil {
+			f.Close() //nolint:errcheck,gosec
+			return err
+		}
+
+		last = cid
+	}
+
+	if err := bw.Flush(); err != nil {
+		f.Close() //nolint:errcheck,gosec
+		return errors.Wrap(err, "error flushing run file")
+	}
+
+	s.buffered = s.buffered[:0]
+
+	return errors.Wrap(f.Close(), "error closing run file")
+}
+
+func (s *diskContentIDSet) Seal() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.spillLocked(); err != nil {
+		return err
+	}
+
+	s.buffered = nil
+
+	return s.mergeRunsLocked()
+}
+
+// +checklocks:s.mu
+func (s *diskContentIDSet) mergeRunsLocked() error {
+	var (
+		h       runHeap
+		readers []*runReader
+	)
+
+	// readers are closed as soon as they are exhausted, so that run files can be removed below,
+	// this only closes the remaining ones on error.
+	defer func() {
+		for _, r := range readers {
+			r.close()
+		}
+	}()
+
+	for _, fn := range s.runFiles {
+		f, err := os.Open(fn) //nolint:gosec
+		if err != nil {
+			return errors.Wrap(err, "unable to open run file")
+		}
+
+		r := &runReader{f: f, r: bufio.NewReader(f)}
+		readers = append(readers, r)
+
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			h = append(h, r)
+		} else {
+			r.close()
+		}
+	}
+
+	heap.Init(&h)
+
+	mf, err := os.CreateTemp(s.dir, "merged-")
+	if err != nil {
+		return errors.Wrap(err, "unable to create merged file")
+	}
+
+	s.merged = mf
+
+	bw := bufio.NewWriter(mf)
+
+	var (
+		offset int64
+		count  int
+		last   content.ID
+	)
+
+	for h.Len() > 0 {
+		r := h[0]
+		cid := r.current
+
+		if count == 0 || cid != last {
+			if count%inUseSetBlockSize == 0 {
+				s.index = append(s.index, sparseIndexEntry{cid, offset})
+			}
+
+			n, err := writeIDRecord(bw, cid)
+			if err != nil {
+				return err
+			}
+
+			offset += int64(n)
+			count++
+			last = cid
+		}
+
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+			r.close()
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return errors.Wrap(err, "error flushing merged file")
+	}
+
+	s.mergedSize = offset
+
+	for _, fn := range s.runFiles {
+		if err := os.Remove(fn); err != nil {
+			return errors.Wrap(err, "unable to remove run file")
+		}
+	}
+
+	s.runFiles = nil
+
+	return nil
+}
+
+func (s *diskContentIDSet) Contains(cid content.ID) (bool, error) {
+	if s.bloom != nil && !s.bloom.mayContain(cid) {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.merged == nil {
+		return false, errors.Errorf("in-use set not sealed")
+	}
+
+	// find the last block whose first ID is <= cid
+	blk := sort.Search(len(s.index), func(i int) bool {
+		return s.index[i].firstID > cid
+	}) - 1
+	if blk < 0 {
+		return false, nil
+	}
+
+	ids, err := s.readBlockLocked(blk)
+	if err != nil {
+		return false, err
+	}
+
+	i := sort.Search(len(ids), func(i int) bool {
+		return ids[i] >= cid
+	})
+
+	return i < len(ids) && ids[i] == cid, nil
+}
+
+// +checklocks:s.mu
+func (s *diskContentIDSet) readBlockLocked(blk int) ([]content.ID, error) {
+	if blk == s.cachedBlock {
+		return s.cachedBlockIDs, nil
+	}
+
+	start := s.index[blk].offset
+	end := s.mergedSize
+
+	if blk+1 < len(s.index) {
+		end = s.index[blk+1].offset
+	}
+
+	buf := make([]byte, end-start)
+	if _, err := s.merged.ReadAt(buf, start); err != nil {
+		return nil, errors.Wrap(err, "error reading in-use set block")
+	}
+
+	ids := make([]content.ID, 0, inUseSetBlockSize)
+
+	for len(buf) > 0 {
+		l, n := binary.Uvarint(buf)
+		if n <= 0 || uint64(len(buf)-n) < l {
+			return nil, errors.Errorf("corrupted in-use set block %v", blk)
+		}
+
+		ids = append(ids, content.ID(buf[n:n+int(l)]))
+		buf = buf[n+int(l):]
+	}
+
+	s.cachedBlock = blk
+	s.cachedBlockIDs = ids
+
+	return ids, nil
+}
+
+// Exact implements contentIDSet, the bloom filter is only used to skip lookups of absent content IDs.
+func (s *diskContentIDSet) Exact() bool {
+	return true
+}
+
+func (s *diskContentIDSet) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.merged != nil {
+		s.merged.Close() //nolint:errcheck,gosec
+		s.merged = nil
+	}
+
+	return errors.Wrap(os.RemoveAll(s.dir), "unable to remove in-use set directory")
+}
+
+func writeIDRecord(w io.Writer, cid content.ID) (int, error) {
+	var lenBuf [binary.MaxVarintLen64]byte
+
+	n := binary.PutUvarint(lenBuf[:], uint64(len(cid)))
+
+	if _, err := w.Write(lenBuf[:n]); err != nil {
+		return 0, errors.Wrap(err, "error writing record length")
+	}
+
+	if _, err := io.WriteString(w, string(cid)); err != nil {
+		return 0, errors.Wrap(err, "error writing record")
+	}
+
+	return n + len(cid), nil
+}
+
+// runReader reads sorted content IDs from a run file.
+type runReader struct {
+	f       *os.File
+	r       *bufio.Reader
+	current content.ID
+}
+
+// close closes the underlying run file, it's safe to call multiple times.
+func (r *runReader) close() {
+	if r.f != nil {
+		r.f.Close() //nolint:errcheck,gosec
+		r.f = nil
+	}
+}
+
+func (r *runReader) next() (bool, error) {
+	l, err := binary.ReadUvarint(r.r)
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, errors.Wrap(err, "error reading record length")
+	}
+
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return false, errors.Wrap(err, "error reading record")
+	}
+
+	r.current = content.ID(buf)
+
+	return true, nil
+}
+
+// runHeap is a min-heap of run readers ordered by their current content ID.
+type runHeap []*runReader
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return h[i].current < h[j].current }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *runHeap) Push(x interface{}) {
+	*h = append(*h, x.(*runReader)) //nolint:forcetypeassert
+}
+
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+
+	return x
+}
@@ -0,0 +1,96 @@
+package snapshotgc
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/content"
+)
+
+func TestContentIDSets(t *testing.T) {
+	cases := map[string]Options{
+		"memory":     {},
+		"disk":       {InUseSetDirectory: testutil.TempDirectory(t), InUseSetMaxBufferedIDs: 7},
+		"disk-bloom": {InUseSetDirectory: testutil.TempDirectory(t), InUseSetMaxBufferedIDs: 100, BloomFilterBytes: 1000},
+	}
+
+	for name, opt := range cases {
+		opt := opt
+
+		t.Run(name, func(t *testing.T) {
+			s, err := newContentIDSet(opt)
+			require.NoError(t, err)
+
+			defer s.Close()
+
+			var wg sync.WaitGroup
+
+			// add even content IDs from multiple goroutines, with duplicates.
+			for g := 0; g < 4; g++ {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					for i := 0; i < 1000; i += 2 {
+						if err := s.Add(testContentID(i)); err != nil {
+							t.Errorf("add error: %v", err)
+						}
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			require.NoError(t, s.Seal())
+
+			for i := 0; i < 1000; i++ {
+				ok, err := s.Contains(testContentID(i))
+				require.NoError(t, err)
+				require.Equal(t, i%2 == 0, ok, "invalid result for %v", i)
+			}
+		})
+	}
+}
+
+func TestBloomContentIDSetHasNoFalseNegatives(t *testing.T) {
+	s, err := newContentIDSet(Options{BloomFilterOnly: true, BloomFilterBytes: 1250})
+	require.NoError(t, err)
+
+	defer s.Close()
+
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, s.Add(testContentID(i)))
+	}
+
+	require.NoError(t, s.Seal())
+
+	falsePositives := 0
+
+	for i := 0; i < 2000; i++ {
+		ok, err := s.Contains(testContentID(i))
+		require.NoError(t, err)
+
+		if i < 1000 {
+			require.True(t, ok)
+		} else if ok {
+			falsePositives++
+		}
+	}
+
+	// 10 bits per element should result in ~1% false positives.
+	require.Less(t, falsePositives, 50)
+}
+
+func TestBloomContentIDSetRequiresSize(t *testing.T) {
+	_, err := newContentIDSet(Options{BloomFilterOnly: true})
+	require.Error(t, err)
+}
+
+func testContentID(i int) content.ID {
+	return content.ID(fmt.Sprintf("%032x", i*7919))
+}
@@ -0,0 +1,113 @@
+package snapshotgc
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+// packContentState summarizes index entries pointing at a single pack blob.
+type packContentState struct {
+	hasLiveContents bool
+	lastDeletion    time.Time
+}
+
+// findOrphanedBlobs finds pack blobs whose contents have all been deleted, which would otherwise only be
+// reclaimed after deleted contents are dropped from indexes. Index blobs, format blobs and other special
+// blobs are never considered, neither are pack blobs not referenced by the index at all, which may belong
+// to sessions in progress and are handled by blob GC.
+//
+// Orphaned blobs are deleted when requested, but only if their contents have been deleted before the safe
+// drop time determined from the history of snapshot GC runs, after which a concurrent snapshot can no longer
+// undelete them. This is the same condition used when dropping deleted contents from indexes.
+func findOrphanedBlobs(ctx context.Context, rep repo.DirectRepositoryWriter, deleteBlobs bool, safety maintenance.SafetyParameters, st *Stats) error {
+	log(ctx).Infof("Looking for orphaned pack blobs...")
+
+	safeDropTime, err := maintenance.SafeDropTime(ctx, rep, safety)
+	if err != nil {
+		return errors.Wrap(err, "unable to determine safe drop time")
+	}
+
+	packs := map[blob.ID]*packContentState{}
+
+	if err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{IncludeDeleted: true}, func(ci content.Info) error {
+		ps := packs[ci.GetPackBlobID()]
+		if ps == nil {
+			ps = &packContentState{}
+			packs[ci.GetPackBlobID()] = ps
+		}
+
+		if !ci.GetDeleted() {
+			ps.hasLiveContents = true
+		} else if ci.Timestamp().After(ps.lastDeletion) {
+			// timestamp of a deleted content is the time of deletion.
+			ps.lastDeletion = ci.Timestamp()
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "error iterating contents")
+	}
+
+	var orphaned, preserved []blob.Metadata
+
+	for _, prefix := range content.PackBlobIDPrefixes {
+		if err := rep.BlobStorage().ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+			ps := packs[bm.BlobID]
+			if ps == nil || ps.hasLiveContents {
+				return nil
+			}
+
+			if !ps.lastDeletion.Before(safeDropTime) || rep.Time().Sub(bm.Timestamp) < safety.BlobDeleteMinAge {
+				log(ctx).Debugf("  preserving orphaned blob %v because it's too recent", bm.BlobID)
+
+				preserved = append(preserved, bm)
+
+				return nil
+			}
+
+			orphaned = append(orphaned, bm)
+
+			return nil
+		}); err != nil {
+			return errors.Wrap(err, "error listing pack blobs")
+		}
+	}
+
+	for _, bm := range append(orphaned, preserved...) {
+		st.OrphanedBlobCount++
+		st.OrphanedBlobBytes += bm.Length
+	}
+
+	log(ctx).Infof("Found %v orphaned pack blobs (%v), %v of them too recent to delete.",
+		st.OrphanedBlobCount, units.BytesStringBase10(st.OrphanedBlobBytes), len(preserved))
+
+	if !deleteBlobs {
+		return nil
+	}
+
+	if safeDropTime.IsZero() {
+		log(ctx).Infof("Not enough time has passed since previous successful Snapshot GC, not deleting orphaned pack blobs.")
+	}
+
+	for _, bm := range orphaned {
+		log(ctx).Debugf("  deleting orphaned blob %v (%v bytes)", bm.BlobID, bm.Length)
+
+		if err := rep.BlobStorage().DeleteBlob(ctx, bm.BlobID); err != nil {
+			return errors.Wrapf(err, "unable to delete orphaned blob %v", bm.BlobID)
+		}
+
+		st.DeletedOrphanedBlobCount++
+	}
+
+	log(ctx).Infof("Deleted %v orphaned pack blobs.", st.DeletedOrphanedBlobCount)
+
+	return nil
+}
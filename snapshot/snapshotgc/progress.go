@@ -0,0 +1,49 @@
+package snapshotgc
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// Phase identifies the phase of snapshot garbage collection.
+type Phase string
+
+// Phases of snapshot garbage collection.
+const (
+	// PhaseMark walks snapshots to find in-use contents, progress is measured in snapshots.
+	PhaseMark Phase = "mark"
+
+	// PhaseSweep scans all contents to find unused ones, progress is measured in contents.
+	PhaseSweep Phase = "sweep"
+)
+
+// sweepProgressInterval is the number of contents scanned between progress reports.
+const sweepProgressInterval = 1000
+
+// ProgressFunc is invoked to report the number of completed and total work items in the provided phase.
+// It may be called concurrently.
+type ProgressFunc func(phase Phase, completed, total int64)
+
+func (o *Options) reportProgress(phase Phase, completed, total int64) {
+	if o.Progress != nil {
+		o.Progress(phase, completed, total)
+	}
+}
+
+// countContents returns the number of contents scanned by the sweep phase.
+func countContents(ctx context.Context, rep repo.DirectRepository) (int64, error) {
+	var cnt int64
+
+	if err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{IncludeDeleted: true}, func(ci content.Info) error {
+		cnt++
+		return nil
+	}); err != nil {
+		return 0, errors.Wrap(err, "error counting contents")
+	}
+
+	return cnt, nil
+}
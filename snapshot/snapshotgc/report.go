@@ -0,0 +1,61 @@
+package snapshotgc
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/content"
+)
+
+// ContentStatus classifies contents not referenced by any snapshot.
+type ContentStatus string
+
+// Classification of unreferenced contents.
+const (
+	// ContentStatusUnused indicates content that is old enough to be deleted.
+	ContentStatusUnused ContentStatus = "unused"
+
+	// ContentStatusTooRecent indicates unused content that is not deleted because it is newer than
+	// MinContentAgeSubjectToGC.
+	ContentStatusTooRecent ContentStatus = "too-recent"
+
+	// ContentStatusSystem indicates system content (such as manifests), which is never deleted.
+	ContentStatusSystem ContentStatus = "system"
+)
+
+// ReportEntry describes a single content written to the report, one JSON object per line.
+type ReportEntry struct {
+	ContentID    content.ID    `json:"contentID"`
+	PackedLength uint64        `json:"packedLength"`
+	Timestamp    time.Time     `json:"timestamp"`
+	Status       ContentStatus `json:"status"`
+}
+
+// reportWriter writes report entries, it is a no-op when the writer is nil.
+type reportWriter struct {
+	enc *json.Encoder
+}
+
+func newReportWriter(w io.Writer) *reportWriter {
+	if w == nil {
+		return &reportWriter{}
+	}
+
+	return &reportWriter{enc: json.NewEncoder(w)}
+}
+
+func (r *reportWriter) write(ci content.Info, status ContentStatus) error {
+	if r.enc == nil {
+		return nil
+	}
+
+	return errors.Wrap(r.enc.Encode(&ReportEntry{
+		ContentID:    ci.GetContentID(),
+		PackedLength: ci.GetPackedLength(),
+		Timestamp:    ci.Timestamp(),
+		Status:       status,
+	}), "error writing report")
+}
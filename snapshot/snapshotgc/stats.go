@@ -1,10 +1,19 @@
 package snapshotgc
 
+import "github.com/kopia/kopia/repo/content"
+
 // Stats contains statistics about a GC run.
 type Stats struct {
 	// Keep int64 fields first to ensure they get aligned to at least 64-bit
 	// boundaries which is required for atomic access on ARM and x86-32.
 	// Also results in a smaller struct size
-	UnusedBytes, InUseBytes, SystemBytes, TooRecentBytes, UndeletedBytes int64
-	UnusedCount, InUseCount, SystemCount, TooRecentCount, UndeletedCount uint32
+	UnusedBytes, InUseBytes, SystemBytes, TooRecentBytes, UndeletedBytes, OrphanedBlobBytes int64
+	UnusedCount, InUseCount, SystemCount, TooRecentCount, UndeletedCount, OrphanedBlobCount uint32
+	DeletedOrphanedBlobCount                                                                uint32
+
+	// RemainingBytes and RemainingCount describe unused contents (included in UnusedBytes and UnusedCount)
+	// that were not deleted because of the delete limit, starting at DeleteLimitStoppedAt.
+	RemainingBytes       int64
+	RemainingCount       uint32
+	DeleteLimitStoppedAt content.ID
 }
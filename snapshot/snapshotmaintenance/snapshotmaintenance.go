@@ -13,12 +13,17 @@
 
 // Run runs the complete snapshot and repository maintenance.
 func Run(ctx context.Context, dr repo.DirectRepositoryWriter, mode maintenance.Mode, force bool, safety maintenance.SafetyParameters) error {
+	return RunWithOptions(ctx, dr, mode, force, safety, snapshotgc.Options{})
+}
+
+// RunWithOptions runs the complete snapshot and repository maintenance using the provided snapshot GC options.
+func RunWithOptions(ctx context.Context, dr repo.DirectRepositoryWriter, mode maintenance.Mode, force bool, safety maintenance.SafetyParameters, gcOpts snapshotgc.Options) error {
 	// nolint:wrapcheck
 	return maintenance.RunExclusive(ctx, dr, mode, force,
 		func(ctx context.Context, runParams maintenance.RunParameters) error {
 			// run snapshot GC before full maintenance
 			if runParams.Mode == maintenance.ModeFull {
-				if _, err := snapshotgc.Run(ctx, dr, true, safety); err != nil {
+				if _, err := snapshotgc.RunWithOptions(ctx, dr, true, safety, gcOpts); err != nil {
 					return errors.Wrap(err, "snapshot GC failure")
 				}
 			}
@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,6 +19,7 @@
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotgc"
 	"github.com/kopia/kopia/snapshot/snapshotmaintenance"
 )
 
@@ -77,6 +79,45 @@ func (s *formatSpecificTestSuite) TestSnapshotGCSimple(t *testing.T) {
 	t.Log("root info:", pretty.Sprint(info))
 }
 
+func (s *formatSpecificTestSuite) TestSnapshotGCProgress(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newTestHarness(t, s.formatVersion)
+
+	th.sourceDir.AddFile("f1", []byte{1, 2, 3, 4}, defaultPermissions)
+
+	for _, p := range []string{"/foo", "/bar", "/baz"} {
+		mustSnapshot(t, th.RepositoryWriter, th.sourceDir, snapshot.SourceInfo{Host: "host", UserName: "user", Path: p})
+	}
+
+	mustFlush(t, th.RepositoryWriter)
+
+	var (
+		mu       sync.Mutex
+		progress = map[snapshotgc.Phase][][2]int64{}
+	)
+
+	require.NoError(t, snapshotmaintenance.RunWithOptions(ctx, th.RepositoryWriter, maintenance.ModeFull, true, maintenance.SafetyFull, snapshotgc.Options{
+		SnapshotParallelism: 2,
+		Progress: func(phase snapshotgc.Phase, completed, total int64) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			progress[phase] = append(progress[phase], [2]int64{completed, total})
+		},
+	}))
+
+	// snapshots are walked concurrently, so progress may be reported out of order.
+	require.ElementsMatch(t, [][2]int64{{0, 3}, {1, 3}, {2, 3}, {3, 3}}, progress[snapshotgc.PhaseMark])
+
+	sweep := progress[snapshotgc.PhaseSweep]
+	require.NotEmpty(t, sweep)
+	require.Equal(t, int64(0), sweep[0][0])
+
+	last := sweep[len(sweep)-1]
+	require.Positive(t, last[1])
+	require.Equal(t, last[1], last[0])
+}
+
 // Test maintenance when a directory is deleted and then reused.
 // Scenario / events:
 // - create snapshot s1 on a directory d is created
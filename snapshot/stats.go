@@ -14,6 +14,17 @@ type Stats struct {
 	// +checkatomic
 	ExcludedTotalFileSize int64 `json:"excludedTotalSize"`
 
+	// packed size of contents newly written to the repository by uploaded files, which excludes
+	// deduplicated contents and cached files. Contents counted in UnknownContentBytes are not included,
+	// so when connected to a repository server this is a lower bound.
+	// +checkatomic
+	NewContentBytes int64 `json:"newContentBytes,omitempty"`
+
+	// packed size of contents written by uploaded files, for which the repository could not determine
+	// whether they were new or deduplicated, which is the case when connected to a repository server.
+	// +checkatomic
+	UnknownContentBytes int64 `json:"unknownContentBytes,omitempty"`
+
 	// keep all int32 aligned because they will be atomically updated
 	// +checkatomic
 	TotalFileCount int32 `json:"fileCount"`
@@ -21,6 +32,8 @@ type Stats struct {
 	CachedFiles int32 `json:"cachedFiles"`
 	// +checkatomic
 	NonCachedFiles int32 `json:"nonCachedFiles"`
+	// +checkatomic
+	CollapsedHardLinks int32 `json:"collapsedHardLinks,omitempty"`
 
 	// +checkatomic
 	TotalDirectoryCount int32 `json:"dirCount"`
@@ -29,6 +42,8 @@ type Stats struct {
 	ExcludedFileCount int32 `json:"excludedFileCount"`
 	// +checkatomic
 	ExcludedDirCount int32 `json:"excludedDirCount"`
+	// +checkatomic
+	SkippedSpecialCount int32 `json:"skippedSpecialCount,omitempty"`
 
 	// +checkatomic
 	IgnoredErrorCount int32 `json:"ignoredErrorCount"`
@@ -1,8 +1,11 @@
 package endtoend_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -11,6 +14,7 @@
 
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/snapshot/snapshotgc"
 	"github.com/kopia/kopia/tests/testenv"
 )
 
@@ -38,6 +42,21 @@ func (s *formatSpecificTestSuite) TestSnapshotGC(t *testing.T) {
 	expectedContentCount += 3
 	e.RunAndVerifyOutputLineCount(t, expectedContentCount, "content", "list")
 
+	// export in-use contents, which are the data block and directory block, in sorted order.
+	exportFile := filepath.Join(testutil.TempDirectory(t), "in-use.txt")
+	e.RunAndExpectSuccess(t, "snapshot", "gc", "--export-in-use", exportFile)
+
+	exportData, err := os.ReadFile(exportFile)
+	require.NoError(t, err)
+
+	inUse := strings.Split(strings.TrimSpace(string(exportData)), "\n")
+	require.Len(t, inUse, 2)
+	require.True(t, sort.StringsAreSorted(inUse))
+
+	for _, cid := range inUse {
+		e.RunAndExpectSuccess(t, "content", "show", cid)
+	}
+
 	// now delete all manifests, making the content unreachable
 	for _, line := range e.RunAndExpectSuccess(t, "snap", "list", "-m") {
 		p := strings.Index(line, "manifest:")
@@ -62,6 +81,28 @@ func (s *formatSpecificTestSuite) TestSnapshotGC(t *testing.T) {
 	// makes contents subject to GC immediately but we're not specifying --delete flag.
 	e.RunAndExpectFailure(t, "snapshot", "gc", "--safety=none")
 
+	// dry run with report succeeds and lists unreferenced contents.
+	reportFile := filepath.Join(testutil.TempDirectory(t), "gc-report.json")
+	e.RunAndExpectSuccess(t, "snapshot", "gc", "--safety=none", "--report", reportFile)
+
+	reportData, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+
+	reportedByStatus := map[snapshotgc.ContentStatus]int{}
+
+	for dec := json.NewDecoder(bytes.NewReader(reportData)); dec.More(); {
+		var re snapshotgc.ReportEntry
+
+		require.NoError(t, dec.Decode(&re))
+		require.NotEmpty(t, re.ContentID)
+
+		reportedByStatus[re.Status]++
+	}
+
+	// data block + directory block
+	require.Equal(t, 2, reportedByStatus[snapshotgc.ContentStatusUnused])
+	require.Positive(t, reportedByStatus[snapshotgc.ContentStatusSystem])
+
 	// data block + directory block + manifest block + manifest block from manifest deletion
 	var contentInfo []content.InfoStruct
 
@@ -80,10 +121,66 @@ func (s *formatSpecificTestSuite) TestSnapshotGC(t *testing.T) {
 	// make sure we are not too quick
 	time.Sleep(2 * time.Second)
 
-	// garbage-collect for real, this time without age limit
+	// garbage-collect with delete limit, only one of two unused contents is deleted.
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, "snapshot", "gc", "--delete", "--safety=none", "--max-delete-count=1")
+	require.Contains(t, strings.Join(stderr, "\n"), "1 unused contents (")
+	require.Contains(t, strings.Join(stderr, "\n"), "remain to be deleted by subsequent runs")
+
+	expectedContentCount--
+	e.RunAndVerifyOutputLineCount(t, expectedContentCount, "content", "list")
+
+	// garbage-collect for real, this time without age or delete limit
 	e.RunAndExpectSuccess(t, "snapshot", "gc", "--delete", "--safety=none")
 
-	// two contents are deleted
+	// remaining content is deleted
+	expectedContentCount--
+	e.RunAndVerifyOutputLineCount(t, expectedContentCount, "content", "list")
+
+	// pack blob holding the deleted data content is orphaned, but only deleted when requested.
+	packBlobCount := len(e.RunAndExpectSuccess(t, "blob", "list", "--prefix=p"))
+	e.RunAndExpectSuccess(t, "snapshot", "gc", "--delete", "--safety=none", "--find-orphaned-blobs")
+	e.RunAndVerifyOutputLineCount(t, packBlobCount, "blob", "list", "--prefix=p")
+
+	e.RunAndExpectSuccess(t, "snapshot", "gc", "--delete", "--safety=none", "--delete-orphaned-blobs")
+	e.RunAndVerifyOutputLineCount(t, packBlobCount-1, "blob", "list", "--prefix=p")
+	e.RunAndExpectSuccess(t, "content", "verify")
+}
+
+func (s *formatSpecificTestSuite) TestSnapshotGCParallel(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, s.formatFlags, runner)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	var dataDirs []string
+
+	for i := 0; i < 5; i++ {
+		dataDir := testutil.TempDirectory(t)
+		require.NoError(t, os.WriteFile(filepath.Join(dataDir, "some-file"), []byte(dataDir), 0o600))
+		e.RunAndExpectSuccess(t, "snap", "create", dataDir)
+
+		dataDirs = append(dataDirs, dataDir)
+	}
+
+	// delete snapshots of the first directory, making its data and directory contents unreachable.
+	for _, line := range e.RunAndExpectSuccess(t, "snap", "list", "-m", dataDirs[0]) {
+		p := strings.Index(line, "manifest:")
+		if p >= 0 {
+			e.RunAndExpectSuccess(t, "manifest", "rm", strings.TrimPrefix(strings.Split(line[p:], " ")[0], "manifest:"))
+		}
+	}
+
+	expectedContentCount := len(e.RunAndExpectSuccess(t, "content", "list"))
+
+	// make sure we are not too quick
+	time.Sleep(2 * time.Second)
+
+	e.RunAndExpectSuccess(t, "snapshot", "gc", "--delete", "--safety=none", "--snapshot-parallel=3")
+
+	// contents of remaining snapshots are all found by concurrent walkers.
 	expectedContentCount -= 2
 	e.RunAndVerifyOutputLineCount(t, expectedContentCount, "content", "list")
+	e.RunAndExpectSuccess(t, "snapshot", "verify")
 }